@@ -0,0 +1,220 @@
+// Package admin provides an HTTP handler that exposes a service's configuration to operators. It serves the following
+// endpoints:
+//
+//	GET  /config         the current configuration, with fields tagged `secret:"true"` redacted
+//	GET  /config/sources the configured sources, in the order they are loaded, and the outcome of the last load
+//	POST /config/reload  loads the configuration again and, if it succeeds, makes it the current configuration
+//	GET  /config/diff    loads the configuration without applying it and reports how it differs from the current one
+//	GET  /config/docs    an HTML page documenting every setting with its current value, default and source
+//
+// The handler is meant to be mounted on an admin mux that isn't exposed publicly:
+//
+//	h, err := admin.New(&defaultConfig, qcl.UseEnv(), qcl.UseFlags())
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	adminMux := http.NewServeMux()
+//	adminMux.Handle("/config", h)
+//	adminMux.Handle("/config/", h)
+//	go http.ListenAndServe("localhost:9090", adminMux)
+//
+// The application reads the current configuration with h.Config(), which is always a complete, successfully loaded
+// configuration.
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/thezmc/qcl"
+)
+
+// Handler serves the admin endpoints for a configuration of type T. It is safe for concurrent use: the configuration
+// can be read while it is being reloaded, and reloads triggered concurrently, e.g. from an HTTP request and a SIGHUP
+// handler, are serialized.
+type Handler[T any] struct {
+	defaults *T
+	opts     []qcl.LoadOption
+	sources  []string
+	mux      *http.ServeMux
+
+	reloadMu   sync.Mutex   // reloadMu serializes reloads.
+	mu         sync.RWMutex // mu guards the fields below.
+	current    *T
+	provenance qcl.Provenance
+	loadedAt   time.Time
+	lastErr    error
+	onChange   []func(old, new *T)
+}
+
+// New loads the configuration with qcl.Load and returns a Handler serving it. The default config and options are kept
+// and used again for every reload, so each reload starts from the same defaults. If the initial load fails, New
+// returns the error.
+func New[T any](defaultConfig *T, opts ...qcl.LoadOption) (*Handler[T], error) {
+	if defaultConfig == nil {
+		defaultConfig = new(T)
+	}
+	h := &Handler[T]{
+		defaults: qcl.Clone(defaultConfig),
+		opts:     opts,
+		sources:  sources(opts...),
+	}
+	if _, err := h.Reload(); err != nil {
+		return nil, err
+	}
+
+	h.mux = http.NewServeMux()
+	h.mux.HandleFunc("/config", h.handleConfig)
+	h.mux.HandleFunc("/config/sources", h.handleSources)
+	h.mux.HandleFunc("/config/reload", h.handleReload)
+	h.mux.HandleFunc("/config/diff", h.handleDiff)
+	h.mux.HandleFunc("/config/docs", h.handleDocs)
+	return h, nil
+}
+
+// Config returns the current configuration. The returned config must not be modified, since it is shared with every
+// other caller.
+func (h *Handler[T]) Config() *T {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.current
+}
+
+// Reload loads the configuration again and, if it succeeds, makes it the current configuration. It returns the
+// changes between the previous configuration and the new one. If the load fails, the current configuration is kept
+// and the error is returned.
+func (h *Handler[T]) Reload() ([]qcl.Change, error) {
+	h.reloadMu.Lock()
+	defer h.reloadMu.Unlock()
+
+	next, provenance, err := h.load()
+
+	h.mu.Lock()
+	h.lastErr = err
+	if err != nil {
+		h.mu.Unlock()
+		return nil, err
+	}
+	old := h.current
+	changes := qcl.Diff(old, next)
+	h.current, h.provenance, h.loadedAt = next, provenance, time.Now()
+	onChange := h.onChange
+	h.mu.Unlock()
+
+	// callbacks run without holding mu so they can call Config, but still under reloadMu so they see reloads in order.
+	if old != nil && len(changes) > 0 {
+		for _, fn := range onChange {
+			fn(old, next)
+		}
+	}
+	return changes, nil
+}
+
+// OnChange registers a function that is called with the previous and the new configuration whenever a reload changes
+// the configuration. Functions are called in the order they were registered, before Reload returns.
+func (h *Handler[T]) OnChange(fn func(old, new *T)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.onChange = append(h.onChange, fn)
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler[T]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler[T]) load() (*T, qcl.Provenance, error) {
+	var provenance qcl.Provenance
+	opts := append(append(make([]qcl.LoadOption, 0, len(h.opts)+1), h.opts...), qcl.WithProvenance(&provenance))
+	config, err := qcl.Load(qcl.Clone(h.defaults), opts...)
+	return config, provenance, err
+}
+
+func (h *Handler[T]) handleConfig(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	writeJSON(w, http.StatusOK, qcl.Redacted(h.Config()))
+}
+
+type sourcesResponse struct {
+	Sources  []string  `json:"sources"`
+	LoadedAt time.Time `json:"loadedAt"`
+	Error    string    `json:"error,omitempty"`
+}
+
+func (h *Handler[T]) handleSources(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	h.mu.RLock()
+	resp := sourcesResponse{Sources: h.sources, LoadedAt: h.loadedAt}
+	if h.lastErr != nil {
+		resp.Error = h.lastErr.Error()
+	}
+	h.mu.RUnlock()
+	writeJSON(w, http.StatusOK, resp)
+}
+
+type changesResponse struct {
+	Changes []qcl.Change `json:"changes"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func (h *Handler[T]) handleReload(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+	changes, err := h.Reload()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, errorResponse{err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, changesResponse{changes})
+}
+
+func (h *Handler[T]) handleDiff(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	next, _, err := h.load()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, errorResponse{err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, changesResponse{qcl.Diff(h.Config(), next)})
+}
+
+// sources returns the names of the sources the options configure, in the order they are loaded.
+func sources(opts ...qcl.LoadOption) []string {
+	lc := &qcl.LoadConfig{Loaders: make(map[string]qcl.Loader)}
+	for _, opt := range opts {
+		opt(lc)
+	}
+	if len(lc.Sources) == 0 {
+		for _, opt := range qcl.DefaultLoadOptions {
+			opt(lc)
+		}
+	}
+	return lc.Sources
+}
+
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	writeJSON(w, http.StatusMethodNotAllowed, errorResponse{http.StatusText(http.StatusMethodNotAllowed)})
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,139 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/thezmc/qcl"
+)
+
+type TestConfig struct {
+	Host     string
+	Password string `secret:"true"`
+}
+
+// useCounter returns a LoadOption whose loader sets Host to a different value on every load, or fails if fail is set.
+func useCounter(fail *bool) qcl.LoadOption {
+	loads := 0
+	return qcl.UseCustom("counter", func(config any) error {
+		if *fail {
+			return errors.New("failed")
+		}
+		loads++
+		config.(*TestConfig).Host = strings.Repeat("a", loads)
+		return nil
+	})
+}
+
+func serve(t *testing.T, h http.Handler, method, path string, v any) int {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+	if err := json.NewDecoder(rec.Body).Decode(v); err != nil {
+		t.Fatalf("%s %s returned invalid JSON: %v", method, path, err)
+	}
+	return rec.Code
+}
+
+func Test_Handler(t *testing.T) {
+	fail := false
+	h, err := New(&TestConfig{Password: "hunter2"}, useCounter(&fail))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if h.Config().Host != "a" {
+		t.Errorf("Config().Host = %v, want %v", h.Config().Host, "a")
+	}
+
+	t.Run("config", func(t *testing.T) {
+		var got map[string]any
+		if code := serve(t, h, http.MethodGet, "/config", &got); code != http.StatusOK {
+			t.Errorf("GET /config status = %v, want %v", code, http.StatusOK)
+		}
+		want := map[string]any{"Host": "a", "Password": qcl.RedactedValue}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("GET /config = %v, want %v", got, want)
+		}
+	})
+	t.Run("sources", func(t *testing.T) {
+		var got sourcesResponse
+		serve(t, h, http.MethodGet, "/config/sources", &got)
+		if !reflect.DeepEqual(got.Sources, []string{"counter"}) || got.LoadedAt.IsZero() || got.Error != "" {
+			t.Errorf("GET /config/sources = %+v", got)
+		}
+	})
+	t.Run("diff", func(t *testing.T) {
+		var got changesResponse
+		serve(t, h, http.MethodGet, "/config/diff", &got)
+		want := []qcl.Change{{Field: "Host", Old: "a", New: "aa"}}
+		if !reflect.DeepEqual(got.Changes, want) {
+			t.Errorf("GET /config/diff = %v, want %v", got.Changes, want)
+		}
+		if h.Config().Host != "a" {
+			t.Errorf("GET /config/diff should not apply the config")
+		}
+	})
+	t.Run("reload", func(t *testing.T) {
+		var changed []string
+		h.OnChange(func(old, new *TestConfig) {
+			changed = append(changed, old.Host+"->"+h.Config().Host)
+		})
+		var got changesResponse
+		if code := serve(t, h, http.MethodPost, "/config/reload", &got); code != http.StatusOK {
+			t.Errorf("POST /config/reload status = %v, want %v", code, http.StatusOK)
+		}
+		want := []qcl.Change{{Field: "Host", Old: "a", New: "aaa"}}
+		if !reflect.DeepEqual(got.Changes, want) {
+			t.Errorf("POST /config/reload = %v, want %v", got.Changes, want)
+		}
+		if h.Config().Host != "aaa" || h.Config().Password != "hunter2" {
+			t.Errorf("Config() = %v after reload", h.Config())
+		}
+		if !reflect.DeepEqual(changed, []string{"a->aaa"}) {
+			t.Errorf("OnChange() called with %v, want %v", changed, []string{"a->aaa"})
+		}
+	})
+	t.Run("failed reload keeps config", func(t *testing.T) {
+		fail = true
+		defer func() { fail = false }()
+		var got errorResponse
+		if code := serve(t, h, http.MethodPost, "/config/reload", &got); code != http.StatusInternalServerError {
+			t.Errorf("POST /config/reload status = %v, want %v", code, http.StatusInternalServerError)
+		}
+		if h.Config().Host != "aaa" {
+			t.Errorf("Config().Host = %v, want %v", h.Config().Host, "aaa")
+		}
+		var sources sourcesResponse
+		serve(t, h, http.MethodGet, "/config/sources", &sources)
+		if sources.Error != "failed" {
+			t.Errorf("GET /config/sources error = %v, want %v", sources.Error, "failed")
+		}
+		var diff errorResponse
+		if code := serve(t, h, http.MethodGet, "/config/diff", &diff); code != http.StatusInternalServerError {
+			t.Errorf("GET /config/diff status = %v, want %v", code, http.StatusInternalServerError)
+		}
+	})
+	t.Run("method not allowed", func(t *testing.T) {
+		var got errorResponse
+		if code := serve(t, h, http.MethodGet, "/config/reload", &got); code != http.StatusMethodNotAllowed {
+			t.Errorf("GET /config/reload status = %v, want %v", code, http.StatusMethodNotAllowed)
+		}
+	})
+	t.Run("failed initial load", func(t *testing.T) {
+		fail := true
+		if _, err := New(&TestConfig{}, useCounter(&fail)); err == nil {
+			t.Errorf("New() error = nil, want error")
+		}
+	})
+}
+
+func Test_sources(t *testing.T) {
+	if got := sources(); !reflect.DeepEqual(got, []string{"env", "flags"}) {
+		t.Errorf("sources() = %v, want %v", got, []string{"env", "flags"})
+	}
+}
@@ -0,0 +1,100 @@
+package admin
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/thezmc/qcl"
+)
+
+// setting is a row of the documentation page.
+type setting struct {
+	qcl.Setting
+	Default any
+	Source  string
+}
+
+type docsPage struct {
+	Sources  []string
+	LoadedAt time.Time
+	Error    string
+	Settings []setting
+}
+
+var docsTemplate = template.Must(template.New("docs").Funcs(template.FuncMap{
+	"value": formatSetting,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Configuration</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border-bottom: 1px solid #ddd; padding: 0.4em 0.8em; text-align: left; vertical-align: top; }
+th { background: #f5f5f5; }
+code { font-family: ui-monospace, monospace; }
+.changed { font-weight: bold; }
+.error { color: #b00020; }
+</style>
+</head>
+<body>
+<h1>Configuration</h1>
+<p>Loaded {{.LoadedAt.Format "2006-01-02 15:04:05 MST"}} from {{range $i, $s := .Sources}}{{if $i}}, {{end}}<code>{{$s}}</code>{{end}}.</p>
+{{if .Error}}<p class="error">The last reload failed: {{.Error}}</p>{{end}}
+<table>
+<thead>
+<tr><th>Setting</th><th>Type</th><th>Value</th><th>Default</th><th>Source</th><th>Description</th></tr>
+</thead>
+<tbody>
+{{range .Settings}}<tr>
+<td><code>{{.Field}}</code></td>
+<td><code>{{.Type}}</code></td>
+<td{{if ne .Source "default"}} class="changed"{{end}}><code>{{value .Value}}</code></td>
+<td><code>{{value .Default}}</code></td>
+<td>{{.Source}}</td>
+<td>{{.Usage}}</td>
+</tr>
+{{end}}</tbody>
+</table>
+</body>
+</html>
+`))
+
+func (h *Handler[T]) handleDocs(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	defaults := make(map[string]any)
+	for _, s := range qcl.Settings(h.defaults) {
+		defaults[s.Field] = s.Value
+	}
+
+	h.mu.RLock()
+	page := docsPage{Sources: h.sources, LoadedAt: h.loadedAt}
+	if h.lastErr != nil {
+		page.Error = h.lastErr.Error()
+	}
+	for _, s := range qcl.Settings(h.current) {
+		page.Settings = append(page.Settings, setting{Setting: s, Default: defaults[s.Field], Source: h.provenance[s.Field].Source})
+	}
+	h.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := docsTemplate.Execute(w, page); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// formatSetting renders a setting's value for the documentation page.
+func formatSetting(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case fmt.Stringer:
+		return v.String()
+	}
+	return fmt.Sprintf("%v", v)
+}
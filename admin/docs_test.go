@@ -0,0 +1,48 @@
+package admin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/thezmc/qcl"
+)
+
+func Test_handleDocs(t *testing.T) {
+	fail := false
+	h, err := New(&TestConfig{Host: "<default>", Password: "hunter2"}, useCounter(&fail))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/config/docs", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /config/docs status = %v, want %v", rec.Code, http.StatusOK)
+	}
+	if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/html") {
+		t.Errorf("GET /config/docs Content-Type = %v, want text/html", got)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		"<code>Host</code>",
+		`<td class="changed"><code>a</code></td>`, // current value, set by the counter source
+		"<code>&lt;default&gt;</code>",            // default value, escaped
+		"<td>counter</td>",
+		"<td>default</td>",
+		qcl.RedactedValue,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("GET /config/docs doesn't contain %q:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "hunter2") {
+		t.Errorf("GET /config/docs contains a secret value:\n%s", body)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/config/docs", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST /config/docs status = %v, want %v", rec.Code, http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,42 @@
+//go:build windows
+
+package admin
+
+// Service control codes used to reload the configuration of a Windows service. They have the same values as
+// svc.ParamChange and svc.AcceptParamChange in golang.org/x/sys/windows/svc, so they can be used with that package
+// without this one depending on it.
+const (
+	// ParamChange is the control code (SERVICE_CONTROL_PARAMCHANGE) the service control manager sends a service when
+	// its parameters have changed, e.g. after `sc control <service> paramchange`.
+	ParamChange = 0x00000006
+	// AcceptParamChange (SERVICE_ACCEPT_PARAMCHANGE) must be included in the service's accepted controls for the
+	// service control manager to send it ParamChange.
+	AcceptParamChange = 0x00000008
+)
+
+// HandleServiceControl reloads the configuration if cmd is ParamChange, so that Windows services reload their
+// configuration through the platform-native mechanism. It reports whether the command was handled, along with the
+// error from the reload, so it can sit at the top of a service's control request loop:
+//
+//	const accepts = svc.AcceptStop | svc.AcceptShutdown | admin.AcceptParamChange
+//	changes <- svc.Status{State: svc.Running, Accepts: accepts}
+//	for c := range r {
+//		if handled, err := h.HandleServiceControl(uint32(c.Cmd)); handled {
+//			if err != nil {
+//				elog.Error(1, err.Error())
+//			}
+//			continue
+//		}
+//		switch c.Cmd {
+//		// ...
+//		}
+//	}
+//
+// A failed reload keeps the current configuration, the same as Reload.
+func (h *Handler[T]) HandleServiceControl(cmd uint32) (bool, error) {
+	if cmd != ParamChange {
+		return false, nil
+	}
+	_, err := h.Reload()
+	return true, err
+}
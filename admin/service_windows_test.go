@@ -0,0 +1,26 @@
+//go:build windows
+
+package admin
+
+import "testing"
+
+func Test_HandleServiceControl(t *testing.T) {
+	fail := false
+	h, err := New(&TestConfig{}, useCounter(&fail))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if handled, err := h.HandleServiceControl(1); handled || err != nil { // SERVICE_CONTROL_STOP
+		t.Errorf("HandleServiceControl(stop) = %v, %v, want false, nil", handled, err)
+	}
+	if handled, err := h.HandleServiceControl(ParamChange); !handled || err != nil {
+		t.Errorf("HandleServiceControl(ParamChange) = %v, %v, want true, nil", handled, err)
+	}
+	if h.Config().Host != "aa" {
+		t.Errorf("Config().Host = %v, want %v", h.Config().Host, "aa")
+	}
+	fail = true
+	if handled, err := h.HandleServiceControl(ParamChange); !handled || err == nil {
+		t.Errorf("HandleServiceControl(ParamChange) = %v, %v, want true, error", handled, err)
+	}
+}
@@ -0,0 +1,130 @@
+package qcl
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os/exec"
+	"reflect"
+	"strings"
+)
+
+// agePrefix marks a value encrypted with age, followed by the base64 encoding of the encrypted file.
+const agePrefix = "age:"
+
+// ageArmorHeader starts a value encrypted with age in its ASCII-armored form, as written by age --armor.
+const ageArmorHeader = "-----BEGIN AGE ENCRYPTED FILE-----"
+
+// errNoAgeIdentity is returned for fields tagged `encrypted:"age"` when no identity was set with WithAgeIdentity.
+var errNoAgeIdentity = errors.New("encrypted with age, but no identity is set with WithAgeIdentity")
+
+// ageCommand returns the command that decrypts its standard input with the identity file. It's a variable so tests
+// can stand in for age.
+var ageCommand = func(ctx context.Context, identity string) *exec.Cmd {
+	return exec.CommandContext(ctx, "age", "--decrypt", "--identity", identity)
+}
+
+// WithAgeIdentity decrypts values encrypted with age (https://age-encryption.org), so that only the sensitive values
+// of an otherwise plain config need to be encrypted, with the identity file at path. Encrypted values are written
+// with the age: prefix followed by the base64 encoding of the encrypted file, or, for fields tagged
+// `encrypted:"age"`, also without the prefix or in the ASCII-armored form:
+//
+//	$ echo -n hunter2 | age -r age1ql3z7hjy54pw3hyww5ayyfg7zqgvc7w3j2elw8zmrj2kg5sfn9aqmcac8p | base64
+//	YWdlLWVuY3J5cHRpb24ub3JnL3YxCi0+IFgyNTUxOSB...
+//
+//	export DB_PASSWORD=age:YWdlLWVuY3J5cHRpb24ub3JnL3YxCi0+IFgyNTUxOSB...
+//
+//	type Config struct {
+//		DBPassword qcl.Secret
+//		APIKey     string `encrypted:"age"`
+//	}
+//
+//	qcl.Load(&defaultConfig, qcl.UseFile("config.ini"), qcl.UseEnv(), qcl.WithAgeIdentity("/run/secrets/age.key"))
+//
+// Values are decrypted once every source has run, before they're validated, by the age command, which must be on the
+// PATH. String fields, pointers to strings and slices of strings can be encrypted. A value that can't be decrypted is
+// reported as a *FieldError. Without WithAgeIdentity, values with the age: prefix are left as they are, while fields
+// tagged `encrypted:"age"` fail to load if they're set.
+func WithAgeIdentity(path string) LoadOption {
+	return func(o *LoadConfig) {
+		o.ageIdentity = path
+	}
+}
+
+// decryptValues decrypts the values of the config encrypted with age, returning a *FieldError for each field that
+// can't be decrypted.
+func (c *LoadConfig) decryptValues(config any) error {
+	var errs []error
+	for _, f := range leafFields(config) {
+		tagged := f.sf.Tag.Get("encrypted") == "age"
+		for _, v := range encryptableValues(f.value) {
+			value := v.String()
+			if value == "" || !tagged && !strings.HasPrefix(value, agePrefix) {
+				continue
+			}
+			if c.ageIdentity == "" {
+				if tagged {
+					errs = append(errs, &FieldError{Path: f.name(), Key: f.name(), RawValue: value, Err: errNoAgeIdentity})
+				}
+				continue
+			}
+			plaintext, err := c.decryptAge(value)
+			if err != nil {
+				errs = append(errs, &FieldError{Path: f.name(), Key: f.name(), RawValue: value, Err: err})
+				continue
+			}
+			v.SetString(plaintext)
+		}
+	}
+	return joinErrors(errs)
+}
+
+// encryptableValues returns the strings of a string field, a pointer to a string or a slice of strings, which can be
+// encrypted.
+func encryptableValues(v reflect.Value) []reflect.Value {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	switch {
+	case v.Kind() == reflect.String:
+		return []reflect.Value{v}
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		values := make([]reflect.Value, v.Len())
+		for i := range values {
+			values[i] = v.Index(i)
+		}
+		return values
+	}
+	return nil
+}
+
+// decryptAge decrypts a value encrypted with age, with the identity set with WithAgeIdentity.
+func (c *LoadConfig) decryptAge(value string) (string, error) {
+	value = strings.TrimPrefix(value, agePrefix)
+	ciphertext := []byte(value)
+	if !strings.HasPrefix(strings.TrimSpace(value), ageArmorHeader) {
+		var err error
+		if ciphertext, err = base64.StdEncoding.DecodeString(strings.TrimSpace(value)); err != nil {
+			return "", fmt.Errorf("age ciphertext isn't valid base64: %w", err)
+		}
+	}
+	ctx := c.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := ageCommand(ctx, c.ageIdentity)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = bytes.NewReader(ciphertext), &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("age: %w: %s", err, msg)
+		}
+		return "", fmt.Errorf("age: %w", err)
+	}
+	return stdout.String(), nil
+}
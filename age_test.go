@@ -0,0 +1,120 @@
+package qcl
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"reflect"
+	"testing"
+)
+
+// Test_ageHelper isn't a real test: it is run as a subprocess by Test_WithAgeIdentity to stand in for age. It
+// "decrypts" its standard input by printing it back, if the identity file holds testAgeIdentity.
+func Test_ageHelper(t *testing.T) {
+	if os.Getenv("QCL_TEST_AGE") == "" {
+		return
+	}
+	identity, err := os.ReadFile(os.Args[len(os.Args)-1])
+	if err != nil || string(identity) != testAgeIdentity {
+		fmt.Fprint(os.Stderr, "no identity matched any of the recipients")
+		os.Exit(1)
+	}
+	ciphertext, _ := io.ReadAll(os.Stdin)
+	fmt.Print(string(ciphertext))
+	os.Exit(0)
+}
+
+const testAgeIdentity = "AGE-SECRET-KEY-1TEST"
+
+type TestAgeConfig struct {
+	Password Secret
+	APIKey   string `encrypted:"age"`
+	Tokens   []string
+	Plain    string
+	Port     int
+}
+
+func Test_WithAgeIdentity(t *testing.T) {
+	original := ageCommand
+	t.Cleanup(func() { ageCommand = original })
+	ageCommand = func(ctx context.Context, identity string) *exec.Cmd {
+		cmd := exec.CommandContext(ctx, os.Args[0], "-test.run=Test_ageHelper", "--", "--decrypt", "--identity", identity)
+		cmd.Env = append(os.Environ(), "QCL_TEST_AGE=1")
+		return cmd
+	}
+	identity := writeFile(t, "age.key", []byte(testAgeIdentity))
+	encrypt := func(s string) string {
+		return base64.StdEncoding.EncodeToString([]byte(s))
+	}
+	armored := ageArmorHeader + "\nsk-123\n"
+
+	tests := map[string]struct {
+		env      map[string]string
+		identity string
+		want     *TestAgeConfig
+		wantErr  string
+	}{
+		"decrypted": {
+			env: map[string]string{
+				"TEST_PASSWORD": agePrefix + encrypt("hunter2"),
+				"TEST_API_KEY":  encrypt("sk-123"),
+				"TEST_TOKENS":   agePrefix + encrypt("t1") + ",t2",
+				"TEST_PLAIN":    "plain",
+			},
+			identity: identity,
+			want:     &TestAgeConfig{Password: "hunter2", APIKey: "sk-123", Tokens: []string{"t1", "t2"}, Plain: "plain"},
+		},
+		"armored": {
+			env:      map[string]string{"TEST_API_KEY": armored},
+			identity: identity,
+			want:     &TestAgeConfig{APIKey: armored},
+		},
+		"no identity": {
+			env:  map[string]string{"TEST_PASSWORD": agePrefix + encrypt("hunter2")},
+			want: &TestAgeConfig{Password: Secret(agePrefix + encrypt("hunter2"))},
+		},
+		"tagged without identity": {
+			env:     map[string]string{"TEST_API_KEY": encrypt("sk-123")},
+			wantErr: "APIKey",
+		},
+		"wrong identity": {
+			env:      map[string]string{"TEST_PASSWORD": agePrefix + encrypt("hunter2")},
+			identity: writeFile(t, "other.key", []byte("AGE-SECRET-KEY-1OTHER")),
+			wantErr:  "Password",
+		},
+		"invalid base64": {
+			env:      map[string]string{"TEST_PLAIN": agePrefix + "!!!"},
+			identity: identity,
+			wantErr:  "Plain",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			for key, value := range test.env {
+				t.Setenv(key, value)
+			}
+			opts := []LoadOption{UseEnv(WithEnvPrefix("TEST"))}
+			if test.identity != "" {
+				opts = append(opts, WithAgeIdentity(test.identity))
+			}
+			got, err := Load(&TestAgeConfig{}, opts...)
+			if test.wantErr != "" {
+				var fieldErr *FieldError
+				if !errors.As(err, &fieldErr) || fieldErr.Path != test.wantErr {
+					t.Errorf("Load() error = %v, want a FieldError for %s", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Load() = %+v, want %+v", *got, *test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package qcl
+
+import (
+	"context"
+	"reflect"
+	"strings"
+)
+
+// A BatchFetcher fetches the values of many keys from a backing store in a single call, e.g. AWS SSM GetParameters or
+// Secrets Manager BatchGetSecretValue. Keys missing from the returned map are left unset, so a fetcher should return
+// an error only when the call itself fails. The context is the one given to LoadContext, done at the deadline set with
+// WithDeadline, if there is one, so fetchers should pass it on to the calls they make.
+type BatchFetcher func(ctx context.Context, keys []string) (map[string]string, error)
+
+// UseBatchFetcher enables loading fields from a secret or parameter store that can fetch many keys per call. Every
+// field tagged with the given struct tag names the key it is loaded from. Before any field is set, the keys of the
+// whole config are collected, deduplicated, and fetched in batches of at most batchSize keys, so a large config costs
+// a handful of API calls instead of one per field. This reduces cold-start latency and the risk of being throttled.
+//
+// Example:
+//
+//	type Config struct {
+//		DB struct {
+//			Host     string `ssm:"/prod/db/host"`
+//			Password string `ssm:"/prod/db/password" secret:"true"`
+//		}
+//	}
+//
+//	fetch := func(ctx context.Context, keys []string) (map[string]string, error) {
+//		out, err := ssmClient.GetParameters(ctx, &ssm.GetParametersInput{Names: keys, WithDecryption: aws.Bool(true)})
+//		if err != nil {
+//			return nil, err
+//		}
+//		values := make(map[string]string, len(out.Parameters))
+//		for _, p := range out.Parameters {
+//			values[*p.Name] = *p.Value
+//		}
+//		return values, nil
+//	}
+//
+//	qcl.Load(&defaultConfig, qcl.UseEnv(), qcl.UseBatchFetcher("ssm", "ssm", 10, fetch))
+//
+// The name identifies the source, e.g. in a *PartialLoadError. A batchSize of zero or less fetches every key in one
+// call. Once the context is done, no more batches are fetched. Values are parsed the same way the environment loader parses them, with iterables separated by a comma.
+func UseBatchFetcher(name, tag string, batchSize int, fetch BatchFetcher) LoadOption {
+	return func(o *LoadConfig) {
+		o.Sources = append(o.Sources, name)
+		o.Loaders[name] = loadFromBatchFetcher(tag, batchSize, fetch, o.context, o.recorder(name))
+	}
+}
+
+func loadFromBatchFetcher(tag string, batchSize int, fetch BatchFetcher, loadContext func() (context.Context, context.CancelFunc), record func(path, key string)) Loader {
+	return func(config any) error {
+		if reflect.TypeOf(config).Kind() != reflect.Ptr {
+			return ConfigTypeError
+		}
+		val := reflect.ValueOf(config).Elem()
+		if val.Kind() != reflect.Struct {
+			return ConfigTypeError
+		}
+
+		fields := taggedFields(val.Type(), tag, nil, nil)
+		keys := make([]string, 0, len(fields))
+		seen := make(map[string]bool, len(fields))
+		for _, f := range fields {
+			if !seen[f.key] {
+				seen[f.key] = true
+				keys = append(keys, f.key)
+			}
+		}
+		if batchSize <= 0 {
+			batchSize = len(keys)
+		}
+
+		ctx, cancel := loadContext()
+		defer cancel()
+		values := make(map[string]string, len(keys))
+		for start := 0; start < len(keys); start += batchSize {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			end := start + batchSize
+			if end > len(keys) {
+				end = len(keys)
+			}
+			batch, err := fetch(ctx, keys[start:end])
+			if err != nil {
+				return err
+			}
+			for k, v := range batch {
+				values[k] = v
+			}
+		}
+
+		var errs []error
+		for _, f := range fields {
+			value, ok := values[f.key]
+			if !ok {
+				continue
+			}
+			target, err := fieldByPath(val, f.path)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if err := defaultParseOptions.setField(target, value); err != nil {
+				errs = append(errs, &FieldError{Path: f.path, Key: f.key, RawValue: value, Err: err})
+				continue
+			}
+			record(f.path, f.key)
+		}
+		return joinErrors(errs)
+	}
+}
+
+// taggedField is a field that names the key it is loaded from in a struct tag.
+type taggedField struct {
+	path string // path is the dotted path of the field, as accepted by fieldByPath.
+	key  string // key is the value of the tag.
+}
+
+// taggedFields returns the fields of the struct type, and of its nested structs, that have the tag, in the order they
+// are declared. Types already being walked are skipped, so recursive types terminate.
+func taggedFields(typ reflect.Type, tag string, path []string, walking map[reflect.Type]bool) []taggedField {
+	if walking == nil {
+		walking = make(map[reflect.Type]bool)
+	}
+	if walking[typ] {
+		return nil
+	}
+	walking[typ] = true
+	defer delete(walking, typ)
+
+	var fields []taggedField
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		if !sf.IsExported() || skipField(sf) {
+			continue
+		}
+		fieldPath := append(append(make([]string, 0, len(path)+1), path...), sf.Name)
+		if key := strings.TrimSpace(sf.Tag.Get(tag)); key != "" {
+			fields = append(fields, taggedField{path: strings.Join(fieldPath, "."), key: key})
+			continue
+		}
+		ft := sf.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Struct && !isLeafStruct(ft) {
+			fields = append(fields, taggedFields(ft, tag, fieldPath, walking)...)
+		}
+	}
+	return fields
+}
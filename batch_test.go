@@ -0,0 +1,126 @@
+package qcl
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type TestBatchConfig struct {
+	Host     string `ssm:"/app/host"`
+	Port     int    `ssm:"/app/port"`
+	Untagged string
+	DB       *struct {
+		Host     string   `ssm:"/app/db/host"`
+		Password string   `ssm:"/app/db/password"`
+		Replicas []string `ssm:"/app/db/replicas"`
+	}
+	TestConfigWithStructTag
+	Next *TestBatchConfig
+	Also string `ssm:"/app/host"`
+}
+
+func Test_UseBatchFetcher(t *testing.T) {
+	store := map[string]string{
+		"/app/host":        "example.com",
+		"/app/port":        "8080",
+		"/app/db/host":     "db.example.com",
+		"/app/db/password": "hunter2",
+		"/app/db/replicas": "a,b",
+	}
+	tests := map[string]struct {
+		batchSize   int
+		wantBatches [][]string
+	}{
+		"batches of two": {
+			batchSize: 2,
+			wantBatches: [][]string{
+				{"/app/host", "/app/port"},
+				{"/app/db/host", "/app/db/password"},
+				{"/app/db/replicas"},
+			},
+		},
+		"single call": {
+			batchSize:   0,
+			wantBatches: [][]string{{"/app/host", "/app/port", "/app/db/host", "/app/db/password", "/app/db/replicas"}},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var batches [][]string
+			fetch := func(_ context.Context, keys []string) (map[string]string, error) {
+				batches = append(batches, append([]string(nil), keys...))
+				values := make(map[string]string)
+				for _, k := range keys {
+					if v, ok := store[k]; ok {
+						values[k] = v
+					}
+				}
+				return values, nil
+			}
+			got, err := Load(&TestBatchConfig{Untagged: "default"}, UseBatchFetcher("ssm", "ssm", test.batchSize, fetch))
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if !reflect.DeepEqual(batches, test.wantBatches) {
+				t.Errorf("batches = %v, want %v", batches, test.wantBatches)
+			}
+			if got.Host != "example.com" || got.Port != 8080 || got.Also != "example.com" || got.Untagged != "default" {
+				t.Errorf("Load() got = %+v", got)
+			}
+			if got.DB == nil || got.DB.Password != "hunter2" || !reflect.DeepEqual(got.DB.Replicas, []string{"a", "b"}) {
+				t.Errorf("Load() DB = %+v", got.DB)
+			}
+			if got.Next != nil {
+				t.Errorf("Load() allocated Next = %+v, want nil", got.Next)
+			}
+		})
+	}
+	t.Run("fetch error", func(t *testing.T) {
+		failure := errors.New("throttled")
+		_, err := Load(&TestBatchConfig{}, UseBatchFetcher("ssm", "ssm", 10, func(context.Context, []string) (map[string]string, error) {
+			return nil, failure
+		}))
+		if !errors.Is(err, failure) {
+			t.Errorf("Load() error = %v, want %v", err, failure)
+		}
+	})
+	t.Run("context", func(t *testing.T) {
+		type key struct{}
+		ctx := context.WithValue(context.Background(), key{}, "load")
+		_, err := LoadContext(ctx, &TestBatchConfig{}, UseBatchFetcher("ssm", "ssm", 10, func(ctx context.Context, _ []string) (map[string]string, error) {
+			if ctx.Value(key{}) != "load" {
+				t.Error("fetch was not given the context of LoadContext")
+			}
+			return nil, nil
+		}))
+		if err != nil {
+			t.Fatalf("LoadContext() error = %v", err)
+		}
+	})
+	t.Run("done between batches", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		var batches int
+		load := loadFromBatchFetcher("ssm", 2, func(context.Context, []string) (map[string]string, error) {
+			batches++
+			cancel()
+			return nil, nil
+		}, func() (context.Context, context.CancelFunc) { return ctx, func() {} }, func(string, string) {})
+		if err := load(&TestBatchConfig{}); !errors.Is(err, context.Canceled) {
+			t.Errorf("loader error = %v, want context.Canceled", err)
+		}
+		if batches != 1 {
+			t.Errorf("fetched %d batches, want 1", batches)
+		}
+	})
+	t.Run("invalid value", func(t *testing.T) {
+		_, err := Load(&TestBatchConfig{}, UseBatchFetcher("ssm", "ssm", 10, func(context.Context, []string) (map[string]string, error) {
+			return map[string]string{"/app/port": "not a number"}, nil
+		}))
+		if err == nil {
+			t.Error("Load() error = nil, want an error")
+		}
+	})
+}
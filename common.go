@@ -1,10 +1,18 @@
 package qcl
 
 import (
+	"encoding"
+	"errors"
+	"flag"
 	"fmt"
+	"math"
+	"net"
+	"net/url"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 	"unicode"
 )
@@ -19,8 +27,80 @@ type (
 	UnsupportedTypeError struct {
 		kind reflect.Kind
 	}
+	// UnknownFieldError is returned when a field path doesn't match any field of the config struct.
+	UnknownFieldError struct {
+		Field string // Field is the dotted path that didn't match, e.g. "DB.Hots".
+	}
+	// SourceError associates an error with the configuration source it came from.
+	SourceError struct {
+		Source string // Source is the name of the configuration source, e.g. "env" or "flags".
+		Err    error  // Err is the reason the source didn't complete.
+	}
+	// PartialLoadError is returned by Load when one or more sources didn't complete, either because they failed while
+	// WithPartialResult was in use, or because the deadline set with WithDeadline passed.
+	PartialLoadError struct {
+		Incomplete []SourceError // Incomplete lists the sources that didn't complete, in the order they were configured.
+		Invalid    error         // Invalid, if not nil, is why the fields that did load failed decryption, the required check or validation.
+	}
+	// UnsupportedFormatError is returned when a file's format isn't supported, or can't be told from its extension.
+	UnsupportedFormatError struct {
+		Path   string // Path is the path of the file.
+		Format string // Format is the requested format, or empty if the extension didn't match a format.
+	}
+	// MutationError is returned by Frozen.Verify when the shared config has been modified since it was frozen.
+	MutationError struct {
+		Changes []Change // Changes lists the fields that were modified, with their frozen and current values.
+	}
+	// FieldError is returned when a source has a value that can't be set into the field it's for, e.g. "abc" for an
+	// int, and says where the value came from, so that it can be reported as "TEST_DB_PORT from env had value 'abc'
+	// which is not an int" rather than as a bare parse error.
+	FieldError struct {
+		Path     string // Path is the dotted path of the field, e.g. "DB.Port".
+		Source   string // Source is the name of the configuration source, e.g. "env" or "file:config.ini".
+		Key      string // Key is the name the value has in the source, e.g. "TEST_DB_PORT" or "db.port".
+		RawValue string // RawValue is the value as found in the source. It is RedactedValue for secret fields.
+		Err      error  // Err is the reason the value couldn't be set.
+	}
+	// UnknownKeyError is returned when a source has a key that doesn't match any field of the config, and WithStrict
+	// is in use.
+	UnknownKeyError struct {
+		Source string // Source is the name of the configuration source, e.g. "env" or "file:config.ini".
+		Key    string // Key is the unknown key, e.g. "TEST_DB_PRT" or "db.prt".
+	}
+	// MultiError is returned when loading fails for more than one reason, e.g. several fields with invalid values, so
+	// that a single failed startup reports everything that's wrong.
+	MultiError struct {
+		Errors []error // Errors lists the reasons, in the order they were found.
+	}
+	// MissingFieldsError is returned when fields tagged `required:"true"` weren't set by any source.
+	MissingFieldsError struct {
+		Fields []string // Fields lists the dotted paths of the missing fields, in the order they are declared.
+	}
+	// ValidationError is returned when a loaded field breaks a rule of its `validate` struct tag, e.g. a port above
+	// max=65535. It doesn't include the value, which may be a secret.
+	ValidationError struct {
+		Path string // Path is the dotted path of the field, e.g. "DB.Port".
+		Rule string // Rule is the rule the field broke, as written in the tag, e.g. "max=65535".
+		Err  error  // Err is the reason the field broke the rule.
+	}
+	// UnknownSourceError is returned when the `source` tag, or the precedence option of the `qcl` tag, of a field names
+	// a source that is neither one of the sources of the load nor a built-in source, which would otherwise never match.
+	UnknownSourceError struct {
+		Path    string   // Path is the dotted path of the field, e.g. "DB.Port".
+		Name    string   // Name is the source name that matches none of the sources, e.g. "flag".
+		Sources []string // Sources lists the sources of the load.
+	}
 )
 
+// DeadlineExceededError is the reason given for sources that didn't complete before the deadline set with WithDeadline.
+var DeadlineExceededError = errors.New("load deadline exceeded")
+
+// UnboundFlagSetError is returned by the source added with UsePFlags if the flag set has no flags bound with BindPFlags.
+var UnboundFlagSetError = errors.New("no flags bound to the flag set with BindPFlags")
+
+// UTF16Error is returned for files, including dotenv files, that are encoded as UTF-16 rather than UTF-8.
+var UTF16Error = errors.New("file is UTF-16 encoded, save it as UTF-8")
+
 func (e InvalidMapValueError) Error() string {
 	return fmt.Sprintf("keys -> values mismatch: %v -> %v", e.keys, e.values)
 }
@@ -29,6 +109,173 @@ func (e UnsupportedTypeError) Error() string {
 	return fmt.Sprintf("unsupported type: %s", e.kind)
 }
 
+func (e UnknownFieldError) Error() string {
+	return fmt.Sprintf("unknown field: %s", e.Field)
+}
+
+func (e SourceError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Source, e.Err)
+}
+
+func (e SourceError) Unwrap() error {
+	return e.Err
+}
+
+func (e *PartialLoadError) Error() string {
+	msgs := make([]string, len(e.Incomplete))
+	for i, incomplete := range e.Incomplete {
+		msgs[i] = incomplete.Error()
+	}
+	if e.Invalid != nil {
+		return fmt.Sprintf("sources did not complete: %s; the loaded config is invalid: %v", strings.Join(msgs, "; "), e.Invalid)
+	}
+	return fmt.Sprintf("sources did not complete: %s", strings.Join(msgs, "; "))
+}
+
+// Unwrap returns the reason the loaded config is invalid, if any, for errors.Is and errors.As.
+func (e *PartialLoadError) Unwrap() error {
+	return e.Invalid
+}
+
+func (e UnsupportedFormatError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("unsupported file format: %s", e.Format)
+	}
+	if e.Format == "" {
+		return fmt.Sprintf("%s: unknown file format, set one with WithFileFormat", e.Path)
+	}
+	return fmt.Sprintf("%s: unsupported file format: %s", e.Path, e.Format)
+}
+
+func (e *MutationError) Error() string {
+	fields := make([]string, len(e.Changes))
+	for i, change := range e.Changes {
+		fields[i] = change.Field
+	}
+	return fmt.Sprintf("frozen config was modified: %s", strings.Join(fields, ", "))
+}
+
+func (e *FieldError) Error() string {
+	key := e.Key
+	if e.Source != "" {
+		key += " from " + e.Source
+	}
+	return fmt.Sprintf("%s: invalid value %q for %s: %v", key, e.RawValue, e.Path, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+func (e *UnknownKeyError) Error() string {
+	if e.Source == "" {
+		return fmt.Sprintf("unknown key: %s", e.Key)
+	}
+	return fmt.Sprintf("unknown key: %s from %s", e.Key, e.Source)
+}
+
+func (e *MissingFieldsError) Error() string {
+	return fmt.Sprintf("missing required fields: %s", strings.Join(e.Fields, ", "))
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid %s: %v", e.Path, e.Err)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+func (e *UnknownSourceError) Error() string {
+	return fmt.Sprintf("%s: source %q matches none of the sources: %s", e.Path, e.Name, strings.Join(e.Sources, ", "))
+}
+
+func (e *MultiError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("%d errors: %s", len(e.Errors), strings.Join(msgs, "; "))
+}
+
+// Unwrap returns the errors, for errors.Is and errors.As.
+func (e *MultiError) Unwrap() []error {
+	return e.Errors
+}
+
+// Is reports whether any of the errors matches the target, for versions of Go whose errors.Is doesn't use Unwrap.
+func (e *MultiError) Is(target error) bool {
+	for _, err := range e.Errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first of the errors that matches the target, for versions of Go whose errors.As doesn't use Unwrap.
+func (e *MultiError) As(target any) bool {
+	for _, err := range e.Errors {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// visitErrors calls fn with err and every error it wraps, including each of the errors of a *MultiError.
+func visitErrors(err error, fn func(error)) {
+	if err == nil {
+		return
+	}
+	fn(err)
+	switch err := err.(type) {
+	case interface{ Unwrap() []error }:
+		for _, err := range err.Unwrap() {
+			visitErrors(err, fn)
+		}
+	case interface{ Unwrap() error }:
+		visitErrors(err.Unwrap(), fn)
+	}
+}
+
+// joinErrors returns nil if there are no errors, the error if there's one, or a *MultiError of them all otherwise.
+// Errors that are themselves a *MultiError are flattened, and errors with the same message as an earlier one dropped.
+func joinErrors(errs []error) error {
+	var flat []error
+	seen := make(map[string]bool, len(errs))
+	for _, err := range errs {
+		nested := []error{err}
+		if multi, ok := err.(*MultiError); ok {
+			nested = multi.Errors
+		}
+		for _, err := range nested {
+			if err != nil && !seen[err.Error()] {
+				seen[err.Error()] = true
+				flat = append(flat, err)
+			}
+		}
+	}
+	switch len(flat) {
+	case 0:
+		return nil
+	case 1:
+		return flat[0]
+	}
+	return &MultiError{Errors: flat}
+}
+
+// Is reports whether any of the incomplete sources failed with the target error, so that
+// errors.Is(err, qcl.DeadlineExceededError) works on a *PartialLoadError.
+func (e *PartialLoadError) Is(target error) bool {
+	for _, incomplete := range e.Incomplete {
+		if errors.Is(incomplete.Err, target) {
+			return true
+		}
+	}
+	return false
+}
+
 // splitOnWordBoundaries splits a string on word boundaries. Word boundaries are capitalized letters followed immediately
 // by a lowercase letter. For example, "FooBar" is split into "Foo" and "Bar". The first letter is always capitalized.
 // This is useful for converting a camelCase or PascalCase string into a slice of words. It also handles acronyms,
@@ -59,7 +306,56 @@ func splitOnWordBoundaries(s string) []string {
 	return []string{s}
 }
 
-func setMapKeysAndValues(v reflect.Value, keys, values []string, separator string) error {
+// parseOptions controls how strings are parsed into field values. Loaders that are configurable build them from their
+// own options.
+type parseOptions struct {
+	separator    string // separator splits the elements of slices and the entries of maps.
+	isoDurations bool   // isoDurations makes time.Duration fields accept ISO 8601 durations, e.g. PT15M, as well as Go's.
+}
+
+// defaultParseOptions are used where values are parsed outside a loader, e.g. by Override and the types in this package.
+var defaultParseOptions = parseOptions{separator: ","}
+
+// forField returns the options the field at the dotted path of the struct type typ is parsed with: p, with the
+// separator set by the field's `sep` tag, if it has one, like `sep:";"` for a list of values containing commas.
+func (p parseOptions) forField(typ reflect.Type, path string) parseOptions {
+	if sf, ok := structFieldByPath(typ, path); ok {
+		if sep := sf.Tag.Get("sep"); sep != "" {
+			p.separator = sep
+		}
+	}
+	return p
+}
+
+// structFieldByPath returns the struct field at the dotted path of the struct type typ, stepping over the indexes of
+// slices and the keys of maps of structs, like the 0 of Servers.0.Host. It returns false if there's no such field, or
+// it's in an interface field, whose type isn't known.
+func structFieldByPath(typ reflect.Type, path string) (reflect.StructField, bool) {
+	var sf reflect.StructField
+	names := strings.Split(path, ".")
+	for i := 0; i < len(names); i++ {
+		typ = indirectType(typ)
+		if typ.Kind() == reflect.Slice || typ.Kind() == reflect.Map {
+			typ, i = indirectType(typ.Elem()), i+1
+			if i == len(names) {
+				return sf, false
+			}
+		}
+		if typ.Kind() != reflect.Struct {
+			return sf, false
+		}
+		f, ok := typ.FieldByName(names[i])
+		if !ok {
+			return sf, false
+		}
+		sf, typ = f, f.Type
+	}
+	return sf, true
+}
+
+// setMapKeysAndValues sets the entries of the map v, parsing both its keys and its values as fields of the map's key
+// and element types, so maps like map[int]string and map[time.Duration]float64 work too.
+func (p parseOptions) setMapKeysAndValues(v reflect.Value, keys, values []string) error {
 	if v.Kind() != reflect.Map {
 		return NotAMapError
 	}
@@ -72,16 +368,20 @@ func setMapKeysAndValues(v reflect.Value, keys, values []string, separator strin
 		v.Set(reflect.MakeMap(v.Type()))
 	}
 	for i, key := range keys {
+		newKey := reflect.New(v.Type().Key())
+		if err := p.setField(newKey.Elem(), key); err != nil {
+			return fmt.Errorf("invalid map key %q: %w", key, err)
+		}
 		newVal := reflect.New(v.Type().Elem())
-		if err := setField(newVal.Elem(), values[i], separator); err != nil {
+		if err := p.setField(newVal.Elem(), values[i]); err != nil {
 			return err
 		}
-		v.SetMapIndex(reflect.ValueOf(key), newVal.Elem())
+		v.SetMapIndex(newKey.Elem(), newVal.Elem())
 	}
 	return nil
 }
 
-func setSliceValues(v reflect.Value, values []string, separator string) error {
+func (p parseOptions) setSliceValues(v reflect.Value, values []string) error {
 	if v.Kind() != reflect.Slice {
 		return NotASliceError
 	}
@@ -90,7 +390,7 @@ func setSliceValues(v reflect.Value, values []string, separator string) error {
 	}
 	for _, value := range values {
 		newVal := reflect.New(v.Type().Elem())
-		if err := setField(newVal.Elem(), value, separator); err != nil {
+		if err := p.setField(newVal.Elem(), value); err != nil {
 			return err
 		}
 		v.Set(reflect.Append(v, newVal.Elem()))
@@ -98,13 +398,175 @@ func setSliceValues(v reflect.Value, values []string, separator string) error {
 	return nil
 }
 
-func setField(v reflect.Value, value string, separator string) error {
+// isStructSlice reports whether v is a slice of structs, or of pointers to structs, whose elements are loaded field by
+// field from indexed keys, like SERVERS_0_HOST and -servers.0.host, rather than parsed from a single value.
+func isStructSlice(v reflect.Value) bool {
+	if v.Kind() != reflect.Slice {
+		return false
+	}
+	if _, ok := customSetter(v); ok {
+		return false
+	}
+	elem := indirectType(v.Type().Elem())
+	return elem.Kind() == reflect.Struct && !isLeafStruct(elem)
+}
+
+// growSlice extends the slice of structs v, whose dotted path is path, to at least n elements. New elements are
+// allocated, if they're pointers, and set to the defaults their fields are tagged with.
+func growSlice(v reflect.Value, n int, path string) error {
+	if v.Len() >= n {
+		return nil
+	}
+	grown := reflect.MakeSlice(v.Type(), n, n)
+	reflect.Copy(grown, v)
+	var errs []error
+	for i := v.Len(); i < n; i++ {
+		elem := allocate(grown.Index(i))
+		if err := applyTagDefaults(elem, path+"."+strconv.Itoa(i), map[reflect.Type]bool{}); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	v.Set(grown)
+	return joinErrors(errs)
+}
+
+// sliceLength returns the number of elements of a slice of structs that the indexed keys among names set, which is one
+// more than the highest index. Indexed keys start with prefix, followed by the index and sep, like SERVERS_ and _ for
+// SERVERS_1_HOST.
+func sliceLength(names []string, prefix, sep string) int {
+	n := 0
+	for _, name := range names {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		rest := name[len(prefix):]
+		end := strings.Index(rest, sep)
+		if end <= 0 {
+			continue
+		}
+		if i, err := strconv.Atoi(rest[:end]); err == nil && i >= 0 && i+1 > n && rest[:end] == strconv.Itoa(i) {
+			n = i + 1
+		}
+	}
+	return n
+}
+
+// isStructMap reports whether v is a map of structs, or of pointers to structs, whose entries are loaded field by field
+// from keys carrying the map key, like DATABASES_PRIMARY_HOST and -databases.primary.host, rather than parsed from a
+// single value.
+func isStructMap(v reflect.Value) bool {
+	if v.Kind() != reflect.Map {
+		return false
+	}
+	if _, ok := customSetter(v); ok {
+		return false
+	}
+	elem := indirectType(v.Type().Elem())
+	return elem.Kind() == reflect.Struct && !isLeafStruct(elem)
+}
+
+// mapKeySegments returns the map keys, as written in names, that the keys among names set entries of a map of structs
+// for. Those keys start with prefix, followed by the map key, sep, and one of suffixes, the names of the struct's
+// fields, like DATABASES_, PRIMARY, _ and HOST for DATABASES_PRIMARY_HOST. Since map keys may contain sep, the longest
+// matching suffix wins.
+func mapKeySegments(names []string, prefix, sep string, suffixes []string) []string {
+	segments := make(map[string]bool)
+	for _, name := range names {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		rest, segment := name[len(prefix):], ""
+		for _, suffix := range suffixes {
+			if len(rest) > len(sep+suffix) && strings.HasSuffix(rest, sep+suffix) {
+				if s := rest[:len(rest)-len(sep+suffix)]; segment == "" || len(s) < len(segment) {
+					segment = s
+				}
+			}
+		}
+		if segment != "" {
+			segments[segment] = true
+		}
+	}
+	return sortedKeys(segments)
+}
+
+// walkStructMap calls walk with the struct of each entry of the map of structs v, whose dotted path is path, along
+// with the entry's dotted path and its key as segment formats it. Entries are added for the segments that don't match
+// an existing key, with the key newKey returns for the segment, and start with the defaults their fields are tagged
+// with. Map entries aren't addressable, so each struct is a copy, which is stored back into the map after walk returns
+// and again by the returned function, for values set later, like flags once they're parsed.
+func walkStructMap(v reflect.Value, path string, segments []string, segment, newKey func(string) string, walk func(elem reflect.Value, path, segment string) error) (func(), error) {
+	keys, names := make(map[string]reflect.Value), make(map[string]string)
+	iter := v.MapRange()
+	for iter.Next() {
+		name, err := formatValue(iter.Key(), ",")
+		if err != nil {
+			return nil, err
+		}
+		keys[segment(name)], names[segment(name)] = iter.Key(), name
+	}
+	var errs []error
+	added := make(map[string]bool)
+	for _, s := range segments {
+		if _, ok := keys[s]; ok {
+			continue
+		}
+		key := reflect.New(v.Type().Key()).Elem()
+		if err := defaultParseOptions.setField(key, newKey(s)); err != nil {
+			errs = append(errs, &FieldError{Path: path + "." + newKey(s), Key: s, RawValue: newKey(s), Err: fmt.Errorf("invalid map key: %w", err)})
+			continue
+		}
+		keys[s], names[s], added[s] = key, newKey(s), true
+	}
+	if len(keys) == 0 {
+		return func() {}, joinErrors(errs)
+	}
+	if v.IsNil() {
+		v.Set(reflect.MakeMapWithSize(v.Type(), len(keys)))
+	}
+	elems := make(map[string]reflect.Value, len(keys))
+	for _, s := range sortedKeys(keys) {
+		elem := reflect.New(v.Type().Elem()).Elem()
+		if existing := v.MapIndex(keys[s]); existing.IsValid() {
+			elem.Set(existing)
+		}
+		if added[s] {
+			if err := applyTagDefaults(allocate(elem), path+"."+names[s], map[reflect.Type]bool{}); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if err := walk(allocate(elem), path+"."+names[s], s); err != nil {
+			return nil, err
+		}
+		v.SetMapIndex(keys[s], elem)
+		elems[s] = elem
+	}
+	store := func() {
+		for s, elem := range elems {
+			v.SetMapIndex(keys[s], elem)
+		}
+	}
+	return store, joinErrors(errs)
+}
+
+func (p parseOptions) setField(v reflect.Value, value string) error {
 	if !v.CanSet() {
 		return UnsupportedTypeError{v.Kind()}
 	}
+	if set, ok := customSetter(v); ok {
+		return set(value)
+	}
+	// pointers, e.g. the elements of a []*net.IP, are allocated and set through, so that the types they point to can
+	// parse themselves
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return p.setField(v.Elem(), value)
+	}
 	// need to handle time.Duration before the switch..case since it qualifies as an int
 	if v.Type().String() == "time.Duration" {
-		d, err := time.ParseDuration(value)
+		d, err := p.parseDuration(value)
 		if err != nil {
 			return err
 		}
@@ -121,40 +583,609 @@ func setField(v reflect.Value, value string, separator string) error {
 		}
 		v.SetBool(b)
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		i, err := strconv.ParseInt(value, 10, 64)
+		i, err := strconv.ParseInt(value, 10, v.Type().Bits())
 		if err != nil {
 			return err
 		}
 		v.SetInt(i)
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		i, err := strconv.ParseUint(value, 10, 64)
+		i, err := strconv.ParseUint(value, 10, v.Type().Bits())
 		if err != nil {
 			return err
 		}
 		v.SetUint(i)
 	case reflect.Float32, reflect.Float64:
-		f, err := strconv.ParseFloat(value, 64)
+		f, err := strconv.ParseFloat(value, v.Type().Bits())
 		if err != nil {
 			return err
 		}
 		v.SetFloat(f)
+	// a value sets the whole slice or map, replacing what defaults or earlier sources set rather than adding to it
+	case reflect.Slice, reflect.Map:
+		return p.addValues(v, value, true)
+	default:
+		return UnsupportedTypeError{v.Kind()}
+	}
+	return nil
+}
+
+// addValues adds the elements of the slice, or the key=value entries of the map, that value separates with the
+// separator to v, after zeroing it if replace is set.
+func (p parseOptions) addValues(v reflect.Value, value string, replace bool) error {
+	if v.Kind() == reflect.Slice {
+		if replace {
+			v.Set(reflect.Zero(v.Type()))
+		}
+		return p.setSliceValues(v, splitEscaped(value, p.separator))
+	}
+	kv := splitEscaped(value, p.separator)
+	keys := make([]string, len(kv))
+	values := make([]string, len(kv))
+	for i, kv := range kv {
+		kv := strings.SplitN(kv, "=", 2)
+		if len(kv) != 2 {
+			return InvalidMapValueError{keys, values}
+		}
+		keys[i] = kv[0]
+		values[i] = kv[1]
+	}
+	if replace {
+		v.Set(reflect.Zero(v.Type()))
+	}
+	return p.setMapKeysAndValues(v, keys, values)
+}
+
+// splitEscaped splits s on sep, like strings.Split, except where sep is escaped with a backslash, so that elements
+// can contain it, like the inner maps of a map of maps in eu=host=a\,port=1,us=host=b. One level of escaping is
+// removed from each element, so separators escaped twice are escaped once in the element, to split it in turn.
+func splitEscaped(s, sep string) []string {
+	if sep == "" {
+		return strings.Split(s, sep)
+	}
+	var parts []string
+	start := 0
+	for i := 0; i < len(s); {
+		switch {
+		case s[i] == '\\' && strings.HasPrefix(s[i+1:], sep):
+			i += 1 + len(sep)
+		case strings.HasPrefix(s[i:], sep):
+			parts = append(parts, strings.ReplaceAll(s[start:i], "\\"+sep, sep))
+			i += len(sep)
+			start = i
+		default:
+			i++
+		}
+	}
+	return append(parts, strings.ReplaceAll(s[start:], "\\"+sep, sep))
+}
+
+// escapeSeparator escapes sep in s with a backslash, so that splitEscaped keeps s in one piece.
+func escapeSeparator(s, sep string) string {
+	if sep == "" {
+		return s
+	}
+	return strings.ReplaceAll(s, sep, "\\"+sep)
+}
+
+// parseDuration parses a Go duration, e.g. 1h30m, or if isoDurations is set, an ISO 8601 duration, e.g. PT1H30M.
+func (p parseOptions) parseDuration(value string) (time.Duration, error) {
+	if p.isoDurations && isISO8601Duration(value) {
+		return parseISO8601Duration(value)
+	}
+	return time.ParseDuration(value)
+}
+
+func isISO8601Duration(s string) bool {
+	s = strings.TrimLeft(s, "+-")
+	return strings.HasPrefix(s, "P") || strings.HasPrefix(s, "p")
+}
+
+// parseISO8601Duration parses an ISO 8601 duration such as PT15M, P1D or P1DT12H30M5.5S. Weeks and days are taken to
+// be exactly 7 days and 24 hours. Years and months have no fixed length, so they are rejected, as Java's
+// Duration.parse does. The last component may have a fraction, separated by a dot or a comma, and the whole duration
+// may be negated with a leading minus sign.
+func parseISO8601Duration(s string) (time.Duration, error) {
+	invalid := fmt.Errorf("invalid ISO 8601 duration %q", s)
+	rest := strings.ToUpper(s)
+	negative := strings.HasPrefix(rest, "-")
+	rest = strings.TrimLeft(rest, "+-")
+	if !strings.HasPrefix(rest, "P") || len(rest) == 1 {
+		return 0, invalid
+	}
+	rest = rest[1:]
+
+	const designators = "WDTHMS" // in the order they must appear
+	units := map[byte]time.Duration{'W': 7 * 24 * time.Hour, 'D': 24 * time.Hour, 'H': time.Hour, 'S': time.Second}
+	var d time.Duration
+	pos, inTime := 0, false
+	for rest != "" {
+		if rest[0] == 'T' {
+			if inTime || len(rest) == 1 {
+				return 0, invalid
+			}
+			inTime, pos, rest = true, strings.IndexByte(designators, 'T')+1, rest[1:]
+			continue
+		}
+		i := strings.IndexFunc(rest, func(r rune) bool { return (r < '0' || r > '9') && r != '.' && r != ',' })
+		if i <= 0 {
+			return 0, invalid
+		}
+		number, designator := rest[:i], rest[i]
+		rest = rest[i+1:]
+
+		unit, ok := units[designator]
+		switch {
+		case designator == 'M' && inTime:
+			unit, ok = time.Minute, true
+		case (designator == 'M' || designator == 'Y') && !inTime:
+			return 0, fmt.Errorf("invalid ISO 8601 duration %q: years and months have no fixed length", s)
+		case ok && inTime != (designator == 'H' || designator == 'S'):
+			ok = false // W and D belong before the T, H and S after it
+		}
+		next := strings.IndexByte(designators[pos:], designator)
+		if !ok || next < 0 {
+			return 0, invalid
+		}
+		pos += next + 1
+
+		outOfRange := fmt.Errorf("invalid ISO 8601 duration %q: out of range", s)
+		number = strings.Replace(number, ",", ".", 1)
+		if !strings.Contains(number, ".") {
+			n, err := strconv.ParseInt(number, 10, 64)
+			if err != nil {
+				return 0, invalid
+			}
+			if n > int64((math.MaxInt64-d)/unit) {
+				return 0, outOfRange
+			}
+			d += time.Duration(n) * unit
+			continue
+		}
+		if rest != "" {
+			return 0, fmt.Errorf("invalid ISO 8601 duration %q: only the last component can have a fraction", s)
+		}
+		f, err := strconv.ParseFloat(number, 64)
+		if err != nil {
+			return 0, invalid
+		}
+		if f*float64(unit) >= float64(math.MaxInt64-d) {
+			return 0, outOfRange
+		}
+		d += time.Duration(f * float64(unit))
+	}
+	if negative {
+		d = -d
+	}
+	return d, nil
+}
+
+// deepCopy returns a pointer to a deep copy of the value v points to. Pointers, slices, maps and exported struct fields
+// are copied recursively so that nothing loaders can set is shared with the original. Unexported struct fields are
+// copied shallowly, since reflection can't set them, and so are fields skipped by every loader, like loggers in fields
+// tagged `qcl:"-"`, so the copy shares the runtime state of the original. A pointer met again, as in a cycle, is copied
+// once and the copy shared the same way.
+func deepCopy(v reflect.Value) reflect.Value {
+	cp := reflect.New(v.Type().Elem())
+	copier{{v.Pointer(), v.Type()}: cp}.copy(cp.Elem(), v.Elem())
+	return cp
+}
+
+// structCopy returns a deep copy of the struct config is or points to, for walking with walkers that allocate nil
+// pointers without touching the caller's config.
+func structCopy(config any) (reflect.Value, error) {
+	val := reflect.ValueOf(config)
+	if val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return reflect.Value{}, ConfigTypeError
+		}
+		val = val.Elem()
+	}
+	if val.Kind() != reflect.Struct {
+		return reflect.Value{}, ConfigTypeError
+	}
+	cp := reflect.New(val.Type()).Elem()
+	copyValue(cp, val)
+	return cp, nil
+}
+
+func copyValue(dst, src reflect.Value) {
+	make(copier).copy(dst, src)
+}
+
+// copier deep copies values, holding the copies of the pointers copied so far by their address and type, so that
+// values pointing to themselves, directly or not, are copied without recursing forever.
+type copier map[copiedPointer]reflect.Value
+
+type copiedPointer struct {
+	ptr uintptr
+	typ reflect.Type
+}
+
+func (c copier) copy(dst, src reflect.Value) {
+	switch src.Kind() {
+	case reflect.Ptr:
+		if src.IsNil() {
+			return
+		}
+		key := copiedPointer{src.Pointer(), src.Type()}
+		if cp, ok := c[key]; ok {
+			dst.Set(cp)
+			return
+		}
+		cp := reflect.New(src.Type().Elem())
+		c[key] = cp
+		dst.Set(cp)
+		c.copy(cp.Elem(), src.Elem())
+	case reflect.Struct:
+		dst.Set(src)
+		for i := 0; i < src.NumField(); i++ {
+			if dst.Field(i).CanSet() && !skipField(src.Type().Field(i)) {
+				c.copy(dst.Field(i), src.Field(i))
+			}
+		}
 	case reflect.Slice:
-		return setSliceValues(v, strings.Split(value, separator), separator)
+		if src.IsNil() {
+			return
+		}
+		dst.Set(reflect.MakeSlice(src.Type(), src.Len(), src.Cap()))
+		fallthrough
+	case reflect.Array:
+		for i := 0; i < src.Len(); i++ {
+			c.copy(dst.Index(i), src.Index(i))
+		}
 	case reflect.Map:
-		kv := strings.Split(value, separator)
-		keys := make([]string, len(kv))
-		values := make([]string, len(kv))
-		for i, kv := range kv {
-			kv := strings.SplitN(kv, "=", 2)
-			if len(kv) != 2 {
-				return InvalidMapValueError{keys, values}
-			}
-			keys[i] = kv[0]
-			values[i] = kv[1]
-		}
-		return setMapKeysAndValues(v, keys, values, separator)
+		if src.IsNil() {
+			return
+		}
+		dst.Set(reflect.MakeMapWithSize(src.Type(), src.Len()))
+		iter := src.MapRange()
+		for iter.Next() {
+			val := reflect.New(src.Type().Elem()).Elem()
+			c.copy(val, iter.Value())
+			dst.SetMapIndex(iter.Key(), val)
+		}
+	case reflect.Interface:
+		if src.IsNil() {
+			return
+		}
+		val := reflect.New(src.Elem().Type()).Elem()
+		c.copy(val, src.Elem())
+		dst.Set(val)
 	default:
-		return UnsupportedTypeError{v.Kind()}
+		dst.Set(src)
+	}
+}
+
+// copyMatching deep copies every non-zero leaf field of src into the field of dst, a struct, at the same path, if
+// there is one and the types are assignable. Pointers on either side are followed, and allocated in dst as needed.
+func copyMatching(dst reflect.Value, src any) error {
+	if val := reflect.Indirect(reflect.ValueOf(src)); val.Kind() != reflect.Struct {
+		return ConfigTypeError
+	}
+	for _, f := range leafFields(src) {
+		v := f.value
+		for v.Kind() == reflect.Ptr && !v.IsNil() {
+			v = v.Elem()
+		}
+		if v.IsZero() {
+			continue
+		}
+		target, err := fieldByPath(dst, f.name())
+		if err != nil || !target.CanSet() || !v.Type().AssignableTo(target.Type()) {
+			continue
+		}
+		copyValue(target, v)
 	}
 	return nil
 }
+
+// formatValue is the inverse of setField: it renders v as a string that setField parses back into the same value.
+// Slice elements and map entries are joined with the separator, and map entries are sorted by key so the output is
+// stable.
+func formatValue(v reflect.Value, separator string) (string, error) {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return "", nil
+		}
+		v = v.Elem()
+	}
+	if s, ok := formatProto(v); ok {
+		return s, nil
+	}
+	if m, ok := textMarshaler(v); ok {
+		text, err := m.MarshalText()
+		return string(text), err
+	}
+	if fv, ok := flagValue(v); ok {
+		return fv.String(), nil
+	}
+	if isRaw(v) {
+		if v.Kind() == reflect.String {
+			return v.String(), nil
+		}
+		return string(v.Bytes()), nil
+	}
+	if _, ok := lookupParser(v.Type()); ok {
+		p := reflect.New(v.Type())
+		p.Elem().Set(v)
+		if s, ok := p.Interface().(fmt.Stringer); ok {
+			return s.String(), nil
+		}
+		return fmt.Sprint(v.Interface()), nil
+	}
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		return time.Duration(v.Int()).String(), nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits()), nil
+	case reflect.Slice:
+		values := make([]string, v.Len())
+		for i := range values {
+			s, err := formatValue(v.Index(i), separator)
+			if err != nil {
+				return "", err
+			}
+			values[i] = escapeSeparator(s, separator)
+		}
+		return strings.Join(values, separator), nil
+	case reflect.Map:
+		entries := make([]string, 0, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key, err := formatValue(iter.Key(), separator)
+			if err != nil {
+				return "", err
+			}
+			value, err := formatValue(iter.Value(), separator)
+			if err != nil {
+				return "", err
+			}
+			entries = append(entries, escapeSeparator(key+"="+value, separator))
+		}
+		sort.Strings(entries)
+		return strings.Join(entries, separator), nil
+	default:
+		return "", UnsupportedTypeError{v.Kind()}
+	}
+}
+
+// omitFormatted reports whether a field should be left out when rendering a config as arguments or variables, because
+// no value parses back into it: empty slices and maps, and zero values of types that parse themselves, like an
+// unset Schedule.
+func omitFormatted(v reflect.Value) bool {
+	if v.Kind() == reflect.Slice || v.Kind() == reflect.Map {
+		return v.Len() == 0
+	}
+	_, custom := customSetter(v)
+	return custom && v.IsZero()
+}
+
+// textMarshaler returns v as an encoding.TextMarshaler if its type, or a pointer to it, implements the interface.
+func textMarshaler(v reflect.Value) (encoding.TextMarshaler, bool) {
+	if m, ok := v.Interface().(encoding.TextMarshaler); ok {
+		return m, true
+	}
+	if v.CanAddr() {
+		m, ok := v.Addr().Interface().(encoding.TextMarshaler)
+		return m, ok
+	}
+	return nil, false
+}
+
+// formatProto renders the protobuf well-known types supported by protoSetter in the form protoSetter parses.
+func formatProto(v reflect.Value) (string, bool) {
+	if _, ok := protoSetter(v); !ok {
+		return "", false
+	}
+	switch v.Type().Name() {
+	case "Duration":
+		return time.Duration(v.FieldByName("Seconds").Int()*int64(time.Second) + v.FieldByName("Nanos").Int()).String(), true
+	case "Timestamp":
+		return time.Unix(v.FieldByName("Seconds").Int(), v.FieldByName("Nanos").Int()).UTC().Format(time.RFC3339Nano), true
+	}
+	inner := v.FieldByName("Value")
+	if inner.Kind() == reflect.Slice { // BytesValue
+		return string(inner.Bytes()), true
+	}
+	s, err := formatValue(inner, ",")
+	return s, err == nil
+}
+
+// skipField reports whether a struct field should be ignored by every loader. Fields tagged `qcl:"-"` are runtime
+// state, like loggers or caches, kept alongside the configuration. Code generated by protoc-gen-go includes exported
+// bookkeeping fields prefixed with XXX_ and interface-typed oneof fields, neither of which are configuration.
+func skipField(field reflect.StructField) bool {
+	return qclTag(field).skip || strings.HasPrefix(field.Name, "XXX_") || field.Tag.Get("protobuf_oneof") != ""
+}
+
+// customSetter returns a function that sets v from a string if v's type knows how to parse itself, which takes
+// precedence over the kind of the type. That's the case for the types in parsers, including those registered with
+// RegisterParser, for types implementing encoding.TextUnmarshaler, like time.Time and net.IP, for custom flag types
+// implementing flag.Value, for the raw fields set verbatim by rawSetter, and for the protobuf well-known types
+// supported by protoSetter.
+func customSetter(v reflect.Value) (func(string) error, bool) {
+	if parse, ok := lookupParser(v.Type()); ok {
+		return func(s string) error {
+			parsed, err := parse(s)
+			if err != nil {
+				return err
+			}
+			if parsed == nil { // a nil interface
+				v.Set(reflect.Zero(v.Type()))
+				return nil
+			}
+			v.Set(reflect.ValueOf(parsed))
+			return nil
+		}, true
+	}
+	if v.CanAddr() {
+		if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return func(s string) error {
+				return u.UnmarshalText([]byte(s))
+			}, true
+		}
+	}
+	if fv, ok := flagValue(v); ok {
+		return fv.Set, true
+	}
+	if set, ok := rawSetter(v); ok {
+		return set, true
+	}
+	return protoSetter(v)
+}
+
+// parsers parse values by type, starting with the standard library types that don't implement
+// encoding.TextUnmarshaler, plus those registered with RegisterParser. Each returns a value of its type, which is
+// formatted with the String method of a pointer to it, if it has one.
+var parsers = map[reflect.Type]func(string) (any, error){
+	reflect.TypeOf(url.URL{}): func(s string) (any, error) {
+		u, err := url.Parse(s)
+		if err != nil {
+			return nil, err
+		}
+		return *u, nil
+	},
+	// networks are written in CIDR notation, e.g. 10.0.0.0/8
+	reflect.TypeOf(net.IPNet{}): func(s string) (any, error) {
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, err
+		}
+		return *n, nil
+	},
+}
+
+// parsersMu guards parsers, since parsers may be registered while configs are being loaded.
+var parsersMu sync.RWMutex
+
+// RegisterParser teaches every loader to parse fields of type T, and slices, maps and pointers of them, with parse,
+// so that third-party types like decimal.Decimal or semver.Version can be loaded without wrapping them in an
+// encoding.TextUnmarshaler. A registered parser takes precedence over the type's own methods, and registering another
+// parser for the same type replaces it. Values of the type are rendered, e.g. by Args and Environ, with their String
+// method, or else as fmt prints them, so parse should accept that form.
+//
+// Example:
+//
+//	qcl.RegisterParser(decimal.NewFromString)
+//
+//	type Config struct {
+//		MaxSpend decimal.Decimal // MAX_SPEND=1250.50
+//	}
+//
+// It's meant to be called from an init function or before the first load. It panics if parse is nil.
+func RegisterParser[T any](parse func(string) (T, error)) {
+	if parse == nil {
+		panic("qcl: nil parser")
+	}
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	parsersMu.Lock()
+	defer parsersMu.Unlock()
+	parsers[typ] = func(s string) (any, error) {
+		return parse(s)
+	}
+}
+
+// lookupParser returns the parser of the type, if there is one.
+func lookupParser(typ reflect.Type) (func(string) (any, error), bool) {
+	parsersMu.RLock()
+	defer parsersMu.RUnlock()
+	parse, ok := parsers[typ]
+	return parse, ok
+}
+
+// flagValue returns v as a flag.Value, if a pointer to it implements one, so that custom flag types keep working
+// when their flags are defined by UseFlags, and can be set by the other sources too.
+func flagValue(v reflect.Value) (flag.Value, bool) {
+	if !v.CanAddr() {
+		return nil, false
+	}
+	fv, ok := v.Addr().Interface().(flag.Value)
+	return fv, ok
+}
+
+// protoSetter returns a function that sets v from a string if v is one of the protobuf well-known types that represent
+// a single value: the wrapper types (wrapperspb.StringValue, wrapperspb.Int32Value, ...), durationpb.Duration and
+// timestamppb.Timestamp. They are detected by their shape so that the protobuf module isn't a dependency. Wrappers are
+// set like their Value field, durations are parsed with time.ParseDuration and timestamps as RFC 3339.
+func protoSetter(v reflect.Value) (func(string) error, bool) {
+	typ := v.Type()
+	if typ.Kind() != reflect.Struct {
+		return nil, false
+	}
+	switch {
+	case strings.HasSuffix(typ.Name(), "Value") && hasProtoFields(typ, "Value"):
+		inner := v.FieldByName("Value")
+		if inner.Kind() == reflect.Slice && inner.Type().Elem().Kind() == reflect.Uint8 { // BytesValue
+			return func(s string) error {
+				inner.SetBytes([]byte(s))
+				return nil
+			}, true
+		}
+		return func(s string) error {
+			return defaultParseOptions.setField(inner, s)
+		}, true
+	case typ.Name() == "Duration" && hasProtoFields(typ, "Seconds", "Nanos"):
+		return func(s string) error {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return err
+			}
+			v.FieldByName("Seconds").SetInt(int64(d / time.Second))
+			v.FieldByName("Nanos").SetInt(int64(d % time.Second))
+			return nil
+		}, true
+	case typ.Name() == "Timestamp" && hasProtoFields(typ, "Seconds", "Nanos"):
+		return func(s string) error {
+			t, err := time.Parse(time.RFC3339Nano, s)
+			if err != nil {
+				return err
+			}
+			v.FieldByName("Seconds").SetInt(t.Unix())
+			v.FieldByName("Nanos").SetInt(int64(t.Nanosecond()))
+			return nil
+		}, true
+	}
+	return nil, false
+}
+
+// hasProtoFields reports whether the exported fields of the struct type are exactly the named fields, and they all
+// carry a protobuf struct tag.
+func hasProtoFields(typ reflect.Type, names ...string) bool {
+	exported := 0
+	for i := 0; i < typ.NumField(); i++ {
+		if typ.Field(i).IsExported() {
+			exported++
+		}
+	}
+	if exported != len(names) {
+		return false
+	}
+	for _, name := range names {
+		field, ok := typ.FieldByName(name)
+		if !ok || field.Tag.Get("protobuf") == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// tagName returns the name given by a struct tag value. That's usually the first comma separated element, as in
+// `env:"HOST,required"`, but protobuf tags carry the name in a name= element, as in `protobuf:"bytes,1,opt,name=host"`.
+func tagName(tag string) string {
+	parts := strings.Split(tag, ",")
+	for _, part := range parts[1:] {
+		if strings.HasPrefix(part, "name=") {
+			return strings.TrimPrefix(part, "name=")
+		}
+	}
+	return strings.TrimSpace(parts[0])
+}
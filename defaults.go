@@ -0,0 +1,109 @@
+package qcl
+
+import "reflect"
+
+// applyTagDefaults sets the fields of the struct val that are tagged with a default, like `default:"8080"`, and are
+// still zero, parsing the tag as the environment loader parses values, with iterables separated by a comma. Nil
+// pointers to structs are allocated if any of their fields has a default, unless their type is already being walked,
+// so recursive types end. pathPrefix is the dotted path of val.
+func applyTagDefaults(val reflect.Value, pathPrefix string, walking map[reflect.Type]bool) error {
+	typ := val.Type()
+	walking[typ] = true
+	defer delete(walking, typ)
+
+	var errs []error
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		if !sf.IsExported() || skipField(sf) {
+			continue
+		}
+		v := val.Field(i)
+		path := sf.Name
+		if pathPrefix != "" {
+			path = pathPrefix + "." + sf.Name
+		}
+		elem := sf.Type
+		if elem.Kind() == reflect.Ptr {
+			elem = elem.Elem()
+		}
+		if elem.Kind() == reflect.Struct && !isLeafStruct(elem) {
+			if v.Kind() == reflect.Ptr {
+				if v.IsNil() {
+					if walking[elem] || !hasTagDefaults(elem, map[reflect.Type]bool{}) {
+						continue
+					}
+					v.Set(reflect.New(elem))
+				}
+				v = v.Elem()
+			}
+			if sf.Anonymous {
+				path = pathPrefix
+			}
+			if err := applyTagDefaults(v, path, walking); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		value, ok := tagDefault(sf)
+		if !ok || !v.IsZero() {
+			continue
+		}
+		if err := defaultParseOptions.setField(v, value); err != nil {
+			errs = append(errs, &FieldError{Path: path, Key: "default", RawValue: value, Err: err})
+			continue
+		}
+		markDefault(v)
+	}
+	return joinErrors(errs)
+}
+
+// tagDefault returns the default the field is tagged with, either by the `default` tag or the default option of the
+// `qcl` tag, and whether it has one.
+func tagDefault(sf reflect.StructField) (string, bool) {
+	if value, ok := sf.Tag.Lookup("default"); ok {
+		return value, true
+	}
+	tag := qclTag(sf)
+	return tag.defaultValue, tag.hasDefault
+}
+
+// hasTagDefaults reports whether any field of the struct type, or of the structs nested in it, is tagged with a
+// default. Types already being walked are skipped, so recursive types end.
+func hasTagDefaults(typ reflect.Type, walking map[reflect.Type]bool) bool {
+	if walking[typ] {
+		return false
+	}
+	walking[typ] = true
+	defer delete(walking, typ)
+
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		if !sf.IsExported() || skipField(sf) {
+			continue
+		}
+		if _, ok := tagDefault(sf); ok {
+			return true
+		}
+		elem := sf.Type
+		if elem.Kind() == reflect.Ptr {
+			elem = elem.Elem()
+		}
+		if elem.Kind() == reflect.Struct && !isLeafStruct(elem) && hasTagDefaults(elem, walking) {
+			return true
+		}
+	}
+	return false
+}
+
+// defaulter is implemented by configs, and structs nested in them, with defaults that can't be written as tags, like
+// maps or values computed from others.
+type defaulter interface {
+	SetDefaults()
+}
+
+// callDefaultHooks calls the SetDefaults method of v, if it has one, and of the fields nested in it.
+func callDefaultHooks(v reflect.Value) {
+	walkHooks(v, "", false, func(hook defaulter, _ string) {
+		hook.SetDefaults()
+	})
+}
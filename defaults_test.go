@@ -0,0 +1,136 @@
+package qcl
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type TestDefaultsConfig struct {
+	Host    string        `default:"localhost"`
+	Port    int           `default:"8080"`
+	Timeout time.Duration `default:"30s"`
+	Tags    []string      `default:"a,b,c"`
+	Name    string
+	DB      *struct {
+		User string `default:"admin"`
+	}
+}
+
+type TestRecursiveDefaultsConfig struct {
+	Name string `default:"node"`
+	Next *TestRecursiveDefaultsConfig
+}
+
+func Test_applyTagDefaults(t *testing.T) {
+	tests := map[string]struct {
+		defaults *TestDefaultsConfig
+		env      map[string]string
+		want     TestDefaultsConfig
+	}{
+		"tag defaults": {
+			defaults: &TestDefaultsConfig{},
+			want: TestDefaultsConfig{Host: "localhost", Port: 8080, Timeout: 30 * time.Second, Tags: []string{"a", "b", "c"},
+				DB: &struct {
+					User string `default:"admin"`
+				}{User: "admin"}},
+		},
+		"default config wins": {
+			defaults: &TestDefaultsConfig{Host: "example.com", Tags: []string{}},
+			want: TestDefaultsConfig{Host: "example.com", Port: 8080, Timeout: 30 * time.Second, Tags: []string{},
+				DB: &struct {
+					User string `default:"admin"`
+				}{User: "admin"}},
+		},
+		"sources win": {
+			defaults: &TestDefaultsConfig{},
+			env:      map[string]string{"TEST_PORT": "9090", "TEST_DB_USER": "root"},
+			want: TestDefaultsConfig{Host: "localhost", Port: 9090, Timeout: 30 * time.Second, Tags: []string{"a", "b", "c"},
+				DB: &struct {
+					User string `default:"admin"`
+				}{User: "root"}},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			for key, value := range test.env {
+				t.Setenv(key, value)
+			}
+			got, err := Load(test.defaults, UseEnv(WithEnvPrefix("TEST")))
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if !reflect.DeepEqual(*got, test.want) {
+				t.Errorf("Load() = %+v, want %+v", *got, test.want)
+			}
+		})
+	}
+	t.Run("sources replace iterables", func(t *testing.T) {
+		type config struct {
+			Hosts  []string       `default:"a,b"`
+			Labels map[string]int `default:"x=1,y=2"`
+		}
+		got, err := Load(&config{}, UseEnv(WithEnviron(map[string]string{"HOSTS": "c", "LABELS": "z=3"})))
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		if want := (config{Hosts: []string{"c"}, Labels: map[string]int{"z": 3}}); !reflect.DeepEqual(*got, want) {
+			t.Errorf("Load() = %+v, want %+v", *got, want)
+		}
+	})
+	t.Run("recursive type", func(t *testing.T) {
+		var got TestRecursiveDefaultsConfig
+		if err := applyTagDefaults(reflect.ValueOf(&got).Elem(), "", map[reflect.Type]bool{}); err != nil {
+			t.Fatalf("applyTagDefaults() error = %v", err)
+		}
+		if want := (TestRecursiveDefaultsConfig{Name: "node"}); !reflect.DeepEqual(got, want) {
+			t.Errorf("applyTagDefaults() = %+v, want %+v", got, want)
+		}
+	})
+	t.Run("invalid default", func(t *testing.T) {
+		_, err := Load(&struct {
+			Port int `default:"http"`
+		}{}, UseCustom("test", func(any) error { return nil }))
+		var fieldErr *FieldError
+		if !errors.As(err, &fieldErr) || fieldErr.Path != "Port" || fieldErr.RawValue != "http" {
+			t.Errorf("Load() error = %v, want a FieldError for Port", err)
+		}
+	})
+}
+
+type TestDefaulterConfig struct {
+	Port    int `default:"8080"`
+	Addr    string
+	Limits  map[string]int
+	Limiter *TestLimiterConfig
+}
+
+func (c *TestDefaulterConfig) SetDefaults() {
+	if c.Addr == "" {
+		c.Addr = fmt.Sprintf(":%d", c.Port)
+	}
+}
+
+type TestLimiterConfig struct {
+	Limits map[string]int
+}
+
+func (c *TestLimiterConfig) SetDefaults() {
+	if c.Limits == nil {
+		c.Limits = map[string]int{"rps": 100}
+	}
+}
+
+func Test_callDefaultHooks(t *testing.T) {
+	t.Setenv("TEST_LIMITER_LIMITS", "burst=10")
+	got, err := Load(&TestDefaulterConfig{Limiter: &TestLimiterConfig{}}, UseEnv(WithEnvPrefix("TEST"), WithEnvSeparator(",")))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	want := &TestDefaulterConfig{Port: 8080, Addr: ":8080", Limiter: &TestLimiterConfig{Limits: map[string]int{"burst": 10}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Load() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,170 @@
+package qcl
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// A Diagnostic is a non-fatal finding reported while loading configuration, such as a value that looks like a leaked
+// credential. Diagnostics never make Load fail.
+type Diagnostic struct {
+	Field   string // Field is the dotted path of the field the diagnostic is about, e.g. "DB.Host", if any.
+	Message string // Message describes the finding.
+}
+
+func (d Diagnostic) String() string {
+	if d.Field == "" {
+		return d.Message
+	}
+	return fmt.Sprintf("%s: %s", d.Field, d.Message)
+}
+
+// A SecretDetector inspects a value and, if it looks like a credential, returns a short description of why.
+type SecretDetector func(value string) (reason string, ok bool)
+
+// DefaultSecretDetectors are the detectors used by WithSecretScanner when none are given. They look for AWS access key
+// IDs, PEM encoded keys and certificates, well-known API token formats, and long high-entropy strings.
+var DefaultSecretDetectors = []SecretDetector{
+	DetectPattern(regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`), "looks like an AWS access key ID"),
+	DetectPattern(regexp.MustCompile(`-----BEGIN [A-Z ]+-----`), "contains a PEM block"),
+	DetectPattern(regexp.MustCompile(`\b(gh[pousr]_[0-9A-Za-z]{36}|xox[abposr]-[0-9A-Za-z-]{10,})\b`), "looks like an API token"),
+	DetectHighEntropy(20, 4.0),
+}
+
+// DetectPattern returns a SecretDetector that reports values matching the regular expression, giving reason as the
+// reason.
+func DetectPattern(pattern *regexp.Regexp, reason string) SecretDetector {
+	return func(value string) (string, bool) {
+		return reason, pattern.MatchString(value)
+	}
+}
+
+// DetectHighEntropy returns a SecretDetector that reports values of at least minLength characters, without whitespace,
+// whose Shannon entropy is at least minBits bits per character. Random tokens and passwords typically score above 4
+// bits per character, while words, hostnames and paths score well below.
+func DetectHighEntropy(minLength int, minBits float64) SecretDetector {
+	return func(value string) (string, bool) {
+		if len(value) < minLength || strings.ContainsAny(value, " \t\n") {
+			return "", false
+		}
+		counts := make(map[rune]int)
+		n := 0
+		for _, r := range value {
+			counts[r]++
+			n++
+		}
+		entropy := 0.0
+		for _, count := range counts {
+			p := float64(count) / float64(n)
+			entropy -= p * math.Log2(p)
+		}
+		return fmt.Sprintf("has high entropy (%.1f bits per character)", entropy), entropy >= minBits
+	}
+}
+
+// WithDiagnostics registers a function that receives every Diagnostic reported while loading. Without it,
+// diagnostics are written to the standard logger.
+//
+// Example:
+//
+//	qcl.Load(&defaultConfig, qcl.WithSecretScanner(), qcl.WithDiagnostics(func(d qcl.Diagnostic) {
+//		slog.Warn("config diagnostic", "field", d.Field, "message", d.Message)
+//	}))
+func WithDiagnostics(fn func(Diagnostic)) LoadOption {
+	return func(o *LoadConfig) {
+		o.diagnostics = fn
+	}
+}
+
+// WithSecretScanner enables an opt-in pass that runs after all sources have loaded, and reports a Diagnostic for
+// every field that isn't tagged `secret:"true"` but holds a value that looks like a credential. It helps teams catch
+// secrets that leak through the wrong channel, e.g. a token pasted into a plain environment variable. String fields,
+// and strings in slices and maps, are checked with the given detectors, or DefaultSecretDetectors if none are given.
+//
+// Example:
+//
+//	type Config struct {
+//		APIKey string `secret:"true"` // not scanned
+//		Host   string                 // scanned
+//	}
+//
+//	qcl.Load(&defaultConfig, qcl.WithSecretScanner())
+//
+// The scanner is a heuristic, so it can report false positives. It never makes Load fail.
+func WithSecretScanner(detectors ...SecretDetector) LoadOption {
+	if len(detectors) == 0 {
+		detectors = DefaultSecretDetectors
+	}
+	return func(o *LoadConfig) {
+		o.secretDetectors = detectors
+	}
+}
+
+// report delivers a diagnostic to the registered function, or the standard logger if there isn't one.
+func (c *LoadConfig) report(d Diagnostic) {
+	if c.diagnostics != nil {
+		c.diagnostics(d)
+		return
+	}
+	log.Printf("qcl: %s", d)
+}
+
+// scanSecrets runs the secret detectors over every field of the config that isn't tagged as secret or redacted.
+func (c *LoadConfig) scanSecrets(config any) {
+	if len(c.secretDetectors) == 0 {
+		return
+	}
+	for _, f := range leafFields(config) {
+		if f.secret || c.redact != nil && c.redact(f.name()) {
+			continue
+		}
+		for _, value := range stringValues(f.value) {
+			for _, detect := range c.secretDetectors {
+				if reason, ok := detect(value); ok {
+					c.report(Diagnostic{
+						Field:   f.name(),
+						Message: fmt.Sprintf("value %s but the field isn't tagged secret", reason),
+					})
+					break
+				}
+			}
+		}
+	}
+}
+
+// stringValues returns the strings held by v, which may be a string, or a pointer, slice, array, map or Value of
+// strings.
+func stringValues(v reflect.Value) []string {
+	if v.IsValid() && v.CanInterface() {
+		if w, ok := v.Interface().(wrapper); ok {
+			return stringValues(reflect.ValueOf(w.unwrap()))
+		}
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return []string{v.String()}
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		return stringValues(v.Elem())
+	case reflect.Slice, reflect.Array:
+		values := make([]string, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			values = append(values, stringValues(v.Index(i))...)
+		}
+		return values
+	case reflect.Map:
+		values := make([]string, 0, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			values = append(values, stringValues(iter.Value())...)
+		}
+		return values
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package qcl
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// systemConfigDir is the directory holding system-wide configuration, a variable so tests can replace it.
+var systemConfigDir = "/etc"
+
+// UseDiscoveredConfigFile enables loading configuration from a file found in the standard places, the way most
+// daemons locate their configuration. In order of precedence, it looks for:
+//
+//	./<app>.<ext>                         in the working directory
+//	<user config dir>/<app>/config.<ext>  $XDG_CONFIG_HOME, or ~/.config, on Linux; see os.UserConfigDir
+//	/etc/<app>/config.<ext>
+//
+// where <ext> is the extension of any supported format, tried in alphabetical order. The first file found is loaded
+// as by UseFile; with WithFileMergeDiscovered, all of them are, layered as by UseLayeredFiles so that more specific
+// files override less specific ones. The search happens each time the config is loaded, and finding no file isn't an
+// error. The source is named "discovered:" followed by the app name.
+//
+// Example:
+//
+//	qcl.Load(&defaultConfig, qcl.UseDiscoveredConfigFile("myapp"), qcl.UseEnv(), qcl.UseFlags())
+func UseDiscoveredConfigFile(appName string, opts ...fileOption) LoadOption {
+	fileConf := fileConfig{separator: ","}
+	for _, opt := range opts {
+		opt(&fileConf)
+	}
+	name := "discovered:" + appName
+	return func(o *LoadConfig) {
+		o.Sources = append(o.Sources, name)
+		o.Loaders[name] = loadFromDiscoveredFile(appName, fileConf.forLoad(o, name))
+		o.watchers = append(o.watchers, watchFiles(func() []string { return discoveryCandidates(appName) }, fileConf.watchInterval))
+	}
+}
+
+// WithFileMergeDiscovered makes UseDiscoveredConfigFile load every file it finds, instead of only the first.
+func WithFileMergeDiscovered() fileOption {
+	return func(c *fileConfig) {
+		c.mergeDiscovered = true
+	}
+}
+
+func loadFromDiscoveredFile(appName string, fileConf *fileConfig) Loader {
+	return func(config any) error {
+		paths := discoverConfigFiles(appName)
+		if len(paths) == 0 {
+			return nil
+		}
+		if !fileConf.mergeDiscovered {
+			return loadFromFile(paths[0], fileConf)(config)
+		}
+		layers := make([]string, len(paths)) // least specific first
+		for i, path := range paths {
+			layers[len(paths)-1-i] = path
+		}
+		return loadFromLayeredFiles(layers, fileConf)(config)
+	}
+}
+
+// discoverConfigFiles returns the config files found for the app, most specific first.
+func discoverConfigFiles(appName string) []string {
+	var paths []string
+	for _, dir := range discoveryDirs(appName) {
+		for _, path := range dir {
+			if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+				paths = append(paths, path)
+				break
+			}
+		}
+	}
+	return paths
+}
+
+// discoveryCandidates returns every path a config file for the app may be discovered at.
+func discoveryCandidates(appName string) []string {
+	var paths []string
+	for _, dir := range discoveryDirs(appName) {
+		paths = append(paths, dir...)
+	}
+	return paths
+}
+
+// discoveryDirs returns the paths a config file for the app may be discovered at, grouped by directory, most specific
+// first.
+func discoveryDirs(appName string) [][]string {
+	candidates := []struct{ dir, base string }{{".", appName}}
+	if dir, err := os.UserConfigDir(); err == nil {
+		candidates = append(candidates, struct{ dir, base string }{filepath.Join(dir, appName), "config"})
+	}
+	candidates = append(candidates, struct{ dir, base string }{filepath.Join(systemConfigDir, appName), "config"})
+
+	dirs := make([][]string, len(candidates))
+	for i, c := range candidates {
+		for _, ext := range sortedKeys(fileExtensions) {
+			dirs[i] = append(dirs[i], filepath.Join(c.dir, c.base+ext))
+		}
+	}
+	return dirs
+}
@@ -0,0 +1,77 @@
+package qcl
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_UseDiscoveredConfigFile(t *testing.T) {
+	root := t.TempDir()
+	cwd, userDir, systemDir := filepath.Join(root, "cwd"), filepath.Join(root, "home"), filepath.Join(root, "etc")
+	files := map[string]string{
+		filepath.Join(cwd, "myapp.ini"):                 "name = cwd\n",
+		filepath.Join(userDir, "myapp", "config.hcl"):   "name = \"user\"\nhosts = [\"user\"]\n",
+		filepath.Join(systemDir, "myapp", "config.ini"): "name = system\nhosts = system\n[database]\nhost = db\n",
+	}
+	for path, data := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(root, "empty"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+	defer func(dir string) { systemConfigDir = dir }(systemConfigDir)
+	systemConfigDir = systemDir
+	t.Setenv("XDG_CONFIG_HOME", userDir)
+	t.Setenv("HOME", userDir)
+
+	tests := map[string]struct {
+		dir       string
+		opts      []fileOption
+		wantName  string
+		wantHosts []string
+		wantDB    bool
+	}{
+		"first found": {dir: cwd, wantName: "cwd"},
+		"merged":      {dir: cwd, opts: []fileOption{WithFileMergeDiscovered()}, wantName: "cwd", wantHosts: []string{"user"}, wantDB: true},
+		"user config": {dir: root, wantName: "user", wantHosts: []string{"user"}},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := os.Chdir(test.dir); err != nil {
+				t.Fatal(err)
+			}
+			got, err := Load(&TestFileConfig{}, UseDiscoveredConfigFile("myapp", test.opts...))
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if got.Name != test.wantName || !reflect.DeepEqual(got.Hosts, test.wantHosts) || (got.DB != nil) != test.wantDB {
+				t.Errorf("Load() = %+v, want Name %q, Hosts %v and DB set %v", got, test.wantName, test.wantHosts, test.wantDB)
+			}
+		})
+	}
+	t.Run("not found", func(t *testing.T) {
+		if err := os.Chdir(filepath.Join(root, "empty")); err != nil {
+			t.Fatal(err)
+		}
+		got, err := Load(&TestFileConfig{Name: "default"}, UseDiscoveredConfigFile("otherapp"))
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		if got.Name != "default" {
+			t.Errorf("Load() Name = %q, want %q", got.Name, "default")
+		}
+	})
+}
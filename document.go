@@ -0,0 +1,141 @@
+package qcl
+
+import (
+	"reflect"
+	"strings"
+)
+
+// A DocumentOption configures how Document describes a config.
+type DocumentOption func(*documentConfig)
+
+type documentConfig struct {
+	envPrefix    string
+	envStructTag string
+}
+
+// WithDocumentEnvPrefix sets the prefix of the environment variables Document lists, as WithEnvPrefix does for
+// UseEnv.
+func WithDocumentEnvPrefix(prefix string) DocumentOption {
+	return func(c *documentConfig) {
+		if prefix != "" && !strings.HasSuffix(prefix, "_") {
+			prefix += "_"
+		}
+		c.envPrefix = prefix
+	}
+}
+
+// WithDocumentEnvStructTag sets the struct tag naming the environment variables Document lists, as WithEnvStructTag
+// does for UseEnv.
+func WithDocumentEnvStructTag(tag string) DocumentOption {
+	return func(c *documentConfig) {
+		c.envStructTag = tag
+	}
+}
+
+// Document renders a Markdown table of every field of the config, a struct or a pointer to one, in the order they're
+// declared, with the environment variable and the flag that set it, its type, its default and its description, from
+// its `usage` struct tag, so that the configuration section of a README can be generated rather than drift from the
+// code:
+//
+//	type Config struct {
+//		Host string        `usage:"the address to listen on"`
+//		Wait time.Duration `default:"5s" usage:"how long to wait for connections to drain"`
+//	}
+//
+//	doc, err := qcl.Document(&Config{Host: "localhost"}, qcl.WithDocumentEnvPrefix("MYAPP"))
+//
+// renders:
+//
+//	| Field | Environment Variable | Flag | Type | Default | Description |
+//	| --- | --- | --- | --- | --- | --- |
+//	| `Host` | `MYAPP_HOST` | `-host` | `string` | `localhost` | the address to listen on |
+//	| `Wait` | `MYAPP_WAIT` | `-wait` | `time.Duration` | `5s` | how long to wait for connections to drain |
+//
+// Defaults are the values of the config, after the default tags and SetDefaults methods are applied, and are
+// rendered as they'd be written in an environment variable; those of secret fields are replaced with RedactedValue.
+// Interface fields with registered implementations are documented with the implementation they hold, after the key
+// naming it. Document returns ConfigTypeError if the config isn't a struct or a pointer to one.
+func Document(config any, opts ...DocumentOption) (string, error) {
+	docConf := documentConfig{envStructTag: defaultEnvConfig.structTag}
+	for _, opt := range opts {
+		opt(&docConf)
+	}
+	val, err := structCopy(config)
+	if err != nil {
+		return "", err
+	}
+	if err := applyTagDefaults(val, "", map[reflect.Type]bool{}); err != nil {
+		return "", err
+	}
+	callDefaultHooks(val.Addr())
+
+	var rows []documentRow
+	index := make(map[string]int)
+	add := func(v reflect.Value, path string) *documentRow {
+		if i, ok := index[path]; ok {
+			return &rows[i]
+		}
+		index[path] = len(rows)
+		rows = append(rows, documentRow{path: path, value: v})
+		return &rows[len(rows)-1]
+	}
+	err = walkEnv(val, val.Type(), docConf.envPrefix, "", envNaming{structTag: docConf.envStructTag}, nil, func(v reflect.Value, path, key string) error {
+		add(v, path).env = key
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	err = walkFlags(val, val.Type(), "", "", nil, func(v reflect.Value, path, flagName string) error {
+		add(v, path).flag = "-" + flagName
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	fields := make(map[string]field)
+	for _, f := range leafFields(val.Addr().Interface()) {
+		fields[f.name()] = f
+	}
+
+	var b strings.Builder
+	b.WriteString("| Field | Environment Variable | Flag | Type | Default | Description |\n")
+	b.WriteString("| --- | --- | --- | --- | --- | --- |\n")
+	for _, row := range rows {
+		defaultValue := dumpText(row.value)
+		if fields[row.path].secret {
+			defaultValue = RedactedValue
+		}
+		cells := []string{
+			markdownCode(row.path),
+			markdownCode(row.env),
+			markdownCode(row.flag),
+			markdownCode(row.value.Type().String()),
+			markdownCode(defaultValue),
+			markdownText(fieldUsage(val, fields, row.path)),
+		}
+		b.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+	}
+	return b.String(), nil
+}
+
+// documentRow is a row of the table Document renders.
+type documentRow struct {
+	path  string        // path is the dotted path of the field.
+	value reflect.Value // value is the field's value, whose type and default are documented.
+	env   string        // env is the environment variable that sets the field, if any.
+	flag  string        // flag is the flag that sets the field, with its dash, if any.
+}
+
+// markdownCode renders s as code in a cell of a Markdown table, or nothing if it's empty.
+func markdownCode(s string) string {
+	if s == "" {
+		return ""
+	}
+	return "`" + markdownText(s) + "`"
+}
+
+// markdownText escapes s for a cell of a Markdown table, which can't hold pipes or line breaks.
+func markdownText(s string) string {
+	return strings.NewReplacer("|", `\|`, "\r\n", " ", "\n", " ").Replace(s)
+}
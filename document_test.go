@@ -0,0 +1,53 @@
+package qcl
+
+import (
+	"testing"
+	"time"
+)
+
+type TestDocumentConfig struct {
+	Host     string        `usage:"the address to listen on"`
+	Wait     time.Duration `default:"5s" usage:"how long to wait | at most"`
+	Password string        `secret:"true"`
+	DB       struct {
+		Port int `env:"DATABASE_PORT" flag:"db-port"`
+	}
+	Storage TestStorage
+}
+
+func Test_Document(t *testing.T) {
+	tests := map[string]struct {
+		config  any
+		opts    []DocumentOption
+		want    string
+		wantErr error
+	}{
+		"fields": {
+			config: &TestDocumentConfig{Host: "localhost", Password: "hunter2", Storage: &TestDiskStorage{Path: "/var/data"}},
+			opts:   []DocumentOption{WithDocumentEnvPrefix("MYAPP")},
+			want: "| Field | Environment Variable | Flag | Type | Default | Description |\n" +
+				"| --- | --- | --- | --- | --- | --- |\n" +
+				"| `Host` | `MYAPP_HOST` | `-host` | `string` | `localhost` | the address to listen on |\n" +
+				"| `Wait` | `MYAPP_WAIT` | `-wait` | `time.Duration` | `5s` | how long to wait \\| at most |\n" +
+				"| `Password` | `MYAPP_PASSWORD` | `-password` | `string` | `[REDACTED]` |  |\n" +
+				"| `DB.Port` | `MYAPP_DB_DATABASE_PORT` | `-db.db-port` | `int` | `0` |  |\n" +
+				"| `Storage` | `MYAPP_STORAGE_TYPE` | `-storage.type` | `string` | `disk` | the implementation, one of disk, s3 |\n" +
+				"| `Storage.Path` | `MYAPP_STORAGE_PATH` | `-storage.path` | `string` | `/var/data` |  |\n",
+		},
+		"not a struct": {
+			config:  "config",
+			wantErr: ConfigTypeError,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := Document(test.config, test.opts...)
+			if err != test.wantErr {
+				t.Fatalf("Document() error = %v, want %v", err, test.wantErr)
+			}
+			if got != test.want {
+				t.Errorf("Document() =\n%s\nwant\n%s", got, test.want)
+			}
+		})
+	}
+}
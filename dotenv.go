@@ -0,0 +1,74 @@
+package qcl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// decodeDotenv decodes a dotenv file into the variables it sets, by name. Each line is "KEY=VALUE", optionally
+// preceded by "export ". Lines starting with # are comments, as is the rest of an unquoted value from a # preceded by
+// a space. A value wrapped in double quotes is unquoted as a Go string literal, so it may contain escapes like \n, and
+// one wrapped in single quotes is taken as is; either may be followed by a # comment. A repeated key overrides the
+// earlier one.
+func decodeDotenv(text string) (map[string]string, error) {
+	vars := make(map[string]string)
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		sep := strings.Index(line, "=")
+		if sep <= 0 {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE, found %q", i+1, line)
+		}
+		key, value := strings.TrimSpace(line[:sep]), strings.TrimSpace(line[sep+1:])
+		if strings.ContainsAny(key, " \t") {
+			return nil, fmt.Errorf("line %d: invalid variable name %q", i+1, key)
+		}
+		switch {
+		case strings.HasPrefix(value, `"`), strings.HasPrefix(value, "'"):
+			unquoted, err := unquoteDotenv(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			value = unquoted
+		default:
+			if comment := strings.Index(value, " #"); comment >= 0 {
+				value = strings.TrimSpace(value[:comment])
+			}
+		}
+		vars[key] = value
+	}
+	return vars, nil
+}
+
+// unquoteDotenv returns the value quoted at the start of s, which may only be followed by whitespace and a # comment.
+func unquoteDotenv(s string) (string, error) {
+	quote, end := s[0], -1
+	for i := 1; i < len(s); i++ {
+		if quote == '"' && s[i] == '\\' {
+			i++
+			continue
+		}
+		if s[i] == quote {
+			end = i
+			break
+		}
+	}
+	if end < 0 {
+		return "", fmt.Errorf("unterminated quoted value %s", s)
+	}
+	if rest := strings.TrimSpace(s[end+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+		return "", fmt.Errorf("unexpected %q after quoted value", rest)
+	}
+	if quote == '\'' {
+		return s[1:end], nil
+	}
+	value, err := strconv.Unquote(s[:end+1])
+	if err != nil {
+		return "", fmt.Errorf("invalid quoted value %s", s[:end+1])
+	}
+	return value, nil
+}
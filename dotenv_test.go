@@ -0,0 +1,52 @@
+package qcl
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_decodeDotenv(t *testing.T) {
+	tests := map[string]struct {
+		input   string
+		want    map[string]string
+		wantErr bool
+	}{
+		"variables": {
+			input: "# a comment\nHOST=localhost\n\nexport PORT = 8080\nHOST=override",
+			want:  map[string]string{"HOST": "override", "PORT": "8080"},
+		},
+		"quoted values": {
+			input: "A=\"hello world\"\nB='single \\n'\nC=\"line\\nbreak\"\nD=url=http://example.com",
+			want:  map[string]string{"A": "hello world", "B": `single \n`, "C": "line\nbreak", "D": "url=http://example.com"},
+		},
+		"comments": {
+			input: "A=value # a comment\nB=\"quoted # kept\"\nC=no#comment",
+			want:  map[string]string{"A": "value", "B": "quoted # kept", "C": "no#comment"},
+		},
+		"quoted with comment": {
+			input: "MYAPP_DB_PASSWORD=\"s3cret\" # quoted values may contain escapes\nB='single' # comment\nC=\"escaped \\\" quote\"",
+			want:  map[string]string{"MYAPP_DB_PASSWORD": "s3cret", "B": "single", "C": `escaped " quote`},
+		},
+		"empty value": {
+			input: "A=",
+			want:  map[string]string{"A": ""},
+		},
+		"missing separator":    {input: "just a line", wantErr: true},
+		"missing key":          {input: "=value", wantErr: true},
+		"invalid key":          {input: "A B=value", wantErr: true},
+		"unterminated double":  {input: "A=\"open", wantErr: true},
+		"unterminated single":  {input: "A='open", wantErr: true},
+		"trailing after quote": {input: "A=\"a\" b", wantErr: true},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := decodeDotenv(test.input)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("decodeDotenv() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if !test.wantErr && !reflect.DeepEqual(got, test.want) {
+				t.Errorf("decodeDotenv() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,302 @@
+package qcl
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A DumpOption configures how Dump renders a config.
+type DumpOption func(*dumpConfig)
+
+type dumpConfig struct {
+	format string
+	redact func(fieldPath string) bool // redact, if not nil, reports whether a field's value is redacted, besides secrets.
+}
+
+// redacts reports whether the field's value is replaced by RedactedValue.
+func (c dumpConfig) redacts(f field) bool {
+	return f.secret || c.redact != nil && c.redact(f.name())
+}
+
+// WithDumpFormat sets the format Dump renders the config in: "text", the default, with a `Field = value` line per
+// field, "json", or "yaml". Other formats render as text.
+//
+// Example:
+//
+//	log.Print(qcl.Dump(conf, qcl.WithDumpFormat("yaml")))
+func WithDumpFormat(format string) DumpOption {
+	return func(c *dumpConfig) {
+		c.format = format
+	}
+}
+
+// WithDumpRedactor redacts the values of the fields for whose dotted path, like "DB.Password", redact returns true,
+// besides those of fields tagged `secret:"true"`, for secrets a naming convention identifies rather than a tag. It's
+// the counterpart of WithRedactor, which redacts the values of load errors.
+//
+// Example:
+//
+//	redact := func(path string) bool { return strings.HasSuffix(path, "Token") }
+//	log.Print(qcl.Dump(conf, qcl.WithDumpRedactor(redact)))
+func WithDumpRedactor(redact func(fieldPath string) bool) DumpOption {
+	return func(c *dumpConfig) {
+		c.redact = redact
+	}
+}
+
+// Dump renders the fully-resolved config, a struct or a pointer to one, for logging at startup, with the values of
+// fields tagged `secret:"true"` replaced by RedactedValue. Fields are rendered in the order they are declared, with
+// embedded structs flattened into their parent.
+//
+// Example:
+//
+//	conf, err := qcl.Load(&defaultConfig)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	log.Printf("loaded config:\n%s", qcl.Dump(conf))
+//
+// prints something like:
+//
+//	Host = localhost
+//	Port = 8080
+//	DB.Password = [REDACTED]
+//
+// In text, values are rendered the way the environment loader parses them, with iterables separated by a comma. In
+// JSON and YAML, numbers and booleans are rendered as such, slices as lists, and structs and maps as objects, while
+// other values, like durations, are rendered as the strings they're parsed from.
+func Dump(config any, opts ...DumpOption) string {
+	var dumpConf dumpConfig
+	for _, opt := range opts {
+		opt(&dumpConf)
+	}
+	switch dumpConf.format {
+	case "json":
+		var b strings.Builder
+		dumpStruct(config, dumpConf).writeJSON(&b, "")
+		return b.String() + "\n"
+	case "yaml":
+		var b strings.Builder
+		dumpStruct(config, dumpConf).writeYAML(&b, "")
+		return b.String()
+	}
+
+	var b strings.Builder
+	for _, f := range leafFields(config) {
+		value := RedactedValue
+		if !dumpConf.redacts(f) {
+			value = dumpText(f.value)
+		}
+		b.WriteString(strings.TrimSuffix(f.name()+" = "+value, " ") + "\n")
+	}
+	return b.String()
+}
+
+// dumpText renders v the way the environment loader parses it.
+func dumpText(v reflect.Value) string {
+	if s, err := formatValue(v, ","); err == nil {
+		return s
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// dumpNode is a value rendered by Dump: a scalar, a list or an object.
+type dumpNode struct {
+	scalar string     // scalar is the JSON literal of a scalar value.
+	list   []dumpNode // list is the elements of a list.
+	keys   []string   // keys is the keys of an object, in order.
+	values []dumpNode // values is the values of an object, in the order of its keys.
+	kind   reflect.Kind
+}
+
+func dumpScalar(literal string) dumpNode {
+	return dumpNode{kind: reflect.String, scalar: literal}
+}
+
+func dumpObject() dumpNode {
+	return dumpNode{kind: reflect.Struct}
+}
+
+// set sets the value at the path of keys, creating the objects leading to it.
+func (n *dumpNode) set(path []string, value dumpNode) {
+	for i, key := range n.keys {
+		if key == path[0] {
+			if len(path) == 1 {
+				n.values[i] = value
+			} else {
+				n.values[i].set(path[1:], value)
+			}
+			return
+		}
+	}
+	if len(path) > 1 {
+		child := dumpObject()
+		child.set(path[1:], value)
+		value = child
+	}
+	n.keys, n.values = append(n.keys, path[0]), append(n.values, value)
+}
+
+// dumpStruct renders the struct config, or the struct it points to, as an object, with the fields dumpConf redacts
+// replaced by RedactedValue.
+func dumpStruct(config any, dumpConf dumpConfig) dumpNode {
+	node := dumpObject()
+	for _, f := range leafFields(config) {
+		if dumpConf.redacts(f) {
+			node.set(f.path, dumpScalar(jsonString(RedactedValue)))
+			continue
+		}
+		node.set(f.path, dumpValue(f.value))
+	}
+	return node
+}
+
+// dumpValue renders v, with numbers and booleans as such, slices as lists, structs and maps as objects, and other
+// values as the strings they're parsed from.
+func dumpValue(v reflect.Value) dumpNode {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return dumpScalar("null")
+		}
+		v = v.Elem()
+	}
+	if w, ok := v.Interface().(wrapper); ok {
+		return dumpValue(reflect.ValueOf(w.unwrap()))
+	}
+	_, custom := customSetter(v)
+	if _, ok := textMarshaler(v); ok || custom || v.Type() == reflect.TypeOf(time.Duration(0)) {
+		return dumpScalar(jsonString(dumpText(v)))
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		return dumpScalar(strconv.FormatBool(v.Bool()))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return dumpScalar(strconv.FormatInt(v.Int(), 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return dumpScalar(strconv.FormatUint(v.Uint(), 10))
+	case reflect.Float32, reflect.Float64:
+		if f := v.Float(); !math.IsInf(f, 0) && !math.IsNaN(f) {
+			return dumpScalar(strconv.FormatFloat(f, 'g', -1, v.Type().Bits()))
+		}
+	case reflect.Slice, reflect.Array:
+		node := dumpNode{kind: reflect.Slice, list: []dumpNode{}}
+		for i := 0; i < v.Len(); i++ {
+			node.list = append(node.list, dumpValue(v.Index(i)))
+		}
+		return node
+	case reflect.Map:
+		keys := make(map[string]reflect.Value, v.Len())
+		for _, k := range v.MapKeys() {
+			keys[dumpText(k)] = k
+		}
+		node := dumpObject()
+		for _, k := range sortedKeys(keys) {
+			node.keys, node.values = append(node.keys, k), append(node.values, dumpValue(v.MapIndex(keys[k])))
+		}
+		return node
+	case reflect.Struct:
+		return dumpStruct(v.Interface(), dumpConfig{})
+	}
+	return dumpScalar(jsonString(dumpText(v)))
+}
+
+// jsonString returns s as a JSON string literal, which is also a valid YAML scalar.
+func jsonString(s string) string {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s) // strings always encode
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// writeJSON writes the node as indented JSON, with nested lines indented by indent and two spaces.
+func (n dumpNode) writeJSON(b *strings.Builder, indent string) {
+	inner := indent + "  "
+	switch {
+	case n.kind == reflect.Slice && len(n.list) == 0:
+		b.WriteString("[]")
+	case n.kind == reflect.Slice:
+		b.WriteString("[\n")
+		for i, elem := range n.list {
+			b.WriteString(inner)
+			elem.writeJSON(b, inner)
+			if i < len(n.list)-1 {
+				b.WriteString(",")
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString(indent + "]")
+	case n.kind == reflect.Struct && len(n.keys) == 0:
+		b.WriteString("{}")
+	case n.kind == reflect.Struct:
+		b.WriteString("{\n")
+		for i, key := range n.keys {
+			b.WriteString(inner + jsonString(key) + ": ")
+			n.values[i].writeJSON(b, inner)
+			if i < len(n.keys)-1 {
+				b.WriteString(",")
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString(indent + "}")
+	default:
+		b.WriteString(n.scalar)
+	}
+}
+
+// writeYAML writes the node as the lines of a YAML block, each indented by indent. Scalars, and empty lists and
+// objects, which are written inline, are written with a newline.
+func (n dumpNode) writeYAML(b *strings.Builder, indent string) {
+	switch {
+	case n.kind == reflect.Slice && len(n.list) == 0:
+		b.WriteString("[]\n")
+	case n.kind == reflect.Struct && len(n.keys) == 0:
+		b.WriteString("{}\n")
+	case n.kind == reflect.Slice:
+		for _, elem := range n.list {
+			b.WriteString(indent + "-")
+			elem.writeYAMLValue(b, indent+"  ")
+		}
+	case n.kind == reflect.Struct:
+		for i, key := range n.keys {
+			b.WriteString(indent + yamlKey(key) + ":")
+			n.values[i].writeYAMLValue(b, indent+"  ")
+		}
+	default:
+		b.WriteString(n.scalar + "\n")
+	}
+}
+
+// writeYAMLValue writes the node after a key or list item marker: inline if it's a scalar or empty, or as an indented
+// block on the following lines otherwise.
+func (n dumpNode) writeYAMLValue(b *strings.Builder, indent string) {
+	if n.kind == reflect.String || len(n.list)+len(n.keys) == 0 {
+		b.WriteString(" ")
+		n.writeYAML(b, indent)
+		return
+	}
+	b.WriteString("\n")
+	n.writeYAML(b, indent)
+}
+
+// yamlKey returns the key as written in YAML: quoted, unless it starts with a letter or underscore, is made of letters,
+// digits, dots, dashes and underscores, and isn't a word YAML reads as a boolean or null.
+func yamlKey(key string) string {
+	switch strings.ToLower(key) {
+	case "", "y", "n", "yes", "no", "on", "off", "true", "false", "null":
+		return jsonString(key)
+	}
+	for i, r := range key {
+		letter := r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
+		if !letter && (i == 0 || !(r == '-' || r == '.' || r >= '0' && r <= '9')) {
+			return jsonString(key)
+		}
+	}
+	return key
+}
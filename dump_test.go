@@ -0,0 +1,125 @@
+package qcl
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type TestDumpConfig struct {
+	Host    string
+	Port    int
+	Debug   bool
+	Timeout time.Duration
+	Tags    []string
+	Limits  map[string]float64
+	DB      struct {
+		User     string
+		Password string `secret:"true"`
+	}
+	Empty []int
+	Owner *TestConfig
+}
+
+func Test_Dump(t *testing.T) {
+	config := &TestDumpConfig{
+		Host:    "localhost",
+		Port:    8080,
+		Debug:   true,
+		Timeout: 30 * time.Second,
+		Tags:    []string{"a", `b "quoted"`},
+		Limits:  map[string]float64{"rps": 1.5, "on": 2},
+	}
+	config.DB.User = "admin"
+	config.DB.Password = "hunter2"
+
+	tests := map[string]struct {
+		opts []DumpOption
+		want string
+	}{
+		"text": {
+			want: `Host = localhost
+Port = 8080
+Debug = true
+Timeout = 30s
+Tags = a,b "quoted"
+Limits = on=2,rps=1.5
+DB.User = admin
+DB.Password = [REDACTED]
+Empty =
+Owner =
+`,
+		},
+		"yaml": {
+			opts: []DumpOption{WithDumpFormat("yaml")},
+			want: `Host: "localhost"
+Port: 8080
+Debug: true
+Timeout: "30s"
+Tags:
+  - "a"
+  - "b \"quoted\""
+Limits:
+  "on": 2
+  rps: 1.5
+DB:
+  User: "admin"
+  Password: "[REDACTED]"
+Empty: []
+Owner: null
+`,
+		},
+		"unknown format": {
+			opts: []DumpOption{WithDumpFormat("toml")},
+			want: Dump(config),
+		},
+		"redactor": {
+			opts: []DumpOption{WithDumpFormat("yaml"), WithDumpRedactor(func(path string) bool { return path == "Host" || path == "DB.User" })},
+			want: `Host: "[REDACTED]"
+Port: 8080
+Debug: true
+Timeout: "30s"
+Tags:
+  - "a"
+  - "b \"quoted\""
+Limits:
+  "on": 2
+  rps: 1.5
+DB:
+  User: "[REDACTED]"
+  Password: "[REDACTED]"
+Empty: []
+Owner: null
+`,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := Dump(config, test.opts...); got != test.want {
+				t.Errorf("Dump() = %s, want %s", got, test.want)
+			}
+		})
+	}
+
+	t.Run("json", func(t *testing.T) {
+		var got map[string]any
+		if err := json.Unmarshal([]byte(Dump(config, WithDumpFormat("json"))), &got); err != nil {
+			t.Fatalf("Dump() isn't valid JSON: %v", err)
+		}
+		want := map[string]any{
+			"Host":    "localhost",
+			"Port":    8080.0,
+			"Debug":   true,
+			"Timeout": "30s",
+			"Tags":    []any{"a", `b "quoted"`},
+			"Limits":  map[string]any{"rps": 1.5, "on": 2.0},
+			"DB":      map[string]any{"User": "admin", "Password": RedactedValue},
+			"Empty":   []any{},
+			"Owner":   nil,
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Dump() = %v, want %v", got, want)
+		}
+	})
+}
@@ -0,0 +1,82 @@
+package qcl
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// normalizeEnums checks the fields tagged with the set of values they may take, like
+//
+//	type Config struct {
+//		LogLevel string `oneof:"debug,info,warn,error"`
+//	}
+//
+// returning a *ValidationError for every field whose value isn't in the set. String values are matched ignoring case
+// and replaced with the value as the tag spells it, so LOG_LEVEL=INFO sets "info". Other values must match exactly,
+// as they would be written in an environment variable. Each element of a slice is checked on its own. Zero values and
+// nil pointers aren't checked, since they're unset rather than invalid; that's what `required:"true"` is for.
+func normalizeEnums(config any) error {
+	var errs []error
+	for _, f := range leafFields(config) {
+		tag, ok := f.sf.Tag.Lookup("oneof")
+		if !ok {
+			continue
+		}
+		options := enumOptions(tag)
+		v := f.value
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				continue
+			}
+			v = v.Elem()
+		}
+		values := []reflect.Value{v}
+		if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+			values = values[:0]
+			for i := 0; i < v.Len(); i++ {
+				values = append(values, v.Index(i))
+			}
+		}
+		for _, value := range values {
+			if err := normalizeEnum(value, options); err != nil {
+				errs = append(errs, &ValidationError{Path: f.name(), Rule: "oneof", Err: err})
+				break
+			}
+		}
+	}
+	return joinErrors(errs)
+}
+
+// enumOptions returns the values of a `oneof` tag, which are separated by commas.
+func enumOptions(tag string) []string {
+	options := strings.Split(tag, ",")
+	for i, option := range options {
+		options[i] = strings.TrimSpace(option)
+	}
+	return options
+}
+
+// normalizeEnum checks that v is one of the options, setting it to the option's spelling if it's a string that
+// matches ignoring case.
+func normalizeEnum(v reflect.Value, options []string) error {
+	if v.IsZero() {
+		return nil
+	}
+	if v.Kind() == reflect.String {
+		for _, option := range options {
+			if strings.EqualFold(v.String(), option) {
+				v.SetString(option)
+				return nil
+			}
+		}
+	} else {
+		text := validationText(v.Interface())
+		for _, option := range options {
+			if text == option {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("must be one of %s", strings.Join(options, ", "))
+}
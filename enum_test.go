@@ -0,0 +1,81 @@
+package qcl
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type TestLogFormat string
+
+type TestEnumConfig struct {
+	Level    string          `oneof:"debug,info,warn,error"`
+	Format   *TestLogFormat  `oneof:"json, text"`
+	Outputs  []string        `oneof:"stdout,stderr,file"`
+	Workers  int             `oneof:"1,2,4,8"`
+	Optional string          `oneof:"a,b"`
+	Formats  []TestLogFormat `oneof:"json,text"`
+}
+
+func Test_normalizeEnums(t *testing.T) {
+	format := TestLogFormat("JSON")
+	tests := map[string]struct {
+		config  *TestEnumConfig
+		want    *TestEnumConfig
+		wantErr []string
+	}{
+		"normalized": {
+			config: &TestEnumConfig{Level: "INFO", Format: &format, Outputs: []string{"Stdout", "file"}, Workers: 4, Formats: []TestLogFormat{"Text"}},
+			want:   &TestEnumConfig{Level: "info", Format: ptr(TestLogFormat("json")), Outputs: []string{"stdout", "file"}, Workers: 4, Formats: []TestLogFormat{"text"}},
+		},
+		"unset": {
+			config: &TestEnumConfig{},
+			want:   &TestEnumConfig{},
+		},
+		"invalid": {
+			config:  &TestEnumConfig{Level: "verbose", Outputs: []string{"stdout", "syslog"}, Workers: 3},
+			wantErr: []string{"Level", "Outputs", "Workers"},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := normalizeEnums(test.config)
+			if test.wantErr == nil {
+				if err != nil {
+					t.Fatalf("normalizeEnums() error = %v", err)
+				}
+				if !reflect.DeepEqual(test.config, test.want) {
+					t.Errorf("normalizeEnums() = %+v, want %+v", test.config, test.want)
+				}
+				return
+			}
+			var paths []string
+			visitErrors(err, func(err error) {
+				if err, ok := err.(*ValidationError); ok {
+					paths = append(paths, err.Path)
+				}
+			})
+			if !reflect.DeepEqual(paths, test.wantErr) {
+				t.Errorf("normalizeEnums() error = %v, want errors for %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func Test_enumFields(t *testing.T) {
+	t.Setenv("TEST_LEVEL", "Warn")
+	got, err := Load(&TestEnumConfig{}, UseEnv(WithEnvPrefix("TEST")))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got.Level != "warn" {
+		t.Errorf("Load() Level = %q, want %q", got.Level, "warn")
+	}
+
+	t.Setenv("TEST_LEVEL", "verbose")
+	_, err = Load(&TestEnumConfig{}, UseEnv(WithEnvPrefix("TEST")))
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Error() != "invalid Level: must be one of debug, info, warn, error" {
+		t.Errorf("Load() error = %v, want the allowed values of Level", err)
+	}
+}
@@ -2,17 +2,36 @@ package qcl
 
 import (
 	"errors"
+	"fmt"
 	"os"
+	"path"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
+	"unicode"
 )
 
 const env = "env"
 
 type envConfig struct {
-	prefix    string
-	structTag string
-	separator string
+	prefix           string
+	structTag        string
+	separator        string
+	isoDurations     bool
+	fileIndirection  bool
+	caseSensitive    bool                               // caseSensitive keeps the prefix and the names given by struct tags as written.
+	emptyAsSet       bool                               // emptyAsSet applies variables that are set but empty, resetting their fields.
+	trimValues       bool                               // trimValues strips whitespace and a pair of matching quotes around values.
+	environ          map[string]string                  // environ, if not nil, is read instead of the process environment.
+	dotenv           string                             // dotenv, if not empty, is the path of the dotenv file that variables missing from the environment are read from.
+	allowlist        []string                           // allowlist, if not empty, holds the glob patterns of the only variables the source may read.
+	blocklist        []string                           // blocklist holds the glob patterns of variables the source may not read.
+	nestingDelimiter string                             // nestingDelimiter separates the names of nested fields, like the __ of DB__HOST, \"_\" if it's empty.
+	keyMapper        func(fieldPath []string) string    // keyMapper, if not nil, names the variable of each field instead of the default rules.
+	load             *LoadConfig                        // load is the load the source is part of, if any, for its WithStrict and WithScope settings.
+	record           func(path, key string)             // record, if not nil, receives the variable each field is set from, for provenance.
+	lookup           func(path, key string, found bool) // lookup, if not nil, receives every variable looked for a field, and whether it's set.
 }
 
 var defaultEnvConfig = &envConfig{
@@ -47,14 +66,19 @@ var (
 //
 // will set the value of FooBar to the value of the environment variable "FOO_BAR".
 func UseEnv(opts ...envOption) LoadOption {
-	envConf := defaultEnvConfig
+	envConf := *defaultEnvConfig
 
 	for _, opt := range opts {
-		opt(envConf)
+		opt(&envConf)
 	}
 	return func(o *LoadConfig) {
+		envConf := envConf
+		envConf.load = o
+		envConf.record = o.recorder(env)
+		envConf.lookup = o.lookupRecorder(env)
+		o.env = &envConf
 		o.Sources = append(o.Sources, env)
-		o.Loaders[env] = loadFromEnv(envConf)
+		o.Loaders[env] = loadFromEnv(&envConf)
 	}
 }
 
@@ -99,6 +123,160 @@ func WithEnvStructTag(tag string) envOption {
 	}
 }
 
+// WithEnvCaseSensitive makes the loader match environment variable names exactly as they're given, rather than in
+// upper case, for systems where mixed-case variables are meaningful. The prefix, and names given by the env struct tag
+// (or the one set with WithEnvStructTag), are used as written and aren't split on word boundaries; names derived from
+// field names are still split and upper-cased.
+//
+// Example:
+//
+//	WithEnvCaseSensitive()
+//
+//	type Config struct {
+//		Proxy string `env:"http_proxy"` // Proxy will be set by "http_proxy", not "HTTP_PROXY"
+//		Port  int                      // Port will still be set by "PORT"
+//	}
+func WithEnvCaseSensitive() envOption {
+	return func(c *envConfig) {
+		c.caseSensitive = true
+	}
+}
+
+// WithEmptyAsSet makes variables that are set but empty apply to their fields, resetting them to their zero values, so
+// that a default can be overridden with an empty string. By default, an empty variable is treated as if it were unset
+// and leaves its field as it is.
+//
+// Example:
+//
+//	export HOST=
+//
+//	type Config struct {
+//		Host string `default:"localhost"` // Host will be "" instead of "localhost"
+//	}
+//
+//	WithEmptyAsSet()
+func WithEmptyAsSet() envOption {
+	return func(c *envConfig) {
+		c.emptyAsSet = true
+	}
+}
+
+// WithEnvNestingDelimiter sets the delimiter between the names of nested fields, and after the prefix, so that nesting
+// can be told apart from the underscores between the words of a field name. For example, with "__", the field Host
+// of a nested struct DB is set by DB__HOST, while a field DBHost is still set by DB_HOST.
+//
+// Example:
+//
+//	WithEnvPrefix("TEST"), WithEnvNestingDelimiter("__")
+//
+//	type Config struct {
+//		DB struct {
+//			MaxConns int // MaxConns will be set by "TEST__DB__MAX_CONNS"
+//		}
+//	}
+//
+// The delimiter also separates the indexes of slices of structs and the keys of maps of structs, like
+// SERVERS__0__HOST. The default is "_".
+func WithEnvNestingDelimiter(delimiter string) envOption {
+	return func(c *envConfig) {
+		c.nestingDelimiter = delimiter
+	}
+}
+
+// WithEnvTrimValues makes the loader strip the whitespace around values, then a pair of matching single or double quotes
+// and the whitespace inside them, since values injected by orchestration tooling often carry stray quoting that
+// would otherwise end up in the field.
+//
+// Example:
+//
+//	export FOO='" bar "'
+//
+//	type Config struct {
+//		Foo string // Foo will be set to "bar" instead of `" bar "`
+//	}
+//
+//	WithEnvTrimValues()
+//
+// Values that are empty once trimmed, like a pair of quotes, are treated as empty variables, which apply only with
+// WithEmptyAsSet.
+func WithEnvTrimValues() envOption {
+	return func(c *envConfig) {
+		c.trimValues = true
+	}
+}
+
+// WithEnviron makes the loader read variables from environ, by name, instead of the process environment, so tests can
+// load in parallel without t.Setenv, and loading is hermetic in sandboxes. The map is copied, and a nil map is an empty
+// environment.
+//
+// Example:
+//
+//	qcl.Load(&defaultConfig, qcl.UseEnv(qcl.WithEnvPrefix("MYAPP"), qcl.WithEnviron(map[string]string{
+//		"MYAPP_HOST": "localhost",
+//		"MYAPP_PORT": "8080",
+//	})))
+func WithEnviron(environ map[string]string) envOption {
+	return func(c *envConfig) {
+		c.environ = make(map[string]string, len(environ))
+		for key, value := range environ {
+			c.environ[key] = value
+		}
+	}
+}
+
+// WithDotenvFallback makes the loader fill the variables missing from the environment from the dotenv file at path,
+// like .env, so that settings for local development can live in a file while the real environment, as set in a
+// deployment, still wins. A variable set in the environment, even if empty, isn't read from the file. A missing file
+// is ignored, so the same code runs where there's none; a file that can't be read or parsed fails the load.
+//
+// Example:
+//
+//	# .env
+//	MYAPP_DB_HOST=localhost
+//	MYAPP_DB_PASSWORD="s3cret" # quoted values may contain escapes
+//
+//	qcl.Load(&defaultConfig, qcl.UseEnv(qcl.WithEnvPrefix("MYAPP"), qcl.WithDotenvFallback(".env")))
+//
+// Lines are KEY=VALUE, optionally preceded by "export ", and lines starting with # are comments. The file is decoded
+// like the files of UseFile, so a byte order mark and Windows line endings are handled, and UTF-16 is rejected with
+// UTF16Error. The file is read each time the config is loaded. It's the fallback of the variables set with WithEnviron too, if they're used instead of
+// the environment.
+func WithDotenvFallback(path string) envOption {
+	return func(c *envConfig) {
+		c.dotenv = path
+	}
+}
+
+// WithEnvAllowlist restricts the variables the loader may read to those whose names match one of the glob patterns,
+// as path.Match matches them, so that unrelated variables of a shared environment, like a CI runner's, can't leak into
+// the config. Variables that don't match are treated as unset. It can be passed more than once, adding patterns.
+//
+// Example:
+//
+//	WithEnvAllowlist("MYAPP_*", "PORT")
+//
+// allows MYAPP_DB_HOST and PORT, but not HOST. Invalid patterns fail the load with path.ErrBadPattern.
+func WithEnvAllowlist(patterns ...string) envOption {
+	return func(c *envConfig) {
+		c.allowlist = append(c.allowlist, patterns...)
+	}
+}
+
+// WithEnvBlocklist keeps the loader from reading the variables whose names match one of the glob patterns, as
+// path.Match matches them, even if WithEnvAllowlist allows them. They're treated as unset. It can be passed more than
+// once, adding patterns.
+//
+// Example:
+//
+//	WithEnvBlocklist("*_TOKEN", "AWS_*")
+//
+// Invalid patterns fail the load with path.ErrBadPattern.
+func WithEnvBlocklist(patterns ...string) envOption {
+	return func(c *envConfig) {
+		c.blocklist = append(c.blocklist, patterns...)
+	}
+}
+
 // WithEnvSeparator allows you to specify a custom separator for environment variables that are setting iterables.
 //
 // Example:
@@ -134,38 +312,350 @@ func WithEnvSeparator(separator string) envOption {
 	}
 }
 
+// WithEnvKeyMapper names the environment variable of each field with mapper, given the path of Go field names leading
+// to it, like []string{"DB", "Host"} for DB.Host, instead of the default rules, for naming conventions they don't cover.
+// The prefix set with WithEnvPrefix is prepended to the names mapper returns.
+//
+// Example:
+//
+//	WithEnvKeyMapper(func(fieldPath []string) string {
+//		return strings.ToUpper(strings.Join(fieldPath, "__"))
+//	})
+//
+// sets DB.Host from DB__HOST. The elements of slices and maps of structs are in the path as their index or key, like
+// []string{"Servers", "0", "Host"}, and the variable naming the implementation of an interface field is mapped from the
+// field's path followed by "type". Slices are only grown, map entries added and implementations selected by the
+// variables named by the default rules, though.
+func WithEnvKeyMapper(mapper func(fieldPath []string) string) envOption {
+	return func(c *envConfig) {
+		c.keyMapper = mapper
+	}
+}
+
+// WithEnvISO8601Durations allows time.Duration fields to be set from ISO 8601 durations, as well as Go durations.
+// This is useful when the environment is populated by systems, e.g. Java and .NET services or APIs, that emit
+// durations in that format.
+//
+// Example:
+//
+//	export TIMEOUT=PT15M
+//	export RETENTION=P1DT12H
+//
+//	type Config struct {
+//		Timeout   time.Duration // 15m
+//		Retention time.Duration // 36h
+//	}
+//
+// Weeks and days are 7 days and 24 hours exactly. Years and months are rejected, since they have no fixed length.
+func WithEnvISO8601Durations() envOption {
+	return func(c *envConfig) {
+		c.isoDurations = true
+	}
+}
+
+// WithFileIndirection allows fields to be read from files named by environment variables with the field's variable
+// name followed by _FILE, the convention Docker and Kubernetes secrets are passed to containers with.
+//
+// Example:
+//
+//	export DB_PASSWORD_FILE=/run/secrets/db_password
+//
+//	type Config struct {
+//		DBPassword string // set to the content of /run/secrets/db_password
+//	}
+//
+// Trailing newlines are trimmed from the content. Setting both DB_PASSWORD and DB_PASSWORD_FILE is an error, since
+// it's unclear which was meant.
+func WithFileIndirection() envOption {
+	return func(c *envConfig) {
+		c.fileIndirection = true
+	}
+}
+
 func loadFromEnv(envConf *envConfig) Loader {
 	if envConf == nil {
 		envConf = defaultEnvConfig
 	}
-	if envConf.prefix != "" && !strings.HasSuffix(envConf.prefix, "_") {
-		envConf.prefix += "_"
+	if nest := envConf.naming().nesting(); envConf.prefix != "" && !strings.HasSuffix(envConf.prefix, nest) {
+		envConf.prefix += nest
 	}
 	return func(config any) error {
 		if reflect.TypeOf(config).Kind() != reflect.Ptr {
 			return ConfigTypeError
 		}
-		val := reflect.ValueOf(config).Elem()
-		typ := val.Type()
-		return envSetFields(val, typ, envConf.prefix, envConf.structTag, envConf.separator)
+		return envSetFields(reflect.ValueOf(config).Elem(), envConf)
+	}
+}
+
+// envNaming holds the settings walkEnv names variables by.
+type envNaming struct {
+	structTag     string // structTag is the struct tag that names variables, if any.
+	caseSensitive bool   // caseSensitive keeps the prefix and the names given by tags as written, rather than in upper case.
+	delimiter     string // delimiter separates the names of nested fields, "_" if it's empty.
+}
+
+// naming returns the settings walkEnv names the variables of the source by.
+func (c *envConfig) naming() envNaming {
+	return envNaming{structTag: c.structTag, caseSensitive: c.caseSensitive, delimiter: c.nestingDelimiter}
+}
+
+// nesting returns the delimiter between the names of nested fields, like the "_" of DB_HOST.
+func (n envNaming) nesting() string {
+	if n.delimiter == "" {
+		return "_"
+	}
+	return n.delimiter
+}
+
+// mapKey returns the variable that sets the field at the dotted path of the struct type typ: the key walkEnv named it
+// by the default rules, or the name given by the mapper set with WithEnvKeyMapper, after the prefix. The variable naming
+// the implementation of an interface field is mapped from the field's path followed by "type".
+func (c *envConfig) mapKey(typ reflect.Type, envPrefix, path, key string) string {
+	if c.keyMapper == nil {
+		return key
+	}
+	fieldPath := strings.Split(path, ".")
+	if sf, ok := structFieldByPath(typ, path); ok && sf.Type.Kind() == reflect.Interface {
+		fieldPath = append(fieldPath, discriminatorKey)
+	}
+	return envPrefix + c.keyMapper(fieldPath)
+}
+
+// lookedUp reports the variable looked for the field at the dotted path, and whether it's set, to the load.
+func (c *envConfig) lookedUp(path, key string, found bool) {
+	if c.lookup != nil {
+		c.lookup(path, key, found)
+	}
+}
+
+// envKeys returns the variables that set the fields of the struct val, by their dotted paths, leaving out those of the
+// profile set with WithProfile.
+func envKeys(val reflect.Value, envConf *envConfig) map[string]string {
+	keys := make(map[string]string)
+	envPrefix := envConf.prefix + scopeEnvPrefix(envConf.load.scopePath(), envConf.naming().nesting())
+	_ = walkEnv(val, val.Type(), envPrefix, "", envConf.naming(), nil, func(v reflect.Value, path, key string) error {
+		keys[path] = envConf.mapKey(val.Type(), envPrefix, path, key)
+		return nil
+	})
+	return keys
+}
+
+// envSetFields sets the fields of the struct from the environment, then from the variables of the profile set with
+// WithProfile, if any, like MYAPP_PROD_HOST for MYAPP_HOST. In strict mode, variables starting with the prefix that
+// don't match a field are reported as UnknownKeyErrors.
+func envSetFields(val reflect.Value, envConf *envConfig) error {
+	nest := envConf.naming().nesting()
+	scope := scopeEnvPrefix(envConf.load.scopePath(), nest)
+	prefixes := []string{envConf.prefix + scope}
+	if profile := envConf.load.profileName(); profile != "" {
+		prefixes = append(prefixes, envConf.prefix+scopeEnvPrefix([]string{profile}, nest)+scope)
+	}
+	parse := parseOptions{separator: envConf.separator, isoDurations: envConf.isoDurations}
+	var errs []error
+	known := make(map[string]bool)
+	vars, err := envConf.vars()
+	if err != nil {
+		return err
+	}
+	for _, envPrefix := range prefixes {
+		err := walkEnv(val, val.Type(), envPrefix, "", envConf.naming(), vars, func(v reflect.Value, path, key string) error {
+			key = envConf.mapKey(val.Type(), envPrefix, path, key)
+			known[key] = true
+			value, set := vars[key]
+			envConf.lookedUp(path, key, set)
+			if envConf.fileIndirection {
+				fileKey := key + "_FILE"
+				known[fileKey] = true
+				filePath := vars[fileKey]
+				envConf.lookedUp(path, fileKey, filePath != "")
+				if filePath != "" {
+					if value != "" {
+						errs = append(errs, &FieldError{Path: path, Key: fileKey, RawValue: filePath, Err: fmt.Errorf("%s is set too", key)})
+						return nil
+					}
+					data, err := os.ReadFile(filePath)
+					if err != nil {
+						errs = append(errs, &FieldError{Path: path, Key: fileKey, RawValue: filePath, Err: err})
+						return nil
+					}
+					key, value, set = fileKey, strings.TrimRight(string(data), "\r\n"), true
+				}
+			}
+			if envConf.trimValues {
+				value = strings.TrimSpace(unquote(strings.TrimSpace(value)))
+			}
+			if value == "" && set && envConf.emptyAsSet {
+				v.Set(reflect.Zero(v.Type()))
+				if envConf.record != nil {
+					envConf.record(path, key)
+				}
+			} else if value != "" {
+				if err := parse.forField(val.Type(), path).setField(v, value); err != nil {
+					errs = append(errs, &FieldError{Path: path, Key: key, RawValue: value, Err: err})
+				} else if envConf.record != nil {
+					envConf.record(path, key)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if envConf.load.reportsUnknownKeys() && prefixes[0] != "" {
+		var unknown []string
+		for key := range vars {
+			if hasAnyPrefix(key, prefixes) && !known[key] {
+				unknown = append(unknown, key)
+			}
+		}
+		sort.Strings(unknown)
+		for _, key := range unknown {
+			errs = append(errs, &UnknownKeyError{Key: key})
+		}
+	}
+	return envConf.load.ignoreUnknownKeys(env, joinErrors(errs))
+}
+
+// vars returns the variables the source reads, keyed by name: those set with WithEnviron, or the process environment,
+// along with those of the dotenv file set with WithDotenvFallback that they're missing, less those WithEnvAllowlist
+// and WithEnvBlocklist keep it from reading.
+func (c *envConfig) vars() (map[string]string, error) {
+	vars := c.environ
+	if vars == nil {
+		vars = envVars()
+	}
+	if c.dotenv != "" {
+		data, err := os.ReadFile(c.dotenv)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		text, err := decodeText(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", c.dotenv, err)
+		}
+		fallback, err := decodeDotenv(text)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", c.dotenv, err)
+		}
+		merged := make(map[string]string, len(vars)+len(fallback))
+		for key, value := range fallback {
+			merged[key] = value
+		}
+		for key, value := range vars {
+			merged[key] = value
+		}
+		vars = merged
+	}
+	if len(c.allowlist) == 0 && len(c.blocklist) == 0 {
+		return vars, nil
+	}
+	for _, pattern := range append(c.allowlist[:len(c.allowlist):len(c.allowlist)], c.blocklist...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("%q: %w", pattern, err)
+		}
+	}
+	permitted := make(map[string]string, len(vars))
+	for key, value := range vars {
+		if c.permits(key) {
+			permitted[key] = value
+		}
+	}
+	return permitted, nil
+}
+
+// permits reports whether the variable is matched by a pattern of the allowlist, if there is one, and by none of the
+// blocklist. The patterns must be valid.
+func (c *envConfig) permits(key string) bool {
+	if len(c.allowlist) > 0 && !matchAny(c.allowlist, key) {
+		return false
+	}
+	return !matchAny(c.blocklist, key)
+}
+
+// matchAny reports whether name matches any of the valid glob patterns, as path.Match matches them.
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// envVars returns the variables in the environment, keyed by name.
+func envVars() map[string]string {
+	environ := os.Environ()
+	vars := make(map[string]string, len(environ))
+	for _, kv := range environ {
+		kv := strings.SplitN(kv, "=", 2)
+		vars[kv[0]] = kv[len(kv)-1]
+	}
+	return vars
+}
+
+// hasAnyPrefix reports whether s starts with any of the prefixes.
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// scopeEnvPrefix returns the prefix of the environment variables under the scope set with WithScope, with the parts
+// followed by the delimiter, e.g. "DB_POOL_" for ["db", "pool"] and "_".
+func scopeEnvPrefix(scope []string, delimiter string) string {
+	var prefix string
+	for _, part := range scope {
+		prefix += strings.ToUpper(strings.Join(splitOnWordBoundaries(part), "_")) + delimiter
 	}
+	return prefix
 }
 
-func envSetFields(val reflect.Value, typ reflect.Type, envPrefix, structTag, separator string) error {
+// walkEnv calls fn with every field of the struct that is loaded from an environment variable, along with its dotted
+// path, which starts with pathPrefix, and the name of the variable. Nil pointers are allocated along the way.
+//
+// The elements of slices of structs, and the values of maps of structs, are walked as structs of their own, under the
+// variable name followed by the index or the map key, like SERVERS_0_HOST for Servers[0].Host and
+// DATABASES_PRIMARY_HOST for Databases["primary"].Host. If vars isn't nil, slices are first grown, and entries added
+// to maps, to fit the variables among vars. The keys of the entries added are in lower case.
+//
+// Interface fields with registered implementations are walked as the struct of the implementation they hold, after the
+// variable naming it, like STORAGE_TYPE, which selects the implementation if it's among vars.
+func walkEnv(val reflect.Value, typ reflect.Type, envPrefix, pathPrefix string, naming envNaming, vars map[string]string, fn func(v reflect.Value, path, key string) error) error {
+	if !naming.caseSensitive {
+		envPrefix = strings.ToUpper(envPrefix)
+	}
+	nest := naming.nesting()
 	for i := 0; i < typ.NumField(); i++ {
 		field := typ.Field(i)
-		fName := strings.Join(splitOnWordBoundaries(field.Name), "_")
-		if structTag != "" {
-			if tag, ok := field.Tag.Lookup(structTag); ok {
-				tag = strings.Split(strings.TrimSpace(tag), ",")[0]
-				fName = strings.Join(splitOnWordBoundaries(tag), "_")
+		if skipField(field) {
+			continue
+		}
+		fName, tagged := field.Name, false
+		tag := qclTag(field)
+		if tag.name != "" {
+			fName = tag.name
+		}
+		if naming.structTag != "" {
+			if tag, ok := field.Tag.Lookup(naming.structTag); ok {
+				fName, tagged = tagName(strings.TrimSpace(tag)), true
 			}
 		}
+		if tag.env != "" {
+			fName, tagged = tag.env, true
+		}
+		if !naming.caseSensitive || !tagged {
+			fName = strings.ToUpper(strings.Join(splitOnWordBoundaries(fName), "_"))
+		}
 		if val := val.Field(i); val.CanSet() {
 			if field.Anonymous && field.Type.Kind() == reflect.Struct {
-				if err := envSetFields(val, field.Type, envPrefix, structTag, separator); err != nil {
+				if err := walkEnv(val, field.Type, envPrefix, pathPrefix, naming, vars, fn); err != nil {
 					return err
 				}
+				continue
 			}
 			if val.Kind() == reflect.Ptr {
 				if val.IsNil() {
@@ -173,18 +663,183 @@ func envSetFields(val reflect.Value, typ reflect.Type, envPrefix, structTag, sep
 				}
 				val = val.Elem()
 			}
-			if val.Kind() == reflect.Struct {
-				if err := envSetFields(val, val.Type(), envPrefix+fName+"_", structTag, separator); err != nil {
+			val = rawField(field, val)
+			if _, ok := customSetter(val); !ok && val.Kind() == reflect.Struct {
+				if err := walkEnv(val, val.Type(), envPrefix+fName+nest, pathPrefix+field.Name+".", naming, vars, fn); err != nil {
+					return err
+				}
+				continue
+			}
+			if isStructSlice(val) {
+				key := envPrefix + fName
+				if err := growSlice(val, sliceLength(sortedKeys(vars), key+nest, nest), pathPrefix+field.Name); err != nil {
+					return err
+				}
+				for j := 0; j < val.Len(); j++ {
+					elem, index := allocate(val.Index(j)), strconv.Itoa(j)
+					if err := walkEnv(elem, elem.Type(), key+nest+index+nest, pathPrefix+field.Name+"."+index+".", naming, vars, fn); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			if isStructMap(val) {
+				key := envPrefix + fName
+				var suffixes []string
+				elem := reflect.New(indirectType(val.Type().Elem())).Elem()
+				_ = walkEnv(elem, elem.Type(), "", "", naming, nil, func(_ reflect.Value, _, suffix string) error {
+					suffixes = append(suffixes, suffix)
+					return nil
+				})
+				segments := mapKeySegments(sortedKeys(vars), key+nest, nest, suffixes)
+				_, err := walkStructMap(val, pathPrefix+field.Name, segments, envKeySegment, strings.ToLower, func(elem reflect.Value, path, segment string) error {
+					return walkEnv(elem, elem.Type(), key+nest+segment+nest, path+".", naming, vars, fn)
+				})
+				if err != nil {
 					return err
 				}
+				continue
 			}
-			if v := os.Getenv(strings.ToUpper(envPrefix + fName)); v != "" {
-				if err := setField(val, v, separator); err != nil {
+			if isPolymorphic(val) {
+				key := envPrefix + fName
+				typeKey := key + nest + strings.ToUpper(discriminatorKey)
+				impl, err := selectImplementation(val, vars[typeKey])
+				if err != nil {
+					return &FieldError{Path: pathPrefix + field.Name, Key: typeKey, RawValue: vars[typeKey], Err: err}
+				}
+				if !impl.IsValid() {
+					continue
+				}
+				if err := fn(discriminator(val), pathPrefix+field.Name, typeKey); err != nil {
 					return err
 				}
+				if err := walkEnv(impl, impl.Type(), key+nest, pathPrefix+field.Name+".", naming, vars, fn); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := fn(val, pathPrefix+field.Name, envPrefix+fName); err != nil {
+				return err
 			}
 		}
 	}
 	return nil
+}
+
+// envKeySegment returns the map key as it's written in environment variable names, in upper case and with characters
+// other than letters and digits replaced by underscores, like READ_REPLICA for read-replica.
+func envKeySegment(key string) string {
+	return strings.ToUpper(strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, key))
+}
+
+// Environ renders the config into the KEY=VALUE environment variables that would reproduce it when loaded with
+// UseEnv and the given prefix, in the form used by os.Environ and exec.Cmd.Env. Variable names follow the environment
+// loader's default rules, including the "env" struct tag, and iterables are separated with a comma. Empty slices and
+// maps, and zero values of types that parse themselves, are left out, since there's no variable that produces them.
+//
+// Example:
+//
+//	env, err := qcl.Environ(childConfig, "WORKER")
+//	cmd := exec.Command("worker")
+//	cmd.Env = append(os.Environ(), env...)
+//
+// Environ returns an error if the config isn't a struct or pointer to one, or contains a field the environment loader
+// doesn't support.
+func Environ(config any, prefix string) ([]string, error) {
+	val, err := structCopy(config)
+	if err != nil {
+		return nil, err
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, "_") {
+		prefix += "_"
+	}
+
+	environ := make([]string, 0, val.NumField())
+	err = walkEnv(val, val.Type(), prefix, "", defaultEnvConfig.naming(), nil, func(v reflect.Value, _, key string) error {
+		if omitFormatted(v) {
+			return nil
+		}
+		formatted, err := formatValue(v, defaultEnvConfig.separator)
+		if err != nil {
+			return err
+		}
+		environ = append(environ, key+"="+formatted)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return environ, nil
+}
+
+// GenerateEnvTemplate renders a sample .env file listing every environment variable UseEnv with the given prefix
+// loads the config from, in the order the fields are declared, so that operators can bootstrap a deployment from an
+// accurate template. Each variable is commented out and set to its default, the value in the config after the default
+// tags and SetDefaults methods are applied, and preceded by the field's `usage` tag, if it has one:
+//
+//	type Config struct {
+//		Host string        `usage:"the address to listen on"`
+//		Wait time.Duration `default:"5s"`
+//	}
+//
+//	os.WriteFile(".env.example", []byte(qcl.GenerateEnvTemplate(&Config{Host: "localhost"}, "MYAPP")), 0o644)
+//
+// writes:
+//
+//	# the address to listen on
+//	# MYAPP_HOST=localhost
+//
+//	# MYAPP_WAIT=5s
+//
+// Defaults are quoted if they contain spaces, quotes or #, and those of secret fields are left empty. The template is
+// empty if the config isn't a struct or a pointer to one.
+func GenerateEnvTemplate(config any, prefix string) string {
+	val, err := structCopy(config)
+	if err != nil {
+		return ""
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, "_") {
+		prefix += "_"
+	}
+	if applyTagDefaults(val, "", map[reflect.Type]bool{}) == nil {
+		callDefaultHooks(val.Addr())
+	}
 
+	type variable struct {
+		path, key string
+		value     reflect.Value
+	}
+	var variables []variable
+	_ = walkEnv(val, val.Type(), prefix, "", defaultEnvConfig.naming(), nil, func(v reflect.Value, path, key string) error {
+		variables = append(variables, variable{path, key, v})
+		return nil
+	})
+	fields := make(map[string]field, len(variables))
+	for _, f := range leafFields(val.Addr().Interface()) { // once walkEnv has allocated nil pointers
+		fields[f.name()] = f
+	}
+
+	var b strings.Builder
+	for i, variable := range variables {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		f := fields[variable.path]
+		if usage := f.sf.Tag.Get("usage"); usage != "" {
+			b.WriteString("# " + strings.ReplaceAll(usage, "\n", "\n# ") + "\n")
+		}
+		value := dumpText(variable.value)
+		if f.secret {
+			value = ""
+		} else if strings.ContainsAny(value, " \t\n\"'#") {
+			value = strconv.Quote(value)
+		}
+		b.WriteString("# " + variable.key + "=" + value + "\n")
+	}
+	return b.String()
 }
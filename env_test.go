@@ -1,7 +1,11 @@
 package qcl
 
 import (
+	"errors"
+	"os"
+	"path"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -24,6 +28,9 @@ func Test_UseEnv(t *testing.T) {
 	if lc.Loaders[env] == nil {
 		t.Errorf("UseEnv() should add Environment loader")
 	}
+	if !reflect.DeepEqual(*defaultEnvConfig, envConfig{structTag: "env", separator: ","}) {
+		t.Errorf("UseEnv() should not modify the default env config: %+v", *defaultEnvConfig)
+	}
 }
 
 func Test_WithEnvPrefix(t *testing.T) {
@@ -42,6 +49,385 @@ func Test_WithEnvSeparator(t *testing.T) {
 	}
 }
 
+func Test_loadFromEnv_sepTag(t *testing.T) {
+	type config struct {
+		Hosts   []string
+		Sources []string `sep:";"`
+		DB      *struct {
+			Replicas []string `sep:";"`
+		}
+	}
+	t.Setenv("TEST_HOSTS", "a,b")
+	t.Setenv("TEST_SOURCES", "x?a=1,2;y")
+	t.Setenv("TEST_DB_REPLICAS", "r1,r2;r3")
+	got, err := Load(&config{}, UseEnv(WithEnvPrefix("TEST")))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !reflect.DeepEqual(got.Hosts, []string{"a", "b"}) || !reflect.DeepEqual(got.Sources, []string{"x?a=1,2", "y"}) ||
+		!reflect.DeepEqual(got.DB.Replicas, []string{"r1,r2", "r3"}) {
+		t.Errorf("Load() got = %+v, %+v", *got, *got.DB)
+	}
+}
+
+func Test_WithEnvKeyMapper(t *testing.T) {
+	type config struct {
+		Host string
+		DB   struct {
+			MaxConns int
+		}
+	}
+	doubleUnderscore := func(fieldPath []string) string {
+		return strings.ToUpper(strings.Join(fieldPath, "__"))
+	}
+	tests := map[string]struct {
+		opts []envOption
+		env  map[string]string
+		want config
+	}{
+		"mapped": {
+			opts: []envOption{WithEnvKeyMapper(doubleUnderscore)},
+			env:  map[string]string{"HOST": "localhost", "DB__MAXCONNS": "10", "DB_MAX_CONNS": "20"},
+			want: config{Host: "localhost", DB: struct{ MaxConns int }{MaxConns: 10}},
+		},
+		"prefix": {
+			opts: []envOption{WithEnvPrefix("TEST"), WithEnvKeyMapper(doubleUnderscore)},
+			env:  map[string]string{"TEST_DB__MAXCONNS": "10"},
+			want: config{DB: struct{ MaxConns int }{MaxConns: 10}},
+		},
+		"lower case": {
+			opts: []envOption{WithEnvPrefix("test"), WithEnvKeyMapper(func(fieldPath []string) string {
+				return strings.ToLower(strings.Join(fieldPath, "."))
+			})},
+			env:  map[string]string{"test_db.maxconns": "10"},
+			want: config{DB: struct{ MaxConns int }{MaxConns: 10}},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			for key, value := range test.env {
+				t.Setenv(key, value)
+			}
+			got, err := Load(&config{}, UseEnv(test.opts...))
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if *got != test.want {
+				t.Errorf("Load() got = %+v, want %+v", *got, test.want)
+			}
+		})
+	}
+	t.Run("strict", func(t *testing.T) {
+		t.Setenv("TEST_DB__MAXCONNS", "10")
+		if _, err := Load(&config{}, UseEnv(WithEnvPrefix("TEST"), WithEnvKeyMapper(doubleUnderscore)), WithStrict()); err != nil {
+			t.Errorf("Load() error = %v, want the mapped variable to be known", err)
+		}
+	})
+}
+
+func Test_WithEnvCaseSensitive(t *testing.T) {
+	type config struct {
+		Proxy string `env:"http_proxy"`
+		Port  int
+		DB    struct {
+			Host string `env:"dbHost"`
+		}
+	}
+	tests := map[string]struct {
+		opts []envOption
+		env  map[string]string
+		want config
+	}{
+		"insensitive": {
+			env: map[string]string{"HTTP_PROXY": "upper", "http_proxy": "lower", "PORT": "80", "DB_DB_HOST": "db"},
+			want: config{Proxy: "upper", Port: 80, DB: struct {
+				Host string `env:"dbHost"`
+			}{Host: "db"}},
+		},
+		"sensitive": {
+			opts: []envOption{WithEnvCaseSensitive()},
+			env:  map[string]string{"HTTP_PROXY": "upper", "http_proxy": "lower", "PORT": "80", "DB_dbHost": "db"},
+			want: config{Proxy: "lower", Port: 80, DB: struct {
+				Host string `env:"dbHost"`
+			}{Host: "db"}},
+		},
+		"prefix": {
+			opts: []envOption{WithEnvCaseSensitive(), WithEnvPrefix("MyApp")},
+			env:  map[string]string{"MYAPP_http_proxy": "upper", "MyApp_http_proxy": "mixed", "MyApp_PORT": "80"},
+			want: config{Proxy: "mixed", Port: 80},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			for key, value := range test.env {
+				t.Setenv(key, value)
+			}
+			got, err := Load(&config{}, UseEnv(test.opts...))
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if *got != test.want {
+				t.Errorf("Load() got = %+v, want %+v", *got, test.want)
+			}
+		})
+	}
+}
+
+func Test_loadFromEnv_lookups(t *testing.T) {
+	type config struct {
+		Host     string
+		Password string
+	}
+	t.Setenv("TEST_HOST", "localhost")
+	t.Setenv("TEST_PROD_PASSWORD_FILE", writeFile(t, "password", []byte("s3cret")))
+	var provenance Provenance
+	_, err := Load(&config{}, UseEnv(WithEnvPrefix("TEST"), WithFileIndirection()), WithProfile("prod"), WithProvenance(&provenance))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	want := map[string][]KeyLookup{
+		"Host": {
+			{Source: env, Key: "TEST_HOST", Found: true},
+			{Source: env, Key: "TEST_HOST_FILE"},
+			{Source: env, Key: "TEST_PROD_HOST"},
+			{Source: env, Key: "TEST_PROD_HOST_FILE"},
+		},
+		"Password": {
+			{Source: env, Key: "TEST_PASSWORD"},
+			{Source: env, Key: "TEST_PASSWORD_FILE"},
+			{Source: env, Key: "TEST_PROD_PASSWORD"},
+			{Source: env, Key: "TEST_PROD_PASSWORD_FILE", Found: true},
+		},
+	}
+	for path, lookups := range want {
+		if got := provenance[path].Lookups; !reflect.DeepEqual(got, lookups) {
+			t.Errorf("Load() lookups of %s = %+v, want %+v", path, got, lookups)
+		}
+	}
+}
+
+func Test_WithEnviron(t *testing.T) {
+	type config struct {
+		Host string
+		Port int
+	}
+	t.Setenv("TEST_HOST", "process")
+	tests := map[string]struct {
+		environ map[string]string
+		want    config
+	}{
+		"environ": {environ: map[string]string{"TEST_HOST": "localhost", "TEST_PORT": "8080"}, want: config{Host: "localhost", Port: 8080}},
+		"empty":   {environ: map[string]string{}, want: config{}},
+		"nil":     {want: config{}},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := Load(&config{}, UseEnv(WithEnvPrefix("TEST"), WithEnviron(test.environ)))
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if *got != test.want {
+				t.Errorf("Load() = %+v, want %+v", *got, test.want)
+			}
+		})
+	}
+	t.Run("copied", func(t *testing.T) {
+		environ := map[string]string{"TEST_PORT": "80"}
+		opt := UseEnv(WithEnvPrefix("TEST"), WithEnviron(environ))
+		environ["TEST_PORT"] = "8080"
+		got, err := Load(&config{}, opt)
+		if err != nil || got.Port != 80 {
+			t.Errorf("Load() = %+v, %v, want the environ as it was given", got, err)
+		}
+	})
+}
+
+func Test_WithEnvNestingDelimiter(t *testing.T) {
+	type server struct {
+		Host string
+	}
+	type config struct {
+		DBHost string
+		DB     struct {
+			Host string
+		}
+		Servers []server
+	}
+	tests := map[string]struct {
+		opts    []envOption
+		environ map[string]string
+		want    config
+	}{
+		"default": {
+			environ: map[string]string{"DB_HOST": "db"},
+			want:    config{DBHost: "db", DB: struct{ Host string }{Host: "db"}},
+		},
+		"nested": {
+			opts:    []envOption{WithEnvNestingDelimiter("__")},
+			environ: map[string]string{"DB_HOST": "flat", "DB__HOST": "nested", "SERVERS__0__HOST": "a"},
+			want:    config{DBHost: "flat", DB: struct{ Host string }{Host: "nested"}, Servers: []server{{Host: "a"}}},
+		},
+		"prefix": {
+			opts:    []envOption{WithEnvPrefix("TEST"), WithEnvNestingDelimiter("__")},
+			environ: map[string]string{"TEST__DB__HOST": "nested", "TEST_DB_HOST": "flat"},
+			want:    config{DB: struct{ Host string }{Host: "nested"}},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			opts := append(test.opts, WithEnviron(test.environ))
+			got, err := Load(&config{}, UseEnv(opts...))
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if !reflect.DeepEqual(*got, test.want) {
+				t.Errorf("Load() = %+v, want %+v", *got, test.want)
+			}
+		})
+	}
+	t.Run("profile", func(t *testing.T) {
+		environ := map[string]string{"TEST__DB__HOST": "base", "TEST__PROD__DB__HOST": "prod"}
+		got, err := Load(&config{}, UseEnv(WithEnvPrefix("TEST"), WithEnvNestingDelimiter("__"), WithEnviron(environ)), WithProfile("prod"))
+		if err != nil || got.DB.Host != "prod" {
+			t.Errorf("Load() = %+v, %v, want DB.Host from the profile", got, err)
+		}
+	})
+}
+
+func Test_WithDotenvFallback(t *testing.T) {
+	type config struct {
+		Host string
+		Port int
+		User string
+	}
+	dotenv := writeFile(t, ".env", []byte("TEST_HOST=dotenv\nTEST_PORT=8080\nTEST_USER=dotenv\n"))
+	tests := map[string]struct {
+		path    string
+		environ map[string]string
+		want    config
+		wantErr bool
+	}{
+		"fallback": {
+			path:    dotenv,
+			environ: map[string]string{"TEST_HOST": "env", "TEST_USER": ""},
+			want:    config{Host: "env", Port: 8080},
+		},
+		"documented": {
+			path: writeFile(t, "documented.env", []byte("# .env\nTEST_HOST=localhost\nTEST_USER=\"s3cret\" # quoted values may contain escapes\n")),
+			want: config{Host: "localhost", User: "s3cret"},
+		},
+		"byte order mark": {
+			path: writeFile(t, "bom.env", []byte("\xEF\xBB\xBFTEST_HOST=bom\r\nTEST_PORT=80\r\n")),
+			want: config{Host: "bom", Port: 80},
+		},
+		"missing file": {
+			path:    dotenv + ".missing",
+			environ: map[string]string{"TEST_HOST": "env"},
+			want:    config{Host: "env"},
+		},
+		"invalid file": {
+			path:    writeFile(t, "invalid.env", []byte("not a variable")),
+			wantErr: true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := Load(&config{}, UseEnv(WithEnvPrefix("TEST"), WithEnviron(test.environ), WithDotenvFallback(test.path)))
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if !test.wantErr && *got != test.want {
+				t.Errorf("Load() = %+v, want %+v", *got, test.want)
+			}
+		})
+	}
+}
+
+func Test_WithDotenvFallback_utf16(t *testing.T) {
+	type config struct {
+		Host string
+	}
+	path := writeFile(t, "utf16.env", []byte("\xFF\xFET\x00E\x00S\x00T\x00_\x00H\x00O\x00S\x00T\x00=\x00u\x00\r\x00\n\x00"))
+	if _, err := Load(&config{}, UseEnv(WithEnvPrefix("TEST"), WithEnviron(nil), WithDotenvFallback(path))); !errors.Is(err, UTF16Error) {
+		t.Errorf("Load() error = %v, want UTF16Error", err)
+	}
+}
+
+func Test_WithEnvAllowlist(t *testing.T) {
+	type config struct {
+		Host  string
+		Port  int
+		Token string
+	}
+	environ := map[string]string{"HOST": "localhost", "PORT": "8080", "TOKEN": "s3cret"}
+	tests := map[string]struct {
+		opts    []envOption
+		want    config
+		wantErr error
+	}{
+		"none":      {want: config{Host: "localhost", Port: 8080, Token: "s3cret"}},
+		"allowlist": {opts: []envOption{WithEnvAllowlist("HOST"), WithEnvAllowlist("P*")}, want: config{Host: "localhost", Port: 8080}},
+		"blocklist": {opts: []envOption{WithEnvBlocklist("*OKEN")}, want: config{Host: "localhost", Port: 8080}},
+		"both": {
+			opts: []envOption{WithEnvAllowlist("*"), WithEnvBlocklist("PORT", "TOK?N")},
+			want: config{Host: "localhost"},
+		},
+		"bad pattern":       {opts: []envOption{WithEnvAllowlist("HOST", "[")}, wantErr: path.ErrBadPattern},
+		"bad later pattern": {opts: []envOption{WithEnvBlocklist("*", "a[")}, wantErr: path.ErrBadPattern},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := Load(&config{}, UseEnv(append(test.opts, WithEnviron(environ))...))
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("Load() error = %v, want %v", err, test.wantErr)
+			}
+			if test.wantErr == nil && *got != test.want {
+				t.Errorf("Load() = %+v, want %+v", *got, test.want)
+			}
+		})
+	}
+}
+
+func Test_WithEnvTrimValues(t *testing.T) {
+	type config struct {
+		Foo   string
+		Port  int
+		Hosts []string
+	}
+	environ := map[string]string{"FOO": `" bar "`, "PORT": " '8080' ", "HOSTS": "\ta,b\n"}
+	tests := map[string]struct {
+		opts []envOption
+		want config
+	}{
+		"trimmed": {opts: []envOption{WithEnvTrimValues()}, want: config{Foo: "bar", Port: 8080, Hosts: []string{"a", "b"}}},
+		"empty": {
+			opts: []envOption{WithEnvTrimValues(), WithEnviron(map[string]string{"FOO": `""`, "PORT": "  "})},
+			want: config{Foo: "default", Port: 80},
+		},
+		"empty as set": {
+			opts: []envOption{WithEnvTrimValues(), WithEmptyAsSet(), WithEnviron(map[string]string{"FOO": `""`, "PORT": "  "})},
+			want: config{},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := Load(&config{Foo: "default", Port: 80}, UseEnv(append([]envOption{WithEnviron(environ)}, test.opts...)...))
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if !reflect.DeepEqual(*got, test.want) {
+				t.Errorf("Load() = %+v, want %+v", *got, test.want)
+			}
+		})
+	}
+	t.Run("untrimmed", func(t *testing.T) {
+		got, err := Load(&config{}, UseEnv(WithEnviron(map[string]string{"FOO": `" bar "`})))
+		if err != nil || got.Foo != `" bar "` {
+			t.Errorf("Load() = %+v, %v, want the value as is", got, err)
+		}
+	})
+}
+
 func Test_WithEnvStructTag(t *testing.T) {
 	envConf := envConfig{}
 	WithEnvStructTag("test")(&envConf)
@@ -50,6 +436,118 @@ func Test_WithEnvStructTag(t *testing.T) {
 	}
 }
 
+func Test_WithEnvISO8601Durations(t *testing.T) {
+	envConf := envConfig{}
+	WithEnvISO8601Durations()(&envConf)
+	if !envConf.isoDurations {
+		t.Errorf("WithEnvISO8601Durations() should set isoDurations")
+	}
+	t.Setenv("TIMEOUT", "PT15M")
+	got := new(struct{ Timeout time.Duration })
+	if err := loadFromEnv(&envConf)(got); err != nil {
+		t.Fatalf("loadFromEnv() error = %v", err)
+	}
+	if got.Timeout != 15*time.Minute {
+		t.Errorf("loadFromEnv() Timeout = %v, want %v", got.Timeout, 15*time.Minute)
+	}
+}
+
+func Test_WithEmptyAsSet(t *testing.T) {
+	type config struct {
+		Host  string   `default:"localhost"`
+		Port  int      `default:"8080"`
+		Hosts []string `default:"a,b"`
+	}
+	tests := map[string]struct {
+		opts []envOption
+		env  map[string]string
+		want config
+	}{
+		"ignored": {
+			env:  map[string]string{"HOST": "", "PORT": "", "HOSTS": ""},
+			want: config{Host: "localhost", Port: 8080, Hosts: []string{"a", "b"}},
+		},
+		"set": {
+			opts: []envOption{WithEmptyAsSet()},
+			env:  map[string]string{"HOST": "", "PORT": "", "HOSTS": ""},
+			want: config{},
+		},
+		"unset": {
+			opts: []envOption{WithEmptyAsSet()},
+			env:  map[string]string{"PORT": "80"},
+			want: config{Host: "localhost", Port: 80, Hosts: []string{"a", "b"}},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			for _, key := range []string{"HOST", "PORT", "HOSTS"} {
+				t.Setenv(key, "")
+				os.Unsetenv(key)
+			}
+			for key, value := range test.env {
+				t.Setenv(key, value)
+			}
+			got, err := Load(&config{}, UseEnv(test.opts...))
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if !reflect.DeepEqual(*got, test.want) {
+				t.Errorf("Load() = %+v, want %+v", *got, test.want)
+			}
+		})
+	}
+}
+
+func Test_WithFileIndirection(t *testing.T) {
+	type config struct {
+		DBPassword string
+		Port       int
+	}
+	secret := writeFile(t, "db_password", []byte("s3cret\n"))
+	tests := map[string]struct {
+		env     map[string]string
+		want    config
+		wantKey string
+	}{
+		"file":     {env: map[string]string{"TEST_DB_PASSWORD_FILE": secret}, want: config{DBPassword: "s3cret"}},
+		"variable": {env: map[string]string{"TEST_DB_PASSWORD": "plain"}, want: config{DBPassword: "plain"}},
+		"both": {
+			env:     map[string]string{"TEST_DB_PASSWORD": "plain", "TEST_DB_PASSWORD_FILE": secret},
+			wantKey: "TEST_DB_PASSWORD_FILE",
+		},
+		"missing file":  {env: map[string]string{"TEST_DB_PASSWORD_FILE": secret + ".missing"}, wantKey: "TEST_DB_PASSWORD_FILE"},
+		"invalid value": {env: map[string]string{"TEST_PORT_FILE": secret}, wantKey: "TEST_PORT_FILE"},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			for key, value := range test.env {
+				t.Setenv(key, value)
+			}
+			got, err := Load(&config{}, UseEnv(WithEnvPrefix("TEST"), WithFileIndirection()), WithStrict())
+			if test.wantKey != "" {
+				var fieldErr *FieldError
+				if !errors.As(err, &fieldErr) || fieldErr.Key != test.wantKey {
+					t.Errorf("Load() error = %v, want a FieldError for %s", err, test.wantKey)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if *got != test.want {
+				t.Errorf("Load() = %+v, want %+v", *got, test.want)
+			}
+		})
+	}
+	t.Run("disabled", func(t *testing.T) {
+		t.Setenv("TEST_DB_PASSWORD_FILE", secret)
+		got, err := Load(&config{}, UseEnv(WithEnvPrefix("TEST")))
+		if err != nil || got.DBPassword != "" {
+			t.Errorf("Load() = %+v, %v, want the _FILE variable ignored", *got, err)
+		}
+	})
+}
+
 func Test_loadFromEnv(t *testing.T) {
 	tests := map[string]struct {
 		prefix    string
@@ -242,6 +740,35 @@ func Test_loadFromEnv(t *testing.T) {
 				"TEST_PORT": "8080",
 			},
 		},
+		"generated protobuf": {
+			prefix:    "TEST",
+			structTag: "protobuf",
+			want: &TestProtoConfig{
+				DbHost:   "localhost",
+				MaxConns: &Int32Value{Value: 10},
+				Token:    &BytesValue{Value: []byte("token")},
+				Timeout:  &Duration{Seconds: 1, Nanos: 500000000},
+				Start:    &Timestamp{Seconds: 1640995200},
+			},
+			envs: map[string]string{
+				"TEST_DB_HOST":          "localhost",
+				"TEST_MAX_CONNS":        "10",
+				"TEST_TOKEN":            "token",
+				"TEST_TIMEOUT":          "1.5s",
+				"TEST_START":            "2022-01-01T00:00:00Z",
+				"TEST_BACKEND":          "ignored",
+				"TEST_XXX_UNRECOGNIZED": "ignored",
+			},
+		},
+		"skipped fields": {
+			prefix: "TEST",
+			want:   &TestRuntimeConfig{Host: "localhost"},
+			envs: map[string]string{
+				"TEST_HOST":  "localhost",
+				"TEST_NAME":  "ignored",
+				"TEST_READY": "ignored",
+			},
+		},
 		"unparseable bool": {
 			prefix: "TEST",
 			want:   &AllSupportedTypes{},
@@ -372,3 +899,125 @@ func Test_loadFromEnv(t *testing.T) {
 }
 
 func ptr[T any](v T) *T { return &v }
+
+func Test_Environ(t *testing.T) {
+	tests := map[string]struct {
+		config  any
+		prefix  string
+		want    []string
+		wantErr bool
+	}{
+		"simple": {
+			config: &TestConfig{Host: "localhost", Port: 8080},
+			want:   []string{"HOST=localhost", "PORT=8080"},
+		},
+		"prefix": {
+			config: TestConfig{Host: "localhost"},
+			prefix: "TEST",
+			want:   []string{"TEST_HOST=localhost", "TEST_PORT=0"},
+		},
+		"nested and embedded": {
+			config: &struct {
+				TestConfig
+				DB    *TestDBConfig
+				Hosts []string
+				Ports map[string]int
+			}{TestConfig: TestConfig{Host: "localhost"}, DB: &TestDBConfig{Port: 5432}, Ports: map[string]int{"b": 2, "a": 1}},
+			prefix: "TEST_",
+			want: []string{
+				"TEST_HOST=localhost", "TEST_PORT=0", "TEST_DB_HOST=", "TEST_DB_PORT=5432", "TEST_DB_SSL=false",
+				"TEST_PORTS=a=1,b=2",
+			},
+		},
+		"env tag": {
+			config: &struct {
+				HTTPPort int `env:"PORT"`
+			}{HTTPPort: 80},
+			want: []string{"PORT=80"},
+		},
+		"unsupported type": {
+			config:  &UnsupportedStruct{},
+			wantErr: true,
+		},
+		"not a struct": {
+			config:  ptr("string"),
+			wantErr: true,
+		},
+		"nil": {
+			config:  (*TestConfig)(nil),
+			wantErr: true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := Environ(test.config, test.prefix)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Environ() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if !test.wantErr && !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Environ() = %v, want %v", got, test.want)
+			}
+		})
+	}
+	t.Run("round trip", func(t *testing.T) {
+		want := &AllSupportedTypes{Bool: true, Int8: -8, Uint64: 64, Float: 1.5, Duration: time.Minute}
+		environ, err := Environ(want, "TEST")
+		if err != nil {
+			t.Fatalf("Environ() error = %v", err)
+		}
+		for _, kv := range environ {
+			parts := strings.SplitN(kv, "=", 2)
+			t.Setenv(parts[0], parts[1])
+		}
+		got := new(AllSupportedTypes)
+		if err := loadFromEnv(&envConfig{prefix: "TEST", separator: ","})(got); err != nil {
+			t.Fatalf("loadFromEnv() error = %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("loadFromEnv(Environ()) = %v, want %v", got, want)
+		}
+	})
+}
+
+func Test_GenerateEnvTemplate(t *testing.T) {
+	type config struct {
+		Host     string        `usage:"the address to listen on"`
+		Wait     time.Duration `default:"5s"`
+		Greeting string
+		Password string `secret:"true"`
+		DB       *struct {
+			Port int `usage:"the database port\nabove 1024"`
+		}
+	}
+	tests := map[string]struct {
+		config any
+		prefix string
+		want   string
+	}{
+		"defaults": {
+			config: &config{Host: "localhost", Greeting: "hello world", Password: "hunter2"},
+			prefix: "MYAPP",
+			want: `# the address to listen on
+# MYAPP_HOST=localhost
+
+# MYAPP_WAIT=5s
+
+# MYAPP_GREETING="hello world"
+
+# MYAPP_PASSWORD=
+
+# the database port
+# above 1024
+# MYAPP_DB_PORT=0
+`,
+		},
+		"not a struct": {config: 42},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := GenerateEnvTemplate(test.config, test.prefix); got != test.want {
+				t.Errorf("GenerateEnvTemplate() =\n%s\nwant\n%s", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package qcl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ExternalProtocolVersion is the version of the protocol spoken with executables used through UseExternal.
+const ExternalProtocolVersion = 1
+
+// ExternalRequest is written as JSON to the standard input of an executable used through UseExternal. It describes
+// the fields of the config being loaded, so the executable knows what to resolve.
+type ExternalRequest struct {
+	Version int             `json:"version"` // Version is ExternalProtocolVersion.
+	Fields  []ExternalField `json:"fields"`  // Fields lists the leaf fields of the config, in the order they're declared.
+}
+
+// ExternalField describes one field of the config in an ExternalRequest.
+type ExternalField struct {
+	Field  string `json:"field"`            // Field is the dotted path of the field, e.g. "DB.Host".
+	Type   string `json:"type"`             // Type is the field's Go type, e.g. "string" or "[]int".
+	Secret bool   `json:"secret,omitempty"` // Secret is true if the field is tagged `secret:"true"`.
+}
+
+// ExternalResponse is read as JSON from the standard output of an executable used through UseExternal.
+type ExternalResponse struct {
+	Version int `json:"version"` // Version must be ExternalProtocolVersion.
+	// Values maps dotted field paths, as in ExternalField.Field, to the values to set. A value can be a string, which
+	// is parsed the way the environment loader parses it, a number or a boolean, an array of those for slices, or an
+	// object of those for maps. Fields that aren't in Values are left unchanged.
+	Values map[string]json.RawMessage `json:"values"`
+}
+
+// UseExternal enables loading configuration from an external executable, in the spirit of AWS's credential_process.
+// It lets qcl integrate with systems it doesn't support natively, like a company vault or a bespoke config service,
+// with a helper written in any language.
+//
+// The executable is run with the given arguments each time the config is loaded. It receives an ExternalRequest on
+// standard input, and must print an ExternalResponse on standard output and exit with status 0. For example, a
+// helper receiving:
+//
+//	{"version": 1, "fields": [{"field": "DB.Host", "type": "string"}, {"field": "DB.Port", "type": "int"}]}
+//
+// may respond with:
+//
+//	{"version": 1, "values": {"DB.Host": "db.internal", "DB.Port": 5432}}
+//
+// Usage:
+//
+//	qcl.Load(&defaultConfig, qcl.UseEnv(), qcl.UseExternal("/usr/bin/my-config-helper", "--env", "prod"))
+//
+// The executable is killed if the context given to LoadContext is done, or the deadline set with WithDeadline passes.
+// Anything the executable writes to standard error is included in the error returned if it fails. Loading fails if
+// the executable exits with a non-zero status, prints an invalid response, or sets a field that doesn't exist. The
+// source is named "external:" followed by the path.
+func UseExternal(path string, args ...string) LoadOption {
+	name := "external:" + path
+	return func(o *LoadConfig) {
+		o.Sources = append(o.Sources, name)
+		o.Loaders[name] = loadFromExternal(path, args, o.context, o.recorder(name))
+	}
+}
+
+func loadFromExternal(path string, args []string, loadContext func() (context.Context, context.CancelFunc), record func(path, key string)) Loader {
+	return func(config any) error {
+		if reflect.TypeOf(config).Kind() != reflect.Ptr {
+			return ConfigTypeError
+		}
+		val := reflect.ValueOf(config).Elem()
+		if val.Kind() != reflect.Struct {
+			return ConfigTypeError
+		}
+		req := ExternalRequest{Version: ExternalProtocolVersion, Fields: make([]ExternalField, 0, val.NumField())}
+		for _, f := range leafFields(config) {
+			req.Fields = append(req.Fields, ExternalField{Field: f.name(), Type: f.value.Type().String(), Secret: f.secret})
+		}
+		input, err := json.Marshal(req)
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := loadContext()
+		defer cancel()
+		var stdout, stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, path, args...)
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = bytes.NewReader(input), &stdout, &stderr
+		if err := cmd.Run(); err != nil {
+			if msg := strings.TrimSpace(stderr.String()); msg != "" {
+				return fmt.Errorf("external source %s: %w: %s", path, err, msg)
+			}
+			return fmt.Errorf("external source %s: %w", path, err)
+		}
+
+		var resp ExternalResponse
+		if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+			return fmt.Errorf("external source %s: invalid response: %w", path, err)
+		}
+		if resp.Version != ExternalProtocolVersion {
+			return fmt.Errorf("external source %s: unsupported protocol version %d", path, resp.Version)
+		}
+
+		var errs []error
+		for _, field := range sortedKeys(resp.Values) {
+			value, err := externalValue(resp.Values[field])
+			if err != nil {
+				errs = append(errs, &FieldError{Path: field, Key: field, RawValue: string(resp.Values[field]), Err: err})
+				continue
+			}
+			v, err := fieldByPath(val, field)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if v.Kind() == reflect.Slice || v.Kind() == reflect.Map {
+				v.Set(reflect.Zero(v.Type()))
+				if value == "" { // an empty array or object
+					record(field, field)
+					continue
+				}
+			}
+			if err := defaultParseOptions.setField(v, value); err != nil {
+				errs = append(errs, &FieldError{Path: field, Key: field, RawValue: value, Err: err})
+				continue
+			}
+			record(field, field)
+		}
+		return joinErrors(errs)
+	}
+}
+
+// externalValue converts a JSON value from an ExternalResponse into the string form setField parses.
+func externalValue(raw json.RawMessage) (string, error) {
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber() // keep numbers as written, so large integers don't lose precision
+	if err := dec.Decode(&v); err != nil {
+		return "", err
+	}
+	switch v := v.(type) {
+	case []any:
+		items := make([]string, len(v))
+		for i, item := range v {
+			s, err := externalScalar(item)
+			if err != nil {
+				return "", err
+			}
+			items[i] = escapeSeparator(s, defaultParseOptions.separator)
+		}
+		return strings.Join(items, defaultParseOptions.separator), nil
+	case map[string]any:
+		entries := make([]string, 0, len(v))
+		for _, k := range sortedKeys(v) {
+			s, err := externalScalar(v[k])
+			if err != nil {
+				return "", err
+			}
+			entries = append(entries, escapeSeparator(k+"="+s, defaultParseOptions.separator))
+		}
+		return strings.Join(entries, defaultParseOptions.separator), nil
+	}
+	return externalScalar(v)
+}
+
+func externalScalar(v any) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return fmt.Sprint(v), nil
+	}
+	return "", fmt.Errorf("unsupported value %v", v)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,150 @@
+package qcl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Test_externalHelper isn't a real test: it is run as a subprocess by Test_UseExternal to act as an external source.
+// It echoes the request's field names to stderr and prints the response in QCL_TEST_EXTERNAL_RESPONSE.
+func Test_externalHelper(t *testing.T) {
+	response, ok := os.LookupEnv("QCL_TEST_EXTERNAL_RESPONSE")
+	if !ok {
+		return
+	}
+	var req ExternalRequest
+	if err := json.NewDecoder(os.Stdin).Decode(&req); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	fields := make([]string, len(req.Fields))
+	for i, f := range req.Fields {
+		fields[i] = fmt.Sprintf("%s:%s:%v", f.Field, f.Type, f.Secret)
+	}
+	fmt.Fprint(os.Stderr, strings.Join(fields, " "))
+	fmt.Print(response)
+	if os.Getenv("QCL_TEST_EXTERNAL_FAIL") != "" {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+type TestExternalConfig struct {
+	Host     string
+	Port     int
+	Password string `secret:"true"`
+	Hosts    []string
+	Labels   map[string]string
+	DB       TestDBConfig
+}
+
+func Test_UseExternal(t *testing.T) {
+	tests := map[string]struct {
+		response string
+		fail     bool
+		want     *TestExternalConfig
+		wantErr  string
+	}{
+		"values": {
+			response: `{"version": 1, "values": {"Host": "example.com", "Port": 8080, "Password": "hunter2",
+				"Hosts": ["a", "b"], "Labels": {"team": "payments"}, "DB.SSL": true}}`,
+			want: &TestExternalConfig{
+				Host: "example.com", Port: 8080, Password: "hunter2", Hosts: []string{"a", "b"},
+				Labels: map[string]string{"team": "payments"}, DB: TestDBConfig{Host: "db", SSL: true},
+			},
+		},
+		"separators in values": {
+			response: `{"version": 1, "values": {"Hosts": ["a,b", "c"], "Labels": {"k": "x,y"}}}`,
+			want: &TestExternalConfig{
+				Hosts: []string{"a,b", "c"}, Labels: map[string]string{"k": "x,y"}, DB: TestDBConfig{Host: "db"},
+			},
+		},
+		"empty array replaces default": {
+			response: `{"version": 1, "values": {"Hosts": []}}`,
+			want:     &TestExternalConfig{DB: TestDBConfig{Host: "db"}},
+		},
+		"no values": {
+			response: `{"version": 1}`,
+			want:     &TestExternalConfig{Hosts: []string{"default"}, DB: TestDBConfig{Host: "db"}},
+		},
+		"non-zero exit": {
+			response: `{"version": 1}`,
+			fail:     true,
+			wantErr:  "exit status 1: Host:string:false Port:int:false Password:string:true",
+		},
+		"invalid response": {
+			response: `not json`,
+			wantErr:  "invalid response",
+		},
+		"unsupported version": {
+			response: `{"version": 2}`,
+			wantErr:  "unsupported protocol version 2",
+		},
+		"unknown field": {
+			response: `{"version": 1, "values": {"Hots": "example.com"}}`,
+			wantErr:  "unknown field: Hots",
+		},
+		"invalid value": {
+			response: `{"version": 1, "values": {"Port": "not a number"}}`,
+			wantErr:  "Port",
+		},
+		"nested array": {
+			response: `{"version": 1, "values": {"Hosts": [["a"]]}}`,
+			wantErr:  "unsupported value",
+		},
+	}
+	// other tests replace os.Args, so find the test binary another way
+	executable, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("QCL_TEST_EXTERNAL_RESPONSE", test.response)
+			if test.fail {
+				t.Setenv("QCL_TEST_EXTERNAL_FAIL", "1")
+			}
+			got, err := Load(&TestExternalConfig{Hosts: []string{"default"}, DB: TestDBConfig{Host: "db"}},
+				UseExternal(executable, "-test.run=^Test_externalHelper$"),
+			)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("Load() error = %v, want it to contain %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Load() got = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+	t.Run("records only values that were set", func(t *testing.T) {
+		t.Setenv("QCL_TEST_EXTERNAL_RESPONSE", `{"version": 1, "values": {"Host": "example.com", "Port": "not a number"}}`)
+		recorded := make(map[string]string)
+		load := loadFromExternal(executable, []string{"-test.run=^Test_externalHelper$"},
+			func() (context.Context, context.CancelFunc) { return context.WithCancel(context.Background()) },
+			func(path, key string) { recorded[path] = key })
+		if err := load(&TestExternalConfig{}); err == nil {
+			t.Fatal("loader error = nil, want an error for Port")
+		}
+		if want := map[string]string{"Host": "Host"}; !reflect.DeepEqual(recorded, want) {
+			t.Errorf("recorded = %v, want %v", recorded, want)
+		}
+	})
+	t.Run("missing executable", func(t *testing.T) {
+		_, err := Load(&TestExternalConfig{}, UseExternal("/nonexistent/qcl-helper"))
+		if !errors.Is(err, exec.ErrNotFound) && !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("Load() error = %v, want a not found error", err)
+		}
+	})
+}
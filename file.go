@@ -0,0 +1,788 @@
+package qcl
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+)
+
+// A fileFormat decodes the text of a configuration file into a tree of values. The tree's keys are the names used in
+// the file, its leaves are strings or, for repeated values, slices of strings, and its branches are nested trees or,
+// for lists of objects, slices of trees.
+type fileFormat func(text string) (map[string]any, error)
+
+// fileFormats maps format names to their decoders. The format name is also the struct tag that overrides a field's
+// name in files of that format.
+var fileFormats = map[string]fileFormat{
+	"hcl": decodeHCL,
+	"ini": decodeINI,
+}
+
+// fileExtensions maps file extensions to the format of files with that extension.
+var fileExtensions = map[string]string{
+	".hcl": "hcl",
+	".ini": "ini",
+}
+
+type fileConfig struct {
+	format          string
+	separator       string
+	appendSlices    bool
+	mergeDiscovered bool
+	watchInterval   time.Duration
+	templating      *fileTemplating        // templating, if not nil, renders files as templates before they're decoded.
+	load            *LoadConfig            // load is the load the source is part of, if any, for its WithStrict and WithScope settings.
+	source          string                 // source is the name of the source, for the warnings of the load.
+	record          func(path, key string) // record, if not nil, receives the key each field is set from, for provenance.
+}
+
+// forLoad returns a copy of the file config that follows the settings of the load being configured, and records
+// provenance for the named source.
+func (c fileConfig) forLoad(o *LoadConfig, source string) *fileConfig {
+	c.load, c.source = o, source
+	c.record = o.recorder(source)
+	return &c
+}
+
+// withProfile returns the paths, each followed by its variant for the profile set with WithProfile, if any.
+func (c *fileConfig) withProfile(paths []string) []string {
+	return withProfile(paths, c.load.profileName())
+}
+
+// withProfile returns the paths, each followed by its variant for the profile, if it isn't empty, like config.prod.ini
+// for config.ini with the profile "prod".
+func withProfile(paths []string, profile string) []string {
+	if profile == "" {
+		return paths
+	}
+	var withVariants []string
+	for _, path := range paths {
+		ext := filepath.Ext(path)
+		withVariants = append(withVariants, path, strings.TrimSuffix(path, ext)+"."+profile+ext)
+	}
+	return withVariants
+}
+
+// treeOptions returns the options for setting a config from the tree decoded from the file at path.
+func (c *fileConfig) treeOptions(path, format string) treeOptions {
+	return treeOptions{
+		tag:    format,
+		parse:  parseOptions{separator: c.separator},
+		strict: c.load.reportsUnknownKeys(),
+		file:   path,
+		record: c.record,
+	}
+}
+
+type fileOption func(*fileConfig)
+
+// UseFile enables loading configuration from a file. The format of the file is detected from its extension, or can
+// be set with WithFileFormat. The supported formats are:
+//
+//	hcl  .hcl  blocks map to nested structs, and labeled blocks to maps, e.g. port in service "web" {...} sets
+//	           Service["web"].Port
+//	ini  .ini  sections map to nested structs, e.g. host in [db] sets DB.Host
+//
+// Keys are matched to fields by name, ignoring case, underscores and dashes, so "db_host", "db-host" and "dbhost" all
+// set a field named DBHost. A struct tag named after the format overrides the name, e.g. `ini:"hostname"`, as do the
+// name and file options of the `qcl` tag, e.g. `qcl:"file=hostname"`. Values are
+// parsed the same way environment variables are, with iterables separated by a comma.
+//
+// Example:
+//
+//	; config.ini
+//	[db]
+//	host = localhost
+//	port = 5432
+//
+//	type Config struct {
+//		DB struct {
+//			Host string
+//			Port int
+//		}
+//	}
+//
+//	qcl.Load(&defaultConfig, qcl.UseFile("config.ini"), qcl.UseEnv(), qcl.UseFlags())
+//
+// Files must be encoded as UTF-8, with or without a byte order mark, and may use Windows line endings. Loading fails
+// if the file can't be read or decoded, and with UTF16Error if it's UTF-16, as many Windows editors save files. When the config is loaded with Watch, the
+// file is checked for changes every second, or as set with WithFileWatchInterval. The source is named "file:"
+// followed by the path.
+func UseFile(path string, opts ...fileOption) LoadOption {
+	fileConf := fileConfig{separator: ","}
+	for _, opt := range opts {
+		opt(&fileConf)
+	}
+	name := "file:" + path
+	return func(o *LoadConfig) {
+		o.Sources = append(o.Sources, name)
+		o.Loaders[name] = loadFromFile(path, fileConf.forLoad(o, name))
+		o.watchers = append(o.watchers, watchFiles(func() []string { return withProfile([]string{path}, o.profile) }, fileConf.watchInterval))
+	}
+}
+
+// WithFileFormat sets the format of the file, for files whose extension doesn't identify it.
+//
+// Example:
+//
+//	qcl.UseFile("/etc/myapp/config", qcl.WithFileFormat("ini"))
+func WithFileFormat(format string) fileOption {
+	return func(c *fileConfig) {
+		c.format = format
+	}
+}
+
+// WithFileSeparator sets the separator for values setting iterables, like WithEnvSeparator does for environment
+// variables. The default is a comma (,).
+func WithFileSeparator(separator string) fileOption {
+	return func(c *fileConfig) {
+		c.separator = separator
+	}
+}
+
+// WithTemplating renders files as Go templates, with the text/template package, before they're decoded, so that they
+// can use values of the environment, or computed by the application, in place of an envsubst step:
+//
+//	; config.ini
+//	region = {{ env "REGION" | default "us-east-1" }}
+//	bucket = {{ .App }}-{{ env "STAGE" }}
+//
+//	qcl.UseFile("config.ini", qcl.WithTemplating(nil, map[string]string{"App": "myapp"}))
+//
+// Besides the functions predefined by text/template, templates can call env, which returns the value of an environment
+// variable, default, which returns its first argument if the second is empty and the second otherwise, and the funcs,
+// which override them. data is the template's dot. A key missing from a map data is an error, as is any error
+// parsing or executing a template, which is reported with the file's path.
+func WithTemplating(funcs template.FuncMap, data any) fileOption {
+	return func(c *fileConfig) {
+		c.templating = &fileTemplating{funcs: funcs, data: data}
+	}
+}
+
+// fileTemplating renders files as templates, as set with WithTemplating.
+type fileTemplating struct {
+	funcs template.FuncMap
+	data  any
+}
+
+// templateFuncs are the functions templates can call besides those of text/template and WithTemplating.
+var templateFuncs = template.FuncMap{
+	"env": os.Getenv,
+	"default": func(fallback, value any) any {
+		if value == nil || reflect.ValueOf(value).IsZero() {
+			return fallback
+		}
+		return value
+	},
+}
+
+// render returns the text of the file at path rendered as a template, or the text unchanged if t is nil.
+func (t *fileTemplating) render(path, text string) (string, error) {
+	if t == nil {
+		return text, nil
+	}
+	tmpl, err := template.New(filepath.Base(path)).Option("missingkey=error").Funcs(templateFuncs).Funcs(t.funcs).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var rendered strings.Builder
+	if err := tmpl.Execute(&rendered, t.data); err != nil {
+		return "", err
+	}
+	return rendered.String(), nil
+}
+
+// UseConfigFiles enables loading configuration from a base file and layers of overrides, like a config.ini with a
+// config.local.ini. It's shorthand for UseLayeredFiles without options.
+//
+// Example:
+//
+//	qcl.Load(&defaultConfig, qcl.UseConfigFiles("config.ini", "config.local.ini"), qcl.UseEnv())
+func UseConfigFiles(paths ...string) LoadOption {
+	return UseLayeredFiles(paths)
+}
+
+// UseLayeredFiles enables loading configuration from several files, applied in order and deep-merged, so that a base
+// file can be refined by environment- or machine-specific ones. Each file after the first:
+//
+//   - overrides the scalar fields it sets, leaving the others as earlier files set them,
+//   - merges into struct and map fields, entry by entry, and
+//   - replaces slice fields, or appends to them with WithFileAppendSlices.
+//
+// Each file is read as by UseFile, and the files needn't share a format, though a struct tag overriding a field's
+// name only applies to files of the tag's format. The first file must exist, but later files
+// that don't are skipped, so local overrides can be left out of version control. The source is named "files:"
+// followed by the comma-separated paths.
+//
+// Example:
+//
+//	qcl.UseLayeredFiles([]string{"config.hcl", "config.prod.hcl"}, qcl.WithFileAppendSlices())
+func UseLayeredFiles(paths []string, opts ...fileOption) LoadOption {
+	fileConf := fileConfig{separator: ","}
+	for _, opt := range opts {
+		opt(&fileConf)
+	}
+	name := "files:" + strings.Join(paths, ",")
+	return func(o *LoadConfig) {
+		o.Sources = append(o.Sources, name)
+		o.Loaders[name] = loadFromLayeredFiles(paths, fileConf.forLoad(o, name))
+		o.watchers = append(o.watchers, watchFiles(func() []string { return withProfile(paths, o.profile) }, fileConf.watchInterval))
+	}
+}
+
+// WithFileAppendSlices makes files layered with UseLayeredFiles append to slice fields set by earlier files, instead
+// of replacing them.
+func WithFileAppendSlices() fileOption {
+	return func(c *fileConfig) {
+		c.appendSlices = true
+	}
+}
+
+func loadFromFile(path string, fileConf *fileConfig) Loader {
+	return func(config any) error {
+		if reflect.TypeOf(config).Kind() != reflect.Ptr {
+			return ConfigTypeError
+		}
+		val := reflect.ValueOf(config).Elem()
+		if val.Kind() != reflect.Struct {
+			return ConfigTypeError
+		}
+
+		return setLayers(val, fileConf.withProfile([]string{path}), fileConf, false, os.ReadFile)
+	}
+}
+
+func loadFromLayeredFiles(paths []string, fileConf *fileConfig) Loader {
+	return func(config any) error {
+		if reflect.TypeOf(config).Kind() != reflect.Ptr {
+			return ConfigTypeError
+		}
+		val := reflect.ValueOf(config).Elem()
+		if val.Kind() != reflect.Struct {
+			return ConfigTypeError
+		}
+
+		return setLayers(val, fileConf.withProfile(paths), fileConf, false, os.ReadFile)
+	}
+}
+
+// setLayers sets the struct val from each of the files in turn, merging each into what the earlier ones set. Unless
+// dropIns is set, the first file replaces maps and slices, and must exist; drop-ins all merge, including the first,
+// into what earlier sources set. Files that don't exist are otherwise skipped. Files are read with readFile.
+func setLayers(val reflect.Value, paths []string, fileConf *fileConfig, dropIns bool, readFile func(string) ([]byte, error)) error {
+	for i, path := range paths {
+		merge := dropIns || i > 0
+		tree, format, err := decodeFile(path, fileConf.format, readFile, fileConf.templating)
+		if merge && errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		opts := fileConf.treeOptions(path, format)
+		opts.mergeMaps, opts.appendSlices = merge, merge && fileConf.appendSlices
+		if err := fileConf.setTree(val, tree, opts); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// UseEmbeddedFile enables loading configuration from a file in a file system, typically an embed.FS, so binaries can
+// ship with compiled-in defaults. It's meant to come first in the source order, so that files, the environment and
+// flags loaded after it override its values. The file is read as by UseFile, and the source is named "embed:"
+// followed by the path.
+//
+// Example:
+//
+//	//go:embed defaults.ini
+//	var defaults embed.FS
+//
+//	qcl.Load(&Config{}, qcl.UseEmbeddedFile(defaults, "defaults.ini"), qcl.UseEnv(), qcl.UseFlags())
+func UseEmbeddedFile(fsys fs.FS, path string, opts ...fileOption) LoadOption {
+	fileConf := fileConfig{separator: ","}
+	for _, opt := range opts {
+		opt(&fileConf)
+	}
+	name := "embed:" + path
+	return func(o *LoadConfig) {
+		o.Sources = append(o.Sources, name)
+		o.Loaders[name] = loadFromFS(fsys, path, fileConf.forLoad(o, name))
+	}
+}
+
+func loadFromFS(fsys fs.FS, path string, fileConf *fileConfig) Loader {
+	return func(config any) error {
+		if reflect.TypeOf(config).Kind() != reflect.Ptr {
+			return ConfigTypeError
+		}
+		val := reflect.ValueOf(config).Elem()
+		if val.Kind() != reflect.Struct {
+			return ConfigTypeError
+		}
+
+		return setLayers(val, fileConf.withProfile([]string{path}), fileConf, false, func(path string) ([]byte, error) {
+			return fs.ReadFile(fsys, path)
+		})
+	}
+}
+
+// UseConfigDir enables loading configuration from drop-in fragments, every file matching a glob pattern like
+// "/etc/myapp/conf.d/*.ini", so packaging or configuration management tools can add settings without editing a
+// shared file. The pattern's syntax is that of filepath.Match.
+//
+// Matching files are loaded in lexical order, so fragments are conventionally named with a numeric prefix, like
+// 10-database.ini, and merged as by UseLayeredFiles, except that the first fragment also merges into the maps set by
+// earlier sources. Directories are skipped, and no matching files isn't an error. The pattern is matched each time
+// the config is loaded. The source is named "dir:" followed by the pattern.
+//
+// Example:
+//
+//	qcl.Load(&defaultConfig, qcl.UseFile("/etc/myapp/config.ini"), qcl.UseConfigDir("/etc/myapp/conf.d/*.ini"))
+func UseConfigDir(pattern string, opts ...fileOption) LoadOption {
+	fileConf := fileConfig{separator: ","}
+	for _, opt := range opts {
+		opt(&fileConf)
+	}
+	name := "dir:" + pattern
+	return func(o *LoadConfig) {
+		o.Sources = append(o.Sources, name)
+		o.Loaders[name] = loadFromConfigDir(pattern, fileConf.forLoad(o, name))
+		o.watchers = append(o.watchers, watchFiles(func() []string { return globPaths(pattern) }, fileConf.watchInterval))
+	}
+}
+
+func loadFromConfigDir(pattern string, fileConf *fileConfig) Loader {
+	return func(config any) error {
+		if reflect.TypeOf(config).Kind() != reflect.Ptr {
+			return ConfigTypeError
+		}
+		val := reflect.ValueOf(config).Elem()
+		if val.Kind() != reflect.Struct {
+			return ConfigTypeError
+		}
+
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return fmt.Errorf("%s: %w", pattern, err)
+		}
+		paths := make([]string, 0, len(matches))
+		for _, path := range matches {
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				paths = append(paths, path)
+			}
+		}
+		sort.Strings(paths)
+		return setLayers(val, paths, fileConf, true, os.ReadFile)
+	}
+}
+
+// decodeFile reads the file at path with read and decodes it, returning its tree and format. The format is detected
+// from the file's extension if it's empty.
+func decodeFile(path, format string, read func(string) ([]byte, error), templating *fileTemplating) (map[string]any, string, error) {
+	if format == "" {
+		format = fileExtensions[strings.ToLower(filepath.Ext(path))]
+	}
+	decode, ok := fileFormats[format]
+	if !ok {
+		return nil, "", UnsupportedFormatError{Path: path, Format: format}
+	}
+	data, err := read(path)
+	if err != nil {
+		return nil, "", err
+	}
+	text, err := decodeText(data)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", path, err)
+	}
+	if text, err = templating.render(path, text); err != nil {
+		return nil, "", fmt.Errorf("%s: %w", path, err)
+	}
+	tree, err := decode(text)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", path, err)
+	}
+	return tree, format, nil
+}
+
+// decodeText returns the text of a file as UTF-8 with Unix line endings. A UTF-8 byte order mark is dropped, and
+// UTF-16 text, as written by many Windows editors, is rejected with UTF16Error rather than misread.
+func decodeText(data []byte) (string, error) {
+	if isUTF16(data) {
+		return "", UTF16Error
+	}
+	text := string(bytes.TrimPrefix(data, []byte{0xEF, 0xBB, 0xBF}))
+	if !utf8.ValidString(text) || strings.IndexByte(text, 0) >= 0 {
+		return "", fmt.Errorf("file is not valid UTF-8 text")
+	}
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	return strings.ReplaceAll(text, "\r", "\n"), nil
+}
+
+// isUTF16 reports whether data is UTF-16 text: with a byte order mark, or without one if it starts with ASCII
+// characters, which UTF-16 encodes as a byte of the character and a NUL byte, in one order or the other.
+func isUTF16(data []byte) bool {
+	if bytes.HasPrefix(data, []byte{0xFF, 0xFE}) || bytes.HasPrefix(data, []byte{0xFE, 0xFF}) {
+		return true
+	}
+	n := len(data) &^ 1
+	if n == 0 {
+		return false
+	}
+	if n > 32 {
+		n = 32
+	}
+	little, big := true, true
+	for i := 0; i < n; i += 2 {
+		little = little && data[i] != 0 && data[i+1] == 0
+		big = big && data[i] == 0 && data[i+1] != 0
+	}
+	return little || big
+}
+
+// treeOptions control how a tree decoded from a file sets a config.
+type treeOptions struct {
+	tag          string // tag is the struct tag overriding field names, named after the file's format.
+	parse        parseOptions
+	mergeMaps    bool // mergeMaps merges entries into maps, instead of replacing them.
+	appendSlices bool // appendSlices appends to slices, instead of replacing them.
+	strict       bool // strict makes keys that don't match a field errors.
+
+	file   string                 // file is the path of the file the tree was decoded from.
+	path   string                 // path is the dotted path of the field the tree sets, empty for the config itself.
+	key    string                 // key is the dotted key of the tree in the file, empty for the whole file.
+	record func(path, key string) // record, if not nil, receives the key each field is set from, for provenance.
+}
+
+// at returns the options for setting the field named name from the tree's value at key.
+func (opts treeOptions) at(name, key string) treeOptions {
+	if opts.path != "" {
+		name = opts.path + "." + name
+	}
+	if opts.key != "" {
+		key = opts.key + "." + key
+	}
+	opts.path, opts.key = name, key
+	return opts
+}
+
+// setTree sets the struct val from the tree decoded from a file, or from the subtree under the scope set with
+// WithScope. Nothing is set if the file has nothing under the scope.
+func (c *fileConfig) setTree(val reflect.Value, tree map[string]any, opts treeOptions) error {
+	var scope []string
+	for _, part := range c.load.scopePath() {
+		var found bool
+		for k, v := range tree {
+			if sub, ok := v.(map[string]any); ok && normalizeKey(k) == normalizeKey(part) {
+				tree, found = sub, true
+				scope = append(scope, k)
+				break
+			}
+		}
+		if !found {
+			return nil
+		}
+	}
+	opts.key = strings.Join(scope, ".")
+	err := setTree(val, tree, opts)
+	if opts.key != "" {
+		visitErrors(err, func(err error) {
+			switch err := err.(type) {
+			case *FieldError:
+				err.Key = opts.key + "." + err.Key
+			case *UnknownKeyError:
+				err.Key = opts.key + "." + err.Key
+			}
+		})
+	}
+	return c.load.ignoreUnknownKeys(c.source, err)
+}
+
+// setTree sets the fields of the struct val from the tree decoded from a file. Keys are matched to fields by the
+// struct tag named opts.tag, or else by name, ignoring case, underscores and dashes. Keys that don't match a field
+// are ignored, unless opts.strict is set, and nil pointers are only allocated if the tree has a value for them.
+func setTree(val reflect.Value, tree map[string]any, opts treeOptions) error {
+	keys := make(map[string]string, len(tree))
+	for k := range tree {
+		keys[normalizeKey(k)] = k
+	}
+	matched := make(map[string]bool, len(tree))
+	errs := setTreeFields(val, tree, keys, matched, opts)
+	if opts.strict {
+		for _, k := range sortedKeys(tree) {
+			if !matched[k] {
+				errs = append(errs, &UnknownKeyError{Key: k})
+			}
+		}
+	}
+	return joinErrors(errs)
+}
+
+// setTreeFields sets the fields of the struct val, including those of embedded structs, from the tree, whose keys are
+// indexed by their normalized form. The keys that match a field are added to matched.
+func setTreeFields(val reflect.Value, tree map[string]any, keys map[string]string, matched map[string]bool, opts treeOptions) []error {
+	typ := val.Type()
+	var errs []error
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		if !sf.IsExported() || skipField(sf) {
+			continue
+		}
+		field := val.Field(i)
+		if sf.Anonymous && indirectType(sf.Type).Kind() == reflect.Struct {
+			errs = append(errs, setTreeFields(allocate(field), tree, keys, matched, opts)...)
+			continue
+		}
+		name := sf.Name
+		tag := qclTag(sf)
+		if tag.name != "" {
+			name = tag.name
+		}
+		if t, ok := sf.Tag.Lookup(opts.tag); ok && tagName(t) != "" {
+			name = tagName(t)
+		}
+		if tag.file != "" {
+			name = tag.file
+		}
+		key, ok := keys[normalizeKey(name)]
+		if !ok {
+			continue
+		}
+		matched[key] = true
+		fieldOpts := opts.at(sf.Name, key)
+		if err := setTreeValue(rawField(sf, field), tree[key], fieldOpts); err != nil {
+			errs = append(errs, nestFieldErrors(sf.Name, key, tree[key], err)...)
+		} else if opts.record != nil {
+			opts.record(fieldOpts.path, opts.file+":"+fieldOpts.key)
+		}
+	}
+	return errs
+}
+
+// nestFieldErrors returns the errors from setting a value of a tree, found at key, into the field at path. FieldErrors
+// and UnknownKeyErrors from within the value, like the fields of a section, are nested under the path and key, and
+// other errors become FieldErrors for the value itself.
+func nestFieldErrors(path, key string, value any, err error) []error {
+	errs := []error{err}
+	if multi, ok := err.(*MultiError); ok {
+		errs = multi.Errors
+	}
+	nested := make([]error, len(errs))
+	for i, err := range errs {
+		switch err := err.(type) {
+		case *FieldError:
+			nested[i] = &FieldError{Path: path + "." + err.Path, Key: key + "." + err.Key, RawValue: err.RawValue, Err: err.Err}
+			continue
+		case *UnknownKeyError:
+			nested[i] = &UnknownKeyError{Key: key + "." + err.Key}
+			continue
+		}
+		nested[i] = &FieldError{Path: path, Key: key, RawValue: treeRawValue(value), Err: err}
+	}
+	return nested
+}
+
+// treeRawValue returns a value of a tree decoded from a file as it would be written in an environment variable.
+func treeRawValue(value any) string {
+	switch value := value.(type) {
+	case string:
+		return value
+	case []string:
+		return strings.Join(value, ",")
+	}
+	return fmt.Sprint(value)
+}
+
+// setTreeValue sets v from a value of a tree decoded from a file.
+func setTreeValue(v reflect.Value, value any, opts treeOptions) error {
+	v = allocate(v)
+	if set, ok := rawSetter(v); ok {
+		text, err := rawText(value)
+		if err != nil {
+			return err
+		}
+		return set(text)
+	}
+	_, custom := customSetter(v)
+	switch value := value.(type) {
+	case map[string]any:
+		switch {
+		case v.Kind() == reflect.Struct && !custom:
+			return setTree(v, value, opts)
+		case v.Kind() == reflect.Map:
+			keys, values := make([]string, 0, len(value)), make([]string, 0, len(value))
+			for _, k := range sortedKeys(value) {
+				s, ok := value[k].(string)
+				if !ok {
+					return setTreeMap(v, value, opts)
+				}
+				keys, values = append(keys, k), append(values, s)
+			}
+			opts.reset(v)
+			return opts.parse.setMapKeysAndValues(v, keys, values)
+		case isStructSlice(v):
+			return setTreeIndexes(v, value, opts)
+		case isPolymorphic(v):
+			return setTreeImplementation(v, value, opts)
+		}
+		return fmt.Errorf("a section can't set a field of type %s", v.Type())
+	case []map[string]any:
+		if !isStructSlice(v) {
+			return fmt.Errorf("a list of objects can't set a field of type %s", v.Type())
+		}
+		opts.reset(v)
+		start := v.Len()
+		if err := growSlice(v, start+len(value), opts.path); err != nil {
+			return err
+		}
+		var errs []error
+		for i, tree := range value {
+			index := strconv.Itoa(start + i)
+			if err := setTree(allocate(v.Index(start+i)), tree, opts.at(index, index)); err != nil {
+				errs = append(errs, nestFieldErrors(index, index, tree, err)...)
+			}
+		}
+		return joinErrors(errs)
+	case []string:
+		if v.Kind() == reflect.Slice && !custom {
+			opts.reset(v)
+			return opts.parse.setSliceValues(v, value)
+		}
+		return opts.parse.setField(v, strings.Join(value, opts.parse.separator))
+	case string:
+		if !custom && (v.Kind() == reflect.Map && opts.mergeMaps || v.Kind() == reflect.Slice && opts.appendSlices) {
+			return opts.parse.addValues(v, value, false)
+		}
+		return opts.parse.setField(v, value)
+	}
+	return UnsupportedTypeError{v.Kind()}
+}
+
+// setTreeImplementation sets the interface v, which has registered implementations, from a section whose type key
+// names the implementation, if it isn't the one v holds already, and whose other keys set its fields.
+func setTreeImplementation(v reflect.Value, tree map[string]any, opts treeOptions) error {
+	var name string
+	fields := make(map[string]any, len(tree))
+	for k, value := range tree {
+		if normalizeKey(k) != normalizeKey(discriminatorKey) {
+			fields[k] = value
+			continue
+		}
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s must be a string", k)
+		}
+		name = s
+	}
+	impl, err := selectImplementation(v, name)
+	if err != nil {
+		return err
+	}
+	if !impl.IsValid() {
+		return fmt.Errorf("a section setting a field of type %s needs a %s, one of %s", v.Type(), discriminatorKey, implementationNames(v.Type()))
+	}
+	return setTree(impl, fields, opts)
+}
+
+// reset zeroes v if it's a map or a slice that the tree's values replace, rather than merge into.
+func (opts treeOptions) reset(v reflect.Value) {
+	if (v.Kind() == reflect.Map && !opts.mergeMaps) || (v.Kind() == reflect.Slice && !opts.appendSlices) {
+		v.Set(reflect.Zero(v.Type()))
+	}
+}
+
+// setTreeMap sets the map v from a tree whose values aren't all strings, e.g. labeled blocks setting a
+// map[string]Struct, by setting each entry as its own value. When merging, existing entries are updated in place. New
+// struct entries start with the defaults their fields are tagged with.
+func setTreeMap(v reflect.Value, tree map[string]any, opts treeOptions) error {
+	opts.reset(v)
+	if v.IsNil() {
+		v.Set(reflect.MakeMapWithSize(v.Type(), len(tree)))
+	}
+	var errs []error
+	for _, k := range sortedKeys(tree) {
+		key := reflect.New(v.Type().Key()).Elem()
+		if err := opts.parse.setField(key, k); err != nil {
+			errs = append(errs, &FieldError{Path: k, Key: k, RawValue: k, Err: err})
+			continue
+		}
+		elem := reflect.New(v.Type().Elem()).Elem()
+		if existing := v.MapIndex(key); existing.IsValid() {
+			elem.Set(existing)
+		} else if isStructMap(v) {
+			if err := applyTagDefaults(allocate(elem), k, map[reflect.Type]bool{}); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+		}
+		if err := setTreeValue(elem, tree[k], opts.at(k, k)); err != nil {
+			errs = append(errs, nestFieldErrors(k, k, tree[k], err)...)
+			continue
+		}
+		v.SetMapIndex(key, elem)
+	}
+	return joinErrors(errs)
+}
+
+// setTreeIndexes sets the slice of structs v from a tree whose keys are indexes, e.g. the sections [servers.0] and
+// [servers.1], by setting the elements at those indexes. Like the indexed environment variables and flags, they
+// update the elements in place, and the slice is grown to fit them.
+func setTreeIndexes(v reflect.Value, tree map[string]any, opts treeOptions) error {
+	var errs []error
+	indexes := make(map[string]int, len(tree))
+	n := 0
+	for _, k := range sortedKeys(tree) {
+		i, err := strconv.Atoi(k)
+		if err != nil || i < 0 || strconv.Itoa(i) != k {
+			errs = append(errs, &FieldError{Path: k, Key: k, RawValue: treeRawValue(tree[k]), Err: fmt.Errorf("%q isn't a slice index", k)})
+			continue
+		}
+		indexes[k] = i
+		if i+1 > n {
+			n = i + 1
+		}
+	}
+	if err := growSlice(v, n, opts.path); err != nil {
+		return err
+	}
+	for _, k := range sortedKeys(indexes) {
+		if err := setTreeValue(v.Index(indexes[k]), tree[k], opts.at(k, k)); err != nil {
+			errs = append(errs, nestFieldErrors(k, k, tree[k], err)...)
+		}
+	}
+	return joinErrors(errs)
+}
+
+// normalizeKey returns the form of a key or field name used to match them, lowercased and without underscores,
+// dashes and spaces.
+func normalizeKey(key string) string {
+	return strings.ToLower(strings.NewReplacer("_", "", "-", "", " ", "").Replace(key))
+}
+
+// allocate returns the value v points to, allocating it if v is a nil pointer, or v itself if it isn't a pointer.
+func allocate(v reflect.Value) reflect.Value {
+	if v.Kind() != reflect.Ptr {
+		return v
+	}
+	if v.IsNil() {
+		v.Set(reflect.New(v.Type().Elem()))
+	}
+	return v.Elem()
+}
+
+func indirectType(typ reflect.Type) reflect.Type {
+	if typ.Kind() == reflect.Ptr {
+		return typ.Elem()
+	}
+	return typ
+}
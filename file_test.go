@@ -0,0 +1,394 @@
+package qcl
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"text/template"
+	"time"
+	"unicode/utf16"
+)
+
+type TestFileConfig struct {
+	Name    string
+	Timeout time.Duration
+	Hosts   []string
+	Labels  map[string]string
+	DB      *struct {
+		Host     string
+		Port     int
+		Replicas []string
+	} `ini:"database"`
+	Window TimeRange
+	TestConfig
+}
+
+// writeFile writes the data to a file with the given name in a temporary directory, and returns its path.
+func writeFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func utf16File(s string, bigEndian bool) []byte {
+	units := utf16.Encode([]rune(s))
+	data := []byte{0xFF, 0xFE}
+	if bigEndian {
+		data = []byte{0xFE, 0xFF}
+	}
+	for _, u := range units {
+		if bigEndian {
+			data = append(data, byte(u>>8), byte(u))
+		} else {
+			data = append(data, byte(u), byte(u>>8))
+		}
+	}
+	return data
+}
+
+func Test_decodeText(t *testing.T) {
+	tests := map[string]struct {
+		input   []byte
+		want    string
+		wantErr string
+	}{
+		"utf-8":           {input: []byte("a = b\nc = d"), want: "a = b\nc = d"},
+		"utf-8 bom":       {input: []byte("\xEF\xBB\xBFa = b"), want: "a = b"},
+		"crlf":            {input: []byte("a = b\r\nc = d\r\n"), want: "a = b\nc = d\n"},
+		"cr":              {input: []byte("a = b\rc = d"), want: "a = b\nc = d"},
+		"utf-16le":        {input: utf16File("a = é\r\n", false), wantErr: UTF16Error.Error()},
+		"utf-16be":        {input: utf16File("a = é\r\n", true), wantErr: UTF16Error.Error()},
+		"utf-16le no bom": {input: utf16File("a = b", false)[2:], wantErr: UTF16Error.Error()},
+		"utf-16be no bom": {input: utf16File("a = b", true)[2:], wantErr: UTF16Error.Error()},
+		"nul":             {input: []byte("a = b\x00c"), wantErr: "file is not valid UTF-8 text"},
+		"invalid utf-8":   {input: []byte("a = \xFF"), wantErr: "file is not valid UTF-8 text"},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := decodeText(test.input)
+			var msg string
+			if err != nil {
+				msg = err.Error()
+			}
+			if msg != test.wantErr {
+				t.Fatalf("decodeText() error = %v, want %q", err, test.wantErr)
+			}
+			if got != test.want {
+				t.Errorf("decodeText() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func Test_UseFile(t *testing.T) {
+	ini := "name = app\r\ntimeout = 5s\r\nhosts = a,b\r\nhost = localhost\r\n" +
+		"window = 2023-01-01T02:00:00Z/2023-01-01T04:00:00Z\r\n" +
+		"[database]\r\nhost = db\r\nport = 5432\r\nreplicas[] = r1\r\nreplicas[] = r2\r\n" +
+		"[labels]\r\nteam = payments\r\n"
+	start, _ := time.Parse(time.RFC3339, "2023-01-01T02:00:00Z")
+	want := &TestFileConfig{
+		Name:    "app",
+		Timeout: 5 * time.Second,
+		Hosts:   []string{"a", "b"},
+		Labels:  map[string]string{"team": "payments"},
+		Window:  TimeRange{Start: start, End: start.Add(2 * time.Hour)},
+		TestConfig: TestConfig{
+			Host: "localhost",
+			Port: 8080,
+		},
+	}
+	want.DB = &struct {
+		Host     string
+		Port     int
+		Replicas []string
+	}{"db", 5432, []string{"r1", "r2"}}
+
+	tests := map[string]struct {
+		name string
+		data []byte
+		opts []fileOption
+	}{
+		"ini":           {name: "config.ini", data: []byte(ini)},
+		"utf-8 bom ini": {name: "config.INI", data: append([]byte("\xEF\xBB\xBF"), ini...)},
+		"format option": {name: "config", data: []byte(ini), opts: []fileOption{WithFileFormat("ini")}},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := writeFile(t, test.name, test.data)
+			got, err := Load(&TestFileConfig{Hosts: []string{"default"}, TestConfig: TestConfig{Port: 8080}}, UseFile(path, test.opts...))
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Load() got = %+v, want %+v", got, want)
+			}
+		})
+	}
+	t.Run("separator", func(t *testing.T) {
+		path := writeFile(t, "config.ini", []byte("hosts = a;b"))
+		got, err := Load(&TestFileConfig{}, UseFile(path, WithFileSeparator(";")))
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		if !reflect.DeepEqual(got.Hosts, []string{"a", "b"}) {
+			t.Errorf("Load() Hosts = %v, want %v", got.Hosts, []string{"a", "b"})
+		}
+		if got.DB != nil {
+			t.Errorf("Load() allocated DB = %+v, want nil", got.DB)
+		}
+	})
+	t.Run("unknown format", func(t *testing.T) {
+		path := writeFile(t, "config.conf", []byte("a = b"))
+		_, err := Load(&TestFileConfig{}, UseFile(path))
+		if !errors.As(err, new(UnsupportedFormatError)) {
+			t.Errorf("Load() error = %v, want UnsupportedFormatError", err)
+		}
+	})
+	t.Run("missing file", func(t *testing.T) {
+		_, err := Load(&TestFileConfig{}, UseFile(filepath.Join(t.TempDir(), "missing.ini")))
+		if !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("Load() error = %v, want %v", err, os.ErrNotExist)
+		}
+	})
+	t.Run("invalid value", func(t *testing.T) {
+		path := writeFile(t, "config.ini", []byte("[database]\nport = x"))
+		if _, err := Load(&TestFileConfig{}, UseFile(path)); err == nil {
+			t.Error("Load() error = nil, want an error")
+		}
+	})
+	t.Run("section for scalar field", func(t *testing.T) {
+		path := writeFile(t, "config.ini", []byte("[name]\na = b"))
+		if _, err := Load(&TestFileConfig{}, UseFile(path)); err == nil {
+			t.Error("Load() error = nil, want an error")
+		}
+	})
+}
+
+func Test_WithTemplating(t *testing.T) {
+	t.Setenv("TEST_REGION", "eu-west-1")
+	tests := map[string]struct {
+		data    string
+		funcs   template.FuncMap
+		want    string
+		wantErr bool
+	}{
+		"env":          {data: `name = {{ env "TEST_REGION" }}`, want: "eu-west-1"},
+		"default":      {data: `name = {{ env "TEST_UNSET" | default "us-east-1" }}`, want: "us-east-1"},
+		"data":         {data: `name = {{ .App }}-{{ env "TEST_REGION" }}`, want: "myapp-eu-west-1"},
+		"funcs":        {data: `name = {{ upper .App }}`, funcs: template.FuncMap{"upper": strings.ToUpper}, want: "MYAPP"},
+		"missing key":  {data: `name = {{ .Stage }}`, wantErr: true},
+		"invalid":      {data: `name = {{ env }`, wantErr: true},
+		"unknown func": {data: `name = {{ lower .App }}`, wantErr: true},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := writeFile(t, "config.ini", []byte(test.data))
+			got, err := Load(&TestFileConfig{}, UseFile(path, WithTemplating(test.funcs, map[string]string{"App": "myapp"})))
+			if test.wantErr {
+				if err == nil || !strings.Contains(err.Error(), path) {
+					t.Errorf("Load() error = %v, want an error for %s", err, path)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if got.Name != test.want {
+				t.Errorf("Load() Name = %q, want %q", got.Name, test.want)
+			}
+		})
+	}
+	t.Run("disabled", func(t *testing.T) {
+		path := writeFile(t, "config.ini", []byte(`name = {{ env "TEST_REGION" }}`))
+		got, err := Load(&TestFileConfig{}, UseFile(path))
+		if err != nil || got.Name != `{{ env "TEST_REGION" }}` {
+			t.Errorf("Load() Name = %q, %v, want the template left as it is", got.Name, err)
+		}
+	})
+}
+
+func Test_UseLayeredFiles(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "config.ini")
+	override := filepath.Join(dir, "config.local.hcl")
+	if err := os.WriteFile(base, []byte("name = app\nhosts = a,b\n[database]\nhost = db\nport = 5432\n[labels]\nteam = payments\ntier = 1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(override, []byte("hosts = [\"c\"]\ndb {\n  port = 6432\n}\nlabels = { tier = \"2\" }\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing.ini")
+
+	tests := map[string]struct {
+		paths     []string
+		opts      []fileOption
+		wantHosts []string
+		wantPort  int
+		wantErr   bool
+	}{
+		"override":        {paths: []string{base, override}, wantHosts: []string{"c"}, wantPort: 6432},
+		"append slices":   {paths: []string{base, override}, opts: []fileOption{WithFileAppendSlices()}, wantHosts: []string{"a", "b", "c"}, wantPort: 6432},
+		"base only":       {paths: []string{base}, wantHosts: []string{"a", "b"}, wantPort: 5432},
+		"missing layer":   {paths: []string{base, missing}, wantHosts: []string{"a", "b"}, wantPort: 5432},
+		"missing base":    {paths: []string{missing, base}, wantErr: true},
+		"unknown format":  {paths: []string{base, filepath.Join(dir, "config.conf")}, wantErr: true},
+		"no files loaded": {paths: nil, wantHosts: []string{"default"}},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := Load(&TestFileConfig{Hosts: []string{"default"}}, UseLayeredFiles(test.paths, test.opts...))
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if test.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(got.Hosts, test.wantHosts) {
+				t.Errorf("Load() Hosts = %v, want %v", got.Hosts, test.wantHosts)
+			}
+			if test.paths == nil {
+				return
+			}
+			if got.Name != "app" || got.DB.Host != "db" || got.DB.Port != test.wantPort {
+				t.Errorf("Load() Name = %q, DB = %+v, want app, db and port %d", got.Name, *got.DB, test.wantPort)
+			}
+			wantLabels := map[string]string{"team": "payments", "tier": "1"}
+			if len(test.paths) > 1 && test.paths[1] == override {
+				wantLabels["tier"] = "2"
+			}
+			if !reflect.DeepEqual(got.Labels, wantLabels) {
+				t.Errorf("Load() Labels = %v, want %v", got.Labels, wantLabels)
+			}
+		})
+	}
+	t.Run("maps of structs", func(t *testing.T) {
+		first := writeFile(t, "a.hcl", []byte("service \"web\" {\n  port = 80\n  hosts = [\"a\"]\n}\nservice \"api\" {\n  port = 81\n}"))
+		second := writeFile(t, "b.hcl", []byte("service \"web\" {\n  port = 8080\n}"))
+		got, err := Load(&TestHCLConfig{}, UseConfigFiles(first, second))
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		want := map[string]TestHCLService{"web": {Port: 8080, Hosts: []string{"a"}}, "api": {Port: 81}}
+		if !reflect.DeepEqual(got.Services, want) {
+			t.Errorf("Load() Services = %+v, want %+v", got.Services, want)
+		}
+	})
+}
+
+func Test_UseConfigDir(t *testing.T) {
+	dir := t.TempDir()
+	confd := filepath.Join(dir, "conf.d")
+	files := map[string]string{
+		filepath.Join(dir, "config.ini"):                "name = base\n[labels]\nteam = payments\n",
+		filepath.Join(confd, "20-name.ini"):             "name = second\n",
+		filepath.Join(confd, "10-name.ini"):             "name = first\nhosts = a\n",
+		filepath.Join(confd, "30-labels.hcl"):           "labels = { tier = \"1\" }\n",
+		filepath.Join(confd, "sub.ini", "nested.ini"):   "name = nested\n",
+		filepath.Join(dir, "bad.d", "10-bad.ini"):       "[database]\nport = x\n",
+		filepath.Join(dir, "unknown.d", "10-bad.conf"):  "a = b\n",
+		filepath.Join(dir, "merging.d", "10-hosts.ini"): "hosts = b\n",
+	}
+	for path, data := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := map[string]struct {
+		opts       []LoadOption
+		wantName   string
+		wantHosts  []string
+		wantLabels map[string]string
+		wantErr    bool
+	}{
+		"fragments in order": {
+			opts:       []LoadOption{UseFile(filepath.Join(dir, "config.ini")), UseConfigDir(filepath.Join(confd, "*"))},
+			wantName:   "second",
+			wantHosts:  []string{"a"},
+			wantLabels: map[string]string{"team": "payments", "tier": "1"},
+		},
+		"no matches": {
+			opts:       []LoadOption{UseFile(filepath.Join(dir, "config.ini")), UseConfigDir(filepath.Join(dir, "none.d", "*.ini"))},
+			wantName:   "base",
+			wantLabels: map[string]string{"team": "payments"},
+		},
+		"append slices": {
+			opts: []LoadOption{
+				UseConfigDir(filepath.Join(confd, "10-*.ini")),
+				UseConfigDir(filepath.Join(dir, "merging.d", "*.ini"), WithFileAppendSlices()),
+			},
+			wantName:  "first",
+			wantHosts: []string{"a", "b"},
+		},
+		"invalid fragment": {opts: []LoadOption{UseConfigDir(filepath.Join(dir, "bad.d", "*"))}, wantErr: true},
+		"unknown format":   {opts: []LoadOption{UseConfigDir(filepath.Join(dir, "unknown.d", "*"))}, wantErr: true},
+		"bad pattern":      {opts: []LoadOption{UseConfigDir(filepath.Join(dir, "["))}, wantErr: true},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := Load(&TestFileConfig{}, test.opts...)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if test.wantErr {
+				return
+			}
+			if got.Name != test.wantName || !reflect.DeepEqual(got.Hosts, test.wantHosts) || !reflect.DeepEqual(got.Labels, test.wantLabels) {
+				t.Errorf("Load() = %+v, want Name %q, Hosts %v and Labels %v", got, test.wantName, test.wantHosts, test.wantLabels)
+			}
+		})
+	}
+}
+
+func Test_UseEmbeddedFile(t *testing.T) {
+	fsys := fstest.MapFS{
+		"defaults.ini":     {Data: []byte("name = embedded\nhosts = a,b\n[database]\nport = 5432\n")},
+		"config/base.conf": {Data: []byte("name = conf\n")},
+		"invalid.ini":      {Data: []byte("[database]\nport = x\n")},
+	}
+	t.Setenv("NAME", "env")
+
+	tests := map[string]struct {
+		path      string
+		opts      []fileOption
+		env       bool
+		wantName  string
+		wantHosts []string
+		wantErr   bool
+	}{
+		"defaults":           {path: "defaults.ini", wantName: "embedded", wantHosts: []string{"a", "b"}},
+		"overridden by env":  {path: "defaults.ini", env: true, wantName: "env", wantHosts: []string{"a", "b"}},
+		"format option":      {path: "config/base.conf", opts: []fileOption{WithFileFormat("ini")}, wantName: "conf"},
+		"missing file":       {path: "missing.ini", wantErr: true},
+		"invalid value":      {path: "invalid.ini", wantErr: true},
+		"unsupported format": {path: "config/base.conf", wantErr: true},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			opts := []LoadOption{UseEmbeddedFile(fsys, test.path, test.opts...)}
+			if test.env {
+				opts = append(opts, UseEnv())
+			}
+			got, err := Load(&TestFileConfig{}, opts...)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if test.wantErr {
+				return
+			}
+			if got.Name != test.wantName || !reflect.DeepEqual(got.Hosts, test.wantHosts) {
+				t.Errorf("Load() = %+v, want Name %q and Hosts %v", got, test.wantName, test.wantHosts)
+			}
+		})
+	}
+}
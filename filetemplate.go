@@ -0,0 +1,213 @@
+package qcl
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GenerateFileTemplate renders a sample config file in the format, "hcl" or "ini", with every field of the config, a
+// struct or a pointer to one, set to its default, the value in the config after the default tags and SetDefaults
+// methods are applied, so that operators can bootstrap a deployment from a file that loads as is. Keys are named the
+// way UseFile matches them, following the struct tag named after the format and the name and file options of the `qcl`
+// tag, and nested structs become blocks or sections. Each key is preceded by a comment with the field's `usage` tag,
+// if it has one, and its type:
+//
+//	type Config struct {
+//		Host string        `usage:"the address to listen on"`
+//		Wait time.Duration `default:"5s"`
+//		DB   struct {
+//			Port int
+//		}
+//	}
+//
+//	sample, err := qcl.GenerateFileTemplate(&Config{Host: "localhost"}, "hcl")
+//
+// renders:
+//
+//	# the address to listen on (string)
+//	host = "localhost"
+//
+//	# (time.Duration)
+//	wait = "5s"
+//
+//	db {
+//	  # (int)
+//	  port = 0
+//	}
+//
+// Secret fields are commented out rather than set, so that the template holds no secrets. Interface fields with
+// registered implementations are rendered with the key naming the implementation they hold, while slices and maps of
+// structs, whose entries are up to the deployment, are left out. GenerateFileTemplate returns ConfigTypeError if the
+// config isn't a struct or a pointer to one, and an UnsupportedFormatError for formats other than hcl and ini.
+func GenerateFileTemplate(config any, format string) (string, error) {
+	if format != "hcl" && format != "ini" {
+		return "", UnsupportedFormatError{Format: format}
+	}
+	val, err := structCopy(config)
+	if err != nil {
+		return "", err
+	}
+	if err := applyTagDefaults(val, "", map[reflect.Type]bool{}); err != nil {
+		return "", err
+	}
+	callDefaultHooks(val.Addr())
+
+	t := &fileTemplate{format: format}
+	t.writeStruct(val, nil, false)
+	return t.b.String(), nil
+}
+
+// fileTemplate is a sample config file being rendered by GenerateFileTemplate.
+type fileTemplate struct {
+	format string
+	b      strings.Builder
+	opened bool // opened is true right after a block or section is opened, where no blank line is needed.
+}
+
+// separate writes a blank line between what's already written and what comes next, unless nothing is, or a block or
+// section was just opened.
+func (t *fileTemplate) separate() {
+	if t.b.Len() > 0 && !t.opened {
+		t.b.WriteString("\n")
+	}
+	t.opened = false
+}
+
+// templateKey is a field of a struct, rendered as a key of the file.
+type templateKey struct {
+	name   string              // name is the key the field has in the file.
+	sf     reflect.StructField // sf is the field, whose tags document it.
+	value  reflect.Value       // value is the field's value, dereferenced.
+	secret bool                // secret is true if the field's value is kept out of the template.
+}
+
+// writeStruct writes the keys of the struct val, followed by its nested structs as blocks or sections. The section
+// is the path of val's block or section, and secret is true if val is nested in a secret field.
+func (t *fileTemplate) writeStruct(val reflect.Value, section []string, secret bool) {
+	var keys, blocks []templateKey
+	t.collectKeys(val, secret, &keys, &blocks)
+	depth := 0
+	if t.format == "hcl" {
+		depth = len(section)
+	}
+	for _, key := range keys {
+		t.writeKey(key, depth)
+	}
+	for _, block := range blocks {
+		nested := append(append([]string(nil), section...), block.name)
+		t.separate()
+		indent := strings.Repeat("  ", depth)
+		if t.format == "ini" {
+			t.b.WriteString("[" + strings.Join(nested, ".") + "]\n")
+		} else {
+			t.b.WriteString(indent + block.name + " {\n")
+		}
+		t.opened = true
+		fields := block.value
+		if isPolymorphic(block.value) {
+			fields, _ = selectImplementation(block.value, "")
+			typeKey := templateKey{name: discriminatorKey, sf: block.sf, value: discriminator(block.value)}
+			t.writeKey(typeKey, len(nested))
+		}
+		t.writeStruct(fields, nested, block.secret)
+		if t.format == "hcl" {
+			t.b.WriteString(indent + "}\n")
+		}
+	}
+}
+
+// collectKeys adds the fields of the struct val that are rendered as keys to keys, and those rendered as blocks or
+// sections to blocks. The fields of embedded structs are promoted, as UseFile promotes them.
+func (t *fileTemplate) collectKeys(val reflect.Value, secret bool, keys, blocks *[]templateKey) {
+	typ := val.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		if !sf.IsExported() || skipField(sf) {
+			continue
+		}
+		field := val.Field(i)
+		if field.Kind() == reflect.Ptr {
+			if field.IsNil() {
+				field.Set(reflect.New(field.Type().Elem()))
+			}
+			field = field.Elem()
+		}
+		field = rawField(sf, field)
+		key := templateKey{name: t.keyName(sf), sf: sf, value: field, secret: secret || sf.Tag.Get("secret") == "true" || isSecretType(sf.Type)}
+		switch {
+		case sf.Anonymous && field.Kind() == reflect.Struct:
+			t.collectKeys(field, key.secret, keys, blocks)
+		case isPolymorphic(field):
+			if !field.IsNil() {
+				*blocks = append(*blocks, key)
+			}
+		case field.Kind() == reflect.Struct && !isLeafStruct(field.Type()):
+			*blocks = append(*blocks, key)
+		case isStructSlice(field) || isStructMap(field):
+		default:
+			*keys = append(*keys, key)
+		}
+	}
+}
+
+// keyName returns the key UseFile matches the field to: the file or name option of its `qcl` tag, the struct tag named
+// after the format, or its name in snake case.
+func (t *fileTemplate) keyName(sf reflect.StructField) string {
+	tag := qclTag(sf)
+	if tag.file != "" {
+		return tag.file
+	}
+	if name, ok := sf.Tag.Lookup(t.format); ok && tagName(name) != "" {
+		return tagName(name)
+	}
+	if tag.name != "" {
+		return tag.name
+	}
+	return strings.ToLower(strings.Join(splitOnWordBoundaries(sf.Name), "_"))
+}
+
+// writeKey writes the key, with a comment documenting it, indented to the depth of its block in hcl files.
+func (t *fileTemplate) writeKey(key templateKey, depth int) {
+	t.separate()
+	if t.format == "ini" {
+		depth = 0
+	}
+	indent := strings.Repeat("  ", depth)
+	comment := key.value.Type().String()
+	if key.name == discriminatorKey {
+		comment = "the implementation, one of " + implementationNames(key.sf.Type)
+	} else if usage := key.sf.Tag.Get("usage"); usage != "" {
+		comment = usage + " (" + comment + ")"
+	}
+	t.b.WriteString(indent + "# " + strings.ReplaceAll(comment, "\n", "\n"+indent+"# ") + "\n")
+	line := key.name + " = " + t.formatValue(key.value)
+	if key.secret {
+		line = "# " + key.name + " = "
+		if t.format == "hcl" {
+			line += `""`
+		}
+	}
+	t.b.WriteString(indent + strings.TrimSuffix(line, " ") + "\n")
+}
+
+// formatValue renders the value as the format writes it. Values are rendered the way the environment loader parses
+// them, which is how UseFile parses strings; in hcl files, strings are quoted, while numbers and booleans aren't.
+func (t *fileTemplate) formatValue(v reflect.Value) string {
+	s := dumpText(v)
+	if t.format == "ini" {
+		if s != strings.TrimSpace(s) || unquote(s) != s { // quoted, so that decodeINI keeps the spaces or quotes
+			return `"` + s + `"`
+		}
+		return s
+	}
+	switch v.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8,
+		reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		if _, ok := customSetter(v); !ok && v.Type() != reflect.TypeOf(time.Duration(0)) {
+			return s
+		}
+	}
+	return strconv.Quote(s)
+}
@@ -0,0 +1,130 @@
+package qcl
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type TestFileTemplateConfig struct {
+	Host     string        `usage:"the address to listen on"`
+	Wait     time.Duration `default:"5s"`
+	Tags     []string
+	Greeting string `ini:"hello"`
+	Password string `secret:"true"`
+	DB       struct {
+		Port    int
+		Replica *struct {
+			Host string
+		}
+	}
+	Storage TestStorage
+}
+
+func Test_GenerateFileTemplate(t *testing.T) {
+	config := &TestFileTemplateConfig{Host: "localhost", Tags: []string{"a", "b"}, Greeting: " hi ", Password: "hunter2", Storage: &TestDiskStorage{Path: "/var/data"}}
+	tests := map[string]struct {
+		format  string
+		want    string
+		wantErr error
+	}{
+		"hcl": {
+			format: "hcl",
+			want: `# the address to listen on (string)
+host = "localhost"
+
+# time.Duration
+wait = "5s"
+
+# []string
+tags = "a,b"
+
+# string
+greeting = " hi "
+
+# string
+# password = ""
+
+db {
+  # int
+  port = 0
+
+  replica {
+    # string
+    host = ""
+  }
+}
+
+storage {
+  # the implementation, one of disk, s3
+  type = "disk"
+
+  # string
+  path = "/var/data"
+}
+`,
+		},
+		"ini": {
+			format: "ini",
+			want: `# the address to listen on (string)
+host = localhost
+
+# time.Duration
+wait = 5s
+
+# []string
+tags = a,b
+
+# string
+hello = " hi "
+
+# string
+# password =
+
+[db]
+# int
+port = 0
+
+[db.replica]
+# string
+host =
+
+[storage]
+# the implementation, one of disk, s3
+type = disk
+
+# string
+path = /var/data
+`,
+		},
+		"unsupported": {
+			format:  "yaml",
+			wantErr: UnsupportedFormatError{Format: "yaml"},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := GenerateFileTemplate(config, test.format)
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("GenerateFileTemplate() error = %v, want %v", err, test.wantErr)
+			}
+			if got != test.want {
+				t.Errorf("GenerateFileTemplate() =\n%s\nwant\n%s", got, test.want)
+			}
+			if err != nil {
+				return
+			}
+			loaded, err := Load(&TestFileTemplateConfig{}, UseFile(writeFile(t, "config."+test.format, []byte(got))))
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			want := Clone(config)
+			want.Wait, want.Password = 5*time.Second, ""
+			want.DB.Replica = &struct{ Host string }{}
+			if !reflect.DeepEqual(loaded, want) {
+				t.Errorf("Load(GenerateFileTemplate()) = %+v, want %+v", loaded, want)
+			}
+		})
+	}
+}
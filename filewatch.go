@@ -0,0 +1,93 @@
+package qcl
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// defaultFileWatchInterval is how often file sources are checked for changes while watched.
+const defaultFileWatchInterval = time.Second
+
+// WithFileWatchInterval sets how often the file is checked for changes when the config is loaded with Watch. The
+// default is a second.
+func WithFileWatchInterval(interval time.Duration) fileOption {
+	return func(c *fileConfig) {
+		c.watchInterval = interval
+	}
+}
+
+// watchFiles returns a Watcher that checks the files returned by paths every interval, and signals a change once
+// they've changed and then stayed the same for an interval, so that a file being written in several steps triggers a
+// single reload, with its final content. Files are compared by modification time and size, and by the path their
+// symlinks resolve to, which catches Kubernetes swapping the ..data symlink of a mounted volume even if the new files
+// look the same. Paths are listed again at every check, so files that appear or disappear are noticed too.
+//
+// The library has no dependencies, so files are polled rather than watched through fsnotify or inotify and the like.
+// This works the same on every platform and file system, including network and container volumes where inotify events
+// don't arrive, at the cost of a stat of every file each interval, and of noticing a change an interval or two after
+// it's made. A shorter interval reloads sooner for more stats, and a change that leaves a file's size and
+// modification time as they were, within the precision of the file system, isn't noticed.
+func watchFiles(paths func() []string, interval time.Duration) Watcher {
+	if interval <= 0 {
+		interval = defaultFileWatchInterval
+	}
+	return func(ctx context.Context, changed func()) {
+		last, pending := fingerprint(paths()), ""
+		go func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+				case <-ctx.Done():
+					return
+				}
+				current := fingerprint(paths())
+				switch current {
+				case last:
+					pending = ""
+				case pending:
+					last, pending = current, ""
+					changed()
+				default:
+					pending = current // wait for the files to settle
+				}
+			}
+		}()
+	}
+}
+
+// fingerprint describes the state of the files, such that it changes when one of them does.
+func fingerprint(paths []string) string {
+	var b strings.Builder
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			fmt.Fprintf(&b, "%s missing\n", path)
+			continue
+		}
+		resolved, _ := filepath.EvalSymlinks(path)
+		fmt.Fprintf(&b, "%s %s %d %d\n", path, resolved, info.ModTime().UnixNano(), info.Size())
+	}
+	return b.String()
+}
+
+// globPaths returns the paths matching the pattern, or none if it's malformed.
+func globPaths(pattern string) []string {
+	matches, _ := filepath.Glob(pattern)
+	return matches
+}
+
+// dirPaths returns the paths of the entries of the directory.
+func dirPaths(dir string) []string {
+	entries, _ := os.ReadDir(dir)
+	paths := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		paths = append(paths, filepath.Join(dir, entry.Name()))
+	}
+	return paths
+}
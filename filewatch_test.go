@@ -0,0 +1,159 @@
+package qcl
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// startWatcher runs the watcher until the test ends, and returns a channel receiving its signals.
+func startWatcher(t *testing.T, watcher Watcher) <-chan struct{} {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	changed := make(chan struct{}, 10)
+	watcher(ctx, func() { changed <- struct{}{} })
+	return changed
+}
+
+func expectSignals(t *testing.T, changed <-chan struct{}, want int, within time.Duration) {
+	t.Helper()
+	got := 0
+	timeout := time.After(within)
+	for {
+		select {
+		case <-changed:
+			got++
+		case <-timeout:
+			if got != want {
+				t.Errorf("got %d change signals, want %d", got, want)
+			}
+			return
+		}
+	}
+}
+
+func Test_watchFiles(t *testing.T) {
+	const interval = 10 * time.Millisecond
+	touch := func(t *testing.T, path, data string, age time.Duration) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		mtime := time.Now().Add(-age)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("modified", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.ini")
+		touch(t, path, "a = 1", time.Hour)
+		changed := startWatcher(t, watchFiles(func() []string { return []string{path} }, interval))
+		expectSignals(t, changed, 0, 5*interval)
+		touch(t, path, "a = 2", 0)
+		expectSignals(t, changed, 1, 10*interval)
+	})
+	t.Run("created and removed", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.ini")
+		changed := startWatcher(t, watchFiles(func() []string { return []string{path} }, interval))
+		time.Sleep(2 * interval)
+		touch(t, path, "a = 1", 0)
+		expectSignals(t, changed, 1, 10*interval)
+		if err := os.Remove(path); err != nil {
+			t.Fatal(err)
+		}
+		expectSignals(t, changed, 1, 10*interval)
+	})
+	t.Run("symlink swap", func(t *testing.T) {
+		// the files look the same, but ..data points somewhere else, as when Kubernetes updates a volume
+		dir := t.TempDir()
+		mtime := time.Now().Add(-time.Hour)
+		for _, version := range []string{"v1", "v2"} {
+			if err := os.Mkdir(filepath.Join(dir, version), 0o700); err != nil {
+				t.Fatal(err)
+			}
+			touch(t, filepath.Join(dir, version, "host"), "db", 0)
+			if err := os.Chtimes(filepath.Join(dir, version, "host"), mtime, mtime); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := os.Symlink("v1", filepath.Join(dir, "..data")); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(filepath.Join("..data", "host"), filepath.Join(dir, "host")); err != nil {
+			t.Fatal(err)
+		}
+		changed := startWatcher(t, watchFiles(func() []string { return dirPaths(dir) }, interval))
+		time.Sleep(2 * interval)
+		if err := os.Symlink("v2", filepath.Join(dir, "..data_tmp")); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")); err != nil {
+			t.Fatal(err)
+		}
+		expectSignals(t, changed, 1, 10*interval)
+	})
+}
+
+func Test_Watch_file(t *testing.T) {
+	path := writeFile(t, "config.ini", []byte("name = first\n"))
+	changes := make(chan string, 10)
+	conf, stop, err := Watch(&TestFileConfig{}, func(old, new *TestFileConfig) {
+		changes <- new.Name
+	}, UseFile(path, WithFileWatchInterval(10*time.Millisecond)))
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+	defer stop()
+	if conf.Name != "first" {
+		t.Fatalf("Watch() Name = %q, want %q", conf.Name, "first")
+	}
+
+	if err := os.WriteFile(path, []byte("name = second\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case name := <-changes:
+		if name != "second" {
+			t.Errorf("onChange Name = %q, want %q", name, "second")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("onChange wasn't called after the file changed")
+	}
+}
+
+func Test_Watch_mountedDir(t *testing.T) {
+	type config struct {
+		DB struct {
+			Host string
+		}
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "db_host")
+	if err := os.WriteFile(path, []byte("first\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	changes := make(chan string, 10)
+	_, stop, err := Watch(&config{}, func(old, new *config) {
+		changes <- new.DB.Host
+	}, UseMountedDir(dir, WithFileWatchInterval(10*time.Millisecond)))
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+	defer stop()
+
+	if err := os.WriteFile(path, []byte("second\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case host := <-changes:
+		if host != "second" {
+			t.Errorf("onChange DB.Host = %q, want %q", host, "second")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("onChange wasn't called after the file changed")
+	}
+}
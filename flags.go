@@ -3,6 +3,7 @@ package qcl
 import (
 	"errors"
 	"flag"
+	"fmt"
 	"os"
 	"reflect"
 	"strconv"
@@ -12,10 +13,25 @@ import (
 
 const flags = "flags"
 
-// UseFlags enables configuration from command line flags. Currently, the flag loader is not configurable. It will
-// use the struct field names as the flag names, but lowercased and spit on word boundaries with a dash. For example,
-// the field name "FooBar" will be converted to "foo-bar". You can override the flag name by using the "flag" struct
-// tag. Examples:
+type flagConfig struct {
+	isoDurations bool
+	gnu          bool                   // gnu makes flags kebab-case, booleans switches and short booleans groupable.
+	delimiter    string                 // delimiter separates the names of nested fields in flags. Empty means "." or, with gnu, "-".
+	separator    string                 // separator splits the elements of slices and the entries of maps. Empty means ",".
+	errors       *flag.ErrorHandling    // errors, if not nil, is how errors parsing the arguments are handled, instead of the flag set's own way.
+	flagSet      *flag.FlagSet          // flagSet is the flag set flags are bound to and parsed with. Nil means flag.CommandLine.
+	args         []string               // args are the command-line arguments parsed, without the program name. Nil means os.Args.
+	record       func(path, key string) // record, if not nil, receives the flag each field is set from, for provenance.
+	load         *LoadConfig            // load is the load the source is part of, if any, for its WithScope setting.
+}
+
+var defaultFlagConfig = &flagConfig{}
+
+type flagOption func(*flagConfig)
+
+// UseFlags enables configuration from command line flags. It will use the struct field names as the flag names, but
+// lowercased and spit on word boundaries with a dash. For example, the field name "FooBar" will be converted to
+// "foo-bar". You can override the flag name by using the "flag" struct tag. Examples:
 //
 //	type Config struct {
 //	    FooBar string // will look for -foo-bar flag
@@ -27,49 +43,510 @@ const flags = "flags"
 //	    FooBar string `flag:"foo.bar"` // will look for -foo.bar flag
 //	}
 //
+// A single-character alias can be added after the name, or with the "short" struct tag:
+//
+//	type Config struct {
+//	    Verbose bool `flag:"verbose,v"` // will look for -verbose and -v flags
+//	    Quiet   bool `short:"q"`        // will look for -quiet and -q flags
+//	}
+//
+// Fields tagged `arg:"N"` are set from the Nth positional argument, left once the flags are parsed, rather than a
+// flag, and slices tagged `args:"rest"` from the arguments after those:
+//
+//	type Config struct {
+//	    Output string   `arg:"0"`    // ./app -verbose true out.txt in1.txt in2.txt sets Output to out.txt
+//	    Inputs []string `args:"rest"` // and Inputs to [in1.txt in2.txt]
+//	}
+//
+// Boolean fields tagged `negatable:"true"` get a -no- counterpart too, which sets them to false, like -no-ssl for
+// a field named SSL.
+//
 // By default, calling Load() without any LoadOptions will use the flag loader as well as the environment loader, with
 // the flag loader taking precedence. If you want to use only the flag loader, you can call Load with just the UseFlags
 // option:
 //
 //	Load(&config, UseFlags()) // will only use flags
-func UseFlags() LoadOption {
+func UseFlags(opts ...flagOption) LoadOption {
+	flagConf := *defaultFlagConfig
+
+	for _, opt := range opts {
+		opt(&flagConf)
+	}
 	return func(o *LoadConfig) {
+		flagConf := flagConf
+		flagConf.record = o.recorder(flags)
+		flagConf.load = o
 		o.Sources = append(o.Sources, flags)
-		o.Loaders[flags] = loadFromFlags
+		o.Loaders[flags] = loadFromFlags(&flagConf)
+	}
+}
+
+// WithFlagISO8601Durations allows time.Duration flags to be set to ISO 8601 durations, as well as Go durations.
+//
+// Example:
+//
+//	./app -timeout PT15M -retention P1DT12H
+//
+// Weeks and days are 7 days and 24 hours exactly. Years and months are rejected, since they have no fixed length.
+func WithFlagISO8601Durations() flagOption {
+	return func(c *flagConfig) {
+		c.isoDurations = true
+	}
+}
+
+// WithFlagSet binds the flags to fs, and parses the arguments with it, rather than flag.CommandLine, so that loading
+// flags leaves the global flag state alone. This is useful in tests, in binaries with several commands, each with their
+// own flags, and in libraries.
+//
+// Example:
+//
+//	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+//	conf, err := qcl.Load(&defaultConfig, qcl.UseFlags(qcl.WithFlagSet(fs), qcl.WithArgs(os.Args[2:])))
+//
+// Errors parsing the arguments are returned if fs is set to flag.ContinueOnError.
+func WithFlagSet(fs *flag.FlagSet) flagOption {
+	return func(c *flagConfig) {
+		c.flagSet = fs
 	}
 }
 
-func loadFromFlags(config any) error {
-	if len(os.Args) < 2 {
+// WithArgs parses args, the command-line arguments without the program name, rather than os.Args[1:].
+//
+// Example:
+//
+//	conf, err := qcl.Load(&defaultConfig, qcl.UseFlags(qcl.WithArgs([]string{"-host", "localhost"})))
+func WithArgs(args []string) flagOption {
+	return func(c *flagConfig) {
+		c.args = args
+		if c.args == nil {
+			c.args = []string{}
+		}
+	}
+}
+
+// WithFlagErrorHandling sets how errors parsing the arguments, like an unknown flag or -help, are handled, instead of
+// the way the flag set handles them, which for flag.CommandLine is flag.ExitOnError: flag.ContinueOnError makes Load
+// return them, flag.ExitOnError exits, with status 0 for -help and 2 otherwise, and flag.PanicOnError panics.
+//
+// Example:
+//
+//	conf, err := qcl.Load(&defaultConfig, qcl.UseFlags(qcl.WithFlagErrorHandling(flag.ContinueOnError)))
+//	if errors.Is(err, flag.ErrHelp) {
+//		return nil
+//	}
+//
+// The flag set is only set to the error handling while the arguments are parsed.
+func WithFlagErrorHandling(errorHandling flag.ErrorHandling) flagOption {
+	return func(c *flagConfig) {
+		c.errors = &errorHandling
+	}
+}
+
+// WithFlagSeparator splits the values of slice and map flags with separator rather than a comma, like WithEnvSeparator
+// does for environment variables.
+//
+// Example:
+//
+//	./app -dsns "postgres://a/db?sslmode=disable&x=1,2;postgres://b/db"
+//
+// with WithFlagSeparator(";") sets a []string field named DSNs to the two DSNs. A field's `sep` tag overrides it.
+func WithFlagSeparator(separator string) flagOption {
+	return func(c *flagConfig) {
+		c.separator = separator
+	}
+}
+
+// WithFlagDelimiter separates the names of nested fields, and the words of their names, in flags with delimiter rather
+// than ".", like -db-host rather than -db.host for DB.Host with "-". Indexes of slices and keys of maps are delimited
+// the same way, like -servers-0-host.
+func WithFlagDelimiter(delimiter string) flagOption {
+	return func(c *flagConfig) {
+		c.delimiter = delimiter
+	}
+}
+
+// WithGNUFlags parses flags the POSIX/GNU way: nested fields are named in kebab case, like --db-host for DB.Host rather
+// than -db.host, boolean flags are switches, set without a value, and single-character boolean flags can be grouped.
+//
+// Example:
+//
+//	./app --db-host=localhost --verbose -xq
+//
+// sets DB.Host to localhost, and Verbose, and the fields with the short flags x and q, to true. A boolean flag is set
+// to false with an explicit value, like --verbose=false, since its next argument isn't taken as its value.
+func WithGNUFlags() flagOption {
+	return func(c *flagConfig) {
+		c.gnu = true
+	}
+}
+
+// Args renders the config back into the command-line arguments that would reproduce it when loaded with UseFlags. Each
+// field is rendered as a single -name=value argument, in the order the fields are declared, using the same flag names
+// the flag loader looks for. Empty slices and maps, and zero values of types that parse themselves, are left out, since
+// there's no flag value that produces them.
+//
+// Example:
+//
+//	cmd := exec.Command("worker", qcl.Args(workerConfig)...)
+//
+// This is useful for supervisors that spawn workers with explicit flags derived from their own config. Args returns an
+// error if the config isn't a struct or pointer to one, or contains a field the flag loader doesn't support.
+func Args(config any) ([]string, error) {
+	val, err := structCopy(config)
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, 0, val.NumField())
+	err = walkFlags(val, val.Type(), "", "", nil, func(v reflect.Value, _, flagName string) error {
+		if omitFormatted(v) {
+			return nil
+		}
+		formatted, err := formatValue(v, ",")
+		if err != nil {
+			return err
+		}
+		args = append(args, "-"+flagName+"="+formatted)
 		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
+	return args, nil
+}
 
-	if reflect.TypeOf(config).Kind() != reflect.Ptr {
-		return ConfigTypeError
+func loadFromFlags(flagConf *flagConfig) Loader {
+	if flagConf == nil {
+		flagConf = defaultFlagConfig
 	}
-	val := reflect.ValueOf(config).Elem()
-	typ := val.Type()
+	parse := flagConf.parseOptions()
+	return func(config any) error {
+		fs, cmdArgs := flagConf.flagSet, flagConf.args
+		if fs == nil {
+			fs = flag.CommandLine
+		}
+		if cmdArgs == nil && len(os.Args) > 1 {
+			cmdArgs = os.Args[1:]
+		}
+		if len(cmdArgs) == 0 {
+			return nil
+		}
 
-	if err := bindFlags(val, typ, ""); err != nil {
-		return err
+		if reflect.TypeOf(config).Kind() != reflect.Ptr {
+			return ConfigTypeError
+		}
+		val := reflect.ValueOf(config).Elem()
+		typ := val.Type()
+
+		paths := make(map[string]string)
+		values := make(map[string]reflect.Value)
+		var names []string
+		scope := strings.Join(flagConf.load.scopePath(), ".")
+		args := parseFlagArgs(cmdArgs)
+		delimiter := flagConf.nestedDelimiter()
+		args.dotted(delimiter)
+		err := walkFlags(val, typ, scope, "", args, func(v reflect.Value, path, flagName string) error {
+			flagName = strings.ReplaceAll(flagName, ".", delimiter)
+			paths[flagName], values[flagName] = path, v
+			names = append(names, flagName)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		// flags are bound once the walk has allocated every nil pointer, so that the leaf fields, which document them,
+		// include those of nested structs, as do the variables of the environment source that set the same fields
+		fields := make(map[string]field)
+		for _, f := range leafFields(config) {
+			fields[f.name()] = f
+		}
+		var envVars map[string]string
+		if flagConf.load != nil && flagConf.load.env != nil {
+			envVars = envKeys(val, flagConf.load.env)
+		}
+		for _, name := range names {
+			path := paths[name]
+			usage := fieldUsage(val, fields, path)
+			if key, ok := envVars[path]; ok {
+				usage = strings.TrimSpace(usage + " [$" + key + "]")
+			}
+			fieldParse := parse.forField(typ, path)
+			if err := bindFlag(fs, values[name], name, usage, fields[path].secret, fieldParse); err != nil {
+				return err
+			}
+			if fields[path].sf.Tag.Get("negatable") == "true" && values[name].Kind() == reflect.Bool {
+				negated := "no-" + name
+				if other, ok := paths[negated]; ok {
+					return &FieldError{Path: path, Key: flagConf.key(negated), Err: fmt.Errorf("flag %s is already used by %s", flagConf.key(negated), other)}
+				}
+				paths[negated] = path
+				if err := registerFlag(fs, &negatedValue{values[name]}, values[name], negated, "sets "+flagConf.key(name)+" to false", false); err != nil {
+					return err
+				}
+			}
+			short := shortFlag(fields[path].sf)
+			if short == "" {
+				continue
+			}
+			if other, ok := paths[short]; ok {
+				return &FieldError{Path: path, Key: "-" + short, Err: fmt.Errorf("flag -%s is already used by %s", short, other)}
+			}
+			paths[short] = path
+			if err := bindFlag(fs, values[name], short, "shorthand for "+flagConf.key(name), fields[path].secret, fieldParse); err != nil {
+				return err
+			}
+		}
+
+		if groups, titles := flagGroups(typ, names, paths); len(titles) > 0 && hasDefaultUsage(fs) {
+			fs.Usage = groupedUsage(fs, groups, titles)
+		}
+		if flagConf.gnu {
+			for name := range paths {
+				if b, ok := fs.Lookup(name).Value.(*boolValue); ok {
+					b.isSwitch = true
+				}
+			}
+			cmdArgs = ungroupFlags(fs, cmdArgs)
+		}
+		if err := flagConf.parse(fs, cmdArgs); err != nil {
+			return err
+		}
+		if err := setPositionalArgs(val, fs.Args(), parse, flagConf.record); err != nil {
+			return err
+		}
+		for _, store := range args.stores {
+			store()
+		}
+		if flagConf.record != nil {
+			fs.Visit(func(f *flag.Flag) {
+				if path, ok := paths[f.Name]; ok {
+					flagConf.record(path, flagConf.key(f.Name))
+				}
+			})
+		}
+		return nil
 	}
+}
 
-	flag.Parse()
-	return nil
+// parse parses the arguments with fs, handling errors the way set with WithFlagErrorHandling, if it was, rather than
+// fs's way.
+func (c *flagConfig) parse(fs *flag.FlagSet, args []string) error {
+	if c.errors != nil && *c.errors != fs.ErrorHandling() {
+		defer fs.Init(fs.Name(), fs.ErrorHandling())
+		fs.Init(fs.Name(), *c.errors)
+	}
+	return fs.Parse(args)
+}
+
+// parseOptions returns the options flag values are parsed with.
+func (c *flagConfig) parseOptions() parseOptions {
+	parse := parseOptions{separator: c.separator, isoDurations: c.isoDurations}
+	if parse.separator == "" {
+		parse.separator = ","
+	}
+	return parse
+}
+
+// nestedDelimiter returns the delimiter of the names of nested fields in flags, set with WithFlagDelimiter, or "-" with
+// WithGNUFlags, or else ".".
+func (c *flagConfig) nestedDelimiter() string {
+	switch {
+	case c.delimiter != "":
+		return c.delimiter
+	case c.gnu:
+		return "-"
+	default:
+		return "."
+	}
+}
+
+// key returns the flag as it's given in the command-line arguments, with one dash, or with two for the long flags of
+// WithGNUFlags.
+func (c *flagConfig) key(name string) string {
+	if c.gnu && len(name) > 1 {
+		return "--" + name
+	}
+	return "-" + name
+}
+
+// flagGroups returns the group of each flag, by name, named by the `group` tag of the field it sets, or of the nearest
+// struct the field is nested in, along with the titles of the groups, in the order their flags were walked.
+func flagGroups(typ reflect.Type, names []string, paths map[string]string) (map[string]string, []string) {
+	groups := make(map[string]string)
+	var titles []string
+	for name, path := range paths {
+		groups[name] = flagGroup(typ, path)
+	}
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if title := groups[name]; title != "" && !seen[title] {
+			seen[title] = true
+			titles = append(titles, title)
+		}
+	}
+	return groups, titles
+}
+
+// flagGroup returns the `group` tag of the field at the dotted path of the struct type typ, or of the nearest struct
+// the field is nested in, or nothing if none of them has one.
+func flagGroup(typ reflect.Type, path string) string {
+	for {
+		if sf, ok := structFieldByPath(typ, path); ok {
+			if group := sf.Tag.Get("group"); group != "" {
+				return group
+			}
+		}
+		i := strings.LastIndex(path, ".")
+		if i < 0 {
+			return ""
+		}
+		path = path[:i]
+	}
+}
+
+// The code pointers of the usage functions a flag set may have without the user setting one: the flag package's
+// defaults for new flag sets, flag.CommandLine and flag.Usage, and those returned by groupedUsage, which all share
+// their code.
+var (
+	flagSetUsage     = reflect.ValueOf(flag.NewFlagSet("", flag.ContinueOnError).Usage).Pointer()
+	commandLineUsage = reflect.ValueOf(flag.CommandLine.Usage).Pointer()
+	defaultUsage     = reflect.ValueOf(flag.Usage).Pointer()
+	groupUsage       = reflect.ValueOf(groupedUsage(nil, nil, nil)).Pointer()
+)
+
+// hasDefaultUsage reports whether fs prints the flag package's default usage, or the grouped usage of an earlier load,
+// so that grouping the flags doesn't replace a usage function set by the user, on fs or, for flag.CommandLine, as
+// flag.Usage.
+func hasDefaultUsage(fs *flag.FlagSet) bool {
+	if fs.Usage == nil {
+		return true
+	}
+	switch reflect.ValueOf(fs.Usage).Pointer() {
+	case flagSetUsage, groupUsage:
+		return true
+	case commandLineUsage:
+		return reflect.ValueOf(flag.Usage).Pointer() == defaultUsage
+	}
+	return false
+}
+
+// groupedUsage returns the usage function of fs, which prints the flags like the flag package does, but with those of
+// each group in a section of their own, after the flags that aren't in a group, under the group's title.
+func groupedUsage(fs *flag.FlagSet, groups map[string]string, titles []string) func() {
+	return func() {
+		out := fs.Output()
+		if fs.Name() == "" {
+			fmt.Fprintf(out, "Usage:\n")
+		} else {
+			fmt.Fprintf(out, "Usage of %s:\n", fs.Name())
+		}
+		sections := make(map[string]*flag.FlagSet, len(titles)+1)
+		for _, title := range append([]string{""}, titles...) {
+			sections[title] = flag.NewFlagSet(title, flag.ContinueOnError)
+			sections[title].SetOutput(out)
+		}
+		fs.VisitAll(func(f *flag.Flag) {
+			section := sections[groups[f.Name]]
+			section.Var(f.Value, f.Name, f.Usage)
+			section.Lookup(f.Name).DefValue = f.DefValue
+		})
+		sections[""].PrintDefaults()
+		for _, title := range titles {
+			fmt.Fprintf(out, "\n%s:\n", title)
+			sections[title].PrintDefaults()
+		}
+	}
+}
+
+// isPositional reports whether the field is set from the positional arguments, rather than from a flag, by its `arg`
+// or `args` tag.
+func isPositional(sf reflect.StructField) bool {
+	_, arg := sf.Tag.Lookup("arg")
+	_, args := sf.Tag.Lookup("args")
+	return arg || args
 }
 
-func bindFlags(val reflect.Value, typ reflect.Type, name string) error {
+// setPositionalArgs sets the fields of the struct val tagged `arg:"N"` to the Nth of the positional arguments, those
+// left once the flags are parsed, and the slices tagged `args:"rest"` to the arguments after the last of those, if
+// there are any. Fields with no argument to set them are left alone.
+func setPositionalArgs(val reflect.Value, args []string, parse parseOptions, record func(path, key string)) error {
+	var errs []error
+	var rest []field
+	next := 0
+	for _, f := range leafFields(val.Addr().Interface()) {
+		if f.sf.Tag.Get("args") == "rest" {
+			rest = append(rest, f)
+			continue
+		}
+		tag, ok := f.sf.Tag.Lookup("arg")
+		if !ok {
+			continue
+		}
+		i, err := strconv.Atoi(tag)
+		if err != nil || i < 0 {
+			errs = append(errs, &FieldError{Path: f.name(), Key: "arg", RawValue: tag, Err: errors.New("arg tag must be a non-negative index")})
+			continue
+		}
+		if i >= next {
+			next = i + 1
+		}
+		if i >= len(args) {
+			continue
+		}
+		key := "args[" + tag + "]"
+		if err := parse.forField(val.Type(), f.name()).setField(f.value, args[i]); err != nil {
+			errs = append(errs, &FieldError{Path: f.name(), Key: key, RawValue: args[i], Err: err})
+		} else if record != nil {
+			record(f.name(), key)
+		}
+	}
+	for _, f := range rest {
+		if next >= len(args) {
+			break
+		}
+		key := "args[" + strconv.Itoa(next) + ":]"
+		f.value.Set(reflect.Zero(f.value.Type()))
+		if err := parse.forField(val.Type(), f.name()).setSliceValues(f.value, args[next:]); err != nil {
+			errs = append(errs, &FieldError{Path: f.name(), Key: key, RawValue: strings.Join(args[next:], " "), Err: err})
+		} else if record != nil {
+			record(f.name(), key)
+		}
+	}
+	return joinErrors(errs)
+}
+
+// walkFlags calls fn with every field of the struct that is loaded from a flag, along with its dotted path, which
+// starts with pathPrefix, and the name of the flag. Nil pointers are allocated along the way.
+//
+// The elements of slices of structs, and the values of maps of structs, are walked as structs of their own, under the
+// flag name followed by the index or the map key, like -servers.0.host for Servers[0].Host and -databases.primary.host
+// for Databases["primary"].Host. If args isn't nil, slices are first grown, and entries added to maps, to fit the
+// flags in the arguments.
+//
+// Interface fields with registered implementations are walked as the struct of the implementation they hold, after the
+// flag naming it, like -storage.type, which selects the implementation if it's in the arguments.
+func walkFlags(val reflect.Value, typ reflect.Type, name, pathPrefix string, args *flagArgs, fn func(v reflect.Value, path, flagName string) error) error {
 	for i := 0; i < typ.NumField(); i++ {
 		field := typ.Field(i)
+		if skipField(field) || isPositional(field) {
+			continue
+		}
 		if field.Anonymous {
-			if err := bindFlags(val.Field(i), field.Type, ""); err != nil {
+			if err := walkFlags(val.Field(i), field.Type, "", pathPrefix, args, fn); err != nil {
 				return err
 			}
 			continue
 		}
 		flagName := strings.ToLower(field.Name)
-		if tag := field.Tag.Get("flag"); tag != "" {
-			flagName = tag
+		tag := qclTag(field)
+		if tag.name != "" {
+			flagName = strings.ToLower(tag.name)
+		}
+		if flagTag := tagName(field.Tag.Get("flag")); flagTag != "" {
+			flagName = flagTag
+		}
+		if tag.flag != "" {
+			flagName = tag.flag
 		}
 		if name != "" && !strings.HasSuffix(name, ".") {
 			name += "."
@@ -82,13 +559,62 @@ func bindFlags(val reflect.Value, typ reflect.Type, name string) error {
 				}
 				val = val.Elem()
 			}
-			if val.Kind() == reflect.Struct {
-				if err := bindFlags(val, val.Type(), flagName); err != nil {
+			val = rawField(field, val)
+			if _, ok := customSetter(val); !ok && val.Kind() == reflect.Struct {
+				if err := walkFlags(val, val.Type(), flagName, pathPrefix+field.Name+".", args, fn); err != nil {
+					return err
+				}
+				continue
+			}
+			if isStructSlice(val) {
+				if err := growSlice(val, sliceLength(args.flagNames(), flagName+".", "."), pathPrefix+field.Name); err != nil {
+					return err
+				}
+				for j := 0; j < val.Len(); j++ {
+					elem, index := allocate(val.Index(j)), strconv.Itoa(j)
+					if err := walkFlags(elem, elem.Type(), flagName+"."+index, pathPrefix+field.Name+"."+index+".", args, fn); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			if isStructMap(val) {
+				var suffixes []string
+				elem := reflect.New(indirectType(val.Type().Elem())).Elem()
+				_ = walkFlags(elem, elem.Type(), "", "", nil, func(_ reflect.Value, _, suffix string) error {
+					suffixes = append(suffixes, suffix)
+					return nil
+				})
+				segments := mapKeySegments(args.flagNames(), flagName+".", ".", suffixes)
+				store, err := walkStructMap(val, pathPrefix+field.Name, segments, sameKey, sameKey, func(elem reflect.Value, path, segment string) error {
+					return walkFlags(elem, elem.Type(), flagName+"."+segment, path+".", args, fn)
+				})
+				if err != nil {
 					return err
 				}
+				if args != nil {
+					args.stores = append(args.stores, store)
+				}
 				continue
 			}
-			if err := bindFlag(val, flagName); err != nil {
+			if isPolymorphic(val) {
+				typeFlag := flagName + "." + discriminatorKey
+				impl, err := selectImplementation(val, args.value(typeFlag))
+				if err != nil {
+					return &FieldError{Path: pathPrefix + field.Name, Key: typeFlag, RawValue: args.value(typeFlag), Err: err}
+				}
+				if !impl.IsValid() {
+					continue
+				}
+				if err := fn(discriminator(val), pathPrefix+field.Name, typeFlag); err != nil {
+					return err
+				}
+				if err := walkFlags(impl, impl.Type(), flagName, pathPrefix+field.Name+".", args, fn); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := fn(val, pathPrefix+field.Name, flagName); err != nil {
 				return err
 			}
 		}
@@ -96,55 +622,301 @@ func bindFlags(val reflect.Value, typ reflect.Type, name string) error {
 	return nil
 }
 
-func bindFlag(v reflect.Value, flagName string) error {
+// flagArgs are the flags in the command-line arguments, which walkFlags grows slices and adds map entries to fit.
+type flagArgs struct {
+	names  []string          // names are the names of the flags.
+	values map[string]string // values are the values of the flags, by name, if they have one.
+	stores []func()          // stores store the map entries walked back into their maps, once the flags are parsed.
+}
+
+// flagNames returns the names of the flags in the arguments, or nil if args is nil.
+func (args *flagArgs) flagNames() []string {
+	if args == nil {
+		return nil
+	}
+	return args.names
+}
+
+// value returns the value of the flag in the arguments, or an empty string if args is nil. The value of a flag
+// without "=" is taken to be the next argument, which is wrong for boolean flags, so only string flags, like the
+// -storage.type that selects an implementation, are looked up.
+func (args *flagArgs) value(name string) string {
+	if args == nil {
+		return ""
+	}
+	return args.values[name]
+}
+
+// sameKey returns the map key unchanged, since flag names carry map keys as they are.
+func sameKey(key string) string {
+	return key
+}
+
+// parseFlagArgs returns the flags in the command-line arguments, up to the "--" that ends them, like servers.0.host
+// with the value a for -servers.0.host=a or --servers.0.host a.
+func parseFlagArgs(args []string) *flagArgs {
+	parsed := &flagArgs{values: make(map[string]string)}
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		name := strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-")
+		if j := strings.Index(name, "="); j >= 0 {
+			parsed.values[name[:j]] = name[j+1:]
+			name = name[:j]
+		} else if i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
+			parsed.values[name] = args[i+1]
+		}
+		parsed.names = append(parsed.names, name)
+	}
+	return parsed
+}
+
+// dotted names the flags in the arguments with dots rather than the delimiter, the way walkFlags names them, so that
+// the flags of WithFlagDelimiter and WithGNUFlags grow slices, add map entries and select implementations as well.
+func (args *flagArgs) dotted(delimiter string) {
+	if delimiter == "." {
+		return
+	}
+	values := make(map[string]string, len(args.values))
+	for name, value := range args.values {
+		values[strings.ReplaceAll(name, delimiter, ".")] = value
+	}
+	for i, name := range args.names {
+		args.names[i] = strings.ReplaceAll(name, delimiter, ".")
+	}
+	args.values = values
+}
+
+// ungroupFlags splits the groups of single-character boolean flags in the arguments, up to the "--" that ends them,
+// into flags of their own, like -x -q for -xq, so that the flag package parses them.
+func ungroupFlags(fs *flag.FlagSet, args []string) []string {
+	ungrouped := make([]string, 0, len(args))
+	for i, arg := range args {
+		if arg == "--" {
+			return append(ungrouped, args[i:]...)
+		}
+		if !isFlagGroup(fs, arg) {
+			ungrouped = append(ungrouped, arg)
+			continue
+		}
+		for _, c := range arg[1:] {
+			ungrouped = append(ungrouped, "-"+string(c))
+		}
+	}
+	return ungrouped
+}
+
+// isFlagGroup reports whether the argument is a group of single-character boolean flags registered in fs, like -xq.
+func isFlagGroup(fs *flag.FlagSet, arg string) bool {
+	if len(arg) < 3 || arg[0] != '-' || arg[1] == '-' || strings.Contains(arg, "=") {
+		return false
+	}
+	for _, c := range arg[1:] {
+		f := fs.Lookup(string(c))
+		if f == nil {
+			return false
+		}
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); !ok || !b.IsBoolFlag() {
+			return false
+		}
+	}
+	return true
+}
+
+// shortFlag returns the single-character alias of the flag that sets the field, from its `short` tag or the second
+// option of its `flag` tag, like `flag:"verbose,v"`, if it has one.
+func shortFlag(sf reflect.StructField) string {
+	if short := sf.Tag.Get("short"); short != "" {
+		return short
+	}
+	parts := strings.Split(sf.Tag.Get("flag"), ",")
+	if len(parts) > 1 && !strings.Contains(parts[1], "=") {
+		return strings.TrimSpace(parts[1])
+	}
+	return ""
+}
+
+// bindFlag registers the flag that sets v in fs, documented by usage in -help output along with v's current value as its
+// default, unless the field is secret.
+func bindFlag(fs *flag.FlagSet, v reflect.Value, flagName, usage string, secret bool, parse parseOptions) error {
+	value, err := newBoundValue(v, parse)
+	if err != nil {
+		return err
+	}
+	return registerFlag(fs, value, v, flagName, usage, secret)
+}
+
+// registerFlag registers value, bound to v, as the flag in fs, like bindFlag.
+func registerFlag(fs *flag.FlagSet, value boundValue, v reflect.Value, flagName, usage string, secret bool) error {
+	// if the flag was bound by a previous load, e.g. when the config is being reloaded, point it at the new field
+	// instead of registering it again, which would panic, and document the new field's value as its default.
+	f := fs.Lookup(flagName)
+	if existing, ok := flagBoundValue(f); ok && reflect.TypeOf(existing) == reflect.TypeOf(value) {
+		existing.bind(v)
+		f.Usage, f.DefValue = usage, existing.String()
+	} else {
+		fs.Var(value, flagName, usage)
+		f = fs.Lookup(flagName)
+	}
+	if secret {
+		f.DefValue = ""
+	}
+	return nil
+}
+
+// flagBoundValue returns the boundValue of the flag, if it's registered and bound to a field.
+func flagBoundValue(f *flag.Flag) (boundValue, bool) {
+	if f == nil {
+		return nil, false
+	}
+	value, ok := f.Value.(boundValue)
+	return value, ok
+}
+
+// newBoundValue returns the flag.Value that sets a field of v's type, parsing values with the given options.
+func newBoundValue(v reflect.Value, parse parseOptions) (boundValue, error) {
 	if !v.CanSet() {
-		return UnsupportedTypeError{v.Kind()}
+		return nil, UnsupportedTypeError{v.Kind()}
 	}
-	if v.Type().String() == "time.Duration" {
-		flag.DurationVar(v.Addr().Interface().(*time.Duration), flagName, time.Duration(0), "")
-		return nil
+	if _, ok := flagValue(v); ok {
+		return &forwardedValue{v}, nil
+	}
+	if _, ok := customSetter(v); ok {
+		return &customValue{v}, nil
+	}
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		return &durationValue{v, parse}, nil
 	}
 	switch v.Kind() {
 	case reflect.String:
-		flag.Var(&stringValue{v}, flagName, "")
+		return &stringValue{v}, nil
 	case reflect.Bool:
-		flag.Var(&boolValue{v}, flagName, "")
+		return &boolValue{Value: v}, nil
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		flag.Var(&intValue{v}, flagName, "")
+		return &intValue{v}, nil
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		flag.Var(&uintValue{v}, flagName, "")
+		return &uintValue{v}, nil
 	case reflect.Float32, reflect.Float64:
-		flag.Var(&floatValue{v}, flagName, "")
+		return &floatValue{v}, nil
 	case reflect.Slice:
 		if v.IsNil() {
 			v.Set(reflect.MakeSlice(v.Type(), 0, 0))
 		}
-		flag.Var(&sliceValue{v}, flagName, "")
+		return &sliceValue{Value: v, parse: parse}, nil
 	case reflect.Map:
 		if v.IsNil() {
 			v.Set(reflect.MakeMap(v.Type()))
 		}
-		flag.Var(&mapValue{v}, flagName, "")
+		return &mapValue{Value: v, parse: parse}, nil
 	default:
-		return UnsupportedTypeError{v.Kind()}
+		return nil, UnsupportedTypeError{v.Kind()}
 	}
-	return nil
+}
+
+// A boundValue is a flag.Value that sets a struct field. Binding it again points it at another field, which allows the
+// same flags to be loaded more than once.
+type boundValue interface {
+	flag.Value
+	bind(reflect.Value)
 }
 
 type (
 	stringValue struct{ reflect.Value }
-	boolValue   struct{ reflect.Value }
-	sliceValue  struct{ reflect.Value }
-	mapValue    struct{ reflect.Value }
-	intValue    struct{ reflect.Value }
-	uintValue   struct{ reflect.Value }
-	floatValue  struct{ reflect.Value }
+	boolValue   struct {
+		reflect.Value
+		isSwitch bool // isSwitch makes the flag a boolean flag, set to true without a value, with WithGNUFlags.
+	}
+	sliceValue struct {
+		reflect.Value
+		parse parseOptions
+		given bool // given is set once the flag is given, so that repeats add to what it replaced.
+	}
+	mapValue struct {
+		reflect.Value
+		parse parseOptions
+		given bool // given is set once the flag is given, so that repeats add to what it replaced.
+	}
+	intValue   struct{ reflect.Value }
+	uintValue  struct{ reflect.Value }
+	floatValue struct{ reflect.Value }
+
+	durationValue struct {
+		reflect.Value
+		parse parseOptions
+	}
+	customValue struct{ reflect.Value }
+
+	// forwardedValue forwards to a field implementing flag.Value, so the flag behaves as if the field were registered
+	// itself, including as a boolean flag if it has an IsBoolFlag method, while still being rebound on reload.
+	forwardedValue struct{ reflect.Value }
+
+	// negatedValue is the -no- counterpart of a boolean flag tagged `negatable:"true"`, a switch that sets it to false.
+	negatedValue struct{ reflect.Value }
 )
 
+func (s *stringValue) bind(v reflect.Value)    { s.Value = v }
+func (b *boolValue) bind(v reflect.Value)      { b.Value = v }
+func (s *sliceValue) bind(v reflect.Value)     { s.Value, s.given = v, false }
+func (m *mapValue) bind(v reflect.Value)       { m.Value, m.given = v, false }
+func (i *intValue) bind(v reflect.Value)       { i.Value = v }
+func (u *uintValue) bind(v reflect.Value)      { u.Value = v }
+func (f *floatValue) bind(v reflect.Value)     { f.Value = v }
+func (d *durationValue) bind(v reflect.Value)  { d.Value = v }
+func (c *customValue) bind(v reflect.Value)    { c.Value = v }
+func (f *forwardedValue) bind(v reflect.Value) { f.Value = v }
+func (n *negatedValue) bind(v reflect.Value)   { n.Value = v }
+
+// String returns the value of the field as the flag would be given, which -help shows as the flag's default. It's
+// empty if the field is the zero value, and for the zero value the flag package makes to tell whether a default was
+// set, so that -help leaves zero defaults out, like it does for the flag package's own flags.
+func (s *stringValue) String() string   { return flagText(s.Value) }
+func (b *boolValue) String() string     { return flagText(b.Value) }
+func (s *sliceValue) String() string    { return flagText(s.Value) }
+func (m *mapValue) String() string      { return flagText(m.Value) }
+func (i *intValue) String() string      { return flagText(i.Value) }
+func (u *uintValue) String() string     { return flagText(u.Value) }
+func (f *floatValue) String() string    { return flagText(f.Value) }
+func (d *durationValue) String() string { return flagText(d.Value) }
+func (c *customValue) String() string   { return flagText(c.Value) }
+
+// flagText returns the value of the field v as it would be given as a flag, or nothing if v is invalid or zero.
+func flagText(v reflect.Value) string {
+	if !v.IsValid() || v.IsZero() {
+		return ""
+	}
+	return dumpText(v)
+}
+
 func (s *stringValue) Set(value string) error {
 	s.SetString(value)
 	return nil
 }
+
+// String returns nothing, since the default of the field is shown by the flag it negates.
+func (n *negatedValue) String() string { return "" }
+
+// Set sets the field to the negation of the value, which is true when the flag is given without one.
+func (n *negatedValue) Set(value string) error {
+	v, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	n.SetBool(!v)
+	return nil
+}
+
+// IsBoolFlag reports that the flag is a switch, given without a value, like -no-ssl.
+func (n *negatedValue) IsBoolFlag() bool { return true }
+
+// IsBoolFlag reports whether the flag is a switch, which can be given without a value, like --verbose.
+func (b *boolValue) IsBoolFlag() bool {
+	return b.isSwitch
+}
+
 func (b *boolValue) Set(value string) error {
 	v, err := strconv.ParseBool(value)
 	if err != nil {
@@ -153,12 +925,22 @@ func (b *boolValue) Set(value string) error {
 	b.SetBool(v)
 	return nil
 }
+
+// Set replaces the slice the defaults and earlier sources set with the separated values the first time the flag is
+// given, and appends them after that, so that a repeated flag, like -host a -host b, accumulates.
 func (s *sliceValue) Set(value string) error {
-	vals := strings.Split(value, ",")
-	return setSliceValues(s.Value, vals, "")
+	vals := splitEscaped(value, s.parse.separator)
+	if !s.given && s.Kind() == reflect.Slice {
+		s.Value.Set(reflect.Zero(s.Type()))
+		s.given = true
+	}
+	return s.parse.setSliceValues(s.Value, vals)
 }
+
+// Set replaces the map with the separated entries the first time the flag is given, and adds them to it after that,
+// so that a repeated flag accumulates, as it does for slices.
 func (m *mapValue) Set(value string) error {
-	parts := strings.SplitN(value, ",", 2)
+	parts := splitEscaped(value, m.parse.separator)
 	keys := make([]string, 0)
 	values := make([]string, 0)
 	for _, part := range parts {
@@ -170,7 +952,11 @@ func (m *mapValue) Set(value string) error {
 		keys = append(keys, kv[0])
 		values = append(values, kv[1])
 	}
-	return setMapKeysAndValues(m.Value, keys, values, "")
+	if !m.given && m.Kind() == reflect.Map {
+		m.Value.Set(reflect.Zero(m.Type()))
+		m.given = true
+	}
+	return m.parse.setMapKeysAndValues(m.Value, keys, values)
 }
 func (i *intValue) Set(value string) error {
 	kind := i.Kind()
@@ -220,3 +1006,38 @@ func (f *floatValue) Set(value string) error {
 	}
 	return nil
 }
+func (d *durationValue) Set(value string) error {
+	v, err := d.parse.parseDuration(value)
+	if err != nil {
+		return err
+	}
+	d.SetInt(int64(v))
+	return nil
+}
+func (c *customValue) Set(value string) error {
+	set, _ := customSetter(c.Value)
+	return set(value)
+}
+
+func (f *forwardedValue) Set(value string) error {
+	fv, _ := flagValue(f.Value)
+	return fv.Set(value)
+}
+
+func (f *forwardedValue) String() string {
+	if !f.IsValid() { // the zero value the flag package makes to tell whether a default was set
+		return ""
+	}
+	fv, _ := flagValue(f.Value)
+	return fv.String()
+}
+
+// IsBoolFlag reports whether the field is a boolean flag, which can be given without a value, like -verbose.
+func (f *forwardedValue) IsBoolFlag() bool {
+	if !f.IsValid() {
+		return false
+	}
+	fv, _ := flagValue(f.Value)
+	b, ok := fv.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
@@ -1,9 +1,15 @@
 package qcl
 
 import (
+	"bytes"
+	"errors"
 	"flag"
+	"fmt"
+	"io"
 	"os"
 	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
@@ -29,6 +35,25 @@ func Test_UseFlags(t *testing.T) {
 	}
 }
 
+func Test_WithFlagISO8601Durations(t *testing.T) {
+	flagConf := flagConfig{}
+	WithFlagISO8601Durations()(&flagConf)
+	if !flagConf.isoDurations {
+		t.Errorf("WithFlagISO8601Durations() should set isoDurations")
+	}
+	useArgs("-timeout", "P1DT12H", "-retries", "PT1S,2s")
+	got := new(struct {
+		Timeout time.Duration
+		Retries []time.Duration
+	})
+	if err := loadFromFlags(&flagConf)(got); err != nil {
+		t.Fatalf("loadFromFlags() error = %v", err)
+	}
+	if got.Timeout != 36*time.Hour || !reflect.DeepEqual(got.Retries, []time.Duration{time.Second, 2 * time.Second}) {
+		t.Errorf("loadFromFlags() = %+v, want Timeout 36h and Retries [1s 2s]", got)
+	}
+}
+
 func Test_loadFromFlags(t *testing.T) {
 	tests := map[string]struct {
 		args    []string
@@ -205,6 +230,29 @@ func Test_loadFromFlags(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		"generated protobuf": {
+			want: &TestProtoConfig{
+				DbHost:   "localhost",
+				MaxConns: &Int32Value{Value: 10},
+				Token:    &BytesValue{},
+				Timeout:  &Duration{Seconds: 1, Nanos: 500000000},
+				Start:    &Timestamp{},
+			},
+			args: []string{
+				"-dbhost", "localhost",
+				"-maxconns", "10",
+				"-timeout", "1.5s",
+			},
+		},
+		"skipped fields": {
+			want: &TestRuntimeConfig{Host: "localhost"},
+			args: []string{"-host", "localhost"},
+		},
+		"skipped field isn't a flag": {
+			want:    &TestRuntimeConfig{},
+			args:    []string{"-name", "app"},
+			wantErr: true,
+		},
 		"flag tag override": {
 			want: &TestConfigWithFlagTag{
 				HTTPHost: "localhost",
@@ -222,7 +270,7 @@ func Test_loadFromFlags(t *testing.T) {
 			os.Args = append([]string{"test"}, test.args...)
 
 			got := reflect.New(reflect.TypeOf(test.want).Elem()).Interface()
-			if err := loadFromFlags(got); err != nil && !test.wantErr {
+			if err := loadFromFlags(nil)(got); err != nil && !test.wantErr {
 				t.Errorf("loadFromFlags() error = %v, wantErr %v", err, test.wantErr)
 			}
 
@@ -231,8 +279,27 @@ func Test_loadFromFlags(t *testing.T) {
 			}
 		})
 	}
+	t.Run("load twice", func(t *testing.T) {
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+		os.Args = []string{"test", "-host", "localhost", "-duration", "1s"}
+		type config struct {
+			Host     string
+			Duration time.Duration
+		}
+		first, second := new(config), new(config)
+		if err := loadFromFlags(nil)(first); err != nil {
+			t.Fatalf("loadFromFlags() error = %v", err)
+		}
+		if err := loadFromFlags(nil)(second); err != nil {
+			t.Fatalf("loadFromFlags() error = %v", err)
+		}
+		if second.Host != "localhost" || second.Duration != time.Second {
+			t.Errorf("loadFromFlags() got = %v, want Host localhost and Duration 1s", second)
+		}
+	})
 	t.Run("non-pointer config", func(t *testing.T) {
-		if err := loadFromFlags(TestConfig{}); err == nil {
+		os.Args = []string{"test", "-host", "localhost"}
+		if err := loadFromFlags(nil)(TestConfig{}); err == nil {
 			t.Error("LoadFromFlags() expected error, got nil")
 		}
 	})
@@ -240,12 +307,678 @@ func Test_loadFromFlags(t *testing.T) {
 
 func Test_bindFlag(t *testing.T) {
 	t.Run("unsettable type", func(t *testing.T) {
-		if err := bindFlag(reflect.ValueOf(make(chan bool)), "test"); err == nil {
+		if err := bindFlag(flag.NewFlagSet("test", flag.ContinueOnError), reflect.ValueOf(make(chan bool)), "test", "", false, parseOptions{}); err == nil {
 			t.Error("bindFlag() expected error, got nil")
 		}
 	})
 }
 
+func Test_loadFromFlags_usage(t *testing.T) {
+	type config struct {
+		Host     string        `usage:"the address to listen on"`
+		Wait     time.Duration `usage:"how long to wait"`
+		Password string        `secret:"true" usage:"the database password"`
+		Port     int
+	}
+	useArgs("-port", "8080")
+	if err := loadFromFlags(nil)(&config{Host: "localhost", Wait: time.Second, Password: "hunter2"}); err != nil {
+		t.Fatalf("loadFromFlags() error = %v", err)
+	}
+	tests := map[string]struct {
+		usage    string
+		defValue string
+	}{
+		"host":     {usage: "the address to listen on", defValue: "localhost"},
+		"wait":     {usage: "how long to wait", defValue: "1s"},
+		"password": {usage: "the database password"},
+		"port":     {},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			f := flag.Lookup(name)
+			if f == nil {
+				t.Fatalf("flag.Lookup(%q) = nil", name)
+			}
+			if f.Usage != test.usage {
+				t.Errorf("Usage = %q, want %q", f.Usage, test.usage)
+			}
+			if f.DefValue != test.defValue {
+				t.Errorf("DefValue = %q, want %q", f.DefValue, test.defValue)
+			}
+		})
+	}
+}
+
+func Test_loadFromFlags_defaults(t *testing.T) {
+	type config struct {
+		Host    string
+		Timeout time.Duration
+		Retries int
+		Verbose bool
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	var help bytes.Buffer
+	fs.SetOutput(&help)
+	got := config{Host: "localhost", Timeout: 5 * time.Second}
+	if err := loadFromFlags(&flagConfig{flagSet: fs, args: []string{"-retries", "3"}})(&got); err != nil {
+		t.Fatalf("loadFromFlags() error = %v", err)
+	}
+	if want := (config{Host: "localhost", Timeout: 5 * time.Second, Retries: 3}); got != want {
+		t.Errorf("loadFromFlags() got = %+v, want %+v", got, want)
+	}
+	fs.PrintDefaults()
+	for _, want := range []string{"(default localhost)", "(default 5s)"} {
+		if !strings.Contains(help.String(), want) {
+			t.Errorf("PrintDefaults() = %q, want it to contain %q", help.String(), want)
+		}
+	}
+	if strings.Contains(help.String(), "(default 0") || strings.Contains(help.String(), "(default false)") {
+		t.Errorf("PrintDefaults() = %q, want no zero defaults", help.String())
+	}
+
+	reloaded := config{Host: "example.com"}
+	if err := loadFromFlags(&flagConfig{flagSet: fs, args: []string{"-retries", "3"}})(&reloaded); err != nil {
+		t.Fatalf("loadFromFlags() error = %v", err)
+	}
+	if got := fs.Lookup("host").DefValue; got != "example.com" {
+		t.Errorf("DefValue after reload = %q, want %q", got, "example.com")
+	}
+}
+
+func Test_loadFromFlags_envUsage(t *testing.T) {
+	type config struct {
+		Host string `usage:"the address to listen on"`
+		DB   struct {
+			Port int `env:"DATABASE_PORT"`
+		}
+	}
+	tests := map[string]struct {
+		opts []LoadOption
+		want map[string]string
+	}{
+		"with env": {
+			opts: []LoadOption{UseFlags(), UseEnv(WithEnvPrefix("TEST"))},
+			want: map[string]string{"host": "the address to listen on [$TEST_HOST]", "db.port": "[$TEST_DB_DATABASE_PORT]"},
+		},
+		"without env": {
+			opts: []LoadOption{UseFlags()},
+			want: map[string]string{"host": "the address to listen on", "db.port": ""},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			useArgs("-host", "localhost")
+			if _, err := Load(&config{}, test.opts...); err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			for flagName, want := range test.want {
+				if got := flag.Lookup(flagName).Usage; got != want {
+					t.Errorf("flag %s usage = %q, want %q", flagName, got, want)
+				}
+			}
+		})
+	}
+}
+
+func Test_WithFlagSet(t *testing.T) {
+	type config struct {
+		Host string
+		Port int
+	}
+	tests := map[string]struct {
+		args    []string
+		want    config
+		wantErr bool
+	}{
+		"set": {
+			args: []string{"-host", "localhost", "-port=8080"},
+			want: config{Host: "localhost", Port: 8080},
+		},
+		"empty": {
+			args: []string{},
+		},
+		"undefined": {
+			args:    []string{"-nope"},
+			wantErr: true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			useArgs("-host", "global")
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			fs.SetOutput(io.Discard)
+			var got config
+			err := loadFromFlags(&flagConfig{flagSet: fs, args: test.args})(&got)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("loadFromFlags() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if !test.wantErr && got != test.want {
+				t.Errorf("loadFromFlags() got = %+v, want %+v", got, test.want)
+			}
+			if flag.Lookup("host") != nil {
+				t.Error("loadFromFlags() bound flags to flag.CommandLine")
+			}
+		})
+	}
+	t.Run("options", func(t *testing.T) {
+		useArgs("-host", "global")
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		got, err := Load(&config{}, UseFlags(WithFlagSet(fs), WithArgs([]string{"-host", "localhost"})))
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		if got.Host != "localhost" || fs.Lookup("host") == nil {
+			t.Errorf("Load() got = %+v, want Host localhost, bound to the flag set", got)
+		}
+	})
+}
+
+func Test_loadFromFlags_repeated(t *testing.T) {
+	type config struct {
+		Hosts []string
+		Ports map[string]int
+	}
+	tests := map[string]struct {
+		defaults config
+		args     []string
+		want     config
+	}{
+		"slice": {
+			args: []string{"-hosts", "a", "-hosts", "b"},
+			want: config{Hosts: []string{"a", "b"}, Ports: map[string]int{}},
+		},
+		"slice with commas": {
+			args: []string{"-hosts", "a,b", "-hosts=c"},
+			want: config{Hosts: []string{"a", "b", "c"}, Ports: map[string]int{}},
+		},
+		"slice with defaults": {
+			defaults: config{Hosts: []string{"default"}},
+			args:     []string{"-hosts", "a", "-hosts", "b"},
+			want:     config{Hosts: []string{"a", "b"}, Ports: map[string]int{}},
+		},
+		"map": {
+			args: []string{"-ports", "http=80", "-ports", "https=443"},
+			want: config{Hosts: []string{}, Ports: map[string]int{"http": 80, "https": 443}},
+		},
+		"map with defaults": {
+			defaults: config{Ports: map[string]int{"default": 1}},
+			args:     []string{"-ports", "http=80", "-ports", "https=443"},
+			want:     config{Hosts: []string{}, Ports: map[string]int{"http": 80, "https": 443}},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := test.defaults
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			if err := loadFromFlags(&flagConfig{flagSet: fs, args: test.args})(&got); err != nil {
+				t.Fatalf("loadFromFlags() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("loadFromFlags() got = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func Test_loadFromFlags_acrossSources(t *testing.T) {
+	type config struct {
+		Hosts  []string       `default:"d"`
+		Labels map[string]int `default:"d=0"`
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	environ := map[string]string{"HOSTS": "c", "LABELS": "c=1"}
+	args := []string{"-hosts", "e", "-labels", "k=2", "-hosts", "f"}
+	want := config{Hosts: []string{"e", "f"}, Labels: map[string]int{"k": 2}}
+	// loading twice with the same flag set, as a reload does, replaces the values again rather than adding to them
+	for i := 0; i < 2; i++ {
+		got, err := Load(&config{}, UseEnv(WithEnviron(environ)), UseFlags(WithFlagSet(fs), WithArgs(args)))
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		if !reflect.DeepEqual(*got, want) {
+			t.Errorf("Load() got = %+v, want %+v", *got, want)
+		}
+	}
+}
+
+func Test_loadFromFlags_negatable(t *testing.T) {
+	type config struct {
+		SSL     bool `negatable:"true"`
+		Verbose bool
+		DB      struct {
+			TLS bool `negatable:"true"`
+		}
+	}
+	defaults := config{SSL: true}
+	defaults.DB.TLS = true
+	tests := map[string]struct {
+		args []string
+		gnu  bool
+		want func(*config)
+	}{
+		"negated": {
+			args: []string{"-no-ssl", "-no-db.tls"},
+			want: func(c *config) { c.SSL, c.DB.TLS = false, false },
+		},
+		"negated with a value": {
+			args: []string{"-no-ssl=false"},
+			want: func(*config) {},
+		},
+		"gnu": {
+			args: []string{"--no-db-tls"},
+			gnu:  true,
+			want: func(c *config) { c.DB.TLS = false },
+		},
+		"not negatable": {
+			args: []string{"-verbose", "true"},
+			want: func(c *config) { c.Verbose = true },
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, want := defaults, defaults
+			test.want(&want)
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			if err := loadFromFlags(&flagConfig{flagSet: fs, args: test.args, gnu: test.gnu})(&got); err != nil {
+				t.Fatalf("loadFromFlags() error = %v", err)
+			}
+			if got != want {
+				t.Errorf("loadFromFlags() got = %+v, want %+v", got, want)
+			}
+			if fs.Lookup("no-verbose") != nil {
+				t.Error("loadFromFlags() registered -no-verbose for a field that isn't negatable")
+			}
+		})
+	}
+}
+
+func Test_WithFlagDelimiter(t *testing.T) {
+	type config struct {
+		DB struct {
+			Host string
+		}
+		Servers []struct{ Host string }
+	}
+	tests := map[string]struct {
+		delimiter string
+		args      []string
+	}{
+		"dash": {
+			delimiter: "-",
+			args:      []string{"-db-host", "localhost", "-servers-0-host=a"},
+		},
+		"underscore": {
+			delimiter: "_",
+			args:      []string{"-db_host", "localhost", "-servers_0_host=a"},
+		},
+		"dot": {
+			delimiter: ".",
+			args:      []string{"-db.host", "localhost", "-servers.0.host=a"},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			var got config
+			if _, err := Load(&got, UseFlags(WithFlagSet(fs), WithArgs(test.args), WithFlagDelimiter(test.delimiter))); err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if got.DB.Host != "localhost" || len(got.Servers) != 1 || got.Servers[0].Host != "a" {
+				t.Errorf("Load() got = %+v, want DB.Host localhost and Servers[0].Host a", got)
+			}
+		})
+	}
+}
+
+func Test_WithFlagErrorHandling(t *testing.T) {
+	type config struct {
+		Host string
+	}
+	tests := map[string]struct {
+		errorHandling flag.ErrorHandling
+		args          []string
+		wantErr       bool
+		errIs         error
+		wantPanic     bool
+	}{
+		"continue": {
+			errorHandling: flag.ContinueOnError,
+			args:          []string{"-nope"},
+			wantErr:       true,
+		},
+		"help": {
+			errorHandling: flag.ContinueOnError,
+			args:          []string{"-help"},
+			wantErr:       true,
+			errIs:         flag.ErrHelp,
+		},
+		"panic": {
+			errorHandling: flag.PanicOnError,
+			args:          []string{"-nope"},
+			wantPanic:     true,
+		},
+		"valid": {
+			errorHandling: flag.ContinueOnError,
+			args:          []string{"-host", "localhost"},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			fs := flag.NewFlagSet("test", flag.ExitOnError)
+			fs.SetOutput(io.Discard)
+			defer func() {
+				if r := recover(); (r != nil) != test.wantPanic {
+					t.Errorf("Load() panic = %v, wantPanic %v", r, test.wantPanic)
+				}
+				if fs.ErrorHandling() != flag.ExitOnError {
+					t.Errorf("ErrorHandling() = %v, want it restored to ExitOnError", fs.ErrorHandling())
+				}
+			}()
+			_, err := Load(&config{}, UseFlags(WithFlagSet(fs), WithArgs(test.args), WithFlagErrorHandling(test.errorHandling)))
+			if (err != nil) != test.wantErr || (test.errIs != nil && !errors.Is(err, test.errIs)) {
+				t.Errorf("Load() error = %v, wantErr %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func Test_loadFromFlags_positional(t *testing.T) {
+	type config struct {
+		Verbose bool
+		Output  string   `arg:"0"`
+		Count   int      `arg:"1"`
+		Inputs  []string `args:"rest"`
+	}
+	tests := map[string]struct {
+		defaults config
+		args     []string
+		want     config
+		wantErr  bool
+	}{
+		"all": {
+			args: []string{"-verbose", "true", "out.txt", "3", "a.txt", "b.txt"},
+			want: config{Verbose: true, Output: "out.txt", Count: 3, Inputs: []string{"a.txt", "b.txt"}},
+		},
+		"after terminator": {
+			args: []string{"--", "-out.txt", "3"},
+			want: config{Output: "-out.txt", Count: 3},
+		},
+		"missing": {
+			defaults: config{Count: 1, Inputs: []string{"default.txt"}},
+			args:     []string{"out.txt"},
+			want:     config{Output: "out.txt", Count: 1, Inputs: []string{"default.txt"}},
+		},
+		"rest replaces defaults": {
+			defaults: config{Inputs: []string{"default.txt"}},
+			args:     []string{"out.txt", "3", "a.txt"},
+			want:     config{Output: "out.txt", Count: 3, Inputs: []string{"a.txt"}},
+		},
+		"invalid": {
+			args:    []string{"out.txt", "three"},
+			wantErr: true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := test.defaults
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			err := loadFromFlags(&flagConfig{flagSet: fs, args: test.args})(&got)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("loadFromFlags() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if !test.wantErr && !reflect.DeepEqual(got, test.want) {
+				t.Errorf("loadFromFlags() got = %+v, want %+v", got, test.want)
+			}
+			if fs.Lookup("output") != nil || fs.Lookup("inputs") != nil {
+				t.Error("loadFromFlags() registered flags for positional arguments")
+			}
+		})
+	}
+	t.Run("invalid tag", func(t *testing.T) {
+		var got struct {
+			Output string `arg:"first"`
+		}
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		var fieldErr *FieldError
+		if err := loadFromFlags(&flagConfig{flagSet: fs, args: []string{"out.txt"}})(&got); !errors.As(err, &fieldErr) || fieldErr.Path != "Output" {
+			t.Errorf("loadFromFlags() error = %v, want a FieldError for Output", err)
+		}
+	})
+}
+
+func Test_loadFromFlags_groups(t *testing.T) {
+	type config struct {
+		Verbose bool
+		Port    int `group:"Server options"`
+		DB      struct {
+			Host string `usage:"the database host"`
+			Pool struct {
+				Size int
+			}
+		} `group:"Database options"`
+	}
+	fs := flag.NewFlagSet("app", flag.ContinueOnError)
+	var help bytes.Buffer
+	fs.SetOutput(&help)
+	if err := loadFromFlags(&flagConfig{flagSet: fs, args: []string{"-help"}})(&config{}); !errors.Is(err, flag.ErrHelp) {
+		t.Fatalf("loadFromFlags() error = %v, want flag.ErrHelp", err)
+	}
+	want := `Usage of app:
+  -verbose value
+    	
+
+Server options:
+  -port value
+    	
+
+Database options:
+  -db.host value
+    	the database host
+  -db.pool.size value
+    	
+`
+	if got := help.String(); got != want {
+		t.Errorf("usage = %q, want %q", got, want)
+	}
+}
+
+func Test_loadFromFlags_groupsCustomUsage(t *testing.T) {
+	type config struct {
+		Port int `group:"Server options"`
+	}
+	tests := map[string]struct {
+		usage func(fs *flag.FlagSet) func()
+		want  string
+	}{
+		"no usage": {
+			usage: func(*flag.FlagSet) func() { return nil },
+			want:  "Usage of app:\n\nServer options:\n  -port value\n    \t\n",
+		},
+		"custom usage": {
+			usage: func(fs *flag.FlagSet) func() {
+				return func() { fmt.Fprintln(fs.Output(), "usage: app [-port n]") }
+			},
+			want: "usage: app [-port n]\n",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			fs := flag.NewFlagSet("app", flag.ContinueOnError)
+			fs.Usage = test.usage(fs)
+			var help bytes.Buffer
+			fs.SetOutput(&help)
+			for i := 0; i < 2; i++ {
+				help.Reset()
+				if err := loadFromFlags(&flagConfig{flagSet: fs, args: []string{"-help"}})(&config{}); !errors.Is(err, flag.ErrHelp) {
+					t.Fatalf("loadFromFlags() error = %v, want flag.ErrHelp", err)
+				}
+				if got := help.String(); got != test.want {
+					t.Errorf("load %d: usage = %q, want %q", i+1, got, test.want)
+				}
+			}
+		})
+	}
+}
+
+func Test_WithFlagSeparator(t *testing.T) {
+	type config struct {
+		Hosts []string
+		DSNs  []string          `sep:"|"`
+		Ports map[string]string `sep:"|"`
+	}
+	tests := map[string]struct {
+		opts []flagOption
+		args []string
+		want config
+	}{
+		"default": {
+			args: []string{"-hosts", "a,b", "-dsns", "x?a=1,2|y", "-ports", "a=1,2|b=3"},
+			want: config{Hosts: []string{"a", "b"}, DSNs: []string{"x?a=1,2", "y"}, Ports: map[string]string{"a": "1,2", "b": "3"}},
+		},
+		"option": {
+			opts: []flagOption{WithFlagSeparator(";")},
+			args: []string{"-hosts", "a,b;c", "-dsns", "x|y"},
+			want: config{Hosts: []string{"a,b", "c"}, DSNs: []string{"x", "y"}, Ports: map[string]string{}},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			opts := append([]flagOption{WithFlagSet(fs), WithArgs(test.args)}, test.opts...)
+			got, err := Load(&config{}, UseFlags(opts...))
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if !reflect.DeepEqual(*got, test.want) {
+				t.Errorf("Load() got = %+v, want %+v", *got, test.want)
+			}
+		})
+	}
+}
+
+type TestGNUConfig struct {
+	DB struct {
+		Host string
+		Port int
+	}
+	Verbose bool `short:"v"`
+	Quiet   bool `short:"q"`
+	Name    string
+	Servers []struct{ Host string }
+}
+
+func Test_WithGNUFlags(t *testing.T) {
+	tests := map[string]struct {
+		args []string
+		want TestGNUConfig
+	}{
+		"kebab case": {
+			args: []string{"--db-host=localhost", "--db-port", "5432"},
+			want: TestGNUConfig{DB: struct {
+				Host string
+				Port int
+			}{Host: "localhost", Port: 5432}},
+		},
+		"switches": {
+			args: []string{"--verbose", "--quiet=false", "--name", "app"},
+			want: TestGNUConfig{Verbose: true, Name: "app"},
+		},
+		"grouped": {
+			args: []string{"-vq", "--name=app"},
+			want: TestGNUConfig{Verbose: true, Quiet: true, Name: "app"},
+		},
+		"slices of structs": {
+			args: []string{"--servers-0-host=a", "--servers-1-host=b"},
+			want: TestGNUConfig{Servers: []struct{ Host string }{{Host: "a"}, {Host: "b"}}},
+		},
+		"after terminator": {
+			args: []string{"--verbose", "--", "-vq"},
+			want: TestGNUConfig{Verbose: true},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			useArgs(test.args...)
+			var got TestGNUConfig
+			if err := loadFromFlags(&flagConfig{gnu: true})(&got); err != nil {
+				t.Fatalf("loadFromFlags() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("loadFromFlags() got = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+	t.Run("provenance", func(t *testing.T) {
+		useArgs("--db-host=localhost", "-v")
+		var provenance Provenance
+		if _, err := Load(&TestGNUConfig{}, UseFlags(WithGNUFlags()), WithProvenance(&provenance)); err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		if got := provenance["DB.Host"].Key; got != "--db-host" {
+			t.Errorf("provenance key of DB.Host = %q, want %q", got, "--db-host")
+		}
+		if got := provenance["Verbose"].Key; got != "-v" {
+			t.Errorf("provenance key of Verbose = %q, want %q", got, "-v")
+		}
+	})
+}
+
+func Test_loadFromFlags_short(t *testing.T) {
+	type config struct {
+		Verbose bool   `flag:"verbose,v"`
+		Name    string `short:"n"`
+		DB      struct {
+			Port int `flag:",p"`
+		}
+	}
+	tests := map[string]struct {
+		args    []string
+		want    config
+		wantErr bool
+	}{
+		"long": {
+			args: []string{"-verbose", "true", "-name", "app", "-db.port", "5432"},
+			want: config{Verbose: true, Name: "app", DB: struct {
+				Port int `flag:",p"`
+			}{Port: 5432}},
+		},
+		"short": {
+			args: []string{"-v", "true", "-n", "app", "-p", "5432"},
+			want: config{Verbose: true, Name: "app", DB: struct {
+				Port int `flag:",p"`
+			}{Port: 5432}},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			useArgs(test.args...)
+			var got config
+			if err := loadFromFlags(nil)(&got); err != nil {
+				t.Fatalf("loadFromFlags() error = %v", err)
+			}
+			if got != test.want {
+				t.Errorf("loadFromFlags() got = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+	t.Run("usage", func(t *testing.T) {
+		if got := flag.Lookup("v").Usage; got != "shorthand for -verbose" {
+			t.Errorf("flag v usage = %q, want %q", got, "shorthand for -verbose")
+		}
+	})
+	t.Run("duplicate", func(t *testing.T) {
+		type config struct {
+			Verbose bool `short:"v"`
+			Version bool `short:"v"`
+		}
+		useArgs("-v")
+		var fieldErr *FieldError
+		if err := loadFromFlags(nil)(&config{}); !errors.As(err, &fieldErr) || fieldErr.Path != "Version" {
+			t.Errorf("loadFromFlags() error = %v, want a FieldError for Version", err)
+		}
+	})
+}
+
 func Test_boolValue(t *testing.T) {
 	tests := map[string]struct {
 		value   string
@@ -268,7 +1001,7 @@ func Test_boolValue(t *testing.T) {
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
 			var got bool
-			bv := boolValue{reflect.ValueOf(&got).Elem()}
+			bv := boolValue{Value: reflect.ValueOf(&got).Elem()}
 			if err := bv.Set(test.value); err != nil && !test.wantErr {
 				t.Errorf("boolValue.Set() error = %v, wantErr %v", err, test.wantErr)
 			}
@@ -413,7 +1146,7 @@ func Test_mapValue(t *testing.T) {
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
 			var got map[string]string
-			mv := mapValue{reflect.ValueOf(&got).Elem()}
+			mv := mapValue{Value: reflect.ValueOf(&got).Elem(), parse: defaultParseOptions}
 			if err := mv.Set(test.value); err != nil && !test.wantErr {
 				t.Errorf("mapValue.Set() error = %v, wantErr %v", err, test.wantErr)
 			}
@@ -423,9 +1156,178 @@ func Test_mapValue(t *testing.T) {
 		})
 	}
 	t.Run("unsupported type", func(t *testing.T) {
-		mv := mapValue{reflect.ValueOf(make(chan map[string]string))}
+		mv := mapValue{Value: reflect.ValueOf(make(chan map[string]string)), parse: defaultParseOptions}
 		if err := mv.Set("key1=value1,key2=value2"); err == nil {
 			t.Error("mapValue.Set() expected error, got nil")
 		}
 	})
 }
+
+func Test_Args(t *testing.T) {
+	tests := map[string]struct {
+		config  any
+		want    []string
+		wantErr bool
+	}{
+		"simple": {
+			config: &TestConfig{Host: "localhost", Port: 8080},
+			want:   []string{"-host=localhost", "-port=8080"},
+		},
+		"non-pointer": {
+			config: TestConfig{Host: "localhost"},
+			want:   []string{"-host=localhost", "-port=0"},
+		},
+		"nested pointer": {
+			config: &TestNestedPointerConfig{Host: ptr("localhost"), DB: &TestDBConfig{SSL: true}},
+			want:   []string{"-host=localhost", "-port=0", "-ssl=false", "-db.host=", "-db.port=0", "-db.ssl=true"},
+		},
+		"all supported types": {
+			config: &AllSupportedTypes{Float: 1.5, Float8: 2.25, Duration: 90 * time.Second},
+			want: []string{
+				"-bool=false", "-int=0", "-int8=0", "-int16=0", "-int32=0", "-int64=0", "-uint=0", "-uint8=0",
+				"-uint16=0", "-uint32=0", "-uint64=0", "-float=1.5", "-float8=2.25", "-duration=1m30s",
+			},
+		},
+		"slices and maps": {
+			config: &struct {
+				Hosts []string
+				Ports map[string]int
+				Empty []int
+			}{Hosts: []string{"a", "b"}, Ports: map[string]int{"b": 2, "a": 1}},
+			want: []string{"-hosts=a,b", "-ports=a=1,b=2"},
+		},
+		"generated protobuf": {
+			config: &TestProtoConfig{
+				MaxConns: &Int32Value{Value: 10},
+				Token:    &BytesValue{Value: []byte("token")},
+				Timeout:  &Duration{Seconds: 1, Nanos: 500000000},
+				Start:    &Timestamp{Seconds: 1640995200},
+			},
+			want: []string{"-dbhost=", "-maxconns=10", "-token=token", "-timeout=1.5s", "-start=2022-01-01T00:00:00Z"},
+		},
+		"unsupported type": {
+			config:  &UnsupportedStruct{},
+			wantErr: true,
+		},
+		"not a struct": {
+			config:  ptr("string"),
+			wantErr: true,
+		},
+		"nil": {
+			config:  (*TestConfig)(nil),
+			wantErr: true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := Args(test.config)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Args() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if !test.wantErr && !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Args() = %v, want %v", got, test.want)
+			}
+		})
+	}
+	t.Run("round trip", func(t *testing.T) {
+		want := &struct {
+			TestNestedConfig
+			Hosts map[string]int
+			Ports []uint16
+			Wait  time.Duration
+		}{
+			TestNestedConfig: TestNestedConfig{Host: "localhost", SSL: true, DB: TestDBConfig{Port: 5432}},
+			Hosts:            map[string]int{"a": 1, "b": 2, "c": 3},
+			Ports:            []uint16{80, 443},
+			Wait:             time.Minute,
+		}
+		args, err := Args(want)
+		if err != nil {
+			t.Fatalf("Args() error = %v", err)
+		}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+		os.Args = append([]string{"test"}, args...)
+		got := reflect.New(reflect.TypeOf(want).Elem()).Interface()
+		if err := loadFromFlags(nil)(got); err != nil {
+			t.Fatalf("loadFromFlags() error = %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("loadFromFlags(Args()) = %v, want %v", got, want)
+		}
+	})
+}
+
+// useArgs resets the command line flags and sets os.Args to the given arguments, for loading with loadFromFlags.
+func useArgs(args ...string) {
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	os.Args = append([]string{"test"}, args...)
+}
+
+// TestLevel is a custom flag type, parsing a log level by name.
+type TestLevel int
+
+func (l *TestLevel) Set(s string) error {
+	for i, name := range []string{"debug", "info", "warn"} {
+		if s == name {
+			*l = TestLevel(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown level %q", s)
+}
+
+func (l *TestLevel) String() string {
+	return []string{"debug", "info", "warn"}[*l]
+}
+
+// TestVerbosity is a custom boolean flag type, counting how many times it's given.
+type TestVerbosity struct{ count int }
+
+func (v *TestVerbosity) Set(string) error { v.count++; return nil }
+func (v *TestVerbosity) String() string   { return strconv.Itoa(v.count) }
+func (v *TestVerbosity) IsBoolFlag() bool { return true }
+
+type TestFlagValueConfig struct {
+	Level   TestLevel
+	Verbose TestVerbosity
+}
+
+func Test_flagValueFields(t *testing.T) {
+	t.Run("flags", func(t *testing.T) {
+		useArgs("-level", "warn", "-verbose", "-verbose")
+		got, err := Load(&TestFlagValueConfig{}, UseFlags())
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		if want := (TestFlagValueConfig{Level: 2, Verbose: TestVerbosity{2}}); *got != want {
+			t.Errorf("Load() = %+v, want %+v", *got, want)
+		}
+	})
+	t.Run("env", func(t *testing.T) {
+		t.Setenv("TEST_LEVEL", "info")
+		t.Setenv("TEST_VERBOSE", "true")
+		got, err := Load(&TestFlagValueConfig{}, UseEnv(WithEnvPrefix("TEST")))
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		if want := (TestFlagValueConfig{Level: 1, Verbose: TestVerbosity{1}}); *got != want {
+			t.Errorf("Load() = %+v, want %+v", *got, want)
+		}
+	})
+	t.Run("file", func(t *testing.T) {
+		_, err := Load(&TestFlagValueConfig{}, UseFile(writeFile(t, "config.ini", []byte("level = trace\n"))))
+		var fieldErr *FieldError
+		if !errors.As(err, &fieldErr) || fieldErr.Path != "Level" {
+			t.Errorf("Load() error = %v, want a FieldError for Level", err)
+		}
+	})
+	t.Run("args", func(t *testing.T) {
+		args, err := Args(&TestFlagValueConfig{Level: 2})
+		if err != nil {
+			t.Fatalf("Args() error = %v", err)
+		}
+		if want := []string{"-level=warn"}; !reflect.DeepEqual(args, want) {
+			t.Errorf("Args() = %v, want %v", args, want)
+		}
+	})
+}
@@ -0,0 +1,74 @@
+package qcl
+
+import (
+	"sync"
+	"time"
+)
+
+// Frozen guards a loaded config that is shared read-only across an application. It keeps a snapshot of the config
+// taken when it was frozen and can verify, on demand or periodically, that no code has written to the shared config
+// since. Accidental writes to shared config are a common source of heisenbugs, and Frozen turns them into a report
+// naming the modified fields.
+//
+// Example:
+//
+//	conf, err := qcl.Load(&defaultConfig)
+//	frozen := qcl.Freeze(conf)
+//	if debug {
+//		stop := frozen.Monitor(time.Minute, func(err error) {
+//			log.Printf("config mutated at runtime: %v", err)
+//		})
+//		defer stop()
+//	}
+//
+// Only exported fields are compared, and fields tagged `secret:"true"` are redacted in the report. Frozen doesn't
+// prevent writes, it only detects them, so it is meant for debugging and tests rather than as a security boundary.
+type Frozen[T any] struct {
+	config   *T
+	snapshot *T
+}
+
+// Freeze snapshots the config and returns a Frozen guarding it. The config itself is not copied: Config returns the
+// same pointer, so existing readers keep working unchanged.
+func Freeze[T any](config *T) *Frozen[T] {
+	return &Frozen[T]{config: config, snapshot: Clone(config)}
+}
+
+// Config returns the shared config.
+func (f *Frozen[T]) Config() *T {
+	return f.config
+}
+
+// Verify compares the shared config with the snapshot taken when it was frozen, and returns a *MutationError listing
+// the modified fields if they differ. Verify reads the config without synchronization, so a write racing with it is
+// itself a data race, which the race detector will also report.
+func (f *Frozen[T]) Verify() error {
+	if changes := Diff(f.snapshot, f.config); len(changes) > 0 {
+		return &MutationError{Changes: changes}
+	}
+	return nil
+}
+
+// Monitor calls Verify every interval in a new goroutine, and calls onMutation with the error each time it fails. It
+// returns a function that stops monitoring, which is safe to call more than once.
+func (f *Frozen[T]) Monitor(interval time.Duration, onMutation func(error)) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := f.Verify(); err != nil {
+					onMutation(err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
@@ -0,0 +1,98 @@
+package qcl
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_Frozen(t *testing.T) {
+	tests := map[string]struct {
+		mutate func(*TestSecretConfig)
+		want   []Change
+	}{
+		"unmodified": {
+			mutate: func(*TestSecretConfig) {},
+			want:   nil,
+		},
+		"field": {
+			mutate: func(c *TestSecretConfig) { c.User = "root" },
+			want:   []Change{{Field: "User", Old: "admin", New: "root"}},
+		},
+		"through pointer": {
+			mutate: func(c *TestSecretConfig) { c.DB.Port = 5433 },
+			want:   []Change{{Field: "DB.Port", Old: 5432, New: 5433}},
+		},
+		"secret": {
+			mutate: func(c *TestSecretConfig) { c.Password = "changed" },
+			want:   []Change{{Field: "Password", Old: RedactedValue, New: RedactedValue}},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			conf := &TestSecretConfig{User: "admin", Password: "hunter2", DB: &TestDBConfig{Host: "db", Port: 5432}}
+			frozen := Freeze(conf)
+			if frozen.Config() != conf {
+				t.Fatalf("Config() = %p, want %p", frozen.Config(), conf)
+			}
+			test.mutate(conf)
+			err := frozen.Verify()
+			if test.want == nil {
+				if err != nil {
+					t.Errorf("Verify() error = %v, want nil", err)
+				}
+				return
+			}
+			var mutationErr *MutationError
+			if !errors.As(err, &mutationErr) {
+				t.Fatalf("Verify() error = %v, want *MutationError", err)
+			}
+			if !reflect.DeepEqual(mutationErr.Changes, test.want) {
+				t.Errorf("Verify() changes = %v, want %v", mutationErr.Changes, test.want)
+			}
+		})
+	}
+}
+
+func Test_Frozen_Monitor(t *testing.T) {
+	tests := map[string]struct {
+		mutate  bool
+		wantErr bool
+	}{
+		"unmodified": {mutate: false, wantErr: false},
+		"modified":   {mutate: true, wantErr: true},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			frozen := Freeze(&TestConfig{Host: "localhost"})
+			if test.mutate {
+				frozen.Config().Host = "example.com"
+			}
+			errs := make(chan error, 1)
+			stop := frozen.Monitor(time.Millisecond, func(err error) {
+				select {
+				case errs <- err:
+				default:
+				}
+			})
+			defer stop()
+			defer stop() // stopping twice is safe
+
+			wait := 50 * time.Millisecond
+			if test.wantErr {
+				wait = time.Second
+			}
+			select {
+			case err := <-errs:
+				if !test.wantErr {
+					t.Errorf("Monitor() reported %v, want nothing", err)
+				}
+			case <-time.After(wait):
+				if test.wantErr {
+					t.Error("Monitor() didn't report the mutation")
+				}
+			}
+		})
+	}
+}
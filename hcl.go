@@ -0,0 +1,425 @@
+package qcl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// decodeHCL decodes a file in HCL's native syntax. Attributes become values, and blocks become nested trees, with
+// each label nesting one level further, so that
+//
+//	service "web" {
+//		port = 80
+//	}
+//
+// sets Service["web"].Port in a map[string]Struct field. Repeated blocks with the same name and labels are merged.
+// Strings, heredocs, numbers, booleans, lists of those, objects and lists of objects, which set slices of structs, are
+// supported; null leaves a field unset. HCL's expressions, like function calls, arithmetic and "${...}" interpolation,
+// aren't, since there's nothing to evaluate them against.
+func decodeHCL(text string) (map[string]any, error) {
+	p := &hclParser{src: []rune(text), line: 1}
+	tree := make(map[string]any)
+	if err := p.parseBody(tree, false); err != nil {
+		return nil, fmt.Errorf("line %d: %w", p.line, err)
+	}
+	return tree, nil
+}
+
+type hclParser struct {
+	src  []rune
+	pos  int
+	line int
+}
+
+func (p *hclParser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *hclParser) peek() rune {
+	if p.eof() {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *hclParser) next() rune {
+	r := p.src[p.pos]
+	p.pos++
+	if r == '\n' {
+		p.line++
+	}
+	return r
+}
+
+func (p *hclParser) hasPrefix(s string) bool {
+	end := p.pos + len(s)
+	if end > len(p.src) {
+		end = len(p.src)
+	}
+	return string(p.src[p.pos:end]) == s
+}
+
+// skipSpace skips whitespace, including newlines, and comments.
+func (p *hclParser) skipSpace() error {
+	for !p.eof() {
+		switch {
+		case unicode.IsSpace(p.peek()):
+			p.next()
+		case p.peek() == '#' || p.hasPrefix("//"):
+			for !p.eof() && p.peek() != '\n' {
+				p.next()
+			}
+		case p.hasPrefix("/*"):
+			p.pos += 2
+			for !p.hasPrefix("*/") {
+				if p.eof() {
+					return fmt.Errorf("unterminated comment")
+				}
+				p.next()
+			}
+			p.pos += 2
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// describe describes what's at the current position, for error messages.
+func (p *hclParser) describe() string {
+	if p.eof() {
+		return "end of file"
+	}
+	return strconv.QuoteRune(p.peek())
+}
+
+func isIdentRune(r rune, first bool) bool {
+	return unicode.IsLetter(r) || r == '_' || (!first && (unicode.IsDigit(r) || r == '-'))
+}
+
+func (p *hclParser) parseIdent() (string, error) {
+	if p.eof() || !isIdentRune(p.peek(), true) {
+		return "", fmt.Errorf("expected an identifier, found %s", p.describe())
+	}
+	start := p.pos
+	for !p.eof() && isIdentRune(p.peek(), false) {
+		p.next()
+	}
+	return string(p.src[start:p.pos]), nil
+}
+
+// parseBody parses attributes and blocks into the tree, until the end of the file, or the closing brace of the block
+// if inBlock is set.
+func (p *hclParser) parseBody(tree map[string]any, inBlock bool) error {
+	for {
+		if err := p.skipSpace(); err != nil {
+			return err
+		}
+		if p.eof() {
+			if inBlock {
+				return fmt.Errorf("unterminated block")
+			}
+			return nil
+		}
+		if p.peek() == '}' && inBlock {
+			p.next()
+			return nil
+		}
+		name, err := p.parseIdent()
+		if err != nil {
+			return err
+		}
+		if err := p.skipSpace(); err != nil {
+			return err
+		}
+		if p.peek() == '=' {
+			p.next()
+			if _, ok := tree[name]; ok {
+				return fmt.Errorf("%s is defined more than once", name)
+			}
+			value, err := p.parseValue()
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			if value != nil {
+				tree[name] = value
+			}
+			continue
+		}
+		block, err := subtree(tree, name)
+		if err != nil {
+			return err
+		}
+		for {
+			if err := p.skipSpace(); err != nil {
+				return err
+			}
+			if p.peek() == '{' {
+				p.next()
+				break
+			}
+			var label string
+			if p.peek() == '"' {
+				label, err = p.parseString()
+			} else {
+				label, err = p.parseIdent()
+			}
+			if err != nil {
+				return fmt.Errorf("block %s: %w", name, err)
+			}
+			if block, err = subtree(block, label); err != nil {
+				return err
+			}
+		}
+		if err := p.parseBody(block, true); err != nil {
+			return err
+		}
+	}
+}
+
+// parseValue parses the value of an attribute, a list element or an object entry. It returns a string, a []string,
+// a map[string]any, or nil for null.
+func (p *hclParser) parseValue() (any, error) {
+	if err := p.skipSpace(); err != nil {
+		return nil, err
+	}
+	switch r := p.peek(); {
+	case r == '"':
+		return p.parseString()
+	case p.hasPrefix("<<"):
+		return p.parseHeredoc()
+	case r == '[':
+		return p.parseList()
+	case r == '{':
+		return p.parseObject()
+	case r == '-' || unicode.IsDigit(r):
+		return p.parseNumber()
+	case isIdentRune(r, true):
+		ident, _ := p.parseIdent()
+		switch ident {
+		case "true", "false":
+			return ident, nil
+		case "null":
+			return nil, nil
+		}
+		return nil, fmt.Errorf("unsupported expression %q: only literal values are supported", ident)
+	}
+	return nil, fmt.Errorf("expected a value, found %s", p.describe())
+}
+
+func (p *hclParser) parseString() (string, error) {
+	p.next() // opening quote
+	var b strings.Builder
+	for {
+		if p.eof() || p.peek() == '\n' {
+			return "", fmt.Errorf("unterminated string")
+		}
+		r := p.next()
+		switch {
+		case r == '"':
+			return b.String(), nil
+		case r == '\\':
+			if p.eof() {
+				return "", fmt.Errorf("unterminated string")
+			}
+			switch e := p.next(); e {
+			case 'n':
+				b.WriteRune('\n')
+			case 'r':
+				b.WriteRune('\r')
+			case 't':
+				b.WriteRune('\t')
+			case '"', '\\':
+				b.WriteRune(e)
+			case 'u', 'U':
+				n := 4
+				if e == 'U' {
+					n = 8
+				}
+				if p.pos+n > len(p.src) {
+					return "", fmt.Errorf("invalid escape sequence")
+				}
+				code, err := strconv.ParseUint(string(p.src[p.pos:p.pos+n]), 16, 32)
+				if err != nil {
+					return "", fmt.Errorf("invalid escape sequence \\%c%s", e, string(p.src[p.pos:p.pos+n]))
+				}
+				p.pos += n
+				b.WriteRune(rune(code))
+			default:
+				return "", fmt.Errorf("invalid escape sequence \\%c", e)
+			}
+		case (r == '$' || r == '%') && p.peek() == r && p.pos+1 < len(p.src) && p.src[p.pos+1] == '{':
+			p.next() // $${ and %%{ are literal ${ and %{
+			b.WriteRune(r)
+		case (r == '$' || r == '%') && p.peek() == '{':
+			return "", fmt.Errorf("template sequences like %c{...} aren't supported", r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+}
+
+// parseHeredoc parses a heredoc, <<EOF or <<-EOF. The indented form strips the indentation common to every line.
+func (p *hclParser) parseHeredoc() (string, error) {
+	p.pos += 2
+	indented := p.peek() == '-'
+	if indented {
+		p.next()
+	}
+	marker, err := p.parseIdent()
+	if err != nil {
+		return "", fmt.Errorf("heredoc: %w", err)
+	}
+	if p.eof() || p.next() != '\n' {
+		return "", fmt.Errorf("heredoc: expected a newline after <<%s", marker)
+	}
+	var lines []string
+	for {
+		if p.eof() {
+			return "", fmt.Errorf("unterminated heredoc, expected %s", marker)
+		}
+		start := p.pos
+		for !p.eof() && p.peek() != '\n' {
+			p.next()
+		}
+		line := string(p.src[start:p.pos])
+		if !p.eof() {
+			p.next()
+		}
+		if strings.TrimSpace(line) == marker {
+			break
+		}
+		lines = append(lines, line)
+	}
+	if indented {
+		indent := -1
+		for _, line := range lines {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			n := len(line) - len(strings.TrimLeft(line, " \t"))
+			if indent < 0 || n < indent {
+				indent = n
+			}
+		}
+		for i, line := range lines {
+			if len(line) >= indent && indent > 0 {
+				lines[i] = line[indent:]
+			}
+		}
+	}
+	if len(lines) == 0 {
+		return "", nil
+	}
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+func (p *hclParser) parseNumber() (string, error) {
+	start := p.pos
+	if p.peek() == '-' {
+		p.next()
+	}
+	for !p.eof() && (unicode.IsDigit(p.peek()) || strings.ContainsRune(".eE+-", p.peek())) {
+		p.next()
+	}
+	number := string(p.src[start:p.pos])
+	if _, err := strconv.ParseFloat(number, 64); err != nil {
+		return "", fmt.Errorf("invalid number %q", number)
+	}
+	return number, nil
+}
+
+// parseList parses a list of scalar values, or of objects, which can span lines and have a trailing comma. It returns a
+// []string, or a []map[string]any for a list of objects, like the elements of a slice of structs.
+func (p *hclParser) parseList() (any, error) {
+	p.next() // [
+	list := make([]string, 0)
+	var objects []map[string]any
+	for {
+		if err := p.skipSpace(); err != nil {
+			return nil, err
+		}
+		if p.peek() == ']' {
+			p.next()
+			if objects != nil {
+				return objects, nil
+			}
+			return list, nil
+		}
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		switch value := value.(type) {
+		case string:
+			if objects != nil {
+				return nil, fmt.Errorf("lists can't mix objects and other values")
+			}
+			list = append(list, value)
+		case map[string]any:
+			if len(list) > 0 {
+				return nil, fmt.Errorf("lists can't mix objects and other values")
+			}
+			objects = append(objects, value)
+		default:
+			return nil, fmt.Errorf("lists can only contain strings, numbers, booleans and objects")
+		}
+		if err := p.skipSpace(); err != nil {
+			return nil, err
+		}
+		switch p.peek() {
+		case ',':
+			p.next()
+		case ']':
+		default:
+			return nil, fmt.Errorf("expected ',' or ']', found %s", p.describe())
+		}
+	}
+}
+
+// parseObject parses an object, { key = value, ... }, whose entries are separated by commas or newlines.
+func (p *hclParser) parseObject() (map[string]any, error) {
+	p.next() // {
+	object := make(map[string]any)
+	for {
+		if err := p.skipSpace(); err != nil {
+			return nil, err
+		}
+		if p.peek() == '}' {
+			p.next()
+			return object, nil
+		}
+		var key string
+		var err error
+		if p.peek() == '"' {
+			key, err = p.parseString()
+		} else {
+			key, err = p.parseIdent()
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := p.skipSpace(); err != nil {
+			return nil, err
+		}
+		if p.peek() != '=' && p.peek() != ':' {
+			return nil, fmt.Errorf("expected '=' or ':' after %s, found %s", key, p.describe())
+		}
+		p.next()
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		if value != nil {
+			object[key] = value
+		}
+		if err := p.skipSpace(); err != nil {
+			return nil, err
+		}
+		if p.peek() == ',' {
+			p.next()
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package qcl
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_decodeHCL(t *testing.T) {
+	tests := map[string]struct {
+		input   string
+		want    map[string]any
+		wantErr bool
+	}{
+		"attributes": {
+			input: "# a comment\nname = \"app\" // trailing\n/* block\ncomment */ port = 8080\nratio = -1.5e3\ndebug = true\nempty = null",
+			want:  map[string]any{"name": "app", "port": "8080", "ratio": "-1.5e3", "debug": "true"},
+		},
+		"blocks": {
+			input: "db {\n  host = \"localhost\"\n  replica {\n    host = \"replica\"\n  }\n}\ndb {\n  port = 5432\n}",
+			want: map[string]any{
+				"db": map[string]any{"host": "localhost", "port": "5432", "replica": map[string]any{"host": "replica"}},
+			},
+		},
+		"labeled blocks": {
+			input: "service \"web\" {\n  port = 80\n}\nservice \"api\" {\n  port = 81\n}\nbackend s3 \"prod\" {}",
+			want: map[string]any{
+				"service": map[string]any{"web": map[string]any{"port": "80"}, "api": map[string]any{"port": "81"}},
+				"backend": map[string]any{"s3": map[string]any{"prod": map[string]any{}}},
+			},
+		},
+		"lists and objects": {
+			input: "hosts = [\n  \"a\",\n  \"b\", # comment\n]\nnone = []\nlabels = { team = \"payments\", \"cost-center\": 42\n tier = 1 }",
+			want: map[string]any{
+				"hosts":  []string{"a", "b"},
+				"none":   []string{},
+				"labels": map[string]any{"team": "payments", "cost-center": "42", "tier": "1"},
+			},
+		},
+		"lists of objects": {
+			input: "servers = [\n  { host = \"a\", port = 80 },\n  { host = \"b\" },\n]",
+			want: map[string]any{
+				"servers": []map[string]any{{"host": "a", "port": "80"}, {"host": "b"}},
+			},
+		},
+		"escapes": {
+			input: `a = "tab\there \"quoted\" é $${literal} %%{literal}"`,
+			want:  map[string]any{"a": "tab\there \"quoted\" é ${literal} %{literal}"},
+		},
+		"heredocs": {
+			input: "a = <<EOF\nline one\n  line two\nEOF\nb = <<-EOT\n    indented\n      more\n    EOT\n",
+			want:  map[string]any{"a": "line one\n  line two\n", "b": "indented\n  more\n"},
+		},
+		"duplicate attribute":    {input: "a = 1\na = 2", wantErr: true},
+		"attribute then block":   {input: "db = 1\ndb {}", wantErr: true},
+		"unterminated block":     {input: "db {\n  host = \"x\"", wantErr: true},
+		"unterminated string":    {input: "a = \"x\nb = 1", wantErr: true},
+		"unterminated comment":   {input: "/* a", wantErr: true},
+		"unterminated heredoc":   {input: "a = <<EOF\nx", wantErr: true},
+		"interpolation":          {input: `a = "${var.x}"`, wantErr: true},
+		"expression":             {input: "a = var.x", wantErr: true},
+		"nested list":            {input: "a = [[1]]", wantErr: true},
+		"mixed list":             {input: "a = [1, { b = 2 }]", wantErr: true},
+		"mixed object list":      {input: "a = [{ b = 2 }, 1]", wantErr: true},
+		"invalid number":         {input: "a = 1.2.3", wantErr: true},
+		"invalid escape":         {input: `a = "\q"`, wantErr: true},
+		"missing list separator": {input: "a = [1 2]", wantErr: true},
+		"missing value":          {input: "a =", wantErr: true},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := decodeHCL(test.input)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("decodeHCL() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if !test.wantErr && !reflect.DeepEqual(got, test.want) {
+				t.Errorf("decodeHCL() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+type TestHCLService struct {
+	Port  int
+	Hosts []string
+}
+
+type TestHCLConfig struct {
+	Name string
+	DB   struct {
+		Host string
+		Port int
+	} `hcl:"database"`
+	Services map[string]TestHCLService  `hcl:"service"`
+	Backends map[string]*TestHCLService `hcl:"backend"`
+	Regions  map[string]map[string]int  `hcl:"region"`
+	Labels   map[string]string
+}
+
+func Test_UseFile_hcl(t *testing.T) {
+	hcl := `
+name = "app"
+
+database {
+  host = "localhost"
+  port = 5432
+}
+
+service "web" {
+  port  = 8080
+  hosts = ["a.internal", "b.internal"]
+}
+
+service "api" {
+  port = 8081
+}
+
+backend "s3" {
+  port = 443
+}
+
+region "us" {
+  east = 1
+  west = 2
+}
+
+labels = {
+  team = "payments"
+}
+`
+	want := &TestHCLConfig{
+		Name: "app",
+		Services: map[string]TestHCLService{
+			"web": {Port: 8080, Hosts: []string{"a.internal", "b.internal"}},
+			"api": {Port: 8081},
+		},
+		Backends: map[string]*TestHCLService{"s3": {Port: 443}},
+		Regions:  map[string]map[string]int{"us": {"east": 1, "west": 2}},
+		Labels:   map[string]string{"team": "payments"},
+	}
+	want.DB.Host, want.DB.Port = "localhost", 5432
+
+	path := writeFile(t, "config.hcl", []byte(hcl))
+	got, err := Load(&TestHCLConfig{Services: map[string]TestHCLService{"default": {}}}, UseFile(path))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Load() got = %+v, want %+v", got, want)
+	}
+
+	t.Run("block for scalar field", func(t *testing.T) {
+		path := writeFile(t, "config.hcl", []byte("name \"x\" {}"))
+		if _, err := Load(&TestHCLConfig{}, UseFile(path)); err == nil {
+			t.Error("Load() error = nil, want an error")
+		}
+	})
+	t.Run("invalid value in labeled block", func(t *testing.T) {
+		path := writeFile(t, "config.hcl", []byte("service \"web\" {\n  port = \"x\"\n}"))
+		if _, err := Load(&TestHCLConfig{}, UseFile(path)); err == nil {
+			t.Error("Load() error = nil, want an error")
+		}
+	})
+}
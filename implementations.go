@@ -0,0 +1,147 @@
+package qcl
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// discriminatorKey is the key, under an interface field, whose value names the implementation the field is loaded
+// as, like STORAGE_TYPE, -storage.type or the type key of a [storage] section.
+const discriminatorKey = "type"
+
+// implementation is a concrete type registered with RegisterImplementation.
+type implementation struct {
+	name string               // name is the value of the discriminator that selects the implementation.
+	typ  reflect.Type         // typ is the type of the values the factory returns, a pointer to a struct.
+	new  func() reflect.Value // new returns a new value from the factory.
+}
+
+var (
+	// implementations are the implementations of interface types that interface fields can be loaded as.
+	implementations   = make(map[reflect.Type][]implementation)
+	implementationsMu sync.RWMutex
+)
+
+// RegisterImplementation registers a concrete type that fields of the interface type I can be loaded as, under the
+// name that selects it. The sources set the name under the field's own key, followed by "type", and the fields of the
+// implementation under the field's key like those of a nested struct:
+//
+//	type Storage interface{ Open() (Bucket, error) }
+//
+//	type Config struct {
+//		Storage Storage // STORAGE_TYPE=s3 STORAGE_BUCKET=assets
+//	}
+//
+//	qcl.RegisterImplementation("s3", func() Storage { return &S3Config{Region: "us-east-1"} })
+//	qcl.RegisterImplementation("gcs", func() Storage { return &GCSConfig{} })
+//
+// The factory returns the implementation with its defaults, and must return a pointer to a struct. Names are matched
+// ignoring case, and registering a name again replaces the implementation. A field whose name isn't set keeps the
+// implementation it holds, whose fields are still loaded, or stays nil. RegisterImplementation panics if I isn't an
+// interface type, name is empty or the factory doesn't return a pointer to a struct. It's meant to be called from an
+// init function, before any config is loaded.
+func RegisterImplementation[I any](name string, factory func() I) {
+	iface := reflect.TypeOf((*I)(nil)).Elem()
+	if iface.Kind() != reflect.Interface {
+		panic("qcl: RegisterImplementation of non-interface type " + iface.String())
+	}
+	if name == "" || factory == nil {
+		panic("qcl: RegisterImplementation with an empty name or a nil factory")
+	}
+	typ := reflect.TypeOf(factory())
+	if typ == nil || typ.Kind() != reflect.Ptr || typ.Elem().Kind() != reflect.Struct {
+		panic(fmt.Sprintf("qcl: implementation %q of %s isn't a pointer to a struct", name, iface))
+	}
+	impl := implementation{name: name, typ: typ, new: func() reflect.Value {
+		return reflect.ValueOf(factory())
+	}}
+	implementationsMu.Lock()
+	defer implementationsMu.Unlock()
+	impls := implementations[iface]
+	for i := range impls {
+		if strings.EqualFold(impls[i].name, name) {
+			impls[i] = impl
+			return
+		}
+	}
+	implementations[iface] = append(impls, impl)
+}
+
+// isPolymorphic reports whether v is an interface field with registered implementations.
+func isPolymorphic(v reflect.Value) bool {
+	if v.Kind() != reflect.Interface {
+		return false
+	}
+	implementationsMu.RLock()
+	defer implementationsMu.RUnlock()
+	return len(implementations[v.Type()]) > 0
+}
+
+// lookupImplementations returns the implementations of the interface type, sorted by name.
+func lookupImplementations(iface reflect.Type) []implementation {
+	implementationsMu.RLock()
+	impls := append([]implementation(nil), implementations[iface]...)
+	implementationsMu.RUnlock()
+	sort.Slice(impls, func(i, j int) bool {
+		return impls[i].name < impls[j].name
+	})
+	return impls
+}
+
+// implementationNames returns the names of the implementations of the interface type, for error messages.
+func implementationNames(iface reflect.Type) string {
+	var names []string
+	for _, impl := range lookupImplementations(iface) {
+		names = append(names, impl.name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// selectImplementation sets the interface v to a new value of the implementation called name, unless it already holds
+// one, and returns the struct its fields are loaded into. If name is empty, the struct of the implementation v holds is
+// returned, or an invalid Value if it's nil or not a pointer to a struct.
+func selectImplementation(v reflect.Value, name string) (reflect.Value, error) {
+	if name != "" {
+		var impl *implementation
+		impls := lookupImplementations(v.Type())
+		for i := range impls {
+			if strings.EqualFold(impls[i].name, name) {
+				impl = &impls[i]
+				break
+			}
+		}
+		if impl == nil {
+			return reflect.Value{}, fmt.Errorf("unknown %s %q: must be one of %s", discriminatorKey, name, implementationNames(v.Type()))
+		}
+		if v.IsNil() || v.Elem().Type() != impl.typ {
+			v.Set(impl.new())
+		}
+	}
+	if v.IsNil() {
+		return reflect.Value{}, nil
+	}
+	if elem := v.Elem(); elem.Kind() == reflect.Ptr && !elem.IsNil() && elem.Elem().Kind() == reflect.Struct {
+		return elem.Elem(), nil
+	}
+	return reflect.Value{}, nil
+}
+
+// discriminator returns a string holding the name of the implementation the interface v holds, or an empty string if
+// it isn't registered. The walkers pass it to their callbacks as the value of the discriminator key, so that it's
+// rendered by Environ and Args, and known to the loaders in strict mode.
+func discriminator(v reflect.Value) reflect.Value {
+	name := reflect.New(reflect.TypeOf("")).Elem()
+	if v.IsNil() {
+		return name
+	}
+	for _, impl := range lookupImplementations(v.Type()) {
+		if impl.typ == v.Elem().Type() {
+			name.SetString(impl.name)
+			break
+		}
+	}
+	return name
+}
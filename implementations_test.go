@@ -0,0 +1,197 @@
+package qcl
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type TestStorage interface {
+	Location() string
+}
+
+type TestS3Storage struct {
+	Bucket string
+	Region string `oneof:"us-east-1,eu-west-1"`
+	Key    string `secret:"true"`
+}
+
+func (s *TestS3Storage) Location() string { return "s3://" + s.Bucket }
+
+type TestDiskStorage struct {
+	Path string
+}
+
+func (s *TestDiskStorage) Location() string { return s.Path }
+
+type TestStorageConfig struct {
+	Name    string
+	Storage TestStorage
+}
+
+func init() {
+	RegisterImplementation("s3", func() TestStorage { return &TestS3Storage{Region: "us-east-1"} })
+	RegisterImplementation("disk", func() TestStorage { return &TestDiskStorage{} })
+}
+
+func Test_implementationFields(t *testing.T) {
+	want := &TestStorageConfig{Name: "app", Storage: &TestS3Storage{Bucket: "assets", Region: "us-east-1"}}
+	tests := map[string]struct {
+		config *TestStorageConfig
+		opts   []LoadOption
+		env    map[string]string
+		args   []string
+		want   *TestStorageConfig
+	}{
+		"env": {
+			opts: []LoadOption{UseEnv(WithEnvPrefix("TEST"))},
+			env:  map[string]string{"TEST_NAME": "app", "TEST_STORAGE_TYPE": "S3", "TEST_STORAGE_BUCKET": "assets"},
+		},
+		"flags": {
+			opts: []LoadOption{UseFlags()},
+			args: []string{"-name=app", "-storage.type", "s3", "-storage.bucket=assets"},
+		},
+		"ini": {opts: []LoadOption{UseFile(writeFile(t, "config.ini", []byte(`name = app
+
+[storage]
+type = s3
+bucket = assets
+`)))}},
+		"hcl": {opts: []LoadOption{UseFile(writeFile(t, "config.hcl", []byte(`name = "app"
+storage {
+  type   = "s3"
+  bucket = "assets"
+}
+`)))}},
+		"default implementation": {
+			config: &TestStorageConfig{Storage: &TestS3Storage{Region: "eu-west-1"}},
+			opts:   []LoadOption{UseEnv(WithEnvPrefix("TEST"))},
+			env:    map[string]string{"TEST_NAME": "app", "TEST_STORAGE_BUCKET": "assets"},
+			want:   &TestStorageConfig{Name: "app", Storage: &TestS3Storage{Bucket: "assets", Region: "eu-west-1"}},
+		},
+		"replaced implementation": {
+			config: &TestStorageConfig{Storage: &TestS3Storage{Bucket: "assets"}},
+			opts:   []LoadOption{UseEnv(WithEnvPrefix("TEST"))},
+			env:    map[string]string{"TEST_STORAGE_TYPE": "disk", "TEST_STORAGE_PATH": "/var/data"},
+			want:   &TestStorageConfig{Storage: &TestDiskStorage{Path: "/var/data"}},
+		},
+		"unset": {
+			opts: []LoadOption{UseEnv(WithEnvPrefix("TEST"))},
+			env:  map[string]string{"TEST_NAME": "app"},
+			want: &TestStorageConfig{Name: "app"},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			for key, value := range test.env {
+				t.Setenv(key, value)
+			}
+			useArgs(test.args...)
+			config, expected := test.config, test.want
+			if config == nil {
+				config = &TestStorageConfig{}
+			}
+			if expected == nil {
+				expected = want
+			}
+			got, err := Load(config, test.opts...)
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, expected) {
+				t.Errorf("Load() = %+v, want %+v", got, expected)
+			}
+		})
+	}
+}
+
+func Test_implementationErrors(t *testing.T) {
+	tests := map[string]struct {
+		opts    []LoadOption
+		env     map[string]string
+		wantKey string
+		wantErr string
+	}{
+		"unknown type": {
+			opts:    []LoadOption{UseEnv(WithEnvPrefix("TEST"))},
+			env:     map[string]string{"TEST_STORAGE_TYPE": "ftp"},
+			wantKey: "TEST_STORAGE_TYPE",
+			wantErr: `unknown type "ftp": must be one of disk, s3`,
+		},
+		"missing type": {
+			opts:    []LoadOption{UseFile(writeFile(t, "config.ini", []byte("[storage]\nbucket = assets\n")))},
+			wantKey: "storage",
+			wantErr: "a section setting a field of type qcl.TestStorage needs a type, one of disk, s3",
+		},
+		"invalid field": {
+			opts:    []LoadOption{UseEnv(WithEnvPrefix("TEST"))},
+			env:     map[string]string{"TEST_STORAGE_TYPE": "s3", "TEST_STORAGE_REGION": "mars"},
+			wantErr: "invalid Storage.Region: must be one of us-east-1, eu-west-1",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			for key, value := range test.env {
+				t.Setenv(key, value)
+			}
+			_, err := Load(&TestStorageConfig{}, test.opts...)
+			if err == nil {
+				t.Fatal("Load() error = nil")
+			}
+			if test.wantKey == "" {
+				if !strings.Contains(err.Error(), test.wantErr) {
+					t.Errorf("Load() error = %v, want an error for %s", err, test.wantErr)
+				}
+				return
+			}
+			var fieldErr *FieldError
+			if !errors.As(err, &fieldErr) || fieldErr.Key != test.wantKey || fieldErr.Err.Error() != test.wantErr {
+				t.Errorf("Load() error = %v, want a FieldError for %s: %s", err, test.wantKey, test.wantErr)
+			}
+		})
+	}
+}
+
+func Test_implementationRender(t *testing.T) {
+	config := &TestStorageConfig{Name: "app", Storage: &TestS3Storage{Bucket: "assets", Region: "eu-west-1"}}
+	env, err := Environ(config, "TEST")
+	if err != nil {
+		t.Fatalf("Environ() error = %v", err)
+	}
+	wantEnv := []string{"TEST_NAME=app", "TEST_STORAGE_TYPE=s3", "TEST_STORAGE_BUCKET=assets", "TEST_STORAGE_REGION=eu-west-1", "TEST_STORAGE_KEY="}
+	if !reflect.DeepEqual(env, wantEnv) {
+		t.Errorf("Environ() = %v, want %v", env, wantEnv)
+	}
+	args, err := Args(config)
+	if err != nil {
+		t.Fatalf("Args() error = %v", err)
+	}
+	useArgs(args...)
+	got, err := Load(&TestStorageConfig{}, UseFlags())
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, config) {
+		t.Errorf("Load(Args()) = %+v, want %+v", got, config)
+	}
+}
+
+func Test_RegisterImplementation(t *testing.T) {
+	tests := map[string]func(){
+		"not an interface": func() { RegisterImplementation("s3", func() *TestS3Storage { return &TestS3Storage{} }) },
+		"empty name":       func() { RegisterImplementation("", func() TestStorage { return &TestS3Storage{} }) },
+		"nil factory":      func() { RegisterImplementation[TestStorage]("s3", nil) },
+		"nil value":        func() { RegisterImplementation("s3", func() TestStorage { return nil }) },
+	}
+	for name, register := range tests {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("RegisterImplementation() didn't panic")
+				}
+			}()
+			register()
+		})
+	}
+}
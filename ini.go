@@ -0,0 +1,77 @@
+package qcl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// decodeINI decodes an INI file. Sections become nested trees, and dotted section names, e.g. [db.replica], nest
+// further. Keys before the first section are top level. Both "key = value" and "key: value" are accepted, and a value
+// wrapped in matching single or double quotes is unquoted. Lines starting with ; or # are comments. Keys ending in [],
+// e.g. "hosts[] = a", are collected into a list, for slice fields; otherwise a repeated key overrides the earlier one.
+func decodeINI(text string) (map[string]any, error) {
+	root := make(map[string]any)
+	section := root
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: unterminated section header %q", i+1, line)
+			}
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			if name == "" {
+				return nil, fmt.Errorf("line %d: empty section name", i+1)
+			}
+			section = root
+			for _, part := range strings.Split(name, ".") {
+				next, err := subtree(section, strings.TrimSpace(part))
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %w", i+1, err)
+				}
+				section = next
+			}
+			continue
+		}
+
+		sep := strings.IndexAny(line, "=:")
+		if sep <= 0 {
+			return nil, fmt.Errorf("line %d: expected key = value, found %q", i+1, line)
+		}
+		key, value := strings.TrimSpace(line[:sep]), unquote(strings.TrimSpace(line[sep+1:]))
+		if strings.HasSuffix(key, "[]") {
+			key = strings.TrimSpace(strings.TrimSuffix(key, "[]"))
+			list, _ := section[key].([]string)
+			section[key] = append(list, value)
+			continue
+		}
+		if _, ok := section[key].(map[string]any); ok {
+			return nil, fmt.Errorf("line %d: %s is already a section", i+1, key)
+		}
+		section[key] = value
+	}
+	return root, nil
+}
+
+// subtree returns the tree nested in the tree under the key, creating it if there isn't one.
+func subtree(tree map[string]any, key string) (map[string]any, error) {
+	switch existing := tree[key].(type) {
+	case map[string]any:
+		return existing, nil
+	case nil:
+		next := make(map[string]any)
+		tree[key] = next
+		return next, nil
+	}
+	return nil, fmt.Errorf("%s is already a value", key)
+}
+
+// unquote removes matching single or double quotes around s.
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
@@ -0,0 +1,58 @@
+package qcl
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_decodeINI(t *testing.T) {
+	tests := map[string]struct {
+		input   string
+		want    map[string]any
+		wantErr bool
+	}{
+		"sections": {
+			input: "name = app\n\n; a comment\n# another\n[db]\nhost = localhost\nport: 5432\n",
+			want: map[string]any{
+				"name": "app",
+				"db":   map[string]any{"host": "localhost", "port": "5432"},
+			},
+		},
+		"nested sections": {
+			input: "[db]\nhost = primary\n[db.replica]\nhost = replica\n[ db ]\nport = 5432",
+			want: map[string]any{
+				"db": map[string]any{"host": "primary", "port": "5432", "replica": map[string]any{"host": "replica"}},
+			},
+		},
+		"quoted values": {
+			input: "a = \"hello world\"\nb = 'single'\nc = \"unbalanced'\nd = url=http://example.com",
+			want:  map[string]any{"a": "hello world", "b": "single", "c": "\"unbalanced'", "d": "url=http://example.com"},
+		},
+		"lists": {
+			input: "hosts[] = a\nhosts[] = b\nport = 1\nport = 2",
+			want:  map[string]any{"hosts": []string{"a", "b"}, "port": "2"},
+		},
+		"empty value": {
+			input: "a =",
+			want:  map[string]any{"a": ""},
+		},
+		"unterminated section": {input: "[db", wantErr: true},
+		"empty section":        {input: "[]", wantErr: true},
+		"missing separator":    {input: "just a line", wantErr: true},
+		"missing key":          {input: "= value", wantErr: true},
+		"value then section":   {input: "db = x\n[db]", wantErr: true},
+		"section then value":   {input: "[db]\n[other]\ndb = x", wantErr: false, want: map[string]any{"db": map[string]any{}, "other": map[string]any{"db": "x"}}},
+		"overwrite section":    {input: "[db]\n[x]\n[db.host]\n[db]\nhost = x", wantErr: true},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := decodeINI(test.input)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("decodeINI() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if !test.wantErr && !reflect.DeepEqual(got, test.want) {
+				t.Errorf("decodeINI() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,360 @@
+package qcl
+
+import (
+	"encoding"
+	"reflect"
+	"strings"
+)
+
+// RedactedValue replaces the value of fields tagged `secret:"true"` wherever a config is rendered for humans.
+const RedactedValue = "[REDACTED]"
+
+// A Change describes a field whose value differs between two configs.
+type Change struct {
+	Field string `json:"field"` // Field is the dotted path of the field, e.g. "DB.Host".
+	Old   any    `json:"old"`   // Old is the value in the old config. It is RedactedValue for secret fields.
+	New   any    `json:"new"`   // New is the value in the new config. It is RedactedValue for secret fields.
+}
+
+// field is a leaf field found while walking a config struct.
+type field struct {
+	path   []string            // path is the Go field names leading to the field, e.g. ["DB", "Host"].
+	sf     reflect.StructField // sf is the struct field itself.
+	value  reflect.Value       // value is the field's value.
+	secret bool                // secret is true if the field, or a struct containing it, is tagged `secret:"true"`, or it's a Secret.
+}
+
+// name returns the dotted path of the field, e.g. "DB.Host".
+func (f field) name() string {
+	return strings.Join(f.path, ".")
+}
+
+// Clone returns a deep copy of the config. Slices, maps and pointers are copied recursively, so the clone can be
+// modified, or loaded into, without affecting the original. Fields tagged `qcl:"-"`, like loggers, are shared with the
+// original rather than copied.
+//
+// Example:
+//
+//	conf, _ := qcl.Load(&defaultConfig)
+//	testConf := qcl.Clone(conf)
+//	testConf.DB.Host = "localhost" // conf.DB.Host is unchanged
+func Clone[T any](config *T) *T {
+	if config == nil {
+		return nil
+	}
+	return deepCopy(reflect.ValueOf(config)).Interface().(*T)
+}
+
+// Override returns a clone of the config with the given fields overridden, leaving the original untouched. The keys of
+// the overrides map are dotted field paths, as in Change.Field, e.g. "DB.Host", and the values are parsed the same way
+// the loaders parse them. Nil pointers along a path are allocated in the clone, and slice and map fields are replaced
+// rather than appended to. If a key doesn't match a field, or a value can't be parsed, Override returns an error.
+//
+// Example:
+//
+//	testConf, err := qcl.Override(conf, map[string]string{"DB.Host": "localhost", "Timeout": "1s"})
+//
+// This is useful in tests and request-scoped experiments that need one field changed without mutating shared state.
+func Override[T any](config *T, overrides map[string]string) (*T, error) {
+	if config == nil {
+		config = new(T)
+	}
+	cp := deepCopy(reflect.ValueOf(config))
+	if cp.Elem().Kind() != reflect.Struct {
+		return nil, ConfigTypeError
+	}
+	for path, value := range overrides {
+		field, err := fieldByPath(cp.Elem(), path)
+		if err != nil {
+			return nil, err
+		}
+		if field.Kind() == reflect.Slice || field.Kind() == reflect.Map {
+			field.Set(reflect.Zero(field.Type()))
+		}
+		if err := defaultParseOptions.setField(field, value); err != nil {
+			return nil, err
+		}
+	}
+	attributeSource(cp.Interface(), "override")
+	return cp.Interface().(*T), nil
+}
+
+// fieldByPath returns the field of the struct val at the dotted path, allocating nil pointers along the way. Fields of
+// embedded structs can be addressed with or without the embedded type's name, the same way Go promotes them.
+func fieldByPath(val reflect.Value, path string) (reflect.Value, error) {
+	for _, name := range strings.Split(path, ".") {
+		if val.Kind() == reflect.Ptr {
+			if val.IsNil() {
+				val.Set(reflect.New(val.Type().Elem()))
+			}
+			val = val.Elem()
+		}
+		if val.Kind() != reflect.Struct {
+			return reflect.Value{}, UnknownFieldError{path}
+		}
+		sf, ok := val.Type().FieldByName(name)
+		if !ok || !sf.IsExported() {
+			return reflect.Value{}, UnknownFieldError{path}
+		}
+		val = val.FieldByIndex(sf.Index)
+	}
+	if val.Kind() == reflect.Ptr && val.Type().Elem().Kind() != reflect.Struct {
+		if val.IsNil() {
+			val.Set(reflect.New(val.Type().Elem()))
+		}
+		val = val.Elem()
+	}
+	return val, nil
+}
+
+// Diff compares two configs of the same type and returns a Change for every field that differs between them, in the
+// order the fields are declared. The values of fields tagged `secret:"true"` are replaced with RedactedValue.
+//
+// Example:
+//
+//	for _, change := range qcl.Diff(oldConf, newConf) {
+//		log.Printf("%s changed from %v to %v", change.Field, change.Old, change.New)
+//	}
+func Diff(old, new any) []Change {
+	oldFields, newFields := leafFields(old), leafFields(new)
+	changes := make([]Change, 0)
+	seen := make(map[string]bool, len(newFields))
+	for _, f := range newFields {
+		seen[f.name()] = true
+	}
+	oldByName := make(map[string]field, len(oldFields))
+	for _, f := range oldFields {
+		oldByName[f.name()] = f
+		if oldVal := fieldInterface(f); !seen[f.name()] && oldVal != nil {
+			changes = append(changes, newChange(f.name(), f.secret, oldVal, nil))
+		}
+	}
+	for _, f := range newFields {
+		o, ok := oldByName[f.name()]
+		var oldVal any
+		if ok {
+			oldVal = fieldInterface(o)
+		}
+		if newVal := fieldInterface(f); !reflect.DeepEqual(oldVal, newVal) {
+			changes = append(changes, newChange(f.name(), f.secret || o.secret, oldVal, newVal))
+		}
+	}
+	return changes
+}
+
+func newChange(name string, secret bool, old, new any) Change {
+	if secret {
+		old, new = RedactedValue, RedactedValue
+	}
+	return Change{Field: name, Old: old, New: new}
+}
+
+// Redacted returns the config as nested maps keyed by field name, with the values of fields tagged `secret:"true"`
+// replaced by RedactedValue. Embedded structs are flattened into their parent, the same way Go promotes their fields.
+// The result is suitable for encoding as JSON and showing to operators.
+func Redacted(config any) map[string]any {
+	out := make(map[string]any)
+	for _, f := range leafFields(config) {
+		m := out
+		for _, name := range f.path[:len(f.path)-1] {
+			next, ok := m[name].(map[string]any)
+			if !ok {
+				next = make(map[string]any)
+				m[name] = next
+			}
+			m = next
+		}
+		if f.secret {
+			m[f.path[len(f.path)-1]] = RedactedValue
+			continue
+		}
+		m[f.path[len(f.path)-1]] = fieldInterface(f)
+	}
+	return out
+}
+
+// A Setting describes one field of a config, for documenting it.
+type Setting struct {
+	Field  string `json:"field"`            // Field is the dotted path of the field, e.g. "DB.Host".
+	Type   string `json:"type"`             // Type is the field's Go type, e.g. "string" or "time.Duration".
+	Usage  string `json:"usage,omitempty"`  // Usage is the field's description, from its `usage` struct tag.
+	Secret bool   `json:"secret,omitempty"` // Secret is true if the field is tagged `secret:"true"`.
+	Value  any    `json:"value"`            // Value is the field's value. It is RedactedValue for secret fields.
+}
+
+// Settings describes every leaf field of the config, in the order they are declared, with the values of fields tagged
+// `secret:"true"` replaced by RedactedValue. Fields can be described with a `usage` struct tag.
+//
+// Example:
+//
+//	type Config struct {
+//		Host string `usage:"the address to listen on"`
+//	}
+//
+//	for _, s := range qcl.Settings(conf) {
+//		fmt.Printf("%s (%s): %v\n", s.Field, s.Usage, s.Value)
+//	}
+func Settings(config any) []Setting {
+	fields := leafFields(config)
+	settings := make([]Setting, len(fields))
+	for i, f := range fields {
+		settings[i] = Setting{
+			Field:  f.name(),
+			Type:   f.value.Type().String(),
+			Usage:  f.sf.Tag.Get("usage"),
+			Secret: f.secret,
+			Value:  fieldInterface(f),
+		}
+		if f.secret {
+			settings[i].Value = RedactedValue
+		}
+	}
+	return settings
+}
+
+// fieldUsage returns the description of the field at the dotted path of the struct val, given its leaf fields by path:
+// its `usage` tag or, for an interface field with registered implementations, whose key names the implementation it
+// holds, the implementations to choose from.
+func fieldUsage(val reflect.Value, fields map[string]field, path string) string {
+	if f, ok := fields[path]; ok {
+		return f.sf.Tag.Get("usage")
+	}
+	if iface, err := fieldByPath(val, path); err == nil && isPolymorphic(iface) {
+		return "the implementation, one of " + implementationNames(iface.Type())
+	}
+	return ""
+}
+
+// leafFields returns the leaf fields of the config, which may be a struct or a pointer to one.
+func leafFields(config any) []field {
+	val := reflect.ValueOf(config)
+	for val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return nil
+		}
+		val = val.Elem()
+	}
+	if val.Kind() != reflect.Struct {
+		return nil
+	}
+	fields := make([]field, 0, val.NumField())
+	walkFields(val, nil, false, func(f field) {
+		fields = append(fields, f)
+	})
+	return fields
+}
+
+// walkFields calls fn for every exported leaf field of the struct val, descending into nested structs and pointers to
+// structs. Embedded structs are flattened, so their fields don't include the embedded type's name in their path. A nil
+// pointer to a struct is itself treated as a leaf, and so is a nil interface field with registered implementations,
+// which are otherwise walked into like the struct they point to.
+func walkFields(val reflect.Value, path []string, secret bool, fn func(field)) {
+	typ := val.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		if !sf.IsExported() || skipField(sf) {
+			continue
+		}
+		fieldVal := val.Field(i)
+		fieldSecret := secret || sf.Tag.Get("secret") == "true" || isSecretType(sf.Type)
+		fieldPath := append(append(make([]string, 0, len(path)+1), path...), sf.Name)
+		elem := fieldVal
+		if isPolymorphic(elem) && !elem.IsNil() {
+			elem = elem.Elem()
+		}
+		if elem.Kind() == reflect.Ptr && !elem.IsNil() {
+			elem = elem.Elem()
+		}
+		if elem.Kind() == reflect.Struct && !isLeafStruct(elem.Type()) {
+			if sf.Anonymous {
+				fieldPath = path
+			}
+			walkFields(elem, fieldPath, fieldSecret, fn)
+			continue
+		}
+		fn(field{path: fieldPath, sf: sf, value: fieldVal, secret: fieldSecret})
+	}
+}
+
+// isLeafStruct reports whether a struct type should be treated as a single value rather than walked into, which is the
+// case for types that know how to parse or marshal themselves, like time.Time, and types with no exported fields.
+func isLeafStruct(typ reflect.Type) bool {
+	if _, ok := customSetter(reflect.New(typ).Elem()); ok {
+		return true
+	}
+	if typ.Implements(textMarshalerType) || reflect.PtrTo(typ).Implements(textMarshalerType) {
+		return true
+	}
+	for i := 0; i < typ.NumField(); i++ {
+		if typ.Field(i).IsExported() {
+			return false
+		}
+	}
+	return true
+}
+
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// fieldInterface returns the field's value as an interface, dereferencing pointers so that two configs with equal
+// values behind different pointers compare equal, and unwrapping Values so that their metadata doesn't count.
+func fieldInterface(f field) any {
+	v := f.value
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if w, ok := v.Interface().(wrapper); ok {
+		return w.unwrap()
+	}
+	return v.Interface()
+}
+
+// walkHooks calls fn with v, and every field nested in it, that implements the hook interface H, or whose pointer
+// does, along with its dotted path, outermost first. The methods of an embedded struct are promoted to the struct
+// embedding it, so they're only called through the embedding struct; if it overrides them, it's responsible for
+// calling them. Nil pointers are skipped.
+func walkHooks[H any](v reflect.Value, path string, promoted bool, fn func(hook H, path string)) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	hook, ok := asHook[H](v)
+	if ok && !promoted {
+		fn(hook, path)
+	}
+	if v.Kind() != reflect.Struct || isLeafStruct(v.Type()) {
+		return
+	}
+	for i := 0; i < v.NumField(); i++ {
+		sf := v.Type().Field(i)
+		if !sf.IsExported() || skipField(sf) {
+			continue
+		}
+		fieldPath := sf.Name
+		if sf.Anonymous {
+			fieldPath = path
+		} else if path != "" {
+			fieldPath = path + "." + sf.Name
+		}
+		walkHooks(v.Field(i), fieldPath, sf.Anonymous && ok, fn)
+	}
+}
+
+// asHook returns v as the hook interface H, if it or a pointer to it implements it.
+func asHook[H any](v reflect.Value) (H, bool) {
+	if v.CanAddr() {
+		if hook, ok := v.Addr().Interface().(H); ok {
+			return hook, true
+		}
+	}
+	var none H
+	if !v.CanInterface() {
+		return none, false
+	}
+	hook, ok := v.Interface().(H)
+	return hook, ok
+}
@@ -0,0 +1,223 @@
+package qcl
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type TestSecretConfig struct {
+	User     string
+	Password string `secret:"true"`
+	DB       *TestDBConfig
+	Creds    struct {
+		Token string
+	} `secret:"true"`
+	Timeout time.Time
+}
+
+func Test_Clone(t *testing.T) {
+	original := &TestSliceConfig{Hosts: []string{"localhost"}}
+	got := Clone(original)
+	if !reflect.DeepEqual(got, original) {
+		t.Fatalf("Clone() = %v, want %v", got, original)
+	}
+	got.Hosts[0] = "changed"
+	if original.Hosts[0] != "localhost" {
+		t.Errorf("Clone() shares memory with the original")
+	}
+	if Clone[TestConfig](nil) != nil {
+		t.Errorf("Clone(nil) should return nil")
+	}
+}
+
+func Test_Diff(t *testing.T) {
+	tests := map[string]struct {
+		old  any
+		new  any
+		want []Change
+	}{
+		"equal": {
+			old:  &TestConfig{Host: "localhost"},
+			new:  &TestConfig{Host: "localhost"},
+			want: []Change{},
+		},
+		"changed": {
+			old:  &TestConfig{Host: "localhost", Port: 80},
+			new:  &TestConfig{Host: "otherhost", Port: 80},
+			want: []Change{{Field: "Host", Old: "localhost", New: "otherhost"}},
+		},
+		"nested": {
+			old:  &TestNestedConfig{DB: TestDBConfig{Port: 5432}},
+			new:  &TestNestedConfig{DB: TestDBConfig{Port: 5433}},
+			want: []Change{{Field: "DB.Port", Old: 5432, New: 5433}},
+		},
+		"embedded": {
+			old:  &TestEmbeddedConfig{TestConfig{Port: 80}},
+			new:  &TestEmbeddedConfig{TestConfig{Port: 81}},
+			want: []Change{{Field: "Port", Old: 80, New: 81}},
+		},
+		"pointer to equal values": {
+			old:  &TestPointerConfig{Host: ptr("localhost")},
+			new:  &TestPointerConfig{Host: ptr("localhost")},
+			want: []Change{},
+		},
+		"nil pointer to struct": {
+			old: &TestSecretConfig{},
+			new: &TestSecretConfig{DB: &TestDBConfig{Host: "localhost"}},
+			want: []Change{
+				{Field: "DB.Host", Old: nil, New: "localhost"},
+				{Field: "DB.Port", Old: nil, New: 0},
+				{Field: "DB.SSL", Old: nil, New: false},
+			},
+		},
+		"removed pointer to struct": {
+			old: &TestSecretConfig{DB: &TestDBConfig{Port: 5432}},
+			new: &TestSecretConfig{},
+			want: []Change{
+				{Field: "DB.Host", Old: "", New: nil},
+				{Field: "DB.Port", Old: 5432, New: nil},
+				{Field: "DB.SSL", Old: false, New: nil},
+			},
+		},
+		"secret": {
+			old:  &TestSecretConfig{Password: "hunter2"},
+			new:  &TestSecretConfig{Password: "hunter3"},
+			want: []Change{{Field: "Password", Old: RedactedValue, New: RedactedValue}},
+		},
+		"secret struct": {
+			old: &TestSecretConfig{},
+			new: &TestSecretConfig{Creds: struct {
+				Token string
+			}{Token: "hunter2"}},
+			want: []Change{{Field: "Creds.Token", Old: RedactedValue, New: RedactedValue}},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := Diff(test.old, test.new); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Diff() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func Test_Redacted(t *testing.T) {
+	timeout := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	config := &TestSecretConfig{
+		User:     "admin",
+		Password: "hunter2",
+		DB:       &TestDBConfig{Host: "localhost", Port: 5432},
+		Timeout:  timeout,
+	}
+	want := map[string]any{
+		"User":     "admin",
+		"Password": RedactedValue,
+		"DB":       map[string]any{"Host": "localhost", "Port": 5432, "SSL": false},
+		"Creds":    map[string]any{"Token": RedactedValue},
+		"Timeout":  timeout,
+	}
+	if got := Redacted(config); !reflect.DeepEqual(got, want) {
+		t.Errorf("Redacted() = %v, want %v", got, want)
+	}
+	if got := Redacted(nil); len(got) != 0 {
+		t.Errorf("Redacted(nil) = %v, want empty map", got)
+	}
+}
+
+func Test_Override(t *testing.T) {
+	original := &TestNestedPointerConfig{Host: ptr("localhost")}
+	tests := map[string]struct {
+		overrides map[string]string
+		want      *TestNestedPointerConfig
+		wantErr   bool
+	}{
+		"none": {
+			want: &TestNestedPointerConfig{Host: ptr("localhost")},
+		},
+		"pointer": {
+			overrides: map[string]string{"Host": "otherhost", "Port": "8080"},
+			want:      &TestNestedPointerConfig{Host: ptr("otherhost"), Port: ptr(8080)},
+		},
+		"nested nil pointer": {
+			overrides: map[string]string{"DB.Port": "5432"},
+			want:      &TestNestedPointerConfig{Host: ptr("localhost"), DB: &TestDBConfig{Port: 5432}},
+		},
+		"unknown field": {
+			overrides: map[string]string{"DB.Hots": "localhost"},
+			wantErr:   true,
+		},
+		"not a struct": {
+			overrides: map[string]string{"Host.Name": "localhost"},
+			wantErr:   true,
+		},
+		"unparseable": {
+			overrides: map[string]string{"Port": "not an int"},
+			wantErr:   true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := Override(original, test.overrides)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Override() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if !test.wantErr && !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Override() = %v, want %v", got, test.want)
+			}
+			if *original.Host != "localhost" || original.Port != nil || original.DB != nil {
+				t.Errorf("Override() modified the original config")
+			}
+		})
+	}
+	t.Run("replaces slices and maps", func(t *testing.T) {
+		got, err := Override(&TestSliceConfig{Hosts: []string{"localhost"}}, map[string]string{"Hosts": "a,b"})
+		if err != nil {
+			t.Fatalf("Override() error = %v", err)
+		}
+		if want := []string{"a", "b"}; !reflect.DeepEqual(got.Hosts, want) {
+			t.Errorf("Override() Hosts = %v, want %v", got.Hosts, want)
+		}
+	})
+	t.Run("embedded", func(t *testing.T) {
+		got, err := Override(&TestEmbeddedConfig{}, map[string]string{"Host": "a", "TestConfig.Port": "1"})
+		if err != nil {
+			t.Fatalf("Override() error = %v", err)
+		}
+		if want := (&TestEmbeddedConfig{TestConfig{Host: "a", Port: 1}}); !reflect.DeepEqual(got, want) {
+			t.Errorf("Override() = %v, want %v", got, want)
+		}
+	})
+	t.Run("nil config", func(t *testing.T) {
+		got, err := Override[TestConfig](nil, map[string]string{"Host": "a"})
+		if err != nil || got.Host != "a" {
+			t.Errorf("Override() = %v, %v", got, err)
+		}
+	})
+	t.Run("non-struct config", func(t *testing.T) {
+		if _, err := Override(ptr("string"), nil); err == nil {
+			t.Errorf("Override() should return an error for non-struct config")
+		}
+	})
+}
+
+func Test_Settings(t *testing.T) {
+	type config struct {
+		Host    string `usage:"the address to listen on"`
+		Token   string `secret:"true"`
+		Timeout *time.Duration
+		DB      TestDBConfig
+	}
+	got := Settings(&config{Host: "localhost", Token: "hunter2", DB: TestDBConfig{Port: 5432}})
+	want := []Setting{
+		{Field: "Host", Type: "string", Usage: "the address to listen on", Value: "localhost"},
+		{Field: "Token", Type: "string", Secret: true, Value: RedactedValue},
+		{Field: "Timeout", Type: "*time.Duration", Value: nil},
+		{Field: "DB.Host", Type: "string", Value: ""},
+		{Field: "DB.Port", Type: "int", Value: 5432},
+		{Field: "DB.SSL", Type: "bool", Value: false},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Settings() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,46 @@
+//go:build go1.21
+
+package qcl
+
+import (
+	"log/slog"
+	"reflect"
+)
+
+// SyncLevel returns a change callback that keeps lv in sync with the log level in the config, so that changing the
+// level in a configuration source adjusts logging at runtime without a restart. The level function returns the
+// level from the config as a string, e.g. "debug", "INFO" or "warn+2", in any form accepted by slog.Level's
+// UnmarshalText. If the level is empty or can't be parsed, lv is left unchanged.
+//
+// Example:
+//
+//	type Config struct {
+//		LogLevel string
+//	}
+//
+//	var level slog.LevelVar
+//	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: &level}))
+//
+//	syncLevel := qcl.SyncLevel(&level, func(c *Config) string { return c.LogLevel })
+//	h, _ := admin.New(&defaultConfig, qcl.UseEnv())
+//	syncLevel(nil, h.Config()) // set the initial level
+//	h.OnChange(syncLevel)      // and keep it up to date on every reload
+//
+// The callback only touches lv when the level actually changed, so it can be registered on reloads that change
+// unrelated fields.
+func SyncLevel[T any](lv *slog.LevelVar, level func(config *T) string) func(old, new *T) {
+	return func(old, new *T) {
+		if new == nil {
+			return
+		}
+		next := level(new)
+		if next == "" || (old != nil && reflect.DeepEqual(level(old), next)) {
+			return
+		}
+		var l slog.Level
+		if err := l.UnmarshalText([]byte(next)); err != nil {
+			return
+		}
+		lv.Set(l)
+	}
+}
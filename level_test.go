@@ -0,0 +1,58 @@
+//go:build go1.21
+
+package qcl
+
+import (
+	"log/slog"
+	"testing"
+)
+
+type TestLogConfig struct {
+	LogLevel string
+}
+
+func Test_SyncLevel(t *testing.T) {
+	logLevel := func(c *TestLogConfig) string { return c.LogLevel }
+	tests := map[string]struct {
+		old  *TestLogConfig
+		new  *TestLogConfig
+		want slog.Level
+	}{
+		"initial": {
+			new:  &TestLogConfig{LogLevel: "debug"},
+			want: slog.LevelDebug,
+		},
+		"changed": {
+			old:  &TestLogConfig{LogLevel: "info"},
+			new:  &TestLogConfig{LogLevel: "WARN"},
+			want: slog.LevelWarn,
+		},
+		"unchanged": {
+			old:  &TestLogConfig{LogLevel: "debug"},
+			new:  &TestLogConfig{LogLevel: "debug"},
+			want: slog.LevelError,
+		},
+		"empty": {
+			old:  &TestLogConfig{LogLevel: "debug"},
+			new:  &TestLogConfig{},
+			want: slog.LevelError,
+		},
+		"invalid": {
+			new:  &TestLogConfig{LogLevel: "loud"},
+			want: slog.LevelError,
+		},
+		"nil config": {
+			want: slog.LevelError,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var lv slog.LevelVar
+			lv.Set(slog.LevelError)
+			SyncLevel(&lv, logLevel)(test.old, test.new)
+			if got := lv.Level(); got != test.want {
+				t.Errorf("SyncLevel() level = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
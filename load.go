@@ -1,5 +1,16 @@
 package qcl
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
 // A Loader is a function that loads the configuration from a specific source.
 type Loader func(any) error
 type LoadOption func(*LoadConfig) // LoadOption is a function that configures the Load function's LoadConfig. The Load function accepts a variable number of LoadOptions.
@@ -10,6 +21,32 @@ type LoadOption func(*LoadConfig) // LoadOption is a function that configures th
 type LoadConfig struct {
 	Sources []string          // Sources is a slice of the configuration sources.
 	Loaders map[string]Loader // Loaders is a map of the configuration sources and their corresponding loaders.
+
+	bases       []any                                                              // bases are the configs whose matching fields are copied into the config before any source runs.
+	provenance  *Provenance                                                        // provenance, if not nil, receives the origin of every field.
+	keys        map[string]map[string]string                                       // keys maps sources to the key each field they set came from, if provenance is being recorded.
+	lookups     map[string][]KeyLookup                                             // lookups are the keys sources looked for each field under, by dotted path, if provenance is being recorded.
+	keysMu      sync.Mutex                                                         // keysMu guards keys and lookups, since loaders abandoned at the deadline may still record.
+	deadline    time.Time                                                          // deadline is the time by which all sources must have completed. The zero value means no deadline.
+	partial     bool                                                               // partial makes Load return a best-effort config instead of nil when a source doesn't complete.
+	strict      bool                                                               // strict makes sources fail on keys that don't match a field.
+	scope       string                                                             // scope is the dotted prefix of the keys sources load, e.g. "db".
+	required    *requiredTracker                                                   // required, if not nil, tracks which of the fields tagged `required:"true"` sources set.
+	profile     string                                                             // profile is the environment profile, e.g. "prod", whose files and variables override the base ones.
+	ageIdentity string                                                             // ageIdentity, if not empty, is the path of the identity file values encrypted with age are decrypted with.
+	redact      func(fieldPath string) bool                                        // redact, if not nil, reports whether the value of a field is redacted from errors, besides secrets.
+	warnings    *[]Warning                                                         // warnings, if not nil, receives the warnings of the load.
+	debug       func(ctx context.Context, msg string, args ...any)                 // debug, if not nil, receives the debug records of the load, set with WithLogger.
+	metrics     func(source string, duration time.Duration, err error)             // metrics, if not nil, is called after each source has run.
+	tracing     func(ctx context.Context, source string) func(keys int, err error) // tracing, if not nil, starts a span around each source, set with WithTracing.
+	env         *envConfig                                                         // env is the configuration of the environment source, if any, whose variables -help mentions.
+
+	diagnostics     func(Diagnostic) // diagnostics receives the diagnostics reported while loading. Nil means the standard logger.
+	secretDetectors []SecretDetector // secretDetectors are run over non-secret fields once loading is done. Nil disables the scan.
+
+	watchers []Watcher // watchers signal Watch to reload the config.
+
+	ctx context.Context // ctx is the context of the load, done at the deadline, if there is one.
 }
 
 // DefaultLoadOptions is the default LoadOptions used by the Load function if no LoadOptions are passed into it.
@@ -18,6 +55,499 @@ var DefaultLoadOptions = []LoadOption{
 	UseFlags(),
 }
 
+// UseCustom enables loading configuration with a loader of your own, so backends qcl doesn't support, like a
+// proprietary config service, plug into Load like the built-in sources do. Sources are loaded in the order their
+// options are passed, so a custom source overrides the sources before it and is overridden by those after it. The
+// name identifies the source in errors, diagnostics and provenance, and must be unique among the sources of a Load.
+//
+// The loader receives a pointer to the config struct, and should set the fields it has values for and leave the
+// others alone. An error it returns fails the load, or, with WithPartialResult, skips the source.
+//
+// Example:
+//
+//	loader := func(config any) error {
+//		conf := config.(*Config)
+//		conf.DB.Host = lookupFromCompanyService("db.host")
+//		return nil
+//	}
+//
+//	qcl.Load(&defaultConfig, qcl.UseEnv(), qcl.UseCustom("company-service", loader), qcl.UseFlags())
+func UseCustom(name string, loader Loader) LoadOption {
+	return func(o *LoadConfig) {
+		o.Sources = append(o.Sources, name)
+		o.Loaders[name] = loader
+	}
+}
+
+// WithBase copies the fields of an already loaded config into the config being loaded, before any source runs, so
+// that sources override the base, and the base overrides the defaults. The base doesn't need to be of the same type:
+// fields are matched by name, including fields of nested structs, and copied if their types are assignable. Fields
+// that are zero in the base are left alone, so defaults the base doesn't set are kept. When WithBase is used more than
+// once, the bases are applied in order, each overriding the one before.
+//
+// Example:
+//
+//	type Common struct {
+//		LogLevel string
+//		DB       DBConfig
+//	}
+//
+//	type WorkerConfig struct {
+//		LogLevel    string
+//		DB          DBConfig
+//		Concurrency int
+//	}
+//
+//	common, _ := qcl.Load(&defaultCommon)
+//	worker, _ := qcl.Load(&defaultWorker, qcl.WithBase(common), qcl.UseEnv(qcl.WithEnvPrefix("WORKER")))
+//
+// This allows application, profile and per-component configs to be layered without copying fields by hand. Load
+// returns ConfigTypeError if the base isn't a struct or a pointer to one.
+func WithBase(base any) LoadOption {
+	return func(o *LoadConfig) {
+		o.bases = append(o.bases, base)
+	}
+}
+
+// An Origin describes where the value of a field came from.
+type Origin struct {
+	Source  string      `json:"source"`            // Source is the name of the source that set the field, "base" or "default".
+	Key     string      `json:"key,omitempty"`     // Key is the name the value has in the source, e.g. "DB_HOST", "config.ini:db.host" or "-db.host".
+	Lookups []KeyLookup `json:"lookups,omitempty"` // Lookups are the keys sources looked for the field under, whether or not they found them.
+}
+
+// A KeyLookup is a key a source looked for a field under, like the environment variable MYAPP_DB_HOST for DB.Host.
+type KeyLookup struct {
+	Source string `json:"source"` // Source is the name of the source that looked for the key.
+	Key    string `json:"key"`    // Key is the name the source looked for.
+	Found  bool   `json:"found"`  // Found reports whether the key was there, even if it was empty or its value was overridden.
+}
+
+// Provenance maps the dotted path of every field of a config, e.g. "DB.Host", to its Origin.
+type Provenance map[string]Origin
+
+// WithProvenance makes Load record where the value of each field came from in p: the name of the last source that
+// changed it, "base" if it came from a config given to WithBase, or "default" if nothing changed it, along with the
+// key the source had the value under: the environment variable, the flag, or the file's path and the key in it, like
+// "config.ini:db.host", and so on. Sources added with UseCustom and UseProvider don't name keys.
+//
+// The environment source also records every variable it read or looked for in the Lookups of each field, found or not,
+// like MYAPP_DB_HOST and MYAPP_DB_HOST_FILE, so that a field that's still at its default can be traced to the names
+// it was expected under.
+//
+// Example:
+//
+//	var provenance qcl.Provenance
+//	conf, err := qcl.Load(&defaultConfig, qcl.UseEnv(), qcl.UseFlags(), qcl.WithProvenance(&provenance))
+//	log.Printf("DB.Host = %s, from %s", conf.DB.Host, provenance["DB.Host"].Source)
+//
+// A source that sets a field to the value it already has isn't recorded as its origin. Recording provenance copies
+// the config before each source runs, so it is off by default.
+func WithProvenance(p *Provenance) LoadOption {
+	return func(o *LoadConfig) {
+		o.provenance = p
+	}
+}
+
+// WithRedactor redacts the raw values of the load errors for fields for whose dotted path, like "DB.Password",
+// redact returns true, besides those of fields tagged `secret:"true"`, so that a value that fails to parse doesn't
+// leak into logs through the error. Redacted fields are also left out of the scan of WithSecretScanner. It's meant
+// for secrets a naming convention identifies rather than a tag; Dump takes the same function with WithDumpRedactor.
+//
+// Example:
+//
+//	redact := func(path string) bool { return strings.HasSuffix(path, "Password") || strings.HasSuffix(path, "Token") }
+//	conf, err := qcl.Load(&defaultConfig, qcl.UseEnv(), qcl.WithRedactor(redact))
+//
+// The path of an error for an entry of a map or an element of a slice includes its key or index, like
+// "Tokens.github".
+func WithRedactor(redact func(fieldPath string) bool) LoadOption {
+	return func(o *LoadConfig) {
+		o.redact = redact
+	}
+}
+
+// WithScope makes sources load only the keys under the scope, a dotted prefix like "db" or "db.pool", into the config,
+// so a library can load its own slice of an application's configuration into a struct of its own, without knowing the
+// application's struct. For example, with the scope "db":
+//
+//   - the environment variable MYAPP_DB_HOST, with the prefix MYAPP, sets the field Host,
+//   - so does the host key of the [db] section of an INI file, or of the db block of an HCL file,
+//   - and the flag -db.host, as well as the file DB_HOST of a directory loaded with UseMountedDir.
+//
+// Example:
+//
+//	type DBConfig struct {
+//		Host string
+//		Port int
+//	}
+//
+//	db, err := qcl.Load(&DBConfig{Port: 5432}, qcl.UseFile("config.ini"), qcl.UseEnv(qcl.WithEnvPrefix("MYAPP")), qcl.WithScope("db"))
+//
+// Sources added with UseCustom, UseProvider, UseBatchFetcher and UseExternal load into the config as is, without
+// regard to the scope.
+func WithScope(scope string) LoadOption {
+	return func(o *LoadConfig) {
+		o.scope = scope
+	}
+}
+
+// ProfileEnvVar is the environment variable WithProfileFromEnv reads the profile from.
+const ProfileEnvVar = "APP_ENV"
+
+// WithProfile selects an environment profile, like "dev", "staging" or "prod", whose settings are merged over the base
+// configuration:
+//
+//   - every file loaded with UseFile, UseLayeredFiles or UseEmbeddedFile, like config.yaml, is followed by its profile
+//     variant, config.prod.yaml, if it exists,
+//   - and the environment variables with the profile after the prefix, like MYAPP_PROD_HOST, override MYAPP_HOST.
+//
+// Example:
+//
+//	qcl.Load(&defaultConfig, qcl.UseFile("config.yaml"), qcl.UseEnv(qcl.WithEnvPrefix("MYAPP")), qcl.WithProfile("prod"))
+//
+// An empty profile selects none.
+func WithProfile(profile string) LoadOption {
+	return func(o *LoadConfig) {
+		o.profile = profile
+	}
+}
+
+// WithProfileFromEnv is WithProfile with the profile named by the environment variable ProfileEnvVar, APP_ENV, so the
+// same binary picks up config.prod.yaml when deployed with APP_ENV=prod. No profile is selected if it isn't set.
+func WithProfileFromEnv() LoadOption {
+	return func(o *LoadConfig) {
+		o.profile = os.Getenv(ProfileEnvVar)
+	}
+}
+
+// profileName returns the profile set with WithProfile, if any.
+func (c *LoadConfig) profileName() string {
+	if c == nil {
+		return ""
+	}
+	return c.profile
+}
+
+// isStrict reports whether WithStrict is in use. It's false for a nil LoadConfig, when a loader is used on its own.
+func (c *LoadConfig) isStrict() bool {
+	return c != nil && c.strict
+}
+
+// scopePath returns the parts of the scope set with WithScope, if any, e.g. ["db", "pool"] for "db.pool".
+func (c *LoadConfig) scopePath() []string {
+	if c == nil {
+		return nil
+	}
+	return splitTagList(c.scope, ".")
+}
+
+// LoadWithReport is Load with WithProvenance, returning the provenance of the loaded config along with it, so
+// operators can answer "where did this value come from?" while debugging an incident.
+//
+// Example:
+//
+//	conf, report, err := qcl.LoadWithReport(&defaultConfig, qcl.UseFile("config.ini"), qcl.UseEnv())
+//	origin := report["DB.Host"]
+//	log.Printf("DB.Host = %s, from %s (%s)", conf.DB.Host, origin.Key, origin.Source)
+//
+// The provenance is nil if loading fails.
+func LoadWithReport[T any](defaultConfig *T, opts ...LoadOption) (*T, Provenance, error) {
+	var provenance Provenance
+	config, err := Load(defaultConfig, append(opts[:len(opts):len(opts)], WithProvenance(&provenance))...)
+	if config == nil {
+		return nil, nil, err
+	}
+	return config, provenance, err
+}
+
+// A PlannedChange is a field that Plan found loading would change, along with the origin of its new value.
+type PlannedChange struct {
+	Change
+	Origin
+}
+
+// Plan is a dry run of Load: it loads a copy of the config, leaving the config itself untouched, and returns the
+// fields loading would change, in the order they're declared, each with its old and new value and the source and key
+// the new value came from, so that a CI job can check, and show, what a config change does before it's deployed.
+// Fields set by a default tag, or a SetDefaults method, have the origin "default". As in Diff, the values of secret
+// fields are replaced with RedactedValue. If loading fails, Plan returns no changes and the error Load returned, so
+// the same validation runs as it would at startup.
+//
+// Example:
+//
+//	changes, err := qcl.Plan(&defaultConfig, qcl.UseFile("config.prod.ini"), qcl.UseEnv())
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	for _, c := range changes {
+//		fmt.Printf("%s: %v -> %v (%s from %s)\n", c.Field, c.Old, c.New, c.Key, c.Source)
+//	}
+func Plan[T any](config *T, opts ...LoadOption) ([]PlannedChange, error) {
+	if config == nil {
+		config = new(T)
+	}
+	var provenance Provenance
+	loaded, err := Load(Clone(config), append(opts[:len(opts):len(opts)], WithProvenance(&provenance))...)
+	if loaded == nil {
+		return nil, err
+	}
+	changes := Diff(config, loaded)
+	plan := make([]PlannedChange, len(changes))
+	for i, change := range changes {
+		plan[i] = PlannedChange{Change: change, Origin: provenance[change.Field]}
+	}
+	return plan, err
+}
+
+// MustLoad is Load for main functions that have no way to recover from a config that doesn't load. It returns the
+// loaded config, or panics with an error listing every reason loading failed, one per line:
+//
+//	conf := qcl.MustLoad(&defaultConfig, qcl.UseEnv(qcl.WithEnvPrefix("MYAPP")), qcl.UseFlags())
+//
+// panics, when two variables are invalid, with:
+//
+//	qcl: loading the config failed with 2 errors:
+//	  - MYAPP_PORT from env: invalid value "eighty" for Port: strconv.ParseInt: parsing "eighty": invalid syntax
+//	  - MYAPP_TIMEOUT from env: invalid value "soon" for Timeout: time: invalid duration "soon"
+//
+// The error wraps the one Load returned, so a recovered panic value can be inspected with errors.As.
+func MustLoad[T any](defaultConfig *T, opts ...LoadOption) *T {
+	config, err := Load(defaultConfig, opts...)
+	if err != nil {
+		panic(&loadFailure{err})
+	}
+	return config
+}
+
+// loadFailure is the error MustLoad panics with, which lists the reasons loading failed one per line.
+type loadFailure struct {
+	err error
+}
+
+func (e *loadFailure) Error() string {
+	lines := errorLines(e.err)
+	if len(lines) == 1 {
+		return "qcl: loading the config failed: " + lines[0]
+	}
+	return fmt.Sprintf("qcl: loading the config failed with %d errors:\n  - %s", len(lines), strings.Join(lines, "\n  - "))
+}
+
+func (e *loadFailure) Unwrap() error {
+	return e.err
+}
+
+// errorLines returns the reasons of the error, with those of a *MultiError and a *PartialLoadError listed one by one.
+func errorLines(err error) []string {
+	switch err := err.(type) {
+	case *MultiError:
+		var lines []string
+		for _, err := range err.Errors {
+			lines = append(lines, errorLines(err)...)
+		}
+		return lines
+	case *PartialLoadError:
+		var lines []string
+		for _, incomplete := range err.Incomplete {
+			if _, ok := incomplete.Err.(*MultiError); !ok {
+				lines = append(lines, incomplete.Error())
+				continue
+			}
+			for _, line := range errorLines(incomplete.Err) {
+				lines = append(lines, incomplete.Source+": "+line)
+			}
+		}
+		return lines
+	}
+	return []string{err.Error()}
+}
+
+// recorder returns the function the source's loader calls with the dotted path of each field it sets and the key the
+// value came from, for provenance and to tell which required fields it set.
+func (c *LoadConfig) recorder(source string) func(path, key string) {
+	return func(path, key string) {
+		c.logDebug("qcl: key found", "source", source, "key", key, "field", path)
+		if c.provenance == nil && c.required == nil && c.warnings == nil && c.debug == nil && c.tracing == nil {
+			return
+		}
+		c.keysMu.Lock()
+		defer c.keysMu.Unlock()
+		if c.keys == nil {
+			c.keys = make(map[string]map[string]string)
+		}
+		if c.keys[source] == nil {
+			c.keys[source] = make(map[string]string)
+		}
+		c.keys[source][path] = key
+	}
+}
+
+// lookupRecorder returns the function a source reports each key it looked for a field under to, and whether it found
+// it, for the provenance.
+func (c *LoadConfig) lookupRecorder(source string) func(path, key string, found bool) {
+	return func(path, key string, found bool) {
+		if c.provenance == nil {
+			return
+		}
+		c.keysMu.Lock()
+		defer c.keysMu.Unlock()
+		if c.lookups == nil {
+			c.lookups = make(map[string][]KeyLookup)
+		}
+		c.lookups[path] = append(c.lookups[path], KeyLookup{Source: source, Key: key, Found: found})
+	}
+}
+
+// sourceKeys returns a copy of the keys the source reported for the fields it set, by dotted path.
+func (c *LoadConfig) sourceKeys(source string) map[string]string {
+	c.keysMu.Lock()
+	defer c.keysMu.Unlock()
+	keys := make(map[string]string, len(c.keys[source]))
+	for path, key := range c.keys[source] {
+		keys[path] = key
+	}
+	return keys
+}
+
+// WithMetrics calls observe after each source has run, with the name of the source, e.g. "env" or "file:config.ini",
+// how long it took, and the error it failed with, if any, so services can export the latency and failures of loading
+// their config, especially from remote sources. A source that is still running when the context of the load is done,
+// or its deadline passes, is observed with the error Load reports for it; the sources after it, which don't run,
+// aren't observed.
+//
+// Example:
+//
+//	loadSeconds := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "config_load_seconds"}, []string{"source", "result"})
+//	qcl.Load(&defaultConfig, qcl.UseEnv(), qcl.UseProvider("consul", provider), qcl.WithMetrics(func(source string, duration time.Duration, err error) {
+//		result := "ok"
+//		if err != nil {
+//			result = "error"
+//		}
+//		loadSeconds.WithLabelValues(source, result).Observe(duration.Seconds())
+//	}))
+func WithMetrics(observe func(source string, duration time.Duration, err error)) LoadOption {
+	return func(o *LoadConfig) {
+		o.metrics = observe
+	}
+}
+
+// observe reports how long the source took, and its error, to the function set with WithMetrics, if any.
+func (c *LoadConfig) observe(source string, duration time.Duration, err error) {
+	if c.metrics != nil {
+		c.metrics(source, duration, err)
+	}
+}
+
+// WithTracing wraps the load of each source in a span, so that slow sources, like remote config services, show up in
+// startup traces. startSpan is called before each source runs, with the context of the load and the name of the
+// source, e.g. "env" or "file:config.ini", and returns the function that ends the span once the source has run, with
+// the number of keys the source set fields from and the error it failed with, if any. qcl doesn't depend on a tracing
+// library, so startSpan adapts the one in use, e.g. OpenTelemetry:
+//
+//	tracer := otel.Tracer("github.com/thezmc/qcl")
+//	qcl.LoadContext(ctx, &defaultConfig, qcl.UseEnv(), qcl.UseProvider("consul", provider), qcl.WithTracing(
+//		func(ctx context.Context, source string) func(keys int, err error) {
+//			_, span := tracer.Start(ctx, "qcl.load "+source, trace.WithAttributes(attribute.String("qcl.source", source)))
+//			return func(keys int, err error) {
+//				span.SetAttributes(attribute.Int("qcl.keys", keys))
+//				if err != nil {
+//					span.RecordError(err)
+//					span.SetStatus(codes.Error, err.Error())
+//				}
+//				span.End()
+//			}
+//		},
+//	))
+//
+// A source that is still running when the context of the load is done, or its deadline passes, ends its span with the
+// error Load reports for it; the sources after it, which don't run, have no span.
+func WithTracing(startSpan func(ctx context.Context, source string) (endSpan func(keys int, err error))) LoadOption {
+	return func(o *LoadConfig) {
+		o.tracing = startSpan
+	}
+}
+
+// startSpan starts the span of the source with the function set with WithTracing, if any, and returns the function
+// that ends it with the source's error.
+func (c *LoadConfig) startSpan(source string) func(err error) {
+	if c.tracing == nil {
+		return func(error) {}
+	}
+	end := c.tracing(c.ctx, source)
+	return func(err error) {
+		end(len(c.sourceKeys(source)), err)
+	}
+}
+
+// logDebug emits a debug record to the logger set with WithLogger, if any.
+func (c *LoadConfig) logDebug(msg string, args ...any) {
+	if c == nil || c.debug == nil {
+		return
+	}
+	ctx := c.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	c.debug(ctx, msg, args...)
+}
+
+// WithDeadline sets a deadline for the whole load pipeline. Each source is loaded in turn, and if the deadline passes
+// before every source has completed, Load stops waiting and returns an error wrapping DeadlineExceededError for the
+// source that was running and every source after it. Sources that complete before the deadline are applied as usual.
+//
+// Example:
+//
+//	qcl.Load(&defaultConfig, qcl.UseEnv(), qcl.UseFlags(), qcl.WithDeadline(time.Now().Add(5*time.Second)))
+//
+// By default, there is no deadline.
+func WithDeadline(t time.Time) LoadOption {
+	return func(o *LoadConfig) {
+		o.deadline = t
+	}
+}
+
+// WithPartialResult makes Load return the best-effort, partially loaded configuration when one or more sources don't
+// complete, either because they returned an error or because the deadline set by WithDeadline passed. Each source is
+// applied atomically, so a source that fails part way through leaves no trace in the returned configuration. The
+// returned error is a *PartialLoadError describing which sources didn't complete and why. The fields that did load are
+// decrypted, checked and validated as they are when every source completes, and what's wrong with them is reported in
+// the error's Invalid field.
+//
+// Example:
+//
+//	conf, err := qcl.Load(&defaultConfig, qcl.UseEnv(), qcl.UseFlags(), qcl.WithPartialResult())
+//	if err != nil {
+//		log.Printf("starting with degraded config: %v", err)
+//	}
+//
+// This is intended for systems that prefer a degraded startup over no startup. By default, Load returns a nil config
+// along with the errors encountered.
+func WithPartialResult() LoadOption {
+	return func(o *LoadConfig) {
+		o.partial = true
+	}
+}
+
+// WithStrict makes sources fail on keys that don't match any field of the config, which catches typos like
+// TEST_DB_PRT that would otherwise silently do nothing. Each unknown key is reported as an *UnknownKeyError. It
+// applies to:
+//
+//   - environment variables starting with the prefix set with WithEnvPrefix; without a prefix, there's no telling the
+//     application's variables from the rest of the environment, so none are checked,
+//   - the keys and sections of files, and
+//   - the files of directories loaded with UseMountedDir.
+//
+// Flags are always strict, since the flag package rejects undefined flags.
+//
+// Example:
+//
+//	qcl.Load(&defaultConfig, qcl.UseFile("config.ini"), qcl.UseEnv(qcl.WithEnvPrefix("MYAPP")), qcl.WithStrict())
+func WithStrict() LoadOption {
+	return func(o *LoadConfig) {
+		o.strict = true
+	}
+}
+
 // Load modifies the pointer it receives with configuration information from the sources specified in the LoadOptions.
 // The Load function are passed to the Load function. The default LoadOptions are:
 //
@@ -36,32 +566,323 @@ var DefaultLoadOptions = []LoadOption{
 //
 //	qcl.Load(&defaultConfig, qcl.DefaultLoadOptions...)
 //
-// If any LoadOption is passed to the Load function, the default LoadOptions will not be used.
-// The Load function returns a pointer to the configuration struct, and an error.
+// If any of the LoadOptions passed to the Load function add a source, the default LoadOptions will not be used. Options
+// that don't add a source, like WithDeadline, are applied on top of the default LoadOptions.
+// The Load function returns a pointer to the configuration struct, and an error. Load is LoadContext with
+// context.Background().
+//
+// A source that fails doesn't stop the others from loading, and a source with several invalid values reports them all,
+// so that a single failed startup reports everything that's wrong: when there is more than one error, the error
+// returned is a *MultiError listing them, which errors.Is and errors.As look through. Flags are the exception, since
+// the flag package stops parsing at the first invalid flag.
+//
+// Fields tagged `required:"true"` must be set by one of the sources, not just left at the value of the defaults, or
+// Load returns a *MissingFieldsError listing every one that wasn't, once the sources have all loaded.
+//
+//	type Config struct {
+//		DatabaseURL string `required:"true"`
+//	}
+//
+// Fields tagged with a default, like `default:"8080"`, `default:"30s"` or `default:"a,b,c"`, are set to it before any
+// source runs, if the default config leaves them zero. Defaults are parsed as environment variables are, with iterables
+// separated by a comma, and an invalid one is reported as a *FieldError. Defaults that can't be written as tags, like
+// maps or values computed from others, can be set by a SetDefaults() method on the config, or on any struct nested in
+// it, which is called after the tags are applied.
+//
+// Once loaded, fields are checked against the comma-separated rules of their `validate` tag, and every rule broken is
+// reported as a *ValidationError:
+//
+//   - min=N and max=N bound numbers, including durations, like min=1s, and the length of strings, slices and maps,
+//   - oneof=a b c requires one of the space-separated values,
+//   - and regex=EXPR requires a match of the regular expression. Since it may contain commas, it must come last.
+//
+// For example:
+//
+//	type Config struct {
+//		Port     int    `validate:"min=1,max=65535"`
+//		LogLevel string `validate:"oneof=debug info warn error"`
+//		Region   string `validate:"regex=^[a-z]+-[a-z]+-[0-9]$"`
+//	}
+//
+// Invariants the tag can't express, like a certificate and its key being set together, can be checked by a Validate()
+// error method on the config, or on any struct nested in it, which is called once the rules have been checked. Errors
+// from nested structs are prefixed with their dotted path.
 func Load[T any](defaultConfig *T, opts ...LoadOption) (*T, error) {
+	return LoadContext(context.Background(), defaultConfig, opts...)
+}
+
+// LoadContext is Load with a context, which is passed to the sources that accept one, like UseProvider,
+// UseBatchFetcher and UseExternal, so they can honor its deadline and cancellation. If the context is done before every source has
+// completed, LoadContext stops waiting, as it does at the deadline set with WithDeadline: it returns an error wrapping
+// DeadlineExceededError if the context's deadline passed, or the context's error, e.g. context.Canceled, if it was
+// canceled, for the source that was running and every source after it.
+//
+// Example:
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+//	defer cancel()
+//	conf, err := qcl.LoadContext(ctx, &defaultConfig, qcl.UseEnv(), qcl.UseProvider("consul", provider))
+func LoadContext[T any](ctx context.Context, defaultConfig *T, opts ...LoadOption) (*T, error) {
+	if defaultConfig == nil {
+		defaultConfig = new(T)
+	}
+	loaded, err := load(ctx, defaultConfig, opts)
+	if !loaded {
+		return nil, err
+	}
+	return defaultConfig, err
+}
+
+// LoadInto is Load for callers that only hold the config as an any, like plugin systems or dependency injection
+// containers, rather than a type parameter. The config must be a non-nil pointer to a struct, or LoadInto returns
+// ConfigTypeError; it's modified in place, as Load modifies the pointer it receives.
+//
+// Example:
+//
+//	var conf any = &Config{Port: 8080}
+//	err := qcl.LoadInto(conf, qcl.UseEnv(qcl.WithEnvPrefix("MYAPP")))
+func LoadInto(config any, opts ...LoadOption) error {
+	v := reflect.ValueOf(config)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return ConfigTypeError
+	}
+	_, err := load(context.Background(), config, opts)
+	return err
+}
+
+// load loads the pointer to the default config from the sources of the options. It returns whether the config is
+// loaded, which it is if the error is nil or a *PartialLoadError returned for WithPartialResult.
+func load(ctx context.Context, defaultConfig any, opts []LoadOption) (bool, error) {
 	config := new(LoadConfig)
 	config.Sources = make([]string, 0, len(opts))
 	config.Loaders = make(map[string]Loader, len(opts))
 
-	if len(opts) == 0 {
-		return Load(defaultConfig, DefaultLoadOptions...)
-	}
-
 	for _, opt := range opts {
 		opt(config)
 	}
+	if len(config.Sources) == 0 {
+		for _, opt := range DefaultLoadOptions {
+			opt(config)
+		}
+	}
 
-	if defaultConfig == nil {
-		defaultConfig = new(T)
+	if err := applyTagDefaults(reflect.ValueOf(defaultConfig).Elem(), "", map[reflect.Type]bool{}); err != nil {
+		return false, err
+	}
+	callDefaultHooks(reflect.ValueOf(defaultConfig))
+	config.ctx = ctx
+	if !config.deadline.IsZero() {
+		var cancel context.CancelFunc
+		config.ctx, cancel = context.WithDeadline(ctx, config.deadline)
+		defer cancel()
+	}
+	if config.provenance != nil {
+		*config.provenance = make(Provenance)
 	}
-	for _, source := range config.Sources {
-		if load, ok := config.Loaders[source]; ok {
-			err := load(defaultConfig)
-			if err != nil {
-				return nil, err
+	var deprecated map[string]string
+	if config.warnings != nil {
+		*config.warnings = nil
+		deprecated = deprecatedFields(defaultConfig)
+	}
+	before := config.snapshot(defaultConfig)
+	for _, base := range config.bases {
+		if err := copyMatching(reflect.ValueOf(defaultConfig).Elem(), base); err != nil {
+			return false, err
+		}
+	}
+	config.trackOrigin("base", before, defaultConfig)
+	partialErr := new(PartialLoadError)
+	var errs []error
+	if err := checkSourcePolicies(defaultConfig, config.Sources); err != nil {
+		return false, err
+	}
+	precedence := newPrecedenceTracker(defaultConfig)
+	config.required = newRequiredTracker(defaultConfig)
+	config.logDebug("qcl: loading config", "sources", config.Sources)
+	for i, source := range config.Sources {
+		loader, ok := config.Loaders[source]
+		if !ok {
+			config.logDebug("qcl: source skipped", "source", source, "reason", "no loader")
+			continue
+		}
+		before := config.snapshot(defaultConfig)
+		pinned := precedence.snapshot(defaultConfig)
+		unset := config.required.snapshot(defaultConfig)
+		endSpan := config.startSpan(source)
+		start := time.Now()
+		err := config.run(loader, defaultConfig)
+		duration := time.Since(start)
+		if err != nil && config.ctx.Err() != nil {
+			config.logDebug("qcl: source incomplete", "source", source, "duration", duration, "error", config.ctxErr())
+			config.observe(source, duration, config.ctxErr())
+			endSpan(config.ctxErr())
+			for _, pending := range config.Sources[i:] {
+				partialErr.Incomplete = append(partialErr.Incomplete, SourceError{pending, config.ctxErr()})
 			}
+			break
+		}
+		if err != nil {
+			attributeErrors(err, source, defaultConfig, config.redact)
+			config.logDebug("qcl: source failed", "source", source, "duration", duration, "error", err)
+			config.observe(source, duration, err)
+			endSpan(err)
+			// keep going, so that everything that's wrong is reported at once
+			errs = append(errs, err)
+			partialErr.Incomplete = append(partialErr.Incomplete, SourceError{source, err})
+			continue
 		}
+		precedence.enforce(source, pinned, defaultConfig)
+		config.required.mark(unset, defaultConfig, config.sourceKeys(source))
+		config.warnDeprecated(source, deprecated)
+		config.logDebug("qcl: source loaded", "source", source, "duration", duration, "keys", len(config.sourceKeys(source)))
+		config.observe(source, duration, nil)
+		endSpan(nil)
+		attributeSource(defaultConfig, source)
+		config.trackOrigin(source, before, defaultConfig)
 	}
 
-	return defaultConfig, nil
+	config.completeProvenance(defaultConfig)
+	if len(partialErr.Incomplete) == 0 {
+		if err := config.check(defaultConfig); err != nil {
+			return false, err
+		}
+		config.scanSecrets(defaultConfig)
+		return true, nil
+	}
+	if config.partial {
+		// the fields that did load are decrypted and validated as a complete load's are, so that ciphertext and invalid
+		// values are reported rather than passed on silently
+		partialErr.Invalid = config.check(defaultConfig)
+		config.scanSecrets(defaultConfig)
+		return true, partialErr
+	}
+	if len(errs) == len(partialErr.Incomplete) { // every source ran, rather than the context ending the load
+		// the config is checked anyway, so that one failed load reports everything that's wrong with it
+		return false, joinErrors(append(errs, config.check(defaultConfig)))
+	}
+	return false, partialErr
+}
+
+// check decrypts the values of the loaded config, then checks that its required fields are set, normalizes its enums
+// and validates it, returning everything that's wrong.
+func (c *LoadConfig) check(config any) error {
+	checks := append([]error{c.decryptValues(config), c.required.check(), normalizeEnums(config), validate(config)}, callValidateHooks(reflect.ValueOf(config))...)
+	return joinErrors(checks)
+}
+
+// attributeErrors records the source as the origin of the FieldErrors and UnknownKeyErrors in err that don't name one, and replaces their raw
+// values with RedactedValue if they're for fields tagged `secret:"true"`, or fields redact, if not nil, returns true
+// for, since errors end up in logs.
+func attributeErrors(err error, source string, config any, redact func(fieldPath string) bool) {
+	var secrets []string
+	for _, f := range leafFields(config) {
+		if f.secret {
+			secrets = append(secrets, f.name())
+		}
+	}
+	visitErrors(err, func(err error) {
+		switch err := err.(type) {
+		case *UnknownKeyError:
+			if err.Source == "" {
+				err.Source = source
+			}
+		case *FieldError:
+			if err.Source == "" {
+				err.Source = source
+			}
+			if redact != nil && redact(err.Path) {
+				err.RawValue = RedactedValue
+			}
+			for _, secret := range secrets {
+				if err.Path == secret || strings.HasPrefix(err.Path, secret+".") { // e.g. an entry of a secret map
+					err.RawValue = RedactedValue
+				}
+			}
+		}
+	})
+}
+
+// snapshot returns a copy of the config to compare against once a source has run, if provenance is being recorded.
+func (c *LoadConfig) snapshot(config any) any {
+	if c.provenance == nil {
+		return nil
+	}
+	return deepCopy(reflect.ValueOf(config)).Interface()
+}
+
+// trackOrigin records the source as the origin of every field that differs between the snapshot taken before it ran
+// and the config.
+func (c *LoadConfig) trackOrigin(source string, before, config any) {
+	if c.provenance == nil {
+		return
+	}
+	for _, change := range Diff(before, config) {
+		// a nil pointer allocated by a loader isn't a value the loader set
+		if change.Old == nil && change.New != nil && reflect.ValueOf(change.New).IsZero() {
+			continue
+		}
+		c.keysMu.Lock()
+		key := c.keys[source][change.Field]
+		c.keysMu.Unlock()
+		(*c.provenance)[change.Field] = Origin{Source: source, Key: key}
+	}
+}
+
+// completeProvenance limits the provenance to the fields the loaded config has, and records the fields no source
+// changed as coming from the default.
+func (c *LoadConfig) completeProvenance(config any) {
+	if c.provenance == nil {
+		return
+	}
+	complete := make(Provenance)
+	for _, f := range leafFields(config) {
+		origin, ok := (*c.provenance)[f.name()]
+		if !ok {
+			origin = Origin{Source: "default"}
+		}
+		c.keysMu.Lock()
+		origin.Lookups = c.lookups[f.name()]
+		c.keysMu.Unlock()
+		complete[f.name()] = origin
+	}
+	*c.provenance = complete
+}
+
+// run calls the loader against the config. Without a context that can be done or partial results, the loader
+// modifies the config directly. Otherwise it runs against a copy of the config that is only written back once the
+// loader has succeeded, so that a loader that fails or is abandoned when the context is done can't leave the config
+// half-loaded, or race with the caller.
+func (c *LoadConfig) run(load Loader, config any) error {
+	if c.ctx.Done() == nil && !c.partial {
+		return load(config)
+	}
+	if c.ctx.Err() != nil {
+		return c.ctxErr()
+	}
+
+	dst := reflect.ValueOf(config).Elem()
+	cp := deepCopy(reflect.ValueOf(config))
+	done := make(chan error, 1) // buffered so an abandoned loader doesn't leak its goroutine forever
+	go func() {
+		done <- load(cp.Interface())
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return err
+		}
+		dst.Set(cp.Elem())
+		return nil
+	case <-c.ctx.Done():
+		return c.ctxErr()
+	}
+}
+
+// ctxErr returns the reason the load's context is done: DeadlineExceededError if its deadline passed, or its error.
+func (c *LoadConfig) ctxErr() error {
+	if errors.Is(c.ctx.Err(), context.DeadlineExceeded) {
+		return DeadlineExceededError
+	}
+	return c.ctx.Err()
 }
@@ -1,10 +1,19 @@
 package qcl
 
 import (
+	"context"
+	"errors"
 	"flag"
+	"fmt"
+	"io"
+	"log"
 	"os"
+	"path/filepath"
 	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 func Test_Load(t *testing.T) {
@@ -41,3 +50,924 @@ func Test_Load(t *testing.T) {
 		}
 	})
 }
+
+func setHost(host string, delay time.Duration) Loader {
+	return func(config any) error {
+		time.Sleep(delay)
+		config.(*TestConfig).Host = host
+		return nil
+	}
+}
+
+func Test_WithDeadline(t *testing.T) {
+	t.Run("completes before deadline", func(t *testing.T) {
+		got, err := Load(&TestConfig{}, UseCustom("fast", setHost("fast", 0)), WithDeadline(time.Now().Add(time.Second)))
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		if got.Host != "fast" {
+			t.Errorf("Load() got = %v, want %v", got.Host, "fast")
+		}
+	})
+	t.Run("deadline exceeded", func(t *testing.T) {
+		got, err := Load(&TestConfig{},
+			UseCustom("slow", setHost("slow", time.Second)),
+			WithDeadline(time.Now().Add(10*time.Millisecond)),
+		)
+		if !errors.Is(err, DeadlineExceededError) {
+			t.Errorf("Load() error = %v, want %v", err, DeadlineExceededError)
+		}
+		if got != nil {
+			t.Errorf("Load() got = %v, want nil", got)
+		}
+	})
+	t.Run("deadline already passed", func(t *testing.T) {
+		_, err := Load(&TestConfig{}, UseCustom("fast", setHost("fast", 0)), WithDeadline(time.Now().Add(-time.Second)))
+		if !errors.Is(err, DeadlineExceededError) {
+			t.Errorf("Load() error = %v, want %v", err, DeadlineExceededError)
+		}
+	})
+}
+
+func Test_WithMetrics(t *testing.T) {
+	type observation struct {
+		source string
+		err    error
+	}
+	var got []observation
+	var slowDuration time.Duration
+	unavailable := errors.New("unavailable")
+	_, err := Load(&TestConfig{},
+		UseCustom("fast", setHost("fast", 0)),
+		UseCustom("failing", func(any) error { return unavailable }),
+		UseCustom("slow", setHost("slow", time.Second)),
+		UseCustom("never", setHost("never", 0)),
+		WithDeadline(time.Now().Add(50*time.Millisecond)),
+		WithMetrics(func(source string, duration time.Duration, err error) {
+			got = append(got, observation{source, err})
+			if source == "slow" {
+				slowDuration = duration
+			}
+		}),
+	)
+	if err == nil {
+		t.Fatal("Load() error = nil")
+	}
+	want := []observation{{"fast", nil}, {"failing", unavailable}, {"slow", DeadlineExceededError}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("WithMetrics() observed %v, want %v", got, want)
+	}
+	if slowDuration < 40*time.Millisecond || slowDuration > time.Second {
+		t.Errorf("WithMetrics() observed slow taking %v, want about the deadline", slowDuration)
+	}
+}
+
+func Test_WithTracing(t *testing.T) {
+	type ctxKey struct{}
+	t.Setenv("TEST_HOST", "app")
+	t.Setenv("TEST_PORT", "80")
+	var got []string
+	unavailable := errors.New("unavailable")
+	ctx := context.WithValue(context.Background(), ctxKey{}, "trace")
+	_, err := LoadContext(ctx, &TestConfig{},
+		UseEnv(WithEnvPrefix("TEST")),
+		UseCustom("failing", func(any) error {
+			got = append(got, "load failing")
+			return unavailable
+		}),
+		WithTracing(func(ctx context.Context, source string) func(int, error) {
+			got = append(got, fmt.Sprintf("start %s in %v", source, ctx.Value(ctxKey{})))
+			return func(keys int, err error) {
+				got = append(got, fmt.Sprintf("end %s with %d keys: %v", source, keys, err))
+			}
+		}),
+	)
+	if !errors.Is(err, unavailable) {
+		t.Fatalf("LoadContext() error = %v, want %v", err, unavailable)
+	}
+	want := []string{
+		"start env in trace",
+		"end env with 2 keys: <nil>",
+		"start failing in trace",
+		"load failing",
+		"end failing with 0 keys: unavailable",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("WithTracing() spans = %q, want %q", got, want)
+	}
+}
+
+func Test_WithPartialResult(t *testing.T) {
+	t.Run("runtime fields", func(t *testing.T) {
+		type config struct {
+			Host   string
+			Logger *log.Logger `qcl:"-"`
+			Parent *config     `qcl:"-"`
+		}
+		logger := log.New(io.Discard, "", 0)
+		defaultConfig := &config{Logger: logger}
+		defaultConfig.Parent = defaultConfig
+		got, err := Load(defaultConfig, UseCustom("custom", func(c any) error {
+			c.(*config).Host = "custom"
+			return nil
+		}), WithPartialResult())
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		if got.Host != "custom" || got.Logger != logger || got.Parent != defaultConfig {
+			t.Errorf("Load() = %+v, want Host custom with the logger and parent of the defaults", got)
+		}
+	})
+	t.Run("deadline exceeded", func(t *testing.T) {
+		defaultConfig := &TestConfig{Port: 8080}
+		got, err := Load(defaultConfig,
+			UseCustom("fast", setHost("fast", 0)),
+			UseCustom("slow", setHost("slow", time.Second)),
+			UseCustom("never", setHost("never", 0)),
+			WithDeadline(time.Now().Add(50*time.Millisecond)),
+			WithPartialResult(),
+		)
+		want := &TestConfig{Host: "fast", Port: 8080}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Load() got = %v, want %v", got, want)
+		}
+		var partialErr *PartialLoadError
+		if !errors.As(err, &partialErr) {
+			t.Fatalf("Load() error = %v, want *PartialLoadError", err)
+		}
+		wantIncomplete := []SourceError{{"slow", DeadlineExceededError}, {"never", DeadlineExceededError}}
+		if !reflect.DeepEqual(partialErr.Incomplete, wantIncomplete) {
+			t.Errorf("PartialLoadError.Incomplete = %v, want %v", partialErr.Incomplete, wantIncomplete)
+		}
+	})
+	t.Run("failing source is skipped", func(t *testing.T) {
+		failure := errors.New("failure")
+		got, err := Load(&TestConfig{},
+			UseCustom("failing", func(config any) error {
+				config.(*TestConfig).Port = 1234
+				return failure
+			}),
+			UseCustom("fast", setHost("fast", 0)),
+			WithPartialResult(),
+		)
+		want := &TestConfig{Host: "fast"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Load() got = %v, want %v", got, want)
+		}
+		if !errors.Is(err, failure) {
+			t.Errorf("Load() error = %v, want %v", err, failure)
+		}
+		if err.Error() != "sources did not complete: failing: failure" {
+			t.Errorf("Load() error = %v, want %v", err.Error(), "sources did not complete: failing: failure")
+		}
+	})
+	t.Run("loaded fields are checked", func(t *testing.T) {
+		type config struct {
+			Port   int    `validate:"max=100"`
+			Secret string `encrypted:"age"`
+		}
+		got, err := Load(&config{},
+			UseCustom("failing", func(any) error { return errors.New("failure") }),
+			UseEnv(WithEnviron(map[string]string{"PORT": "8080", "SECRET": "age:ciphertext"})),
+			WithPartialResult(),
+		)
+		if got == nil || got.Port != 8080 {
+			t.Fatalf("Load() got = %v, want the partial config", got)
+		}
+		var partialErr *PartialLoadError
+		if !errors.As(err, &partialErr) || partialErr.Invalid == nil {
+			t.Fatalf("Load() error = %v, want a *PartialLoadError with Invalid set", err)
+		}
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) || validationErr.Path != "Port" {
+			t.Errorf("Load() error = %v, want a ValidationError for Port", err)
+		}
+		var fieldErr *FieldError
+		if !errors.As(err, &fieldErr) || fieldErr.Path != "Secret" {
+			t.Errorf("Load() error = %v, want a FieldError for Secret", err)
+		}
+	})
+}
+
+func Test_WithBase(t *testing.T) {
+	host, port, nestedPort := "base", 5432, 8080
+	tests := map[string]struct {
+		defaults any
+		bases    []any
+		want     any
+		wantErr  bool
+	}{
+		"same type": {
+			defaults: &TestNestedConfig{Port: 8080},
+			bases:    []any{&TestNestedConfig{Host: "base", DB: TestDBConfig{Host: "db", SSL: true}}},
+			want:     &TestNestedConfig{Host: "base", Port: 8080, DB: TestDBConfig{Host: "db", SSL: true}},
+		},
+		"different type": {
+			defaults: &TestNestedPointerConfig{},
+			bases:    []any{TestNestedConfig{Host: "base", Port: 8080, DB: TestDBConfig{Port: 5432}}},
+			want:     &TestNestedPointerConfig{Host: &host, Port: &nestedPort, DB: &TestDBConfig{Port: 5432}},
+		},
+		"pointers followed": {
+			defaults: &TestConfig{},
+			bases:    []any{&TestPointerConfig{Host: &host, Port: &port}},
+			want:     &TestConfig{Host: "base", Port: 5432},
+		},
+		"embedded": {
+			defaults: &TestEmbeddedConfig{},
+			bases:    []any{&TestConfig{Host: "base"}},
+			want:     &TestEmbeddedConfig{TestConfig{Host: "base"}},
+		},
+		"applied in order": {
+			defaults: &TestConfig{},
+			bases:    []any{&TestConfig{Host: "first", Port: 1}, &TestConfig{Host: "second"}},
+			want:     &TestConfig{Host: "second", Port: 1},
+		},
+		"not a struct": {
+			defaults: &TestConfig{},
+			bases:    []any{"base"},
+			wantErr:  true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			opts := []LoadOption{UseCustom("test", func(any) error { return nil })}
+			for _, base := range test.bases {
+				opts = append(opts, WithBase(base))
+			}
+			var got any
+			var err error
+			switch defaults := test.defaults.(type) {
+			case *TestNestedConfig:
+				got, err = Load(defaults, opts...)
+			case *TestNestedPointerConfig:
+				got, err = Load(defaults, opts...)
+			case *TestEmbeddedConfig:
+				got, err = Load(defaults, opts...)
+			case *TestConfig:
+				got, err = Load(defaults, opts...)
+			}
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if !test.wantErr && !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Load() got = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+	t.Run("sources override base", func(t *testing.T) {
+		got, err := Load(&TestConfig{}, WithBase(&TestConfig{Host: "base", Port: 1}), UseCustom("test", setHost("source", 0)))
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		if want := (&TestConfig{Host: "source", Port: 1}); !reflect.DeepEqual(got, want) {
+			t.Errorf("Load() got = %+v, want %+v", got, want)
+		}
+	})
+	t.Run("base not modified", func(t *testing.T) {
+		base := &TestSliceConfig{Hosts: []string{"a"}}
+		got, _ := Load(&TestSliceConfig{}, WithBase(base), UseCustom("test", func(any) error { return nil }))
+		got.Hosts[0] = "b"
+		if base.Hosts[0] != "a" {
+			t.Errorf("Load() shares slices with the base")
+		}
+	})
+}
+
+func Test_WithProvenance(t *testing.T) {
+	var got Provenance
+	_, err := Load(&TestNestedPointerConfig{},
+		WithBase(&TestConfig{Port: 8080}),
+		UseCustom("first", func(config any) error {
+			c := config.(*TestNestedPointerConfig)
+			host := "first"
+			c.Host = &host
+			c.DB = &TestDBConfig{Host: "db"}
+			return nil
+		}),
+		UseCustom("second", func(config any) error {
+			c := config.(*TestNestedPointerConfig)
+			host := "second"
+			c.Host = &host
+			return nil
+		}),
+		WithProvenance(&got),
+	)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	want := Provenance{
+		"Host":    {Source: "second"},
+		"Port":    {Source: "base"},
+		"SSL":     {Source: "default"},
+		"DB.Host": {Source: "first"},
+		"DB.Port": {Source: "default"},
+		"DB.SSL":  {Source: "default"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Load() provenance = %v, want %v", got, want)
+	}
+}
+
+func Test_UseCustom(t *testing.T) {
+	t.Setenv("HOST", "env")
+	tests := map[string]struct {
+		opts     []LoadOption
+		wantHost string
+		wantErr  bool
+	}{
+		"overrides earlier sources": {
+			opts:     []LoadOption{UseEnv(), UseCustom("custom", setHost("custom", 0))},
+			wantHost: "custom",
+		},
+		"overridden by later sources": {
+			opts:     []LoadOption{UseCustom("custom", setHost("custom", 0)), UseEnv()},
+			wantHost: "env",
+		},
+		"replaces the defaults": {
+			opts:     []LoadOption{UseCustom("custom", func(any) error { return nil })},
+			wantHost: "default",
+		},
+		"error": {
+			opts:    []LoadOption{UseCustom("custom", func(any) error { return errors.New("unavailable") })},
+			wantErr: true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := Load(&TestConfig{Host: "default"}, test.opts...)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if !test.wantErr && got.Host != test.wantHost {
+				t.Errorf("Load() Host = %q, want %q", got.Host, test.wantHost)
+			}
+		})
+	}
+}
+
+func Test_LoadContext(t *testing.T) {
+	tests := map[string]struct {
+		canceled bool
+		timeout  time.Duration
+		opts     []LoadOption
+		wantHost string
+		wantErr  error
+	}{
+		"background": {
+			opts:     []LoadOption{UseCustom("test", setHost("loaded", 0))},
+			wantHost: "loaded",
+		},
+		"canceled": {
+			canceled: true,
+			opts:     []LoadOption{UseCustom("test", setHost("loaded", 0))},
+			wantErr:  context.Canceled,
+		},
+		"deadline passes": {
+			timeout: 20 * time.Millisecond,
+			opts:    []LoadOption{UseCustom("fast", setHost("fast", 0)), UseCustom("slow", setHost("slow", time.Second))},
+			wantErr: DeadlineExceededError,
+		},
+		"partial result": {
+			timeout:  20 * time.Millisecond,
+			opts:     []LoadOption{UseCustom("fast", setHost("fast", 0)), UseCustom("slow", setHost("slow", time.Second)), WithPartialResult()},
+			wantHost: "fast",
+			wantErr:  DeadlineExceededError,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			if test.timeout > 0 {
+				ctx, cancel = context.WithTimeout(context.Background(), test.timeout)
+			}
+			defer cancel()
+			if test.canceled {
+				cancel()
+			}
+			got, err := LoadContext(ctx, &TestConfig{}, test.opts...)
+			if !errors.Is(err, test.wantErr) || (err == nil) != (test.wantErr == nil) {
+				t.Fatalf("LoadContext() error = %v, want %v", err, test.wantErr)
+			}
+			if test.wantHost == "" {
+				if got != nil {
+					t.Errorf("LoadContext() = %+v, want nil", got)
+				}
+				return
+			}
+			if got.Host != test.wantHost {
+				t.Errorf("LoadContext() Host = %q, want %q", got.Host, test.wantHost)
+			}
+		})
+	}
+
+	t.Run("passed to providers", func(t *testing.T) {
+		type key struct{}
+		ctx := context.WithValue(context.Background(), key{}, "value")
+		provider := &contextProvider{key: key{}}
+		if _, err := LoadContext(ctx, &TestConfig{}, UseProvider("test", provider)); err != nil {
+			t.Fatalf("LoadContext() error = %v", err)
+		}
+		if provider.got != "value" {
+			t.Errorf("provider saw %v in its context, want %q", provider.got, "value")
+		}
+	})
+}
+
+// contextProvider records the value of key in the context it's loaded with.
+type contextProvider struct {
+	key any
+	got any
+}
+
+func (p *contextProvider) Init(context.Context) error { return nil }
+func (p *contextProvider) Close() error               { return nil }
+
+func (p *contextProvider) Load(ctx context.Context, config any) error {
+	p.got = ctx.Value(p.key)
+	return nil
+}
+
+func Test_Load_errors(t *testing.T) {
+	t.Setenv("PORT", "eighty")
+	t.Setenv("DB_PORT", "eighty")
+	t.Setenv("DB_SSL", "maybe")
+	unavailable := errors.New("unavailable")
+	tests := map[string]struct {
+		opts     []LoadOption
+		wantErrs int
+	}{
+		"every field": {
+			opts:     []LoadOption{UseEnv()},
+			wantErrs: 3,
+		},
+		"every source": {
+			opts:     []LoadOption{UseCustom("custom", func(any) error { return unavailable }), UseEnv()},
+			wantErrs: 4,
+		},
+		"duplicates": {
+			opts:     []LoadOption{UseEnv(), UseEnv()},
+			wantErrs: 3,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := Load(&TestNestedConfig{}, test.opts...)
+			if got != nil || err == nil {
+				t.Fatalf("Load() = %v, %v, want an error only", got, err)
+			}
+			var multi *MultiError
+			if !errors.As(err, &multi) || len(multi.Errors) != test.wantErrs {
+				t.Errorf("Load() error = %v, want %d errors", err, test.wantErrs)
+			}
+		})
+	}
+	t.Run("checks", func(t *testing.T) {
+		type config struct {
+			Name  string `required:"true"`
+			Port  int
+			Level string `oneof:"debug,info"`
+		}
+		_, err := Load(&config{}, UseEnv(WithEnviron(map[string]string{"PORT": "x", "LEVEL": "zzz"})))
+		var fieldErr *FieldError
+		var missing *MissingFieldsError
+		var invalid *ValidationError
+		if !errors.As(err, &fieldErr) || !errors.As(err, &missing) || !errors.As(err, &invalid) {
+			t.Errorf("Load() error = %v, want the invalid Port, the missing Name and the invalid Level", err)
+		}
+	})
+	t.Run("errors.Is", func(t *testing.T) {
+		_, err := Load(&TestNestedConfig{}, UseEnv(), UseCustom("custom", func(any) error { return unavailable }))
+		if !errors.Is(err, unavailable) {
+			t.Errorf("errors.Is(%v, unavailable) = false, want true", err)
+		}
+	})
+}
+
+func Test_FieldError(t *testing.T) {
+	type secretConfig struct {
+		PIN int `secret:"true"`
+	}
+	tests := map[string]struct {
+		env    map[string]string
+		config any
+		load   func(config any) error
+		want   FieldError
+	}{
+		"env": {
+			env: map[string]string{"TEST_DB_PORT": "abc"},
+			load: func(config any) error {
+				_, err := Load(config.(*TestNestedConfig), UseEnv(WithEnvPrefix("TEST")))
+				return err
+			},
+			want: FieldError{Path: "DB.Port", Source: "env", Key: "TEST_DB_PORT", RawValue: "abc"},
+		},
+		"file": {
+			load: func(config any) error {
+				path := writeFile(t, "config.ini", []byte("[db]\nport = abc\n"))
+				_, err := Load(config.(*TestNestedConfig), UseFile(path))
+				return err
+			},
+			want: FieldError{Path: "DB.Port", Key: "db.port", RawValue: "abc"},
+		},
+		"secret": {
+			env:    map[string]string{"PIN": "12a4"},
+			config: &secretConfig{},
+			load:   func(config any) error { _, err := Load(config.(*secretConfig), UseEnv()); return err },
+			want:   FieldError{Path: "PIN", Source: "env", Key: "PIN", RawValue: RedactedValue},
+		},
+		"redactor": {
+			env: map[string]string{"TEST_DB_PORT": "abc"},
+			load: func(config any) error {
+				redact := func(path string) bool { return path == "DB.Port" }
+				_, err := Load(config.(*TestNestedConfig), UseEnv(WithEnvPrefix("TEST")), WithRedactor(redact))
+				return err
+			},
+			want: FieldError{Path: "DB.Port", Source: "env", Key: "TEST_DB_PORT", RawValue: RedactedValue},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			for k, v := range test.env {
+				t.Setenv(k, v)
+			}
+			config := test.config
+			if config == nil {
+				config = &TestNestedConfig{}
+			}
+			err := test.load(config)
+			var got *FieldError
+			if !errors.As(err, &got) {
+				t.Fatalf("Load() error = %v, want a *FieldError", err)
+			}
+			if test.want.Source == "" { // file sources are named after the temporary path
+				test.want.Source = got.Source
+			}
+			if got.Path != test.want.Path || got.Source != test.want.Source || got.Key != test.want.Key || got.RawValue != test.want.RawValue {
+				t.Errorf("Load() error = %+v, want %+v", got, test.want)
+			}
+			if !errors.Is(err, strconv.ErrSyntax) {
+				t.Errorf("errors.Is(%v, strconv.ErrSyntax) = false, want true", err)
+			}
+		})
+	}
+}
+
+func Test_WithStrict(t *testing.T) {
+	t.Setenv("TEST_HOST", "env")
+	t.Setenv("TEST_DB_PRT", "5432")
+	file := writeFile(t, "config.ini", []byte("prot = 80\n[db]\nhots = db\n"))
+	mounted := t.TempDir()
+	for name, data := range map[string]string{"host": "mounted", "db-prt": "5432"} {
+		if err := os.WriteFile(filepath.Join(mounted, name), []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := map[string]struct {
+		opts        []LoadOption
+		wantUnknown []string
+	}{
+		"env": {
+			opts:        []LoadOption{UseEnv(WithEnvPrefix("TEST")), WithStrict()},
+			wantUnknown: []string{"TEST_DB_PRT"},
+		},
+		"env without prefix": {
+			opts: []LoadOption{UseEnv(), WithStrict()},
+		},
+		"file": {
+			opts:        []LoadOption{UseFile(file), WithStrict()},
+			wantUnknown: []string{"db.hots", "prot"},
+		},
+		"mounted dir": {
+			opts:        []LoadOption{WithStrict(), UseMountedDir(mounted)},
+			wantUnknown: []string{"db-prt"},
+		},
+		"not strict": {
+			opts: []LoadOption{UseEnv(WithEnvPrefix("TEST")), UseFile(file), UseMountedDir(mounted)},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := Load(&TestNestedConfig{}, test.opts...)
+			var unknown []string
+			visitErrors(err, func(err error) {
+				if err, ok := err.(*UnknownKeyError); ok {
+					unknown = append(unknown, err.Key)
+					if err.Source == "" {
+						t.Errorf("UnknownKeyError %q has no source", err.Key)
+					}
+				}
+			})
+			if (err != nil) != (len(test.wantUnknown) > 0) || !reflect.DeepEqual(unknown, test.wantUnknown) {
+				t.Errorf("Load() error = %v, want unknown keys %v", err, test.wantUnknown)
+			}
+		})
+	}
+}
+
+func Test_MustLoad(t *testing.T) {
+	type config struct {
+		Port    int
+		Timeout time.Duration
+	}
+	mustLoad := func() (got *config, recovered any) {
+		defer func() { recovered = recover() }()
+		return MustLoad(&config{}, UseEnv(WithEnvPrefix("TEST"))), nil
+	}
+
+	t.Setenv("TEST_PORT", "80")
+	if got, recovered := mustLoad(); recovered != nil || got.Port != 80 {
+		t.Fatalf("MustLoad() = %+v, panicked with %v", got, recovered)
+	}
+
+	t.Setenv("TEST_PORT", "eighty")
+	_, recovered := mustLoad()
+	err, ok := recovered.(error)
+	if !ok || !strings.HasPrefix(err.Error(), `qcl: loading the config failed: TEST_PORT from env: invalid value "eighty" for Port`) {
+		t.Fatalf("MustLoad() panicked with %v, want the error", recovered)
+	}
+
+	t.Setenv("TEST_TIMEOUT", "soon")
+	_, recovered = mustLoad()
+	err, ok = recovered.(error)
+	if !ok {
+		t.Fatalf("MustLoad() panicked with %v, want an error", recovered)
+	}
+	lines := strings.Split(err.Error(), "\n")
+	if len(lines) != 3 || lines[0] != "qcl: loading the config failed with 2 errors:" ||
+		!strings.HasPrefix(lines[1], "  - TEST_PORT") || !strings.HasPrefix(lines[2], "  - TEST_TIMEOUT") {
+		t.Errorf("MustLoad() panicked with %q, want a line per error", err)
+	}
+	var fieldErr *FieldError
+	if !errors.As(err, &fieldErr) {
+		t.Errorf("errors.As(%v, *FieldError) = false, want true", err)
+	}
+}
+
+func Test_LoadInto(t *testing.T) {
+	type config struct {
+		Host string
+		Port int
+	}
+	t.Setenv("TEST_HOST", "example.com")
+	tests := map[string]struct {
+		config  any
+		want    any
+		wantErr error
+	}{
+		"pointer to struct": {
+			config: &config{Port: 8080},
+			want:   &config{Host: "example.com", Port: 8080},
+		},
+		"struct":         {config: config{}, wantErr: ConfigTypeError},
+		"nil pointer":    {config: (*config)(nil), wantErr: ConfigTypeError},
+		"pointer to int": {config: new(int), wantErr: ConfigTypeError},
+		"nil":            {config: nil, wantErr: ConfigTypeError},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := LoadInto(test.config, UseEnv(WithEnvPrefix("TEST")))
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("LoadInto() error = %v, want %v", err, test.wantErr)
+			}
+			if test.want != nil && !reflect.DeepEqual(test.config, test.want) {
+				t.Errorf("LoadInto() loaded %+v, want %+v", test.config, test.want)
+			}
+		})
+	}
+
+	t.Setenv("TEST_PORT", "eighty")
+	var fieldErr *FieldError
+	if err := LoadInto(&config{}, UseEnv(WithEnvPrefix("TEST"))); !errors.As(err, &fieldErr) {
+		t.Errorf("LoadInto() error = %v, want a *FieldError", err)
+	}
+}
+
+func Test_LoadWithReport(t *testing.T) {
+	t.Setenv("TEST_HOST", "env")
+	file := writeFile(t, "config.ini", []byte("port = 80\n[db]\nhost = db\nport = 5432\n"))
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	os.Args = []string{"test", "-db.port", "6543"}
+	conf, got, err := LoadWithReport(&TestNestedConfig{}, UseFile(file), UseEnv(WithEnvPrefix("TEST")), UseFlags())
+	if err != nil {
+		t.Fatalf("LoadWithReport() error = %v", err)
+	}
+	if conf.Host != "env" || conf.DB.Port != 6543 {
+		t.Errorf("LoadWithReport() = %+v", conf)
+	}
+	want := Provenance{
+		"Host":    {Source: "env", Key: "TEST_HOST", Lookups: []KeyLookup{{Source: "env", Key: "TEST_HOST", Found: true}}},
+		"Port":    {Source: "file:" + file, Key: file + ":port", Lookups: []KeyLookup{{Source: "env", Key: "TEST_PORT"}}},
+		"SSL":     {Source: "default", Lookups: []KeyLookup{{Source: "env", Key: "TEST_SSL"}}},
+		"DB.Host": {Source: "file:" + file, Key: file + ":db.host", Lookups: []KeyLookup{{Source: "env", Key: "TEST_DB_HOST"}}},
+		"DB.Port": {Source: "flags", Key: "-db.port", Lookups: []KeyLookup{{Source: "env", Key: "TEST_DB_PORT"}}},
+		"DB.SSL":  {Source: "default", Lookups: []KeyLookup{{Source: "env", Key: "TEST_DB_SSL"}}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("LoadWithReport() provenance = %v, want %v", got, want)
+	}
+
+	conf, got, err = LoadWithReport(&TestNestedConfig{}, UseCustom("failing", func(any) error { return errors.New("unavailable") }))
+	if conf != nil || got != nil || err == nil {
+		t.Errorf("LoadWithReport() = %v, %v, %v, want an error only", conf, got, err)
+	}
+}
+
+func Test_Plan(t *testing.T) {
+	t.Setenv("TEST_HOST", "new")
+	file := writeFile(t, "config.ini", []byte("port = 80\n[db]\nport = 5432\n"))
+	config := &TestNestedConfig{Host: "old", Port: 80}
+	got, err := Plan(config, UseFile(file), UseEnv(WithEnvPrefix("TEST")))
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+	want := []PlannedChange{
+		{Change{Field: "Host", Old: "old", New: "new"}, Origin{Source: "env", Key: "TEST_HOST", Lookups: []KeyLookup{{Source: "env", Key: "TEST_HOST", Found: true}}}},
+		{Change{Field: "DB.Port", Old: 0, New: 5432}, Origin{Source: "file:" + file, Key: file + ":db.port", Lookups: []KeyLookup{{Source: "env", Key: "TEST_DB_PORT"}}}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Plan() = %+v, want %+v", got, want)
+	}
+	if want := (&TestNestedConfig{Host: "old", Port: 80}); !reflect.DeepEqual(config, want) {
+		t.Errorf("Plan() modified the config to %+v", config)
+	}
+
+	t.Setenv("TEST_PORT", "eighty")
+	got, err = Plan(config, UseEnv(WithEnvPrefix("TEST")))
+	var fieldErr *FieldError
+	if got != nil || !errors.As(err, &fieldErr) {
+		t.Errorf("Plan() = %v, %v, want a FieldError only", got, err)
+	}
+}
+
+func Test_WithScope(t *testing.T) {
+	t.Setenv("MYAPP_HOST", "app")
+	t.Setenv("MYAPP_DB_HOST", "env")
+	file := writeFile(t, "config.ini", []byte("host = app\nport = 80\n[db]\nport = 5432\n"))
+	mounted := t.TempDir()
+	for name, data := range map[string]string{"host": "app", "db-host": "mounted"} {
+		if err := os.WriteFile(filepath.Join(mounted, name), []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := map[string]struct {
+		opts []LoadOption
+		args []string
+		want TestDBConfig
+	}{
+		"env": {
+			opts: []LoadOption{UseEnv(WithEnvPrefix("MYAPP"))},
+			want: TestDBConfig{Host: "env"},
+		},
+		"file": {
+			opts: []LoadOption{UseFile(file)},
+			want: TestDBConfig{Port: 5432},
+		},
+		"flags": {
+			opts: []LoadOption{UseFlags()},
+			args: []string{"-db.ssl=true"},
+			want: TestDBConfig{SSL: true},
+		},
+		"mounted dir": {
+			opts: []LoadOption{UseMountedDir(mounted)},
+			want: TestDBConfig{Host: "mounted"},
+		},
+		"nothing under the scope": {
+			opts: []LoadOption{UseFile(writeFile(t, "app.ini", []byte("host = app\n")))},
+		},
+		"strict": {
+			opts: []LoadOption{UseFile(file), UseEnv(WithEnvPrefix("MYAPP")), UseMountedDir(mounted), WithStrict()},
+			want: TestDBConfig{Host: "mounted", Port: 5432},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+			os.Args = append([]string{"test"}, test.args...)
+			got, err := Load(&TestDBConfig{}, append(test.opts, WithScope("db"))...)
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if *got != test.want {
+				t.Errorf("Load() = %+v, want %+v", *got, test.want)
+			}
+		})
+	}
+	t.Run("strict errors", func(t *testing.T) {
+		file := writeFile(t, "config.ini", []byte("[db]\nprot = 5432\n"))
+		_, err := Load(&TestDBConfig{}, UseFile(file), WithScope("db"), WithStrict())
+		var unknown *UnknownKeyError
+		if !errors.As(err, &unknown) || unknown.Key != "db.prot" {
+			t.Errorf("Load() error = %v, want unknown key db.prot", err)
+		}
+	})
+}
+
+func Test_WithProfile(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string]string{"config.ini": "host = base\nport = 80\n", "config.prod.ini": "port = 443\n"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	file := filepath.Join(dir, "config.ini")
+	t.Setenv("MYAPP_HOST", "env")
+	t.Setenv("MYAPP_PROD_SSL", "true")
+	tests := map[string]struct {
+		opts []LoadOption
+		env  string
+		want TestDBConfig
+	}{
+		"no profile": {
+			opts: []LoadOption{UseFile(file), UseEnv(WithEnvPrefix("MYAPP"))},
+			want: TestDBConfig{Host: "env", Port: 80},
+		},
+		"profile": {
+			opts: []LoadOption{UseFile(file), UseEnv(WithEnvPrefix("MYAPP")), WithProfile("prod")},
+			want: TestDBConfig{Host: "env", Port: 443, SSL: true},
+		},
+		"profile without variants": {
+			opts: []LoadOption{UseFile(file), UseEnv(WithEnvPrefix("MYAPP")), WithProfile("dev")},
+			want: TestDBConfig{Host: "env", Port: 80},
+		},
+		"layered files": {
+			opts: []LoadOption{UseLayeredFiles([]string{file, writeFile(t, "local.ini", []byte("host = local\n"))}), WithProfile("prod")},
+			want: TestDBConfig{Host: "local", Port: 443},
+		},
+		"profile from the environment": {
+			opts: []LoadOption{UseFile(file), WithProfileFromEnv()},
+			env:  "prod",
+			want: TestDBConfig{Host: "base", Port: 443},
+		},
+		"strict": {
+			opts: []LoadOption{UseEnv(WithEnvPrefix("MYAPP")), WithProfile("prod"), WithStrict()},
+			want: TestDBConfig{Host: "env", SSL: true},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(ProfileEnvVar, test.env)
+			got, err := Load(&TestDBConfig{}, test.opts...)
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if *got != test.want {
+				t.Errorf("Load() = %+v, want %+v", *got, test.want)
+			}
+		})
+	}
+}
+
+type TestRequiredConfig struct {
+	Host string `required:"true"`
+	Port int    `required:"true"`
+	Name string
+	DB   *struct {
+		User string `required:"true"`
+	}
+}
+
+func Test_required(t *testing.T) {
+	tests := map[string]struct {
+		env    map[string]string
+		loader Loader
+		want   []string
+	}{
+		"all set": {
+			env: map[string]string{"TEST_HOST": "localhost", "TEST_PORT": "9090", "TEST_DB_USER": "admin"},
+		},
+		"set to the default": {
+			env: map[string]string{"TEST_HOST": "localhost", "TEST_PORT": "8080", "TEST_DB_USER": "admin"},
+		},
+		"missing": {
+			env:  map[string]string{"TEST_NAME": "app", "TEST_PORT": "9090"},
+			want: []string{"Host", "DB.User"},
+		},
+		"set by a custom source": {
+			env: map[string]string{"TEST_DB_USER": "admin"},
+			loader: func(config any) error {
+				c := config.(*TestRequiredConfig)
+				c.Host, c.Port = "custom", 1
+				return nil
+			},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			for key, value := range test.env {
+				t.Setenv(key, value)
+			}
+			opts := []LoadOption{UseEnv(WithEnvPrefix("TEST"))}
+			if test.loader != nil {
+				opts = append(opts, UseCustom("custom", test.loader))
+			}
+			_, err := Load(&TestRequiredConfig{Port: 8080}, opts...)
+			var missing *MissingFieldsError
+			if test.want == nil && err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if test.want != nil && (!errors.As(err, &missing) || !reflect.DeepEqual(missing.Fields, test.want)) {
+				t.Errorf("Load() error = %v, want missing %v", err, test.want)
+			}
+		})
+	}
+}
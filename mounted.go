@@ -0,0 +1,127 @@
+package qcl
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// UseMountedDir enables loading configuration from a directory holding a file per setting, the way Kubernetes
+// projects ConfigMap and Secret volumes. Each file's name is the key and its content the value, and names map to
+// fields the way environment variable names do, ignoring case and treating dashes and dots as underscores, so a file
+// named db_host or DB_HOST sets DB.Host. The "env" struct tag overrides a field's name.
+//
+// Example:
+//
+//	# a Secret mounted at /etc/secrets, with keys db_host and db_password
+//	/etc/secrets/db_host
+//	/etc/secrets/db_password
+//
+//	type Config struct {
+//		DB struct {
+//			Host     string
+//			Password string `secret:"true"`
+//		}
+//	}
+//
+//	qcl.Load(&defaultConfig, qcl.UseMountedDir("/etc/secrets"), qcl.UseEnv())
+//
+// Trailing newlines are trimmed from values, and iterables are separated by a comma. Hidden files and directories,
+// like the ..data directory Kubernetes uses to update volumes atomically, are skipped, and symlinks are followed.
+// Loading fails if the directory can't be read. When the config is loaded with Watch, the directory is checked for
+// changes every second, or as set with WithFileWatchInterval, the only file option that applies, including Kubernetes
+// atomically swapping in updated content. The source is named "mounted:" followed by the path.
+func UseMountedDir(path string, opts ...fileOption) LoadOption {
+	name := "mounted:" + path
+	var fileConf fileConfig
+	for _, opt := range opts {
+		opt(&fileConf)
+	}
+	return func(o *LoadConfig) {
+		o.Sources = append(o.Sources, name)
+		o.Loaders[name] = loadFromMountedDir(path, name, o)
+		o.watchers = append(o.watchers, watchFiles(func() []string { return dirPaths(path) }, fileConf.watchInterval))
+	}
+}
+
+func loadFromMountedDir(dir, name string, load *LoadConfig) Loader {
+	record := load.recorder(name)
+	return func(config any) error {
+		if reflect.TypeOf(config).Kind() != reflect.Ptr {
+			return ConfigTypeError
+		}
+		val := reflect.ValueOf(config).Elem()
+		if val.Kind() != reflect.Struct {
+			return ConfigTypeError
+		}
+
+		files, err := readMountedDir(dir)
+		if err != nil {
+			return err
+		}
+		var errs []error
+		known := make(map[string]bool, len(files))
+		prefix := scopeEnvPrefix(load.scopePath(), "_")
+		vars := make(map[string]string, len(files))
+		for key, file := range files {
+			vars[key] = file.value
+		}
+		err = walkEnv(val, val.Type(), prefix, "", defaultEnvConfig.naming(), vars, func(v reflect.Value, path, key string) error {
+			known[key] = true
+			file, ok := files[key]
+			if !ok {
+				return nil
+			}
+			if err := defaultParseOptions.setField(v, file.value); err != nil {
+				errs = append(errs, &FieldError{Path: path, Key: file.name, RawValue: file.value, Err: err})
+			} else {
+				record(path, file.name)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if load.reportsUnknownKeys() {
+			for _, key := range sortedKeys(files) {
+				if !known[key] && strings.HasPrefix(key, prefix) {
+					errs = append(errs, &UnknownKeyError{Key: files[key].name})
+				}
+			}
+		}
+		return load.ignoreUnknownKeys(name, joinErrors(errs))
+	}
+}
+
+// mountedFile is a file read from a mounted directory.
+type mountedFile struct {
+	name  string // name is the name of the file.
+	value string // value is the content of the file, without trailing newlines.
+}
+
+// readMountedDir returns the files in dir, keyed by their names in the form of environment variable names.
+func readMountedDir(dir string) (map[string]mountedFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]mountedFile, len(entries))
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		info, err := os.Stat(path) // follows the symlinks Kubernetes projects keys as
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		key := strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(entry.Name()))
+		files[key] = mountedFile{entry.Name(), strings.TrimRight(string(data), "\r\n")}
+	}
+	return files, nil
+}
@@ -0,0 +1,77 @@
+package qcl
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_UseMountedDir(t *testing.T) {
+	// lay the directory out the way Kubernetes does, with keys symlinked into a hidden, timestamped directory
+	dir := t.TempDir()
+	data := filepath.Join(dir, "..2024_01_01_00_00_00.000000000")
+	if err := os.Mkdir(data, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"host":          "db.internal\n",
+		"port":          "5432",
+		"hosts":         "a,b\r\n",
+		"labels":        "team=payments",
+		"ignored-value": "x",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(data, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Base(data), filepath.Join(dir, "..data")); err != nil {
+		t.Fatal(err)
+	}
+	for name := range files {
+		if err := os.Symlink(filepath.Join("..data", name), filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "test.config-name"), []byte("from-dashes"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	type config struct {
+		TestConfig
+		Hosts  []string
+		Labels map[string]string
+		Test   struct {
+			ConfigName string
+		}
+	}
+	got, err := Load(&config{TestConfig: TestConfig{Host: "localhost"}}, UseMountedDir(dir))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	want := &config{
+		TestConfig: TestConfig{Host: "db.internal", Port: 5432},
+		Hosts:      []string{"a", "b"},
+		Labels:     map[string]string{"team": "payments"},
+	}
+	want.Test.ConfigName = "from-dashes"
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Load() got = %+v, want %+v", got, want)
+	}
+
+	t.Run("invalid value", func(t *testing.T) {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "port"), []byte("x"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(&TestConfig{}, UseMountedDir(dir)); err == nil {
+			t.Error("Load() error = nil, want an error")
+		}
+	})
+	t.Run("missing directory", func(t *testing.T) {
+		if _, err := Load(&TestConfig{}, UseMountedDir(filepath.Join(dir, "missing"))); !os.IsNotExist(err) {
+			t.Errorf("Load() error = %v, want a not exist error", err)
+		}
+	})
+}
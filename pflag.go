@@ -0,0 +1,174 @@
+package qcl
+
+import (
+	"flag"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+const pflags = "pflags"
+
+// A PFlagSet is a flag set of github.com/spf13/pflag, the flags of a Cobra command, which *pflag.FlagSet implements.
+// qcl doesn't depend on pflag; flags are registered with the flag package and added to the set the way pflag adds
+// them.
+type PFlagSet interface {
+	AddGoFlagSet(*flag.FlagSet)
+}
+
+// pflagBindings maps the PFlagSets given to BindPFlags to their flags, by the dotted paths of their fields.
+var pflagBindings sync.Map
+
+// BindPFlags registers a flag for every field of the config, a pointer to a struct, in the flag set, so that
+// applications built on Cobra parse them along with the rest of the command's flags, then load them with UsePFlags.
+// Flags are named like WithGNUFlags names them, in kebab case, unless WithFlagDelimiter is given, and documented with the `usage` tag of their fields,
+// along with their defaults, the values in the config, except for secret fields. Boolean flags are switches, and
+// single-character aliases set with the `short` tag, or after the name in the `flag` tag, are added as shorthands.
+//
+// Example:
+//
+//	cmd := &cobra.Command{
+//		Use: "app",
+//		RunE: func(cmd *cobra.Command, args []string) error {
+//			conf, err := qcl.Load(&defaultConfig, qcl.UseEnv(), qcl.UsePFlags(cmd.Flags()))
+//			...
+//		},
+//	}
+//	if err := qcl.BindPFlags(cmd.Flags(), &defaultConfig); err != nil {
+//		...
+//	}
+//
+// The config isn't modified by parsing. Since flags are bound before the command line is known, the elements of slices
+// and maps of structs don't get flags, and interface fields with registered implementations only get the flags of the
+// implementation they hold. BindPFlags returns ConfigTypeError if the config isn't a pointer to a struct.
+func BindPFlags(fs PFlagSet, config any, opts ...flagOption) error {
+	flagConf := *defaultFlagConfig
+	for _, opt := range opts {
+		opt(&flagConf)
+	}
+	if reflect.TypeOf(config).Kind() != reflect.Ptr {
+		return ConfigTypeError
+	}
+	val, err := structCopy(config)
+	if err != nil {
+		return err
+	}
+	parse := flagConf.parseOptions()
+	delimiter := flagConf.delimiter
+	if delimiter == "" {
+		delimiter = "-"
+	}
+	fields := make(map[string]field)
+	for _, f := range leafFields(val.Addr().Interface()) {
+		fields[f.name()] = f
+	}
+	goFlags := flag.NewFlagSet(pflags, flag.ContinueOnError)
+	bound := make(map[string]*pflagValue)
+	err = walkFlags(val, val.Type(), "", "", nil, func(v reflect.Value, path, flagName string) error {
+		fieldParse := parse.forField(val.Type(), path)
+		value, err := newBoundValue(v, fieldParse)
+		if err != nil {
+			return err
+		}
+		if b, ok := value.(*boolValue); ok {
+			b.isSwitch = true
+		}
+		name := strings.ReplaceAll(flagName, ".", delimiter)
+		bound[path] = &pflagValue{boundValue: value, name: name, parse: fieldParse, typ: v.Type().String(), secret: fields[path].secret}
+		names := []string{name}
+		if short := shortFlag(fields[path].sf); short != "" {
+			names = append(names, short)
+		}
+		for _, n := range names {
+			goFlags.Var(bound[path], n, fieldUsage(val, fields, path))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fs.AddGoFlagSet(goFlags)
+	pflagBindings.Store(fs, bound)
+	return nil
+}
+
+// UsePFlags enables configuration from the flags bound to the flag set with BindPFlags, once it has parsed the command
+// line, like Cobra does before running a command. Fields are set as UseFlags sets them, from the values their flags
+// were given, and flags that weren't given leave their fields alone. The source returns UnboundFlagSetError if
+// BindPFlags wasn't called with the flag set.
+func UsePFlags(fs PFlagSet) LoadOption {
+	return func(o *LoadConfig) {
+		o.Sources = append(o.Sources, pflags)
+		o.Loaders[pflags] = loadFromPFlags(fs, o.recorder(pflags))
+	}
+}
+
+func loadFromPFlags(fs PFlagSet, record func(path, key string)) Loader {
+	return func(config any) error {
+		bindings, ok := pflagBindings.Load(fs)
+		if !ok {
+			return UnboundFlagSetError
+		}
+		bound := bindings.(map[string]*pflagValue)
+		if reflect.TypeOf(config).Kind() != reflect.Ptr {
+			return ConfigTypeError
+		}
+		val := reflect.ValueOf(config).Elem()
+		return walkFlags(val, val.Type(), "", "", nil, func(v reflect.Value, path, _ string) error {
+			flagValue, ok := bound[path]
+			if !ok || len(flagValue.values) == 0 {
+				return nil
+			}
+			value, err := newBoundValue(v, flagValue.parse)
+			if err != nil {
+				return err
+			}
+			for _, s := range flagValue.values {
+				if err := value.Set(s); err != nil {
+					return &FieldError{Path: path, Key: "--" + flagValue.name, RawValue: s, Err: err}
+				}
+			}
+			record(path, "--"+flagValue.name)
+			return nil
+		})
+	}
+}
+
+// pflagValue is a flag bound with BindPFlags. The values it's given are parsed into a copy of the config, which shows
+// the defaults, and kept, so that UsePFlags sets them again in the config being loaded.
+type pflagValue struct {
+	boundValue
+	name   string       // name is the name of the flag.
+	parse  parseOptions // parse are the options the values are parsed with.
+	typ    string       // typ is the type of the field, which pflag shows in usage.
+	values []string     // values are the values the flag was given, in order.
+	secret bool         // secret hides the value, which pflag shows as the default in usage.
+}
+
+// String returns the value of the field as the flag would be given, or nothing for secret fields and for the zero
+// value the flag package makes to tell whether a default was set.
+func (p *pflagValue) String() string {
+	if p.boundValue == nil || p.secret {
+		return ""
+	}
+	return p.boundValue.String()
+}
+
+// Type returns the type of the field, which makes pflag use the flag as a pflag.Value of its own.
+func (p *pflagValue) Type() string {
+	return p.typ
+}
+
+func (p *pflagValue) Set(value string) error {
+	if err := p.boundValue.Set(value); err != nil {
+		return err
+	}
+	p.values = append(p.values, value)
+	return nil
+}
+
+// IsBoolFlag reports whether the flag is a switch, which pflag sets to true when it's given without a value.
+func (p *pflagValue) IsBoolFlag() bool {
+	b, ok := p.boundValue.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
@@ -0,0 +1,131 @@
+package qcl
+
+import (
+	"errors"
+	"flag"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testPFlagSet adds the flags the way pflag does, to a flag set of its own.
+type testPFlagSet struct{ *flag.FlagSet }
+
+func (fs testPFlagSet) AddGoFlagSet(goFlags *flag.FlagSet) {
+	goFlags.VisitAll(func(f *flag.Flag) {
+		fs.Var(f.Value, f.Name, f.Usage)
+		fs.Lookup(f.Name).DefValue = f.Value.String()
+	})
+}
+
+type TestPFlagsConfig struct {
+	Host    string `usage:"the address to listen on"`
+	Verbose bool   `short:"v"`
+	Wait    time.Duration
+	DB      struct {
+		Port     int
+		Password string `secret:"true"`
+	}
+	Tags []string
+}
+
+func Test_UsePFlags(t *testing.T) {
+	defaults := TestPFlagsConfig{Host: "localhost", Tags: []string{"a"}}
+	defaults.DB.Port = 5432
+	tests := map[string]struct {
+		args    []string
+		want    func(*TestPFlagsConfig)
+		wantErr bool
+	}{
+		"none": {
+			want: func(*TestPFlagsConfig) {},
+		},
+		"long": {
+			args: []string{"--host=example.com", "--db-port", "6543", "--wait=5s", "--tags", "b"},
+			want: func(c *TestPFlagsConfig) {
+				c.Host, c.DB.Port, c.Wait, c.Tags = "example.com", 6543, 5*time.Second, []string{"b"}
+			},
+		},
+		"shorthand": {
+			args: []string{"-v"},
+			want: func(c *TestPFlagsConfig) { c.Verbose = true },
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			fs := testPFlagSet{flag.NewFlagSet("test", flag.ContinueOnError)}
+			if err := BindPFlags(fs, &defaults); err != nil {
+				t.Fatalf("BindPFlags() error = %v", err)
+			}
+			if err := fs.Parse(test.args); err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			got, err := Load(Clone(&defaults), UsePFlags(fs))
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			want := defaults
+			want.Tags = append([]string(nil), defaults.Tags...)
+			test.want(&want)
+			if !reflect.DeepEqual(*got, want) {
+				t.Errorf("Load() got = %+v, want %+v", *got, want)
+			}
+			if defaults.Host != "localhost" || len(defaults.Tags) != 1 {
+				t.Errorf("BindPFlags() config was modified: %+v", defaults)
+			}
+		})
+	}
+}
+
+func Test_BindPFlags(t *testing.T) {
+	fs := testPFlagSet{flag.NewFlagSet("test", flag.ContinueOnError)}
+	defaults := TestPFlagsConfig{Host: "localhost", Wait: time.Second}
+	defaults.DB.Password = "hunter2"
+	if err := BindPFlags(fs, &defaults); err != nil {
+		t.Fatalf("BindPFlags() error = %v", err)
+	}
+	tests := map[string]struct {
+		usage    string
+		defValue string
+		typ      string
+	}{
+		"host":        {usage: "the address to listen on", defValue: "localhost", typ: "string"},
+		"v":           {typ: "bool"},
+		"db-password": {typ: "string"},
+		"wait":        {defValue: "1s", typ: "time.Duration"},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			f := fs.Lookup(name)
+			if f == nil {
+				t.Fatalf("Lookup(%q) = nil", name)
+			}
+			if f.Usage != test.usage || f.DefValue != test.defValue {
+				t.Errorf("usage, default = %q, %q, want %q, %q", f.Usage, f.DefValue, test.usage, test.defValue)
+			}
+			if typ := f.Value.(interface{ Type() string }).Type(); typ != test.typ {
+				t.Errorf("Type() = %q, want %q", typ, test.typ)
+			}
+		})
+	}
+	t.Run("secret", func(t *testing.T) {
+		var help strings.Builder
+		fs.SetOutput(&help)
+		fs.PrintDefaults()
+		if strings.Contains(help.String(), "hunter2") {
+			t.Errorf("PrintDefaults() shows the secret default:\n%s", help.String())
+		}
+	})
+	t.Run("non-pointer config", func(t *testing.T) {
+		if err := BindPFlags(fs, TestPFlagsConfig{}); !errors.Is(err, ConfigTypeError) {
+			t.Errorf("BindPFlags() error = %v, want ConfigTypeError", err)
+		}
+	})
+	t.Run("unbound", func(t *testing.T) {
+		_, err := Load(&TestPFlagsConfig{}, UsePFlags(testPFlagSet{flag.NewFlagSet("test", flag.ContinueOnError)}))
+		if !errors.Is(err, UnboundFlagSetError) {
+			t.Errorf("Load() error = %v, want UnboundFlagSetError", err)
+		}
+	})
+}
@@ -0,0 +1,172 @@
+package qcl
+
+import (
+	"reflect"
+	"strings"
+)
+
+// A sourcePolicy pins which sources may set a field, and which of them win, independently of the order the sources
+// are loaded in. It's set with the `source` struct tag, listing the sources that may set the field, and the precedence
+// option of the `qcl` struct tag, listing sources from the most to the least important:
+//
+//	type Config struct {
+//		AdminToken string `source:"env"`                    // only ever set from the environment
+//		Replicas   int    `qcl:"precedence=flags>env>file"` // flags win, whatever the order of the sources
+//	}
+type sourcePolicy struct {
+	allowed    []string // allowed lists the sources that may set the field. Empty means any source may.
+	precedence []string // precedence lists sources from the most to the least important.
+}
+
+// sourceAliases maps the other names source policies may give built-in sources to their names, so that
+// "precedence=env>file>flag" means what it says.
+var sourceAliases = map[string]string{
+	"flag":  flags,
+	"pflag": pflags,
+}
+
+// builtinSources lists the names of the built-in sources, and the kinds of the built-in sources named "kind:path",
+// which source policies may name whether or not a particular load has them.
+var builtinSources = []string{env, flags, pflags, "file", "files", "embed", "dir", "mounted", "discovered"}
+
+// fieldPolicy returns the source policy of the field, if it has one.
+func fieldPolicy(sf reflect.StructField) (sourcePolicy, bool) {
+	var policy sourcePolicy
+	if tag, ok := sf.Tag.Lookup("source"); ok {
+		policy.allowed = sourceNames(splitTagList(tag, ","))
+	}
+	policy.precedence = sourceNames(splitTagList(qclTag(sf).precedence, ">"))
+	return policy, len(policy.allowed)+len(policy.precedence) > 0
+}
+
+// sourceNames replaces the aliases among the names with the names of the sources they stand for.
+func sourceNames(names []string) []string {
+	for i, name := range names {
+		if alias, ok := sourceAliases[name]; ok {
+			names[i] = alias
+		}
+	}
+	return names
+}
+
+// checkSourcePolicies returns an UnknownSourceError for every name in the source policies of the config's fields that
+// refers to none of the sources and isn't the name of a built-in source, which is most likely a typo.
+func checkSourcePolicies(config any, sources []string) error {
+	var errs []error
+	for _, f := range leafFields(config) {
+		policy, ok := fieldPolicy(f.sf)
+		if !ok {
+			continue
+		}
+		for _, names := range [][]string{policy.allowed, policy.precedence} {
+			for _, name := range names {
+				if kind, _, _ := strings.Cut(name, ":"); !refersToAny(name, sources) && !refersToAny(kind, builtinSources) {
+					errs = append(errs, &UnknownSourceError{Path: f.name(), Name: name, Sources: sources})
+				}
+			}
+		}
+	}
+	return joinErrors(errs)
+}
+
+// refersToAny reports whether the name refers to any of the sources, as matchSource matches them.
+func refersToAny(name string, sources []string) bool {
+	for _, source := range sources {
+		if matchSource([]string{name}, source) >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// rank returns the rank of values from the source, higher ranks overriding lower ones, or false if the source may not
+// set the field. Sources missing from the precedence rank lowest, along with the defaults.
+func (p sourcePolicy) rank(source string) (int, bool) {
+	if len(p.allowed) > 0 && matchSource(p.allowed, source) < 0 {
+		return 0, false
+	}
+	if i := matchSource(p.precedence, source); i >= 0 {
+		return len(p.precedence) - i, true
+	}
+	return 0, true
+}
+
+// matchSource returns the index of the first of the names that refers to the source, or -1. A name refers to the
+// source of the same name, like "env" or "flags", and to the sources named after it and a colon, so "file" refers to
+// every source added with UseFile, like "file:config.ini".
+func matchSource(names []string, source string) int {
+	for i, name := range names {
+		if source == name || strings.HasPrefix(source, name+":") {
+			return i
+		}
+	}
+	return -1
+}
+
+// precedenceTracker enforces the source policies of a config's fields as its sources are loaded.
+type precedenceTracker struct {
+	ranks map[string]int // ranks holds the rank of the source that set each pinned field, by dotted path.
+}
+
+// newPrecedenceTracker returns a tracker for the config, or nil if none of its fields has a source policy.
+func newPrecedenceTracker(config any) *precedenceTracker {
+	for _, f := range leafFields(config) {
+		if _, ok := fieldPolicy(f.sf); ok {
+			return &precedenceTracker{ranks: make(map[string]int)}
+		}
+	}
+	return nil
+}
+
+// snapshot returns copies of the values of the pinned fields, by dotted path, to compare against once a source has run.
+func (t *precedenceTracker) snapshot(config any) map[string]reflect.Value {
+	if t == nil {
+		return nil
+	}
+	values := make(map[string]reflect.Value)
+	for _, f := range leafFields(config) {
+		if _, ok := fieldPolicy(f.sf); ok {
+			cp := reflect.New(f.value.Type())
+			copyValue(cp.Elem(), f.value)
+			values[f.name()] = cp.Elem()
+		}
+	}
+	return values
+}
+
+// enforce restores the pinned fields the source changed but may not set, or that a more important source had set,
+// to their values in the snapshot taken before it ran.
+func (t *precedenceTracker) enforce(source string, before map[string]reflect.Value, config any) {
+	if t == nil {
+		return
+	}
+	for _, f := range leafFields(config) {
+		policy, ok := fieldPolicy(f.sf)
+		if !ok {
+			continue
+		}
+		old, ok := before[f.name()]
+		if !ok { // the field was in a struct the source allocated
+			old = reflect.Zero(f.value.Type())
+		}
+		if reflect.DeepEqual(old.Interface(), f.value.Interface()) {
+			continue
+		}
+		if rank, ok := policy.rank(source); ok && rank >= t.ranks[f.name()] {
+			t.ranks[f.name()] = rank
+			continue
+		}
+		f.value.Set(old)
+	}
+}
+
+// splitTagList splits a list from a struct tag, dropping spaces and empty items.
+func splitTagList(list, separator string) []string {
+	var items []string
+	for _, item := range strings.Split(list, separator) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
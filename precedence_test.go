@@ -0,0 +1,139 @@
+package qcl
+
+import (
+	"reflect"
+	"testing"
+)
+
+type TestPrecedenceConfig struct {
+	Token    string `source:"env"`
+	Replicas int    `qcl:"precedence=flags>env>file"`
+	Region   string `source:"file,env" qcl:"precedence=env"`
+	Name     string
+}
+
+// setAll returns a loader setting every field of a TestPrecedenceConfig to the value.
+func setAll(s string, n int) Loader {
+	return func(config any) error {
+		c := config.(*TestPrecedenceConfig)
+		c.Token, c.Replicas, c.Region, c.Name = s, n, s, s
+		return nil
+	}
+}
+
+func Test_sourcePrecedence(t *testing.T) {
+	tests := map[string]struct {
+		opts []LoadOption
+		want TestPrecedenceConfig
+	}{
+		"file, env, flags": {
+			opts: []LoadOption{UseCustom("file:config.ini", setAll("file", 1)), UseCustom("env", setAll("env", 2)), UseCustom("flags", setAll("flags", 3))},
+			want: TestPrecedenceConfig{Token: "env", Replicas: 3, Region: "env", Name: "flags"},
+		},
+		"flags, env, file": {
+			opts: []LoadOption{UseCustom("flags", setAll("flags", 3)), UseCustom("env", setAll("env", 2)), UseCustom("file:config.ini", setAll("file", 1))},
+			want: TestPrecedenceConfig{Token: "env", Replicas: 3, Region: "env", Name: "file"},
+		},
+		"unlisted sources rank lowest": {
+			opts: []LoadOption{UseCustom("env", setAll("env", 2)), UseCustom("vault", setAll("vault", 4))},
+			want: TestPrecedenceConfig{Token: "env", Replicas: 2, Region: "env", Name: "vault"},
+		},
+		"unlisted sources override the defaults": {
+			opts: []LoadOption{UseCustom("vault", setAll("vault", 4))},
+			want: TestPrecedenceConfig{Token: "default", Replicas: 4, Region: "default", Name: "vault"},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := Load(&TestPrecedenceConfig{Token: "default", Region: "default"}, test.opts...)
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if !reflect.DeepEqual(*got, test.want) {
+				t.Errorf("Load() = %+v, want %+v", *got, test.want)
+			}
+		})
+	}
+}
+
+func Test_matchSource(t *testing.T) {
+	tests := map[string]struct {
+		names  []string
+		source string
+		want   int
+	}{
+		"exact":          {names: []string{"env", "flags"}, source: "flags", want: 1},
+		"kind":           {names: []string{"file"}, source: "file:config.ini", want: 0},
+		"other kind":     {names: []string{"file"}, source: "files:a.ini,b.ini", want: -1},
+		"no match":       {names: []string{"env"}, source: "flags", want: -1},
+		"first of names": {names: []string{"file", "file:config.ini"}, source: "file:config.ini", want: 0},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := matchSource(test.names, test.source); got != test.want {
+				t.Errorf("matchSource(%v, %q) = %d, want %d", test.names, test.source, got, test.want)
+			}
+		})
+	}
+}
+
+func Test_sourceAliases(t *testing.T) {
+	type config struct {
+		Port int `qcl:"precedence=flag>env"`
+	}
+	set := func(port int) Loader {
+		return func(c any) error {
+			c.(*config).Port = port
+			return nil
+		}
+	}
+	got, err := Load(&config{}, UseCustom("flags", set(1)), UseCustom("env", set(2)))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got.Port != 1 {
+		t.Errorf("Load() Port = %d, want 1", got.Port)
+	}
+}
+
+func Test_checkSourcePolicies(t *testing.T) {
+	tests := map[string]struct {
+		config  any
+		sources []string
+		want    []string
+	}{
+		"configured sources": {
+			config: &struct {
+				Token string `source:"vault,env"`
+			}{},
+			sources: []string{"env", "vault"},
+		},
+		"built-in sources": {
+			config: &struct {
+				Port int `source:"file:config.ini,dir" qcl:"precedence=flags>pflag>env"`
+			}{},
+			sources: []string{"vault"},
+		},
+		"unknown sources": {
+			config: &struct {
+				Token string `source:"enviroment,vault"`
+				Port  int    `qcl:"precedence=flagz>env"`
+			}{},
+			sources: []string{"env"},
+			want:    []string{"enviroment", "vault", "flagz"},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var got []string
+			visitErrors(checkSourcePolicies(test.config, test.sources), func(err error) {
+				if unknown, ok := err.(*UnknownSourceError); ok {
+					got = append(got, unknown.Name)
+				}
+			})
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("checkSourcePolicies() unknown sources = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package qcl
+
+import (
+	"context"
+)
+
+// A SourceProvider is a source with a lifecycle, for backends that hold resources, like a connection to a config
+// service, that must be set up before loading and released after. It's the counterpart of a Loader for sources that
+// need more than a function.
+type SourceProvider interface {
+	// Init prepares the provider to load, e.g. by connecting to its backend.
+	Init(ctx context.Context) error
+	// Load sets the fields of the config, a pointer to the config struct, that the provider has values for.
+	Load(ctx context.Context, config any) error
+	// Close releases what Init acquired.
+	Close() error
+}
+
+// UseProvider enables loading configuration from a SourceProvider. Each time the config is loaded, the provider is
+// initialized, loaded from and closed, in that order, and Close is called before Load moves on to the next source,
+// whether loading succeeded or not, so connections are released deterministically. Close isn't called if Init fails.
+// An error from any of the three fails the source; when both Load and Close fail, the error from Load is returned.
+//
+// The context passed to Init and Load is the one given to LoadContext, and carries the deadline set by WithDeadline,
+// if any, so providers can give up on slow backends or a canceled load. The name identifies the source, as with
+// UseCustom.
+//
+// Example:
+//
+//	qcl.Load(&defaultConfig, qcl.UseEnv(), qcl.UseProvider("consul", consulProvider), qcl.UseFlags())
+func UseProvider(name string, provider SourceProvider) LoadOption {
+	return func(o *LoadConfig) {
+		o.Sources = append(o.Sources, name)
+		o.Loaders[name] = func(config any) (err error) {
+			ctx, cancel := o.context()
+			defer cancel()
+			if err := provider.Init(ctx); err != nil {
+				return err
+			}
+			defer func() {
+				if closeErr := provider.Close(); err == nil {
+					err = closeErr
+				}
+			}()
+			return provider.Load(ctx, config)
+		}
+	}
+}
+
+// context returns the context sources are loaded in: the one given to LoadContext, which is done at the deadline set
+// with WithDeadline, if there is one.
+func (c *LoadConfig) context() (context.Context, context.CancelFunc) {
+	ctx := c.ctx
+	if ctx == nil { // the loader is run outside of LoadContext
+		ctx = context.Background()
+	}
+	if !c.deadline.IsZero() {
+		return context.WithDeadline(ctx, c.deadline)
+	}
+	return context.WithCancel(ctx)
+}
@@ -0,0 +1,80 @@
+package qcl
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testProvider records the calls made to it, and fails the ones it's told to.
+type testProvider struct {
+	calls       []string
+	fail        string
+	host        string
+	hadDeadline bool
+}
+
+func (p *testProvider) call(name string) error {
+	p.calls = append(p.calls, name)
+	if p.fail == name {
+		return errors.New(name + " failed")
+	}
+	return nil
+}
+
+func (p *testProvider) Init(ctx context.Context) error {
+	return p.call("init")
+}
+
+func (p *testProvider) Load(ctx context.Context, config any) error {
+	_, p.hadDeadline = ctx.Deadline()
+	if err := p.call("load"); err != nil {
+		return err
+	}
+	config.(*TestConfig).Host = p.host
+	return nil
+}
+
+func (p *testProvider) Close() error {
+	return p.call("close")
+}
+
+func Test_UseProvider(t *testing.T) {
+	tests := map[string]struct {
+		fail         string
+		opts         []LoadOption
+		wantCalls    []string
+		wantDeadline bool
+		wantErr      bool
+	}{
+		"lifecycle":    {wantCalls: []string{"init", "load", "close"}},
+		"init fails":   {fail: "init", wantCalls: []string{"init"}, wantErr: true},
+		"load fails":   {fail: "load", wantCalls: []string{"init", "load", "close"}, wantErr: true},
+		"close fails":  {fail: "close", wantCalls: []string{"init", "load", "close"}, wantErr: true},
+		"deadline":     {opts: []LoadOption{WithDeadline(time.Now().Add(time.Minute))}, wantCalls: []string{"init", "load", "close"}, wantDeadline: true},
+		"partial load": {fail: "load", opts: []LoadOption{WithPartialResult()}, wantCalls: []string{"init", "load", "close"}, wantErr: true},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			provider := &testProvider{fail: test.fail, host: "provided"}
+			got, err := Load(&TestConfig{Host: "default"}, append(test.opts, UseProvider("test", provider))...)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if !reflect.DeepEqual(provider.calls, test.wantCalls) {
+				t.Errorf("calls = %v, want %v", provider.calls, test.wantCalls)
+			}
+			if provider.hadDeadline != test.wantDeadline {
+				t.Errorf("context has deadline = %v, want %v", provider.hadDeadline, test.wantDeadline)
+			}
+			if !test.wantErr && got.Host != "provided" {
+				t.Errorf("Load() Host = %q, want %q", got.Host, "provided")
+			}
+			if test.fail == "load" && got != nil && got.Host != "default" {
+				t.Errorf("Load() Host = %q after a failed load, want %q", got.Host, "default")
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package qcl
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+)
+
+// rawMessageType is the type of json.RawMessage fields, which, like string and []byte fields tagged `raw:"true"`,
+// receive the value of a source verbatim rather than parsed, so that sections whose shape only the application knows
+// can be decoded later:
+//
+//	type Config struct {
+//		Plugins json.RawMessage // PLUGINS='{"cache":{"size":100}}'
+//		Script  []byte          `raw:"true"`
+//	}
+//
+// json.RawMessage fields must be valid JSON. A section of a file, which has no text of its own, is set as JSON, with
+// the strings its format decodes values to.
+var rawMessageType = reflect.TypeOf(json.RawMessage(nil))
+
+// rawBytes and rawString are the types that []byte and string fields tagged `raw:"true"` are viewed as while they're
+// loaded, so that they're set verbatim like a json.RawMessage, but without requiring JSON.
+type (
+	rawBytes  []byte
+	rawString string
+)
+
+var (
+	rawBytesType  = reflect.TypeOf(rawBytes(nil))
+	rawStringType = reflect.TypeOf(rawString(""))
+)
+
+// errInvalidJSON is returned when a json.RawMessage field is set to a value that isn't valid JSON.
+var errInvalidJSON = errors.New("not valid JSON")
+
+// rawField returns the field v, of the struct field sf, viewed as a rawBytes or rawString if sf is tagged
+// `raw:"true"`, or v itself otherwise. Pointers are allocated, and types that parse themselves are left alone.
+func rawField(sf reflect.StructField, v reflect.Value) reflect.Value {
+	if sf.Tag.Get("raw") != "true" && !qclTag(sf).raw {
+		return v
+	}
+	v = allocate(v)
+	if _, ok := customSetter(v); ok || !v.CanAddr() {
+		return v
+	}
+	switch {
+	case v.Kind() == reflect.String:
+		return reflect.NewAt(rawStringType, v.Addr().UnsafePointer()).Elem()
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+		return reflect.NewAt(rawBytesType, v.Addr().UnsafePointer()).Elem()
+	}
+	return v
+}
+
+// isRaw reports whether v is set verbatim.
+func isRaw(v reflect.Value) bool {
+	return v.Type() == rawMessageType || v.Type() == rawBytesType || v.Type() == rawStringType
+}
+
+// rawSetter returns the function that sets v verbatim, if it's a json.RawMessage or a field tagged `raw:"true"`.
+func rawSetter(v reflect.Value) (func(string) error, bool) {
+	switch v.Type() {
+	case rawMessageType:
+		return func(s string) error {
+			if !json.Valid([]byte(s)) {
+				return errInvalidJSON
+			}
+			v.SetBytes([]byte(s))
+			return nil
+		}, true
+	case rawBytesType:
+		return func(s string) error {
+			v.SetBytes([]byte(s))
+			return nil
+		}, true
+	case rawStringType:
+		return func(s string) error {
+			v.SetString(s)
+			return nil
+		}, true
+	}
+	return nil, false
+}
+
+// rawText returns the text a value of a tree decoded from a file sets a raw field to: strings as they are, and lists
+// and sections as JSON.
+func rawText(value any) (string, error) {
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	text, err := json.Marshal(value)
+	return string(text), err
+}
@@ -0,0 +1,116 @@
+package qcl
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type TestRawConfig struct {
+	Plugins json.RawMessage
+	Script  []byte  `raw:"true"`
+	Query   *string `qcl:"raw"`
+	Ports   []byte
+}
+
+func Test_rawFields(t *testing.T) {
+	query := "a=1,b=2"
+	want := &TestRawConfig{
+		Plugins: json.RawMessage(`{"cache":{"size":100}}`),
+		Script:  []byte("echo a,b"),
+		Query:   &query,
+		Ports:   []byte{80, 81},
+	}
+	args, err := Args(want)
+	if err != nil {
+		t.Fatalf("Args() error = %v", err)
+	}
+	tests := map[string]struct {
+		opts []LoadOption
+		env  map[string]string
+		args []string
+	}{
+		"env": {
+			opts: []LoadOption{UseEnv(WithEnvPrefix("TEST"))},
+			env: map[string]string{
+				"TEST_PLUGINS": `{"cache":{"size":100}}`,
+				"TEST_SCRIPT":  "echo a,b",
+				"TEST_QUERY":   "a=1,b=2",
+				"TEST_PORTS":   "80,81",
+			},
+		},
+		"flags": {opts: []LoadOption{UseFlags()}, args: args},
+		"file": {opts: []LoadOption{UseFile(writeFile(t, "config.ini", []byte(`plugins = {"cache":{"size":100}}
+script = echo a,b
+query = a=1,b=2
+ports = 80,81
+`)))}},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			for key, value := range test.env {
+				t.Setenv(key, value)
+			}
+			useArgs(test.args...)
+			got, err := Load(&TestRawConfig{}, test.opts...)
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Load() = %+v, want %+v", got, want)
+			}
+		})
+	}
+	t.Run("sections", func(t *testing.T) {
+		got, err := Load(&TestRawConfig{}, UseFile(writeFile(t, "config.hcl", []byte(`plugins {
+  cache {
+    size = 100
+    regions = ["eu", "us"]
+  }
+}
+`))))
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		if want := `{"cache":{"regions":["eu","us"],"size":"100"}}`; string(got.Plugins) != want {
+			t.Errorf("Load() = %s, want %s", got.Plugins, want)
+		}
+	})
+	t.Run("invalid JSON", func(t *testing.T) {
+		t.Setenv("TEST_PLUGINS", "{cache}")
+		_, err := Load(&TestRawConfig{}, UseEnv(WithEnvPrefix("TEST")))
+		var fieldErr *FieldError
+		if !errors.As(err, &fieldErr) || fieldErr.Key != "TEST_PLUGINS" || !errors.Is(err, errInvalidJSON) {
+			t.Errorf("Load() error = %v, want a FieldError for TEST_PLUGINS", err)
+		}
+	})
+}
+
+func Test_rawField(t *testing.T) {
+	type config struct {
+		Text  string `raw:"true"`
+		Bytes []byte `raw:"true"`
+		Plain []byte
+		Count int `raw:"true"`
+	}
+	var c config
+	val := reflect.ValueOf(&c).Elem()
+	tests := map[string]struct {
+		field string
+		want  reflect.Type
+	}{
+		"string":      {field: "Text", want: rawStringType},
+		"bytes":       {field: "Bytes", want: rawBytesType},
+		"untagged":    {field: "Plain", want: reflect.TypeOf([]byte(nil))},
+		"unsupported": {field: "Count", want: reflect.TypeOf(0)},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			sf, _ := val.Type().FieldByName(test.field)
+			if got := rawField(sf, val.FieldByName(test.field)); got.Type() != test.want {
+				t.Errorf("rawField() = %v, want %v", got.Type(), test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package qcl
+
+import (
+	"reflect"
+	"strings"
+)
+
+// requiredTracker checks that the fields tagged `required:"true"` are set by a source, rather than left at the value
+// of the defaults:
+//
+//	type Config struct {
+//		DatabaseURL string `required:"true"`
+//	}
+type requiredTracker struct {
+	paths []string        // paths are the dotted paths of the required fields, in the order they are declared.
+	set   map[string]bool // set holds the paths of the required fields a source has set.
+}
+
+// newRequiredTracker returns a tracker for the config, or nil if none of its fields is required.
+func newRequiredTracker(config any) *requiredTracker {
+	typ := reflect.TypeOf(config)
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	paths := requiredPaths(typ, nil, map[reflect.Type]bool{})
+	if len(paths) == 0 {
+		return nil
+	}
+	return &requiredTracker{paths: paths, set: make(map[string]bool)}
+}
+
+// requiredPaths returns the paths of the required fields of the struct type, including those of nested structs
+// behind nil pointers, which leafFields doesn't reach. Types already being walked are skipped, so recursive types end.
+func requiredPaths(typ reflect.Type, path []string, walking map[reflect.Type]bool) []string {
+	if walking[typ] {
+		return nil
+	}
+	walking[typ] = true
+	defer delete(walking, typ)
+
+	var paths []string
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		if !sf.IsExported() || skipField(sf) {
+			continue
+		}
+		fieldPath := append(append(make([]string, 0, len(path)+1), path...), sf.Name)
+		elem := sf.Type
+		if elem.Kind() == reflect.Ptr {
+			elem = elem.Elem()
+		}
+		if elem.Kind() == reflect.Struct && !isLeafStruct(elem) {
+			if sf.Anonymous {
+				fieldPath = path
+			}
+			paths = append(paths, requiredPaths(elem, fieldPath, walking)...)
+			continue
+		}
+		if sf.Tag.Get("required") == "true" || qclTag(sf).required {
+			paths = append(paths, strings.Join(fieldPath, "."))
+		}
+	}
+	return paths
+}
+
+// isRequired reports whether the field at the dotted path is required.
+func (t *requiredTracker) isRequired(path string) bool {
+	for _, p := range t.paths {
+		if p == path {
+			return true
+		}
+	}
+	return false
+}
+
+// snapshot returns copies of the values of the required fields, by dotted path, to compare against once a source has
+// run.
+func (t *requiredTracker) snapshot(config any) map[string]reflect.Value {
+	if t == nil {
+		return nil
+	}
+	values := make(map[string]reflect.Value)
+	for _, f := range leafFields(config) {
+		if t.isRequired(f.name()) {
+			cp := reflect.New(f.value.Type())
+			copyValue(cp.Elem(), f.value)
+			values[f.name()] = cp.Elem()
+		}
+	}
+	return values
+}
+
+// mark records the required fields the source set: those whose value changed since the snapshot taken before it ran,
+// and those it reported a key for, which it set even if to the value they already had.
+func (t *requiredTracker) mark(before map[string]reflect.Value, config any, keys map[string]string) {
+	if t == nil {
+		return
+	}
+	for path := range keys {
+		if t.isRequired(path) {
+			t.set[path] = true
+		}
+	}
+	for _, f := range leafFields(config) {
+		if !t.isRequired(f.name()) {
+			continue
+		}
+		old, ok := before[f.name()]
+		if !ok { // the field was in a struct the source allocated
+			old = reflect.Zero(f.value.Type())
+		}
+		if !reflect.DeepEqual(old.Interface(), f.value.Interface()) {
+			t.set[f.name()] = true
+		}
+	}
+}
+
+// check returns a MissingFieldsError listing the required fields no source set, if any.
+func (t *requiredTracker) check() error {
+	if t == nil {
+		return nil
+	}
+	var missing []string
+	for _, path := range t.paths {
+		if !t.set[path] {
+			missing = append(missing, path)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	return &MissingFieldsError{Fields: missing}
+}
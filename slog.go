@@ -0,0 +1,39 @@
+//go:build go1.21
+
+package qcl
+
+import (
+	"context"
+	"log/slog"
+)
+
+// WithLogger emits debug records of the load to the logger, to help answer "why isn't my environment variable taking
+// effect?": the sources being loaded, every key a source found and the field it set, the keys that were ignored
+// because they match no field, deprecated fields being set, and how long each source took, or why it failed or was
+// skipped. Records are logged at slog.LevelDebug with the context of the load, so the logger's handler must enable
+// that level for them to show.
+//
+// Example:
+//
+//	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))
+//	qcl.Load(&defaultConfig, qcl.UseFile("config.ini"), qcl.UseEnv(qcl.WithEnvPrefix("MYAPP")), qcl.WithLogger(logger))
+//
+// logs, among others:
+//
+//	level=DEBUG msg="qcl: key found" source=env key=MYAPP_DB_HOST field=DB.Host
+//	level=DEBUG msg="qcl: warning" source=env key=MYAPP_DB_PRT field="" message="unknown key ignored"
+//	level=DEBUG msg="qcl: source loaded" source=env duration=41.2µs keys=1
+//
+// Values aren't logged, besides in the errors of sources that failed, whose secret values are redacted as they are in
+// the error Load returns.
+func WithLogger(logger *slog.Logger) LoadOption {
+	return func(o *LoadConfig) {
+		if logger == nil {
+			o.debug = nil
+			return
+		}
+		o.debug = func(ctx context.Context, msg string, args ...any) {
+			logger.DebugContext(ctx, msg, args...)
+		}
+	}
+}
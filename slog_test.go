@@ -0,0 +1,50 @@
+//go:build go1.21
+
+package qcl
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"reflect"
+	"testing"
+)
+
+func Test_WithLogger(t *testing.T) {
+	t.Setenv("TEST_HOST", "app")
+	t.Setenv("TEST_DB_PRT", "5432")
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	failing := func(any) error { return errors.New("unavailable") }
+	_, err := Load(&TestNestedConfig{}, UseEnv(WithEnvPrefix("TEST")), UseCustom("failing", failing), WithLogger(logger))
+	if err == nil {
+		t.Fatal("Load() error = nil, want the failing source's error")
+	}
+
+	var got []map[string]any
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var record map[string]any
+		if err := dec.Decode(&record); err != nil {
+			t.Fatal(err)
+		}
+		if record["level"] != "DEBUG" {
+			t.Errorf("record %v isn't at the debug level", record)
+		}
+		delete(record, "time")
+		delete(record, "level")
+		delete(record, "duration")
+		got = append(got, record)
+	}
+	want := []map[string]any{
+		{"msg": "qcl: loading config", "sources": []any{"env", "failing"}},
+		{"msg": "qcl: key found", "source": "env", "key": "TEST_HOST", "field": "Host"},
+		{"msg": "qcl: warning", "source": "env", "key": "TEST_DB_PRT", "field": "", "message": "unknown key ignored"},
+		{"msg": "qcl: source loaded", "source": "env", "keys": float64(1)},
+		{"msg": "qcl: source failed", "source": "failing", "error": "unavailable"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("WithLogger() records = %v, want %v", got, want)
+	}
+}
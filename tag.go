@@ -0,0 +1,103 @@
+package qcl
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// A fieldTag is the parsed `qcl` struct tag of a field, which gathers the settings every source shares in a single
+// tag, instead of separate tags that can drift apart:
+//
+//	type Config struct {
+//		DBHost string `qcl:"name=db_host,env=DATABASE_HOST,flag=db-host,required,default=localhost"`
+//	}
+//
+// Its options are:
+//
+//   - name= sets the name of the field in every source, e.g. the variable DB_HOST, the flag -db_host and the file
+//     key db_host for name=db_host,
+//   - env=, flag= and file= set the name of the field in the environment, flags and files, overriding name=; like
+//     the name, they're under the prefix and the names of the structs the field is nested in,
+//   - required is the same as `required:"true"`,
+//   - raw is the same as `raw:"true"`,
+//   - default= is the same as the `default` tag. Since defaults may contain commas, like default=a,b,c, it takes the
+//     rest of the tag, and so must come last,
+//   - precedence= ranks the sources that may set the field, as described by sourcePolicy,
+//   - and a tag of just "-" makes every source skip the field.
+//
+// Unknown options are ignored.
+type fieldTag struct {
+	skip         bool   // skip makes every source skip the field.
+	name         string // name is the name of the field in every source, unless overridden for the source.
+	env          string // env is the name of the field in the environment.
+	flag         string // flag is the name of the field in flags.
+	file         string // file is the name of the field in files.
+	required     bool   // required makes Load fail if no source sets the field.
+	raw          bool   // raw makes sources set the field verbatim.
+	defaultValue string // defaultValue is the value the field is set to before any source runs, if hasDefault is set.
+	hasDefault   bool
+	precedence   string // precedence ranks the sources that may set the field, e.g. "flags>env>file".
+}
+
+// fieldTags caches parsed tags, by the text of the tag.
+var fieldTags sync.Map
+
+// qclTag returns the parsed `qcl` struct tag of the field.
+func qclTag(sf reflect.StructField) fieldTag {
+	tag := sf.Tag.Get("qcl")
+	if tag == "" {
+		return fieldTag{}
+	}
+	if parsed, ok := fieldTags.Load(tag); ok {
+		return parsed.(fieldTag)
+	}
+	parsed := parseFieldTag(tag)
+	fieldTags.Store(tag, parsed)
+	return parsed
+}
+
+// parseFieldTag parses the comma-separated options of a `qcl` struct tag. Spaces around options and their values are
+// ignored, except in the value of the last, default=, which is everything after the "=", spaces included.
+func parseFieldTag(tag string) fieldTag {
+	var parsed fieldTag
+	if strings.TrimSpace(tag) == "-" {
+		parsed.skip = true
+		return parsed
+	}
+	for tag != "" {
+		option := tag
+		tag = ""
+		if !strings.HasPrefix(strings.TrimSpace(option), "default=") {
+			if i := strings.Index(option, ","); i >= 0 {
+				option, tag = option[:i], option[i+1:]
+			}
+		}
+		key, value := strings.TrimSpace(option), ""
+		if i := strings.Index(option, "="); i >= 0 {
+			key, value = strings.TrimSpace(option[:i]), option[i+1:]
+			if key != "default" { // defaults are taken as written, like those of the `default` tag
+				value = strings.TrimSpace(value)
+			}
+		}
+		switch key {
+		case "name":
+			parsed.name = value
+		case "env":
+			parsed.env = value
+		case "flag":
+			parsed.flag = value
+		case "file":
+			parsed.file = value
+		case "required":
+			parsed.required = true
+		case "raw":
+			parsed.raw = true
+		case "default":
+			parsed.defaultValue, parsed.hasDefault = value, true
+		case "precedence":
+			parsed.precedence = value
+		}
+	}
+	return parsed
+}
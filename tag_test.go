@@ -0,0 +1,92 @@
+package qcl
+
+import (
+	"flag"
+	"os"
+	"reflect"
+	"testing"
+)
+
+type TestTagConfig struct {
+	DBHost  string   `qcl:"name=db_host,default=localhost"`
+	DBPort  int      `qcl:"name=db_port,env=DATABASE_PORT,flag=database-port,file=database_port"`
+	Tags    []string `qcl:"default=a,b,c"`
+	Runtime string   `qcl:"-"`
+}
+
+func Test_parseFieldTag(t *testing.T) {
+	tests := map[string]struct {
+		tag  string
+		want fieldTag
+	}{
+		"skip":           {tag: "-", want: fieldTag{skip: true}},
+		"names":          {tag: "name=db_host, env=DB_HOST, flag=db-host, file=host", want: fieldTag{name: "db_host", env: "DB_HOST", flag: "db-host", file: "host"}},
+		"required":       {tag: "required", want: fieldTag{required: true}},
+		"raw":            {tag: "name=query,raw", want: fieldTag{name: "query", raw: true}},
+		"default":        {tag: "required,default=a,b,c", want: fieldTag{required: true, defaultValue: "a,b,c", hasDefault: true}},
+		"empty default":  {tag: "default=", want: fieldTag{hasDefault: true}},
+		"spaced default": {tag: "required, default= a b ", want: fieldTag{required: true, defaultValue: " a b ", hasDefault: true}},
+		"precedence":     {tag: "precedence=flags>env", want: fieldTag{precedence: "flags>env"}},
+		"unknown option": {tag: "name=host,unknown", want: fieldTag{name: "host"}},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := parseFieldTag(test.tag); got != test.want {
+				t.Errorf("parseFieldTag(%q) = %+v, want %+v", test.tag, got, test.want)
+			}
+		})
+	}
+}
+
+func Test_qclTag(t *testing.T) {
+	tests := map[string]struct {
+		env  map[string]string
+		args []string
+		file string
+		want TestTagConfig
+	}{
+		"defaults": {
+			want: TestTagConfig{DBHost: "localhost", Tags: []string{"a", "b", "c"}},
+		},
+		"env": {
+			env:  map[string]string{"TEST_DB_HOST": "env", "TEST_DATABASE_PORT": "5432", "TEST_RUNTIME": "ignored"},
+			want: TestTagConfig{DBHost: "env", DBPort: 5432, Tags: []string{"a", "b", "c"}},
+		},
+		"flags": {
+			args: []string{"-db_host", "flag", "-database-port", "5433"},
+			want: TestTagConfig{DBHost: "flag", DBPort: 5433, Tags: []string{"a", "b", "c"}},
+		},
+		"file": {
+			file: "db_host = file\ndatabase_port = 5434\n",
+			want: TestTagConfig{DBHost: "file", DBPort: 5434, Tags: []string{"a", "b", "c"}},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			for key, value := range test.env {
+				t.Setenv(key, value)
+			}
+			flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+			os.Args = append([]string{"test"}, test.args...)
+			opts := []LoadOption{UseEnv(WithEnvPrefix("TEST")), UseFlags()}
+			if test.file != "" {
+				opts = append(opts, UseFile(writeFile(t, "config.ini", []byte(test.file))))
+			}
+			got, err := Load(&TestTagConfig{}, opts...)
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if !reflect.DeepEqual(*got, test.want) {
+				t.Errorf("Load() = %+v, want %+v", *got, test.want)
+			}
+		})
+	}
+	t.Run("required", func(t *testing.T) {
+		_, err := Load(&struct {
+			Name string `qcl:"required"`
+		}{}, UseCustom("test", func(any) error { return nil }))
+		if want := "missing required fields: Name"; err == nil || err.Error() != want {
+			t.Errorf("Load() error = %v, want %v", err, want)
+		}
+	})
+}
@@ -0,0 +1,458 @@
+package qcl
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimeRange is an interval of time from Start (inclusive) to End (exclusive). It is loaded from the ISO 8601 interval
+// notation: two RFC 3339 timestamps separated by a slash.
+//
+// Example:
+//
+//	export MAINTENANCE_WINDOW="2023-01-01T02:00:00Z/2023-01-01T04:00:00Z"
+//
+//	type Config struct {
+//		MaintenanceWindow qcl.TimeRange
+//	}
+//
+// Loading fails if the value isn't in that form, or if End isn't after Start.
+type TimeRange struct {
+	Start time.Time
+	End   time.Time
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (r *TimeRange) UnmarshalText(text []byte) error {
+	parts := strings.Split(string(text), "/")
+	if len(parts) != 2 {
+		return fmt.Errorf("invalid time range %q: must be two RFC 3339 timestamps separated by a slash", text)
+	}
+	start, err := time.Parse(time.RFC3339, strings.TrimSpace(parts[0]))
+	if err != nil {
+		return fmt.Errorf("invalid time range start: %w", err)
+	}
+	end, err := time.Parse(time.RFC3339, strings.TrimSpace(parts[1]))
+	if err != nil {
+		return fmt.Errorf("invalid time range end: %w", err)
+	}
+	if !end.After(start) {
+		return fmt.Errorf("invalid time range %q: end must be after start", text)
+	}
+	r.Start, r.End = start, end
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (r TimeRange) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// String returns the range in the form it is loaded from.
+func (r TimeRange) String() string {
+	return r.Start.Format(time.RFC3339) + "/" + r.End.Format(time.RFC3339)
+}
+
+// Contains reports whether t is within the range.
+func (r TimeRange) Contains(t time.Time) bool {
+	return !t.Before(r.Start) && t.Before(r.End)
+}
+
+// Duration returns the length of the range.
+func (r TimeRange) Duration() time.Duration {
+	return r.End.Sub(r.Start)
+}
+
+// HostPort is a network address of the form host:port, as accepted by net.Dial and net.Listen. It is split with
+// net.SplitHostPort when it is loaded, so malformed addresses fail at load time rather than when they're first dialed.
+//
+// Example:
+//
+//	export LISTEN_ADDR=":8080"
+//	export UPSTREAM="[::1]:9000"
+//
+//	type Config struct {
+//		ListenAddr qcl.HostPort // {"" 8080}
+//		Upstream   qcl.HostPort // {"::1" 9000}
+//	}
+//
+// The host may be empty, as in listen addresses, but the port must be a number from 0 to 65535.
+type HostPort struct {
+	Host string
+	Port int
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (a *HostPort) UnmarshalText(text []byte) error {
+	host, port, err := net.SplitHostPort(strings.TrimSpace(string(text)))
+	if err != nil {
+		return fmt.Errorf("invalid address: %w", err)
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return fmt.Errorf("invalid address %q: port must be a number from 0 to 65535", text)
+	}
+	a.Host, a.Port = host, int(p)
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (a HostPort) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// String returns the address in the form it is loaded from, with IPv6 hosts in brackets.
+func (a HostPort) String() string {
+	return net.JoinHostPort(a.Host, strconv.Itoa(a.Port))
+}
+
+// ByteSize is a number of bytes that is loaded from a human-readable size, like 512KB, 10MiB or 1.5GB, so buffer sizes
+// and limits read well in environment variables and config files.
+//
+// Example:
+//
+//	export MAX_UPLOAD="10MiB"
+//
+//	type Config struct {
+//		MaxUpload qcl.ByteSize // 10485760
+//	}
+//
+// The units are case-insensitive. KB, MB, GB, TB, PB and EB are powers of 1000 and KiB, MiB, GiB, TiB, PiB and EiB
+// powers of 1024, as in IEC 80000-13; a plain number, or one with a B suffix, is a number of bytes. Fractional sizes
+// are rounded to the nearest byte.
+type ByteSize uint64
+
+// byteUnits are the units a ByteSize may be given in, largest first, which is the order String tries them in.
+var byteUnits = []struct {
+	name string
+	size ByteSize
+}{
+	{"EiB", 1 << 60}, {"EB", 1e18},
+	{"PiB", 1 << 50}, {"PB", 1e15},
+	{"TiB", 1 << 40}, {"TB", 1e12},
+	{"GiB", 1 << 30}, {"GB", 1e9},
+	{"MiB", 1 << 20}, {"MB", 1e6},
+	{"KiB", 1 << 10}, {"KB", 1e3},
+	{"B", 1},
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (b *ByteSize) UnmarshalText(text []byte) error {
+	value := strings.TrimSpace(string(text))
+	number, unit := value, ByteSize(1)
+	for _, u := range byteUnits {
+		if len(value) > len(u.name) && strings.EqualFold(value[len(value)-len(u.name):], u.name) {
+			number, unit = strings.TrimSpace(value[:len(value)-len(u.name)]), u.size
+			break
+		}
+	}
+	if n, err := strconv.ParseUint(number, 10, 64); err == nil {
+		if n > math.MaxUint64/uint64(unit) {
+			return fmt.Errorf("invalid byte size %q: too large", text)
+		}
+		*b = ByteSize(n) * unit
+		return nil
+	}
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil || n < 0 || math.IsInf(n, 0) || math.IsNaN(n) {
+		return fmt.Errorf("invalid byte size %q: must be a non-negative number with an optional unit, like 512KB or 10MiB", text)
+	}
+	if n = math.Round(n * float64(unit)); n >= math.MaxUint64 {
+		return fmt.Errorf("invalid byte size %q: too large", text)
+	}
+	*b = ByteSize(n)
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (b ByteSize) MarshalText() ([]byte, error) {
+	return []byte(b.String()), nil
+}
+
+// String returns the size in the largest unit it is a whole number of, like 10MiB for 10485760, or 0B.
+func (b ByteSize) String() string {
+	for _, u := range byteUnits {
+		if b != 0 && b%u.size == 0 {
+			return strconv.FormatUint(uint64(b/u.size), 10) + u.name
+		}
+	}
+	return "0B"
+}
+
+// Schedule is a cron expression that is validated when it is loaded. It supports the standard five fields (minute,
+// hour, day of month, month and day of week) with lists (1,15), ranges (1-5), steps (*/10, 0-30/5) and month and
+// weekday names (JAN, MON), as well as the @yearly, @annually, @monthly, @weekly, @daily, @midnight and @hourly
+// macros.
+//
+// Example:
+//
+//	export CLEANUP_SCHEDULE="*/15 * * * MON-FRI"
+//
+//	type Config struct {
+//		CleanupSchedule qcl.Schedule
+//	}
+//
+//	next := conf.CleanupSchedule.Next(time.Now())
+//
+// As in most cron implementations, if both the day of month and day of week are restricted, a time matches if
+// either matches.
+type Schedule struct {
+	expr                          string
+	minute, hour, dom, month, dow uint64 // bit sets of the values each field matches
+	domRestricted, dowRestricted  bool   // whether the day of month and day of week fields are restricted
+	isValid                       bool   // false for the zero Schedule
+}
+
+var scheduleMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var (
+	monthNames   = map[string]int{"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6, "JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12}
+	weekdayNames = map[string]int{"SUN": 0, "MON": 1, "TUE": 2, "WED": 3, "THU": 4, "FRI": 5, "SAT": 6}
+)
+
+// ParseSchedule parses a cron expression into a Schedule.
+func ParseSchedule(expr string) (Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	fields := strings.Fields(expr)
+	if macro, ok := scheduleMacros[strings.ToLower(expr)]; ok {
+		fields = strings.Fields(macro)
+	}
+	if len(fields) != 5 {
+		return Schedule{}, fmt.Errorf("invalid schedule %q: expected 5 fields, found %d", expr, len(fields))
+	}
+	s := Schedule{expr: expr, isValid: true}
+	var err error
+	if s.minute, err = parseScheduleField(fields[0], 0, 59, nil); err != nil {
+		return Schedule{}, fmt.Errorf("invalid schedule %q: minute: %w", expr, err)
+	}
+	if s.hour, err = parseScheduleField(fields[1], 0, 23, nil); err != nil {
+		return Schedule{}, fmt.Errorf("invalid schedule %q: hour: %w", expr, err)
+	}
+	if s.dom, err = parseScheduleField(fields[2], 1, 31, nil); err != nil {
+		return Schedule{}, fmt.Errorf("invalid schedule %q: day of month: %w", expr, err)
+	}
+	if s.month, err = parseScheduleField(fields[3], 1, 12, monthNames); err != nil {
+		return Schedule{}, fmt.Errorf("invalid schedule %q: month: %w", expr, err)
+	}
+	// 7 is accepted as an alias for Sunday
+	if s.dow, err = parseScheduleField(fields[4], 0, 7, weekdayNames); err != nil {
+		return Schedule{}, fmt.Errorf("invalid schedule %q: day of week: %w", expr, err)
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domRestricted, s.dowRestricted = !strings.HasPrefix(fields[2], "*"), !strings.HasPrefix(fields[4], "*")
+	return s, nil
+}
+
+// parseScheduleField parses one field of a cron expression into a bit set of the values it matches.
+func parseScheduleField(field string, min, max int, names map[string]int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step %q", part[i+1:])
+			}
+			rng = part[:i]
+		}
+		lo, hi := min, max
+		if rng != "*" {
+			bounds := strings.SplitN(rng, "-", 2)
+			var err error
+			if lo, err = parseScheduleValue(bounds[0], names); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = parseScheduleValue(bounds[1], names); err != nil {
+					return 0, err
+				}
+			} else if step > 1 {
+				hi = max // "5/10" means every 10 starting at 5
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%q is out of range %d-%d", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func parseScheduleValue(s string, names map[string]int) (int, error) {
+	if v, ok := names[strings.ToUpper(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	return v, nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (s *Schedule) UnmarshalText(text []byte) error {
+	parsed, err := ParseSchedule(string(text))
+	if err != nil {
+		return err
+	}
+	*s = parsed
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (s Schedule) MarshalText() ([]byte, error) {
+	return []byte(s.expr), nil
+}
+
+// String returns the cron expression the schedule was parsed from.
+func (s Schedule) String() string {
+	return s.expr
+}
+
+// Next returns the first time after t that matches the schedule, in t's location. It returns the zero time if the
+// schedule is the zero Schedule or never matches, e.g. "0 0 30 2 *".
+func (s Schedule) Next(t time.Time) time.Time {
+	if !s.isValid {
+		return time.Time{}
+	}
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	// a schedule that hasn't matched in five years, which covers leap days, never will
+	for limit := t.AddDate(5, 0, 0); t.Before(limit); {
+		switch {
+		case s.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case s.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (s Schedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domRestricted && s.dowRestricted {
+		return dom || dow
+	}
+	return dom && dow
+}
+
+// Weighted is a value annotated with a weight, loaded from "value=weight". A value without a weight, e.g. "hostC", has
+// a weight of 1. In a list, it lets load-balancer style configs keep values and their weights together instead of in
+// parallel slices.
+//
+// Example:
+//
+//	export BACKENDS="hostA=3,hostB=1,hostC"
+//
+//	type Config struct {
+//		Backends []qcl.Weighted[string] // [{hostA 3} {hostB 1} {hostC 1}]
+//	}
+//
+// The value is parsed the same way a field of type T would be, and the weight must be a non-negative integer. The
+// weight is separated from the value by the last equals sign, so values containing equals signs need an explicit
+// weight.
+type Weighted[T any] struct {
+	Value  T
+	Weight int
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (w *Weighted[T]) UnmarshalText(text []byte) error {
+	value, weight := string(text), 1
+	if i := strings.LastIndex(value, "="); i >= 0 {
+		var err error
+		if weight, err = strconv.Atoi(strings.TrimSpace(value[i+1:])); err != nil || weight < 0 {
+			return fmt.Errorf("invalid weight %q: must be a non-negative integer", value[i+1:])
+		}
+		value = value[:i]
+	}
+	var v T
+	if err := defaultParseOptions.setField(reflect.ValueOf(&v).Elem(), strings.TrimSpace(value)); err != nil {
+		return err
+	}
+	w.Value, w.Weight = v, weight
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (w Weighted[T]) MarshalText() ([]byte, error) {
+	value, err := formatValue(reflect.ValueOf(&w.Value).Elem(), ",")
+	if err != nil {
+		return nil, err
+	}
+	return []byte(value + "=" + strconv.Itoa(w.Weight)), nil
+}
+
+// Secret is a string, like a password or an API key, that is masked wherever it's formatted, so it can't leak
+// through logging a config. fmt prints it, with any verb, and encoding/json marshals it, as RedactedValue. It's loaded
+// like a string, and Secret fields are treated as though they were tagged `secret:"true"`.
+//
+// Example:
+//
+//	type Config struct {
+//		DBPassword qcl.Secret
+//	}
+//
+//	log.Printf("loaded %+v", conf) // loaded {DBPassword:[REDACTED]}
+//	db, err := sql.Open("postgres", "password="+conf.DBPassword.Reveal())
+type Secret string
+
+// Reveal returns the secret's value.
+func (s Secret) Reveal() string {
+	return string(s)
+}
+
+// String returns RedactedValue.
+func (s Secret) String() string {
+	return RedactedValue
+}
+
+// Format implements fmt.Formatter, printing RedactedValue whatever the verb, quoted for %q.
+func (s Secret) Format(f fmt.State, verb rune) {
+	if verb == 'q' {
+		fmt.Fprint(f, strconv.Quote(RedactedValue))
+		return
+	}
+	fmt.Fprint(f, RedactedValue)
+}
+
+// MarshalJSON implements json.Marshaler, marshaling RedactedValue.
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(RedactedValue)), nil
+}
+
+// secretType is the type of Secret fields.
+var secretType = reflect.TypeOf(Secret(""))
+
+// isSecretType reports whether typ is a Secret, or a pointer to, slice of or map of them.
+func isSecretType(typ reflect.Type) bool {
+	for typ.Kind() == reflect.Ptr || typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array || typ.Kind() == reflect.Map {
+		typ = typ.Elem()
+	}
+	return typ == secretType
+}
@@ -0,0 +1,367 @@
+package qcl
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type TestScheduleConfig struct {
+	Window   TimeRange
+	Cleanup  Schedule
+	Optional *Schedule
+}
+
+func Test_TimeRange(t *testing.T) {
+	start := time.Date(2023, 1, 1, 2, 0, 0, 0, time.UTC)
+	end := time.Date(2023, 1, 1, 4, 0, 0, 0, time.UTC)
+	tests := map[string]struct {
+		value   string
+		want    TimeRange
+		wantErr bool
+	}{
+		"valid":          {value: "2023-01-01T02:00:00Z/2023-01-01T04:00:00Z", want: TimeRange{start, end}},
+		"spaces":         {value: "2023-01-01T02:00:00Z / 2023-01-01T04:00:00Z", want: TimeRange{start, end}},
+		"no slash":       {value: "2023-01-01T02:00:00Z", wantErr: true},
+		"invalid start":  {value: "yesterday/2023-01-01T04:00:00Z", wantErr: true},
+		"invalid end":    {value: "2023-01-01T02:00:00Z/tomorrow", wantErr: true},
+		"end not after":  {value: "2023-01-01T04:00:00Z/2023-01-01T02:00:00Z", wantErr: true},
+		"empty interval": {value: "2023-01-01T02:00:00Z/2023-01-01T02:00:00Z", wantErr: true},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var got TimeRange
+			err := got.UnmarshalText([]byte(test.value))
+			if (err != nil) != test.wantErr {
+				t.Fatalf("TimeRange.UnmarshalText() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if !test.wantErr && !reflect.DeepEqual(got, test.want) {
+				t.Errorf("TimeRange.UnmarshalText() = %v, want %v", got, test.want)
+			}
+		})
+	}
+	r := TimeRange{start, end}
+	if !r.Contains(start) || !r.Contains(start.Add(time.Hour)) || r.Contains(end) || r.Contains(start.Add(-time.Second)) {
+		t.Errorf("TimeRange.Contains() is wrong for %v", r)
+	}
+	if r.Duration() != 2*time.Hour {
+		t.Errorf("TimeRange.Duration() = %v, want %v", r.Duration(), 2*time.Hour)
+	}
+	if text, _ := r.MarshalText(); string(text) != "2023-01-01T02:00:00Z/2023-01-01T04:00:00Z" {
+		t.Errorf("TimeRange.MarshalText() = %s", text)
+	}
+}
+
+func Test_HostPort(t *testing.T) {
+	tests := map[string]struct {
+		value   string
+		want    HostPort
+		wantErr bool
+	}{
+		"host and port": {value: "example.com:443", want: HostPort{"example.com", 443}},
+		"empty host":    {value: ":8080", want: HostPort{"", 8080}},
+		"ipv6":          {value: "[::1]:9000", want: HostPort{"::1", 9000}},
+		"spaces":        {value: " localhost:80 ", want: HostPort{"localhost", 80}},
+		"no port":       {value: "example.com", wantErr: true},
+		"empty port":    {value: "example.com:", wantErr: true},
+		"named port":    {value: "example.com:https", wantErr: true},
+		"port too big":  {value: "example.com:65536", wantErr: true},
+		"unbracketed":   {value: "::1:9000", wantErr: true},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var got HostPort
+			err := got.UnmarshalText([]byte(test.value))
+			if (err != nil) != test.wantErr {
+				t.Fatalf("HostPort.UnmarshalText() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if !test.wantErr && got != test.want {
+				t.Errorf("HostPort.UnmarshalText() = %v, want %v", got, test.want)
+			}
+		})
+	}
+	if text, _ := (HostPort{"::1", 9000}).MarshalText(); string(text) != "[::1]:9000" {
+		t.Errorf("HostPort.MarshalText() = %s", text)
+	}
+	t.Run("load", func(t *testing.T) {
+		t.Setenv("LISTEN_ADDR", ":8080")
+		t.Setenv("PEERS", "10.0.0.1:7000,10.0.0.2:7000")
+		got := new(struct {
+			ListenAddr HostPort
+			Peers      []HostPort
+		})
+		if err := loadFromEnv(&envConfig{separator: ","})(got); err != nil {
+			t.Fatalf("loadFromEnv() error = %v", err)
+		}
+		if got.ListenAddr != (HostPort{"", 8080}) || !reflect.DeepEqual(got.Peers, []HostPort{{"10.0.0.1", 7000}, {"10.0.0.2", 7000}}) {
+			t.Errorf("loadFromEnv() = %v", got)
+		}
+		t.Setenv("LISTEN_ADDR", "8080")
+		var fieldErr *FieldError
+		if err := loadFromEnv(&envConfig{separator: ","})(got); !errors.As(err, &fieldErr) || fieldErr.Key != "LISTEN_ADDR" {
+			t.Errorf("loadFromEnv() error = %v, want a FieldError for LISTEN_ADDR", err)
+		}
+	})
+}
+
+func Test_ByteSize(t *testing.T) {
+	tests := map[string]struct {
+		value   string
+		want    ByteSize
+		wantErr bool
+	}{
+		"bytes":           {value: "4096", want: 4096},
+		"bytes suffix":    {value: "4096B", want: 4096},
+		"decimal":         {value: "512KB", want: 512000},
+		"binary":          {value: "10MiB", want: 10 << 20},
+		"fraction":        {value: "1.5GB", want: 1500000000},
+		"binary fraction": {value: "0.5KiB", want: 512},
+		"lower case":      {value: "2gib", want: 2 << 30},
+		"space":           {value: " 64 MB ", want: 64000000},
+		"largest":         {value: "15EiB", want: 15 << 60},
+		"zero":            {value: "0", want: 0},
+		"too large":       {value: "16EiB", wantErr: true},
+		"negative":        {value: "-1MB", wantErr: true},
+		"unknown unit":    {value: "1XB", wantErr: true},
+		"no number":       {value: "MB", wantErr: true},
+		"empty":           {value: "", wantErr: true},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var got ByteSize
+			err := got.UnmarshalText([]byte(test.value))
+			if (err != nil) != test.wantErr {
+				t.Fatalf("ByteSize.UnmarshalText() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if !test.wantErr && got != test.want {
+				t.Errorf("ByteSize.UnmarshalText() = %d, want %d", got, test.want)
+			}
+		})
+	}
+	for size, want := range map[ByteSize]string{0: "0B", 1000: "1KB", 1024: "1KiB", 1500: "1500B", 10 << 20: "10MiB", 1500000000: "1500MB"} {
+		if got := size.String(); got != want {
+			t.Errorf("ByteSize(%d).String() = %s, want %s", size, got, want)
+		}
+	}
+	t.Run("load", func(t *testing.T) {
+		got, err := Load(&struct{ MaxUpload ByteSize }{}, UseFile(writeFile(t, "config.ini", []byte("max_upload = 10MiB"))))
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		if got.MaxUpload != 10<<20 {
+			t.Errorf("Load() = %d, want %d", got.MaxUpload, 10<<20)
+		}
+		if args, _ := Args(got); !reflect.DeepEqual(args, []string{"-maxupload=10MiB"}) {
+			t.Errorf("Args() = %v", args)
+		}
+	})
+}
+
+func Test_Schedule(t *testing.T) {
+	// 2023-01-01 is a Sunday
+	from := time.Date(2023, 1, 1, 10, 7, 30, 0, time.UTC)
+	tests := map[string]struct {
+		expr    string
+		want    time.Time
+		wantErr bool
+	}{
+		"every minute":      {expr: "* * * * *", want: time.Date(2023, 1, 1, 10, 8, 0, 0, time.UTC)},
+		"step":              {expr: "*/15 * * * *", want: time.Date(2023, 1, 1, 10, 15, 0, 0, time.UTC)},
+		"range with step":   {expr: "0-30/20 * * * *", want: time.Date(2023, 1, 1, 10, 20, 0, 0, time.UTC)},
+		"start with step":   {expr: "50/5 * * * *", want: time.Date(2023, 1, 1, 10, 50, 0, 0, time.UTC)},
+		"list":              {expr: "5,45 9,11 * * *", want: time.Date(2023, 1, 1, 11, 5, 0, 0, time.UTC)},
+		"weekday names":     {expr: "0 9 * * MON-FRI", want: time.Date(2023, 1, 2, 9, 0, 0, 0, time.UTC)},
+		"sunday as 7":       {expr: "0 12 * * 7", want: time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)},
+		"month names":       {expr: "0 0 1 mar *", want: time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC)},
+		"dom or dow":        {expr: "0 0 15 * FRI", want: time.Date(2023, 1, 6, 0, 0, 0, 0, time.UTC)},
+		"leap day":          {expr: "0 0 29 2 *", want: time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		"never":             {expr: "0 0 30 2 *", want: time.Time{}},
+		"macro":             {expr: "@daily", want: time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)},
+		"too few fields":    {expr: "* * * *", wantErr: true},
+		"out of range":      {expr: "60 * * * *", wantErr: true},
+		"invalid hour":      {expr: "* 24 * * *", wantErr: true},
+		"invalid dom":       {expr: "* * 0 * *", wantErr: true},
+		"invalid month":     {expr: "* * * 13 *", wantErr: true},
+		"invalid dow":       {expr: "* * * * 8", wantErr: true},
+		"invalid step":      {expr: "*/0 * * * *", wantErr: true},
+		"invalid value":     {expr: "a * * * *", wantErr: true},
+		"invalid range end": {expr: "1-b * * * *", wantErr: true},
+		"backwards range":   {expr: "30-10 * * * *", wantErr: true},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var got Schedule
+			err := got.UnmarshalText([]byte(test.expr))
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Schedule.UnmarshalText() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if test.wantErr {
+				return
+			}
+			if next := got.Next(from); !next.Equal(test.want) {
+				t.Errorf("Schedule.Next() = %v, want %v", next, test.want)
+			}
+			if text, _ := got.MarshalText(); string(text) != test.expr {
+				t.Errorf("Schedule.MarshalText() = %s, want %s", text, test.expr)
+			}
+		})
+	}
+	if next := (Schedule{}).Next(from); !next.IsZero() {
+		t.Errorf("Schedule{}.Next() = %v, want zero time", next)
+	}
+}
+
+func Test_loadTextUnmarshalers(t *testing.T) {
+	t.Setenv("TEST_WINDOW", "2023-01-01T02:00:00Z/2023-01-01T04:00:00Z")
+	t.Setenv("TEST_CLEANUP", "@hourly")
+	t.Setenv("TEST_OPTIONAL", "0 0 * * *")
+	got := new(TestScheduleConfig)
+	if err := loadFromEnv(&envConfig{prefix: "TEST", separator: ","})(got); err != nil {
+		t.Fatalf("loadFromEnv() error = %v", err)
+	}
+	if got.Window.Duration() != 2*time.Hour || got.Cleanup.String() != "@hourly" || got.Optional.String() != "0 0 * * *" {
+		t.Errorf("loadFromEnv() = %v", got)
+	}
+
+	t.Setenv("TEST_CLEANUP", "not a schedule")
+	if err := loadFromEnv(&envConfig{prefix: "TEST", separator: ","})(new(TestScheduleConfig)); err == nil {
+		t.Errorf("loadFromEnv() should return an error for an invalid schedule")
+	}
+
+	args, err := Args(got)
+	if err != nil {
+		t.Fatalf("Args() error = %v", err)
+	}
+	want := []string{"-window=2023-01-01T02:00:00Z/2023-01-01T04:00:00Z", "-cleanup=@hourly", "-optional=0 0 * * *"}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("Args() = %v, want %v", args, want)
+	}
+	if args, _ := Args(&TestScheduleConfig{}); len(args) != 0 {
+		t.Errorf("Args() = %v, want zero schedules left out", args)
+	}
+	useArgs(args...)
+	fromFlags := new(TestScheduleConfig)
+	if err := loadFromFlags(nil)(fromFlags); err != nil {
+		t.Fatalf("loadFromFlags() error = %v", err)
+	}
+	if !reflect.DeepEqual(fromFlags, got) {
+		t.Errorf("loadFromFlags() = %v, want %v", fromFlags, got)
+	}
+}
+
+func Test_Weighted(t *testing.T) {
+	tests := map[string]struct {
+		value   string
+		want    Weighted[int]
+		wantErr bool
+	}{
+		"weighted":         {value: "8080=3", want: Weighted[int]{8080, 3}},
+		"spaces":           {value: " 8080 = 3 ", want: Weighted[int]{8080, 3}},
+		"no weight":        {value: "8080", want: Weighted[int]{8080, 1}},
+		"zero weight":      {value: "8080=0", want: Weighted[int]{8080, 0}},
+		"negative weight":  {value: "8080=-1", wantErr: true},
+		"invalid weight":   {value: "8080=heavy", wantErr: true},
+		"unparseable type": {value: "port=1", wantErr: true},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var got Weighted[int]
+			err := got.UnmarshalText([]byte(test.value))
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Weighted.UnmarshalText() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if !test.wantErr && got != test.want {
+				t.Errorf("Weighted.UnmarshalText() = %v, want %v", got, test.want)
+			}
+		})
+	}
+	t.Run("list", func(t *testing.T) {
+		t.Setenv("BACKENDS", "hostA=3,hostB=1,http://hostC/?a=b=2")
+		got := new(struct{ Backends []Weighted[string] })
+		if err := loadFromEnv(&envConfig{separator: ","})(got); err != nil {
+			t.Fatalf("loadFromEnv() error = %v", err)
+		}
+		want := []Weighted[string]{{"hostA", 3}, {"hostB", 1}, {"http://hostC/?a=b", 2}}
+		if !reflect.DeepEqual(got.Backends, want) {
+			t.Errorf("loadFromEnv() = %v, want %v", got.Backends, want)
+		}
+		if args, _ := Args(got); !reflect.DeepEqual(args, []string{"-backends=hostA=3,hostB=1,http://hostC/?a=b=2"}) {
+			t.Errorf("Args() = %v", args)
+		}
+	})
+	t.Run("unsupported type", func(t *testing.T) {
+		if _, err := (Weighted[chan int]{}).MarshalText(); err == nil {
+			t.Errorf("Weighted.MarshalText() should return an error for an unsupported type")
+		}
+	})
+}
+
+func Test_Secret(t *testing.T) {
+	type config struct {
+		User     string
+		Password Secret
+		Tokens   []Secret
+	}
+	c := config{User: "admin", Password: "hunter2", Tokens: []Secret{"t1"}}
+	for format, want := range map[string]string{
+		"%v":  "{admin [REDACTED] [[REDACTED]]}",
+		"%+v": "{User:admin Password:[REDACTED] Tokens:[[REDACTED]]}",
+		"%s":  "{admin [REDACTED] [[REDACTED]]}",
+		"%q":  `{"admin" "[REDACTED]" ["[REDACTED]"]}`,
+		"%x":  "{61646d696e [REDACTED] [[REDACTED]]}",
+	} {
+		if got := fmt.Sprintf(format, c); got != want {
+			t.Errorf("Sprintf(%q) = %s, want %s", format, got, want)
+		}
+	}
+	if got := fmt.Sprintf("%#v", c.Password); got != "[REDACTED]" {
+		t.Errorf("Sprintf(%%#v) = %s, want [REDACTED]", got)
+	}
+	if got, err := json.Marshal(c); err != nil || string(got) != `{"User":"admin","Password":"[REDACTED]","Tokens":["[REDACTED]"]}` {
+		t.Errorf("json.Marshal() = %s, %v", got, err)
+	}
+	if c.Password.Reveal() != "hunter2" {
+		t.Errorf("Reveal() = %s, want hunter2", c.Password.Reveal())
+	}
+
+	tests := map[string]struct {
+		opts []LoadOption
+		env  map[string]string
+		args []string
+	}{
+		"env":   {opts: []LoadOption{UseEnv(WithEnvPrefix("TEST"))}, env: map[string]string{"TEST_PASSWORD": "hunter2"}},
+		"flags": {opts: []LoadOption{UseFlags()}, args: []string{"-password=hunter2"}},
+		"file":  {opts: []LoadOption{UseFile(writeFile(t, "config.ini", []byte("password = hunter2")))}},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			for key, value := range test.env {
+				t.Setenv(key, value)
+			}
+			useArgs(test.args...)
+			got, err := Load(&config{}, test.opts...)
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if got.Password.Reveal() != "hunter2" {
+				t.Errorf("Load() Password = %q, want hunter2", got.Password.Reveal())
+			}
+			if dump := Dump(got); strings.Contains(dump, "hunter2") {
+				t.Errorf("Dump() = %s, want the password redacted", dump)
+			}
+		})
+	}
+	t.Run("environ", func(t *testing.T) {
+		env, err := Environ(&c, "")
+		if err != nil {
+			t.Fatalf("Environ() error = %v", err)
+		}
+		if want := []string{"USER=admin", "PASSWORD=hunter2", "TOKENS=t1"}; !reflect.DeepEqual(env, want) {
+			t.Errorf("Environ() = %v, want %v", env, want)
+		}
+	})
+}
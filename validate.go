@@ -0,0 +1,263 @@
+package qcl
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// validators maps the names of the rules of the `validate` struct tag to the functions checking them. Each is called
+// with the value of the field and the parameter of the rule, e.g. "65535" for max=65535.
+var validators = map[string]func(value any, param string) error{
+	"min":   validateMin,
+	"max":   validateMax,
+	"oneof": validateOneOf,
+	"regex": validateRegex,
+}
+
+// validatorsMu guards validators, since validators may be registered while configs are being loaded.
+var validatorsMu sync.RWMutex
+
+// RegisterValidator adds a rule to the `validate` struct tag, so domain checks run along with the built-in rules when
+// a config is loaded. The function is called with the value of the field, dereferenced if it's a pointer, and the
+// parameter of the rule, which is empty if it has none. The error it returns is reported as the Err of a
+// *ValidationError, so it should say what's expected of the value, like "must be a valid S3 bucket name", rather than
+// repeat the field's name. Registering a rule under the name of an existing one, including min, max, oneof and regex,
+// replaces it.
+//
+// Example:
+//
+//	qcl.RegisterValidator("s3bucket", func(value any, _ string) error {
+//		if !bucketName.MatchString(value.(string)) {
+//			return errors.New("must be a valid S3 bucket name")
+//		}
+//		return nil
+//	})
+//
+//	type Config struct {
+//		Bucket string `validate:"s3bucket"`
+//	}
+//
+// It's meant to be called from an init function or before the first load. It panics if the name is empty or contains
+// a comma or equals sign, which the tag syntax reserves, or if fn is nil.
+func RegisterValidator(name string, fn func(value any, param string) error) {
+	if name == "" || strings.ContainsAny(name, ",=") || fn == nil {
+		panic(fmt.Sprintf("qcl: invalid validator %q", name))
+	}
+	validatorsMu.Lock()
+	defer validatorsMu.Unlock()
+	validators[name] = fn
+}
+
+// lookupValidator returns the function checking the named rule.
+func lookupValidator(name string) (func(value any, param string) error, bool) {
+	validatorsMu.RLock()
+	defer validatorsMu.RUnlock()
+	fn, ok := validators[name]
+	return fn, ok
+}
+
+// A validationRule is a rule of the `validate` struct tag, like max=65535.
+type validationRule struct {
+	name  string
+	param string
+}
+
+// String returns the rule as written in the tag.
+func (r validationRule) String() string {
+	if r.param == "" {
+		return r.name
+	}
+	return r.name + "=" + r.param
+}
+
+// parseValidationRules parses the comma-separated rules of a `validate` struct tag. Since regular expressions may
+// contain commas, a regex rule takes the rest of the tag, and so must come last.
+func parseValidationRules(tag string) []validationRule {
+	var rules []validationRule
+	for tag != "" {
+		option := tag
+		tag = ""
+		if !strings.HasPrefix(strings.TrimSpace(option), "regex=") {
+			if i := strings.Index(option, ","); i >= 0 {
+				option, tag = option[:i], option[i+1:]
+			}
+		}
+		name, param := strings.TrimSpace(option), ""
+		if i := strings.Index(name, "="); i >= 0 {
+			name, param = strings.TrimSpace(name[:i]), name[i+1:]
+		}
+		if name != "" {
+			rules = append(rules, validationRule{name: name, param: param})
+		}
+	}
+	return rules
+}
+
+// validate checks the fields of the config against the rules of their `validate` struct tags, returning a
+// *ValidationError for every rule a field breaks. Nil pointers aren't checked, since they're unset rather than
+// invalid; that's what `required:"true"` is for.
+func validate(config any) error {
+	var errs []error
+	for _, f := range leafFields(config) {
+		tag, ok := f.sf.Tag.Lookup("validate")
+		if !ok {
+			continue
+		}
+		v := f.value
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				continue
+			}
+			v = v.Elem()
+		}
+		for _, rule := range parseValidationRules(tag) {
+			check, ok := lookupValidator(rule.name)
+			if !ok {
+				errs = append(errs, &ValidationError{Path: f.name(), Rule: rule.String(), Err: fmt.Errorf("unknown validation rule %q", rule.name)})
+				continue
+			}
+			if err := check(v.Interface(), rule.param); err != nil {
+				errs = append(errs, &ValidationError{Path: f.name(), Rule: rule.String(), Err: err})
+			}
+		}
+	}
+	return joinErrors(errs)
+}
+
+// validateMin checks that a number is at least the parameter, or that a string, slice or map has at least as many
+// elements.
+func validateMin(value any, param string) error {
+	cmp, err := compareBound(value, param)
+	if err != nil {
+		return err
+	}
+	if cmp < 0 {
+		return fmt.Errorf("must be at least %s%s", boundSubject(value), param)
+	}
+	return nil
+}
+
+// validateMax checks that a number is at most the parameter, or that a string, slice or map has at most as many
+// elements.
+func validateMax(value any, param string) error {
+	cmp, err := compareBound(value, param)
+	if err != nil {
+		return err
+	}
+	if cmp > 0 {
+		return fmt.Errorf("must be at most %s%s", boundSubject(value), param)
+	}
+	return nil
+}
+
+// boundSubject returns what min and max compare for the value: nothing for numbers, which are compared themselves,
+// and the length for strings, slices and maps.
+func boundSubject(value any) string {
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		return "length "
+	}
+	return ""
+}
+
+// compareBound returns -1, 0 or 1 as the value is less than, equal to or greater than the bound, comparing numbers by
+// value, durations as durations, like 1s, and strings, slices and maps by length.
+func compareBound(value any, bound string) (int, error) {
+	if d, ok := value.(time.Duration); ok {
+		b, err := time.ParseDuration(bound)
+		if err != nil {
+			return 0, err
+		}
+		return compareFloats(float64(d), float64(b)), nil
+	}
+	v := reflect.ValueOf(value)
+	var n float64
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n = float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		n = v.Float()
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		n = float64(v.Len())
+	default:
+		return 0, UnsupportedTypeError{v.Kind()}
+	}
+	b, err := strconv.ParseFloat(bound, 64)
+	if err != nil {
+		return 0, err
+	}
+	return compareFloats(n, b), nil
+}
+
+func compareFloats(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// validateOneOf checks that the value, as it would be written in an environment variable, is one of the
+// space-separated options of the parameter, like "debug info warn".
+func validateOneOf(value any, param string) error {
+	options := strings.Fields(param)
+	s := validationText(value)
+	for _, option := range options {
+		if s == option {
+			return nil
+		}
+	}
+	return fmt.Errorf("must be one of %s", strings.Join(options, ", "))
+}
+
+// validateRegex checks that the value, as it would be written in an environment variable, matches the regular
+// expression of the parameter. The expression isn't anchored, so it should start with ^ and end with $ to match the
+// whole value.
+func validateRegex(value any, param string) error {
+	re, err := regexp.Compile(param)
+	if err != nil {
+		return err
+	}
+	if !re.MatchString(validationText(value)) {
+		return fmt.Errorf("must match %s", param)
+	}
+	return nil
+}
+
+// validationText returns the value as text, the way the environment loader parses it.
+func validationText(value any) string {
+	v := reflect.ValueOf(value)
+	p := reflect.New(v.Type()).Elem() // addressable, for types that implement encoding.TextMarshaler on a pointer
+	p.Set(v)
+	return dumpText(p)
+}
+
+// validator is implemented by configs, and structs nested in them, that check invariants the `validate` tag can't
+// express, like a certificate and its key being set together.
+type validator interface {
+	Validate() error
+}
+
+// callValidateHooks calls the Validate method of v, if it has one, and of the fields nested in it, returning their
+// errors. Errors from nested fields are prefixed with their dotted path.
+func callValidateHooks(v reflect.Value) []error {
+	var errs []error
+	walkHooks(v, "", false, func(hook validator, path string) {
+		if err := hook.Validate(); err != nil {
+			if path != "" {
+				err = fmt.Errorf("%s: %w", path, err)
+			}
+			errs = append(errs, err)
+		}
+	})
+	return errs
+}
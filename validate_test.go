@@ -0,0 +1,206 @@
+package qcl
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type TestValidateConfig struct {
+	Port     int            `validate:"min=1,max=65535"`
+	Ratio    float64        `validate:"max=1"`
+	Timeout  time.Duration  `validate:"min=1s"`
+	Name     string         `validate:"min=3,max=8"`
+	Hosts    []string       `validate:"min=1"`
+	LogLevel string         `validate:"oneof=debug info warn"`
+	Region   string         `validate:"regex=^[a-z]{2}-[a-z]+-[0-9]{1,2}$"`
+	Replicas *int           `validate:"min=1"`
+	Limits   map[string]int `validate:"max=2"`
+}
+
+func validTestValidateConfig() TestValidateConfig {
+	return TestValidateConfig{
+		Port: 8080, Ratio: 0.5, Timeout: time.Second, Name: "app", Hosts: []string{"a"}, LogLevel: "info",
+		Region: "eu-west-1", Limits: map[string]int{"rps": 1},
+	}
+}
+
+func Test_validate(t *testing.T) {
+	zero := 0
+	tests := map[string]struct {
+		modify func(*TestValidateConfig)
+		want   []string
+	}{
+		"valid":           {modify: func(*TestValidateConfig) {}},
+		"below min":       {modify: func(c *TestValidateConfig) { c.Port = 0 }, want: []string{"invalid Port: must be at least 1"}},
+		"above max":       {modify: func(c *TestValidateConfig) { c.Ratio = 1.5 }, want: []string{"invalid Ratio: must be at most 1"}},
+		"duration":        {modify: func(c *TestValidateConfig) { c.Timeout = time.Millisecond }, want: []string{"invalid Timeout: must be at least 1s"}},
+		"string length":   {modify: func(c *TestValidateConfig) { c.Name = "application" }, want: []string{"invalid Name: must be at most length 8"}},
+		"slice length":    {modify: func(c *TestValidateConfig) { c.Hosts = nil }, want: []string{"invalid Hosts: must be at least length 1"}},
+		"map length":      {modify: func(c *TestValidateConfig) { c.Limits["a"], c.Limits["b"] = 1, 2 }, want: []string{"invalid Limits: must be at most length 2"}},
+		"oneof":           {modify: func(c *TestValidateConfig) { c.LogLevel = "trace" }, want: []string{"invalid LogLevel: must be one of debug, info, warn"}},
+		"regex":           {modify: func(c *TestValidateConfig) { c.Region = "europe" }, want: []string{"invalid Region: must match ^[a-z]{2}-[a-z]+-[0-9]{1,2}$"}},
+		"pointer":         {modify: func(c *TestValidateConfig) { c.Replicas = &zero }, want: []string{"invalid Replicas: must be at least 1"}},
+		"every violation": {modify: func(c *TestValidateConfig) { c.Port, c.Name = 70000, "a" }, want: []string{"invalid Port: must be at most 65535", "invalid Name: must be at least length 3"}},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			config := validTestValidateConfig()
+			test.modify(&config)
+			err := validate(&config)
+			var got []string
+			visitErrors(err, func(err error) {
+				if _, ok := err.(*ValidationError); ok {
+					got = append(got, err.Error())
+				}
+			})
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("validate() error = %v, want %v", got, test.want)
+			}
+		})
+	}
+	t.Run("unknown rule", func(t *testing.T) {
+		err := validate(&struct {
+			Name string `validate:"s3bucket"`
+		}{})
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) || validationErr.Rule != "s3bucket" {
+			t.Errorf("validate() error = %v, want a ValidationError for s3bucket", err)
+		}
+	})
+	t.Run("load", func(t *testing.T) {
+		t.Setenv("TEST_PORT", "70000")
+		defaults := validTestValidateConfig()
+		_, err := Load(&defaults, UseEnv(WithEnvPrefix("TEST")))
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) || validationErr.Path != "Port" {
+			t.Errorf("Load() error = %v, want a ValidationError for Port", err)
+		}
+	})
+}
+
+func Test_parseValidationRules(t *testing.T) {
+	tests := map[string]struct {
+		tag  string
+		want []validationRule
+	}{
+		"rules":         {tag: "min=1, max=10", want: []validationRule{{"min", "1"}, {"max", "10"}}},
+		"no parameter":  {tag: "s3bucket", want: []validationRule{{"s3bucket", ""}}},
+		"regex is last": {tag: "min=1,regex=^a{1,2}$", want: []validationRule{{"min", "1"}, {"regex", "^a{1,2}$"}}},
+		"empty":         {tag: ""},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := parseValidationRules(test.tag); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("parseValidationRules(%q) = %v, want %v", test.tag, got, test.want)
+			}
+		})
+	}
+}
+
+func Test_RegisterValidator(t *testing.T) {
+	RegisterValidator("prefix", func(value any, param string) error {
+		if s, ok := value.(string); !ok || !strings.HasPrefix(s, param) {
+			return fmt.Errorf("must start with %s", param)
+		}
+		return nil
+	})
+	t.Cleanup(func() {
+		validatorsMu.Lock()
+		delete(validators, "prefix")
+		validatorsMu.Unlock()
+	})
+	type config struct {
+		Bucket string `validate:"min=3,prefix=acme-"`
+	}
+	if err := validate(&config{Bucket: "acme-logs"}); err != nil {
+		t.Errorf("validate() error = %v", err)
+	}
+	err := validate(&config{Bucket: "logs"})
+	if want := "invalid Bucket: must start with acme-"; err == nil || err.Error() != want {
+		t.Errorf("validate() error = %v, want %v", err, want)
+	}
+
+	for _, name := range []string{"", "a,b", "a=b"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("RegisterValidator(%q) didn't panic", name)
+				}
+			}()
+			RegisterValidator(name, func(any, string) error { return nil })
+		}()
+	}
+}
+
+type TestTLSConfig struct {
+	Cert string
+	Key  string
+}
+
+func (c TestTLSConfig) Validate() error {
+	if (c.Cert == "") != (c.Key == "") {
+		return errors.New("cert and key must be set together")
+	}
+	return nil
+}
+
+type TestHookConfig struct {
+	Name string
+	TLS  *TestTLSConfig
+	TestTLSConfig
+}
+
+func (c *TestHookConfig) Validate() error {
+	if c.Name == "" {
+		return errors.New("name must be set")
+	}
+	return nil
+}
+
+type TestPromotedHookConfig struct {
+	TestTLSConfig
+	Admin TestTLSConfig
+}
+
+func Test_callValidateHooks(t *testing.T) {
+	tests := map[string]struct {
+		config any
+		want   []string
+	}{
+		"valid": {
+			config: &TestHookConfig{Name: "app", TLS: &TestTLSConfig{Cert: "c", Key: "k"}},
+		},
+		"root and nested": {
+			config: &TestHookConfig{TLS: &TestTLSConfig{Cert: "c"}},
+			want:   []string{"name must be set", "TLS: cert and key must be set together"},
+		},
+		"embedded method overridden": {
+			config: &TestHookConfig{Name: "app", TestTLSConfig: TestTLSConfig{Key: "k"}},
+		},
+		"embedded method promoted": {
+			config: &TestPromotedHookConfig{TestTLSConfig: TestTLSConfig{Key: "k"}, Admin: TestTLSConfig{Cert: "c"}},
+			want:   []string{"cert and key must be set together", "Admin: cert and key must be set together"},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var got []string
+			for _, err := range callValidateHooks(reflect.ValueOf(test.config)) {
+				got = append(got, err.Error())
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("callValidateHooks() = %v, want %v", got, test.want)
+			}
+		})
+	}
+	t.Run("load", func(t *testing.T) {
+		_, err := Load(&TestHookConfig{Name: "app", TLS: &TestTLSConfig{Key: "k"}}, UseCustom("test", func(any) error { return nil }))
+		if want := "TLS: cert and key must be set together"; err == nil || err.Error() != want {
+			t.Errorf("Load() error = %v, want %v", err, want)
+		}
+	})
+}
@@ -0,0 +1,147 @@
+package qcl
+
+import (
+	"reflect"
+	"time"
+)
+
+// Value wraps a config field with metadata about how it was loaded: whether any source set it, which source set it
+// last, and when. It is loaded exactly like a field of type T, so it can be used in place of a plain field wherever
+// richer runtime introspection is wanted.
+//
+// Example:
+//
+//	type Config struct {
+//		Host qcl.Value[string]
+//		Port qcl.Value[int]
+//	}
+//
+//	defaultConfig := Config{Port: qcl.NewValue(8080)}
+//	conf, _ := qcl.Load(&defaultConfig, qcl.UseEnv(), qcl.UseFlags())
+//
+//	conf.Port.Get()       // 9090
+//	conf.Port.Source()    // "flags"
+//	conf.Host.IsSet()     // false
+//	conf.Host.Source()    // ""
+//
+// Slices and maps inside a Value are separated with a comma, whatever separator the loader is configured with. When a
+// config is inspected with Diff or Redacted, or rendered with Args or Environ, a Value is treated as the value it
+// wraps.
+type Value[T any] struct {
+	value     T
+	source    string
+	isSet     bool
+	updatedAt time.Time
+	pending   bool // pending is true when the value was parsed but not yet attributed to a source.
+}
+
+// NewValue returns a Value holding v, for use in a default config. Its source is "default", and it isn't considered
+// set until a source sets it.
+func NewValue[T any](v T) Value[T] {
+	return Value[T]{value: v, source: "default"}
+}
+
+// Get returns the wrapped value.
+func (v Value[T]) Get() T {
+	return v.value
+}
+
+// Source returns the name of the source that last set the value, e.g. "env" or "flags", "default" for a value created
+// with NewValue, "override" for a value set by Override, or "" if nothing has set it.
+func (v Value[T]) Source() string {
+	return v.source
+}
+
+// IsSet reports whether any source has set the value.
+func (v Value[T]) IsSet() bool {
+	return v.isSet
+}
+
+// UpdatedAt returns the time the value was last set by a source, or the zero time if it hasn't been.
+func (v Value[T]) UpdatedAt() time.Time {
+	return v.updatedAt
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (v *Value[T]) UnmarshalText(text []byte) error {
+	var parsed T
+	if err := defaultParseOptions.setField(reflect.ValueOf(&parsed).Elem(), string(text)); err != nil {
+		return err
+	}
+	v.value, v.pending = parsed, true
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (v Value[T]) MarshalText() ([]byte, error) {
+	text, err := formatValue(reflect.ValueOf(&v.value).Elem(), ",")
+	return []byte(text), err
+}
+
+func (v Value[T]) unwrap() any {
+	return v.value
+}
+
+func (v *Value[T]) attribute(source string, at time.Time) {
+	if !v.pending {
+		return
+	}
+	v.source, v.isSet, v.updatedAt, v.pending = source, true, at, false
+}
+
+func (v *Value[T]) markDefault() {
+	v.source, v.isSet, v.pending = "default", false, false
+}
+
+// wrapper is implemented by Value, so that code inspecting a config sees the wrapped value instead of its metadata.
+type wrapper interface {
+	unwrap() any
+}
+
+// attributer is implemented by *Value, so that Load can record which source set it.
+type attributer interface {
+	attribute(source string, at time.Time)
+}
+
+// defaultMarker is implemented by *Value, so that a value set from its default tag is recorded as a default, like one
+// created with NewValue, rather than credited to the first source that runs.
+type defaultMarker interface {
+	markDefault()
+}
+
+// markDefault records the field v, just set from its default tag, as holding a default, if it's a Value or a pointer
+// to one.
+func markDefault(v reflect.Value) {
+	if v.Kind() != reflect.Ptr {
+		if !v.CanAddr() {
+			return
+		}
+		v = v.Addr()
+	}
+	if v.IsNil() {
+		return
+	}
+	if m, ok := v.Interface().(defaultMarker); ok {
+		m.markDefault()
+	}
+}
+
+// attributeSource records the source as the origin of every Value in the config that was parsed since the last call.
+func attributeSource(config any, source string) {
+	now := time.Now()
+	for _, f := range leafFields(config) {
+		v := f.value
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				continue
+			}
+			v = v.Elem()
+		}
+		if !v.CanAddr() {
+			continue
+		}
+		if a, ok := v.Addr().Interface().(attributer); ok {
+			a.attribute(source, now)
+		}
+	}
+}
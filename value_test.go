@@ -0,0 +1,143 @@
+package qcl
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type TestValueConfig struct {
+	Host  Value[string]
+	Port  Value[int]
+	Hosts Value[[]string]
+	DB    struct {
+		Timeout *Value[time.Duration]
+	}
+}
+
+func Test_Value(t *testing.T) {
+	type want struct {
+		value  any
+		source string
+		isSet  bool
+	}
+	tests := map[string]struct {
+		env  map[string]string
+		args []string
+		want map[string]want
+	}{
+		"defaults": {
+			want: map[string]want{
+				"Host": {value: "", source: "", isSet: false},
+				"Port": {value: 8080, source: "default", isSet: false},
+			},
+		},
+		"env": {
+			env: map[string]string{"HOST": "example.com", "HOSTS": "a,b", "DB_TIMEOUT": "5s"},
+			want: map[string]want{
+				"Host":       {value: "example.com", source: env, isSet: true},
+				"Port":       {value: 8080, source: "default", isSet: false},
+				"Hosts":      {value: []string{"a", "b"}, source: env, isSet: true},
+				"DB.Timeout": {value: 5 * time.Second, source: env, isSet: true},
+			},
+		},
+		"flags override env": {
+			env:  map[string]string{"HOST": "example.com", "PORT": "9090"},
+			args: []string{"-port", "9091"},
+			want: map[string]want{
+				"Host": {value: "example.com", source: env, isSet: true},
+				"Port": {value: 9091, source: flags, isSet: true},
+			},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			for k, v := range test.env {
+				t.Setenv(k, v)
+			}
+			useArgs(test.args...)
+			before := time.Now()
+			got, err := Load(&TestValueConfig{Port: NewValue(8080)}, UseEnv(), UseFlags())
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			values := map[string]interface {
+				Source() string
+				IsSet() bool
+				UpdatedAt() time.Time
+			}{"Host": got.Host, "Port": got.Port, "Hosts": got.Hosts}
+			if got.DB.Timeout != nil {
+				values["DB.Timeout"] = *got.DB.Timeout
+			}
+			fields := make(map[string]field)
+			for _, f := range leafFields(got) {
+				fields[f.name()] = f
+			}
+			for path, w := range test.want {
+				v := values[path]
+				if got := fieldInterface(fields[path]); !reflect.DeepEqual(got, w.value) {
+					t.Errorf("%s value = %v, want %v", path, got, w.value)
+				}
+				if v.Source() != w.source {
+					t.Errorf("%s Source() = %q, want %q", path, v.Source(), w.source)
+				}
+				if v.IsSet() != w.isSet {
+					t.Errorf("%s IsSet() = %v, want %v", path, v.IsSet(), w.isSet)
+				}
+				if w.isSet && v.UpdatedAt().Before(before) {
+					t.Errorf("%s UpdatedAt() = %v, want after %v", path, v.UpdatedAt(), before)
+				}
+			}
+		})
+	}
+	t.Run("tag default", func(t *testing.T) {
+		type config struct {
+			Port    Value[int]  `default:"8080"`
+			Timeout *Value[int] `default:"5"`
+		}
+		got, err := Load(&config{}, UseEnv(WithEnviron(nil)))
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		for path, v := range map[string]Value[int]{"Port": got.Port, "Timeout": *got.Timeout} {
+			if v.Source() != "default" || v.IsSet() {
+				t.Errorf("%s Source() = %q, IsSet() = %v, want %q and false", path, v.Source(), v.IsSet(), "default")
+			}
+		}
+		got, err = Load(&config{}, UseEnv(WithEnviron(map[string]string{"PORT": "9090"})))
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		if got.Port.Get() != 9090 || got.Port.Source() != env || !got.Port.IsSet() {
+			t.Errorf("Port = %v from %q, want 9090 from %q", got.Port.Get(), got.Port.Source(), env)
+		}
+	})
+	t.Run("override", func(t *testing.T) {
+		conf := &TestValueConfig{Port: NewValue(8080)}
+		got, err := Override(conf, map[string]string{"Port": "9090"})
+		if err != nil {
+			t.Fatalf("Override() error = %v", err)
+		}
+		if got.Port.Get() != 9090 || got.Port.Source() != "override" || !got.Port.IsSet() {
+			t.Errorf("Override() Port = %v from %q, want 9090 from %q", got.Port.Get(), got.Port.Source(), "override")
+		}
+		if conf.Port.Get() != 8080 || conf.Port.Source() != "default" {
+			t.Errorf("Override() modified the original: %v from %q", conf.Port.Get(), conf.Port.Source())
+		}
+	})
+	t.Run("inspected as wrapped value", func(t *testing.T) {
+		old := &TestValueConfig{Port: NewValue(8080)}
+		new, _ := Override(old, map[string]string{"Port": "9090"})
+		want := []Change{{Field: "Port", Old: 8080, New: 9090}}
+		if got := Diff(old, new); !reflect.DeepEqual(got, want) {
+			t.Errorf("Diff() = %v, want %v", got, want)
+		}
+		args, err := Args(new)
+		if err != nil {
+			t.Fatalf("Args() error = %v", err)
+		}
+		if wantArgs := []string{"-port=9090"}; !reflect.DeepEqual(args, wantArgs) {
+			t.Errorf("Args() = %v, want %v", args, wantArgs)
+		}
+	})
+}
@@ -0,0 +1,148 @@
+package qcl
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// A Warning is a problem with a config that doesn't stop it from loading, but that an operator should act on, like a
+// deprecated key being set, or a key that matches no field being ignored.
+type Warning struct {
+	Source  string // Source is the source the warning is about, e.g. "env" or "file:config.ini".
+	Key     string // Key is the key in the source, e.g. "MYAPP_DB_HOST".
+	Field   string // Field is the dotted path of the field the key sets, e.g. "DB.Host", if any.
+	Message string // Message describes the problem.
+}
+
+func (w Warning) String() string {
+	key := w.Key
+	if w.Source != "" {
+		key += " from " + w.Source
+	}
+	return fmt.Sprintf("%s: %s", key, w.Message)
+}
+
+// WithWarnings records the warnings of the load in the slice w points to, which is reset first. Warnings never make
+// Load fail, so they're returned separately from its error:
+//
+//   - keys that don't match a field, which WithStrict would make errors, are ignored with a warning. For environment
+//     variables, only those starting with the prefix set with WithEnvPrefix are checked,
+//   - and fields tagged `deprecated:"message"` warn with the message when a source sets them.
+//
+// For example:
+//
+//	type Config struct {
+//		Host     string
+//		Hostname string `deprecated:"use Host instead"`
+//	}
+//
+//	var warnings []qcl.Warning
+//	conf, err := qcl.Load(&defaultConfig, qcl.UseFile("config.ini"), qcl.UseEnv(), qcl.WithWarnings(&warnings))
+//	for _, w := range warnings {
+//		log.Printf("config warning: %s", w) // e.g. HOSTNAME from env: Hostname is deprecated: use Host instead
+//	}
+func WithWarnings(w *[]Warning) LoadOption {
+	return func(o *LoadConfig) {
+		o.warnings = w
+	}
+}
+
+// warn records a warning, if warnings are being recorded, and logs it to the logger set with WithLogger, if any.
+func (c *LoadConfig) warn(w Warning) {
+	c.logDebug("qcl: warning", "source", w.Source, "key", w.Key, "field", w.Field, "message", w.Message)
+	if c == nil || c.warnings == nil {
+		return
+	}
+	c.keysMu.Lock()
+	defer c.keysMu.Unlock()
+	*c.warnings = append(*c.warnings, w)
+}
+
+// reportsUnknownKeys reports whether sources should report the keys that don't match a field, either as errors in
+// strict mode, or as warnings, which are also logged with WithLogger.
+func (c *LoadConfig) reportsUnknownKeys() bool {
+	return c != nil && (c.strict || c.warnings != nil || c.debug != nil)
+}
+
+// ignoreUnknownKeys returns err without its UnknownKeyErrors, which are recorded as warnings for the source instead,
+// unless WithStrict is in use.
+func (c *LoadConfig) ignoreUnknownKeys(source string, err error) error {
+	if c.isStrict() || err == nil {
+		return err
+	}
+	errs := []error{err}
+	if multi, ok := err.(*MultiError); ok {
+		errs = multi.Errors
+	}
+	var kept []error
+	for _, err := range errs {
+		if unknown, ok := err.(*UnknownKeyError); ok {
+			c.warn(Warning{Source: source, Key: unknown.Key, Message: "unknown key ignored"})
+			continue
+		}
+		kept = append(kept, err)
+	}
+	return joinErrors(kept)
+}
+
+// warnDeprecated records a warning for each of the fields the source set that is deprecated, or is nested in a
+// deprecated struct.
+func (c *LoadConfig) warnDeprecated(source string, deprecated map[string]string) {
+	if len(deprecated) == 0 {
+		return
+	}
+	keys := c.sourceKeys(source)
+	for _, path := range sortedKeys(keys) {
+		for deprecatedPath, message := range deprecated {
+			if path == deprecatedPath || strings.HasPrefix(path, deprecatedPath+".") {
+				w := Warning{Source: source, Key: keys[path], Field: path, Message: deprecatedPath + " is deprecated"}
+				if message != "" {
+					w.Message += ": " + message
+				}
+				c.warn(w)
+				break
+			}
+		}
+	}
+}
+
+// deprecatedFields returns the messages of the fields of the config tagged `deprecated:"message"`, by dotted path.
+func deprecatedFields(config any) map[string]string {
+	typ := reflect.TypeOf(config)
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	deprecated := make(map[string]string)
+	collectDeprecated(typ, nil, deprecated, map[reflect.Type]bool{})
+	return deprecated
+}
+
+// collectDeprecated adds the deprecated fields of the struct type to deprecated, including those of nested structs.
+// Types already being walked are skipped, so recursive types end.
+func collectDeprecated(typ reflect.Type, path []string, deprecated map[string]string, walking map[reflect.Type]bool) {
+	if typ.Kind() != reflect.Struct || walking[typ] {
+		return
+	}
+	walking[typ] = true
+	defer delete(walking, typ)
+
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		if !sf.IsExported() || skipField(sf) {
+			continue
+		}
+		fieldPath := append(append(make([]string, 0, len(path)+1), path...), sf.Name)
+		if message, ok := sf.Tag.Lookup("deprecated"); ok {
+			deprecated[strings.Join(fieldPath, ".")] = message
+			continue
+		}
+		elem := indirectType(sf.Type)
+		if elem.Kind() == reflect.Struct && !isLeafStruct(elem) {
+			if sf.Anonymous {
+				fieldPath = path
+			}
+			collectDeprecated(elem, fieldPath, deprecated, walking)
+		}
+	}
+}
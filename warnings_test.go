@@ -0,0 +1,96 @@
+package qcl
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+type TestWarningsConfig struct {
+	Host     string
+	Hostname string `deprecated:"use Host instead"`
+	DB       struct {
+		Host string
+		Port int
+	}
+	Legacy struct {
+		Timeout string
+	} `deprecated:""`
+}
+
+func Test_WithWarnings(t *testing.T) {
+	mounted := t.TempDir()
+	if err := os.WriteFile(filepath.Join(mounted, "db-port"), []byte("5432"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(mounted, "extra"), []byte("x"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	file := writeFile(t, "config.ini", []byte("host = app\nretries = 3\n[db]\nhost = db\nssl = true\n"))
+	tests := map[string]struct {
+		opts []LoadOption
+		env  map[string]string
+		args []string
+		want []Warning
+	}{
+		"unknown env": {
+			opts: []LoadOption{UseEnv(WithEnvPrefix("TEST"))},
+			env:  map[string]string{"TEST_HOST": "app", "TEST_HOST_NAME": "app"},
+			want: []Warning{{Source: "env", Key: "TEST_HOST_NAME", Message: "unknown key ignored"}},
+		},
+		"unknown file keys": {
+			opts: []LoadOption{UseFile(file)},
+			want: []Warning{
+				{Source: "file:" + file, Key: "db.ssl", Message: "unknown key ignored"},
+				{Source: "file:" + file, Key: "retries", Message: "unknown key ignored"},
+			},
+		},
+		"unknown mounted file": {
+			opts: []LoadOption{UseMountedDir(mounted)},
+			want: []Warning{{Source: "mounted:" + mounted, Key: "extra", Message: "unknown key ignored"}},
+		},
+		"deprecated": {
+			opts: []LoadOption{UseEnv(WithEnvPrefix("TEST")), UseFlags()},
+			env:  map[string]string{"TEST_DB_HOST": "db"},
+			args: []string{"-hostname", "old", "-legacy.timeout", "1s"},
+			want: []Warning{
+				{Source: "flags", Key: "-hostname", Field: "Hostname", Message: "Hostname is deprecated: use Host instead"},
+				{Source: "flags", Key: "-legacy.timeout", Field: "Legacy.Timeout", Message: "Legacy is deprecated"},
+			},
+		},
+		"none": {
+			opts: []LoadOption{UseEnv(WithEnvPrefix("TEST"))},
+			env:  map[string]string{"TEST_HOST": "app"},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			for key, value := range test.env {
+				t.Setenv(key, value)
+			}
+			useArgs(test.args...)
+			got := []Warning{{Message: "left over"}}
+			if _, err := Load(&TestWarningsConfig{}, append(test.opts, WithWarnings(&got))...); err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Load() warnings = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func Test_WithWarnings_strict(t *testing.T) {
+	t.Setenv("TEST_HOST_NAME", "app")
+	var warnings []Warning
+	_, err := Load(&TestWarningsConfig{}, UseEnv(WithEnvPrefix("TEST")), WithStrict(), WithWarnings(&warnings))
+	var unknown *UnknownKeyError
+	if !errors.As(err, &unknown) || unknown.Key != "TEST_HOST_NAME" {
+		t.Errorf("Load() error = %v, want an UnknownKeyError for TEST_HOST_NAME", err)
+	}
+	if len(warnings) != 0 {
+		t.Errorf("Load() warnings = %v, want none", warnings)
+	}
+}
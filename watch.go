@@ -0,0 +1,156 @@
+package qcl
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// A Watcher starts watching a source for changes, and returns once it has, so that no change made after it returns is
+// missed. From then on, until ctx is done, it calls changed, typically from a goroutine of its own, whenever the
+// source's configuration may have changed. Calls may be spurious, since Watch reloads the config and only reports it
+// if it differs, and calls made while a reload is pending are coalesced.
+type Watcher func(ctx context.Context, changed func())
+
+// WithWatcher adds a watcher that makes Watch reload the config whenever it signals a change. Sources that support
+// watching add their own watchers; this is for sources that don't, or for other triggers, like a SIGHUP handler.
+//
+// Example:
+//
+//	sighup := func(ctx context.Context, changed func()) {
+//		signals := make(chan os.Signal, 1)
+//		signal.Notify(signals, syscall.SIGHUP)
+//		go func() {
+//			defer signal.Stop(signals)
+//			for {
+//				select {
+//				case <-signals:
+//					changed()
+//				case <-ctx.Done():
+//					return
+//				}
+//			}
+//		}()
+//	}
+//
+//	qcl.Watch(&defaultConfig, onChange, qcl.UseFile("config.ini"), qcl.UseEnv(), qcl.WithWatcher(sighup))
+func WithWatcher(watcher Watcher) LoadOption {
+	return func(o *LoadConfig) {
+		o.watchers = append(o.watchers, watcher)
+	}
+}
+
+// WithRefreshInterval makes Watch reload the config periodically, for sources that can't signal changes, like remote
+// config services and secret stores used through UseCustom, UseProvider or UseBatchFetcher. Each wait is randomized by
+// up to a tenth of the interval either way, so that many instances of a service don't all hit a backend at once. Since
+// Watch only calls its onChange function when the reloaded config differs, refreshes that change nothing go unnoticed.
+//
+// Example:
+//
+//	qcl.Watch(&defaultConfig, onChange, qcl.UseProvider("consul", provider), qcl.WithRefreshInterval(time.Minute))
+//
+// It has no effect on Load.
+func WithRefreshInterval(interval time.Duration) LoadOption {
+	return WithWatcher(func(ctx context.Context, changed func()) {
+		go func() {
+			timer := time.NewTimer(jitter(interval))
+			defer timer.Stop()
+			for {
+				select {
+				case <-timer.C:
+					changed()
+					timer.Reset(jitter(interval))
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	})
+}
+
+// jitter returns the interval randomized by up to a tenth either way.
+func jitter(interval time.Duration) time.Duration {
+	spread := int64(interval / 10)
+	if spread <= 0 {
+		return interval
+	}
+	return interval - time.Duration(spread) + time.Duration(rand.Int63n(2*spread+1))
+}
+
+// Watch loads the config like Load, then keeps watching the sources that support it, and reloads the config from
+// scratch, starting from the defaults, whenever one of them changes. If the reloaded config differs from the current
+// one, onChange is called with both. Long-running services can use it to pick up configuration changes without
+// restarting.
+//
+// Example:
+//
+//	var current atomic.Pointer[Config]
+//	conf, stop, err := qcl.Watch(&defaultConfig, func(old, new *Config) {
+//		current.Store(new)
+//	}, qcl.UseFile("config.ini"), qcl.UseEnv())
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer stop()
+//	current.Store(conf)
+//
+// Reloads happen one at a time, and onChange is called from a single goroutine, each time with a freshly loaded
+// config, never one that is modified afterwards. A reload that fails is reported as a Diagnostic, see WithDiagnostics,
+// and the current config is kept. Watch returns the initial config, a function that stops watching, and the error
+// from the initial load, if any. Like Load, it returns a nil config when the initial load fails, unless
+// WithPartialResult is used.
+func Watch[T any](defaultConfig *T, onChange func(old, new *T), opts ...LoadOption) (*T, func(), error) {
+	if defaultConfig == nil {
+		defaultConfig = new(T)
+	}
+	defaults := Clone(defaultConfig)
+	config := new(LoadConfig)
+	config.Loaders = make(map[string]Loader, len(opts))
+	for _, opt := range opts {
+		opt(config)
+	}
+	if len(config.Sources) == 0 {
+		for _, opt := range DefaultLoadOptions {
+			opt(config)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	changed := make(chan struct{}, 1)
+	notify := func() {
+		select {
+		case changed <- struct{}{}:
+		default: // a reload is already pending
+		}
+	}
+	// watch before the initial load, so changes made while it runs aren't missed
+	for _, watcher := range config.watchers {
+		watcher(ctx, notify)
+	}
+	current, err := Load(Clone(defaults), opts...)
+	if current == nil {
+		cancel()
+		return nil, nil, err
+	}
+
+	go func(current *T) {
+		for {
+			select {
+			case <-changed:
+			case <-ctx.Done():
+				return
+			}
+			next, err := Load(Clone(defaults), opts...)
+			if err != nil {
+				config.report(Diagnostic{Message: "reloading the config: " + err.Error()})
+			}
+			if next == nil || ctx.Err() != nil || len(Diff(current, next)) == 0 {
+				continue
+			}
+			old := current
+			current = next
+			onChange(old, next)
+		}
+	}(current)
+	return current, cancel, err
+}
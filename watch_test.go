@@ -0,0 +1,171 @@
+package qcl
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testWatcher returns a Watcher that signals a change whenever a value is sent on the returned channel.
+func testWatcher() (Watcher, chan<- struct{}) {
+	trigger := make(chan struct{})
+	return func(ctx context.Context, changed func()) {
+		go func() {
+			for {
+				select {
+				case <-trigger:
+					changed()
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}, trigger
+}
+
+func Test_Watch(t *testing.T) {
+	var mu sync.Mutex
+	host, fail := "first", false
+	source := UseCustom("test", func(config any) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if fail {
+			return errors.New("unavailable")
+		}
+		config.(*TestConfig).Host = host
+		return nil
+	})
+	set := func(h string, f bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		host, fail = h, f
+	}
+
+	watcher, trigger := testWatcher()
+	type change struct{ old, new *TestConfig }
+	changes := make(chan change, 10)
+	diagnostics := make(chan Diagnostic, 10)
+	defaults := &TestConfig{Port: 8080}
+	conf, stop, err := Watch(defaults, func(old, new *TestConfig) {
+		changes <- change{old, new}
+	}, source, WithWatcher(watcher), WithDiagnostics(func(d Diagnostic) { diagnostics <- d }))
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+	defer stop()
+	if conf.Host != "first" || conf.Port != 8080 {
+		t.Fatalf("Watch() = %+v, want Host first and Port 8080", conf)
+	}
+	if defaults.Host != "" {
+		t.Errorf("Watch() modified the defaults: %+v", defaults)
+	}
+
+	expectChange := func(oldHost, newHost string) {
+		t.Helper()
+		select {
+		case c := <-changes:
+			if c.old.Host != oldHost || c.new.Host != newHost || c.new.Port != 8080 {
+				t.Errorf("onChange(%+v, %+v), want Host %s then %s", c.old, c.new, oldHost, newHost)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("onChange wasn't called for %s", newHost)
+		}
+	}
+
+	set("second", false)
+	trigger <- struct{}{}
+	expectChange("first", "second")
+
+	// an unchanged config isn't reported
+	trigger <- struct{}{}
+	set("third", true)
+	trigger <- struct{}{}
+	select {
+	case d := <-diagnostics:
+		if d.Message == "" {
+			t.Error("failed reload reported an empty diagnostic")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("failed reload wasn't reported")
+	}
+
+	set("third", false)
+	trigger <- struct{}{}
+	expectChange("second", "third")
+	select {
+	case c := <-changes:
+		t.Errorf("unexpected onChange(%+v, %+v)", c.old, c.new)
+	default:
+	}
+
+	stop()
+	set("fourth", false)
+	select {
+	case trigger <- struct{}{}:
+		t.Error("watcher still running after stop")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func Test_Watch_initialError(t *testing.T) {
+	source := UseCustom("test", func(any) error { return errors.New("unavailable") })
+	conf, stop, err := Watch(&TestConfig{}, func(old, new *TestConfig) {}, source)
+	if err == nil || conf != nil || stop != nil {
+		t.Errorf("Watch() = %v, %v, %v, want an error only", conf, stop != nil, err)
+	}
+}
+
+func Test_WithRefreshInterval(t *testing.T) {
+	var mu sync.Mutex
+	loads := 0
+	source := UseCustom("test", func(config any) error {
+		mu.Lock()
+		defer mu.Unlock()
+		loads++
+		if loads >= 3 {
+			config.(*TestConfig).Host = "refreshed"
+		}
+		return nil
+	})
+	changes := make(chan string, 10)
+	_, stop, err := Watch(&TestConfig{Host: "initial"}, func(old, new *TestConfig) {
+		changes <- new.Host
+	}, source, WithRefreshInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+	defer stop()
+
+	select {
+	case host := <-changes:
+		if host != "refreshed" {
+			t.Errorf("onChange Host = %q, want %q", host, "refreshed")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("onChange wasn't called after a refresh changed the config")
+	}
+	// later refreshes load the same config, so they're not reported
+	select {
+	case host := <-changes:
+		t.Errorf("unexpected onChange with Host %q", host)
+	case <-time.After(50 * time.Millisecond):
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if loads < 4 {
+		t.Errorf("loaded %d times, want periodic refreshes", loads)
+	}
+}
+
+func Test_jitter(t *testing.T) {
+	for _, interval := range []time.Duration{0, 5, time.Second, time.Minute} {
+		for i := 0; i < 100; i++ {
+			got := jitter(interval)
+			if got < interval-interval/10 || got > interval+interval/10 {
+				t.Fatalf("jitter(%v) = %v, want within a tenth", interval, got)
+			}
+		}
+	}
+}